@@ -18,6 +18,7 @@ export interface BalanceNode {
   account?: string;
   depth: number;
   balance: Record<string, string>;
+  unconverted?: Record<string, string>;
   children?: BalanceNode[];
 }
 
@@ -26,4 +27,6 @@ export interface BalancesResponse {
   currencies: string[];
   startDate?: string;
   endDate?: string;
+  currency?: string;
+  valuation?: "cost" | "market" | "historical";
 }
@@ -45,9 +45,9 @@ func (i *Include) Position() Position { return i.pos }
 func (i *Include) SetPosition(pos Position) { i.pos = pos }
 
 // Plugin loads a processing plugin that can transform or validate the ledger data.
-// Plugins are Python modules that run after parsing and can add new directives, check
-// for errors, or modify existing entries. An optional configuration string can be passed
-// to customize plugin behavior.
+// Plugins run after parsing and can add new directives, check for errors, or modify
+// existing entries; the plugin package executes those registered under Name. An
+// optional configuration string can be passed to customize plugin behavior.
 //
 // Example:
 //
@@ -30,6 +30,7 @@ type Transaction struct {
 
 	Postings  []*Posting
 	BodyItems []TransactionBodyItem
+	Generated bool // True if the ledger generated the transaction (not parsed)
 }
 
 var _ Directive = &Transaction{}
@@ -0,0 +1,171 @@
+// Package budget reads the budgets declared in a ledger and compares them
+// with what was actually spent, like Fava's budgets.
+//
+// A budget is a custom directive naming an account, a period, and the amount
+// the account may take in each such period:
+//
+//	2024-01-01 custom "budget" Expenses:Food "monthly" 400.00 EUR
+//	2024-01-01 custom "budget" Expenses:Coffee "daily" 4.00 EUR
+//
+// A budget holds from its date until a later one for the same account and
+// currency replaces it. Over an interval that does not line up with the
+// budget's period, the budget is prorated by day: a monthly budget of 400 EUR
+// allows 100 EUR over a quarter of a 28-day February.
+//
+// Example usage:
+//
+//	budgets, errs := budget.FromAST(tree)
+//	lines := budget.Compare(l, budgets, start, end, budget.Monthly)
+//	for _, line := range lines {
+//	    fmt.Println(line.Account, line.Budget, line.Actual, line.Remaining())
+//	}
+package budget
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/shopspring/decimal"
+)
+
+// directiveType is the custom directive type that declares a budget.
+const directiveType = "budget"
+
+// Period is the length of time a budget amount applies to, and the
+// intervals a comparison is split into.
+type Period string
+
+const (
+	Daily     Period = "daily"
+	Weekly    Period = "weekly"
+	Monthly   Period = "monthly"
+	Quarterly Period = "quarterly"
+	Yearly    Period = "yearly"
+)
+
+// ParsePeriod returns the period with the given name.
+func ParsePeriod(name string) (Period, error) {
+	switch period := Period(name); period {
+	case Daily, Weekly, Monthly, Quarterly, Yearly:
+		return period, nil
+	}
+	return "", fmt.Errorf("unknown period %q (expected daily, weekly, monthly, quarterly or yearly)", name)
+}
+
+// Start returns the first day of the period containing date. Weeks start
+// on Monday.
+func (p Period) Start(date time.Time) time.Time {
+	year, month, day := date.Date()
+	switch p {
+	case Weekly:
+		offset := (int(date.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, time.UTC)
+	case Monthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case Quarterly:
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case Yearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the first day of the period after the one starting at start.
+func (p Period) Next(start time.Time) time.Time {
+	switch p {
+	case Weekly:
+		return start.AddDate(0, 0, 7)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	case Quarterly:
+		return start.AddDate(0, 3, 0)
+	case Yearly:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Budget is the amount an account may take per period, from Date on.
+type Budget struct {
+	Date      *ast.Date
+	Account   ast.Account
+	Period    Period
+	Amount    decimal.Decimal
+	Currency  string
+	Directive *ast.Custom
+}
+
+// Error reports a budget directive that cannot be read.
+type Error struct {
+	Directive *ast.Custom
+	Message   string
+}
+
+func (e *Error) Error() string {
+	pos := e.Directive.Position()
+	if pos.Filename != "" {
+		return fmt.Sprintf("%s:%d: Invalid budget: %s", pos.Filename, pos.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: Invalid budget: %s", e.Directive.Date(), e.Message)
+}
+
+// GetPosition implements the positioned-error interface used by the CLI
+// error renderer.
+func (e *Error) GetPosition() ast.Position { return e.Directive.Position() }
+
+// GetDirective returns the invalid directive.
+func (e *Error) GetDirective() ast.Directive { return e.Directive }
+
+// FromAST returns the budgets declared by the custom "budget" directives of
+// tree in date order, and an error for each one that cannot be read.
+func FromAST(tree *ast.AST) ([]*Budget, []error) {
+	var budgets []*Budget
+	var errs []error
+	for _, directive := range tree.Directives {
+		custom, ok := directive.(*ast.Custom)
+		if !ok || custom.Type.Value != directiveType {
+			continue
+		}
+		budget, err := parseBudget(custom)
+		if err != nil {
+			errs = append(errs, &Error{Directive: custom, Message: err.Error()})
+			continue
+		}
+		budgets = append(budgets, budget)
+	}
+	slices.SortStableFunc(budgets, func(a, b *Budget) int {
+		return a.Date.Compare(b.Date.Time)
+	})
+	return budgets, errs
+}
+
+// parseBudget reads the account, period, and amount of a budget directive.
+func parseBudget(custom *ast.Custom) (*Budget, error) {
+	values := custom.Values
+	if len(values) != 3 || values[0].String == nil || values[1].String == nil || values[2].Amount == nil {
+		return nil, fmt.Errorf("expected an account, a period and an amount")
+	}
+	var account ast.Account
+	if err := account.Capture([]string{*values[0].String}); err != nil {
+		return nil, err
+	}
+	period, err := ParsePeriod(*values[1].String)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := ledger.ParseAmount(values[2].Amount)
+	if err != nil {
+		return nil, err
+	}
+	return &Budget{
+		Date:      custom.Date(),
+		Account:   account,
+		Period:    period,
+		Amount:    amount,
+		Currency:  values[2].Amount.Currency,
+		Directive: custom,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+const budgetLedger = `
+2024-01-01 open Assets:Checking EUR
+2024-01-01 open Expenses:Food
+2024-01-01 open Expenses:Food:Restaurants
+2024-01-01 open Expenses:Coffee
+
+2024-01-01 custom "budget" Expenses:Food "monthly" 400.00 EUR
+2024-01-01 custom "budget" Expenses:Coffee "daily" 3.00 EUR
+2024-03-01 custom "budget" Expenses:Food "monthly" 450.00 EUR
+
+2024-01-05 * "Market"
+  Expenses:Food  120.00 EUR
+  Assets:Checking
+
+2024-01-20 * "Bistro"
+  Expenses:Food:Restaurants  85.50 EUR
+  Assets:Checking
+
+2024-02-10 * "Market"
+  Expenses:Food  430.00 EUR
+  Assets:Checking
+
+2024-02-10 * "Cafe"
+  Expenses:Coffee  4.50 EUR
+  Assets:Checking
+
+2024-03-03 * "Market"
+  Expenses:Food  200.00 EUR
+  Assets:Checking
+`
+
+// loadBudgets parses and processes source into a ledger and the budgets it
+// declares.
+func loadBudgets(t *testing.T, source string) (*ledger.Ledger, []*Budget) {
+	t.Helper()
+	ctx := context.Background()
+	tree, err := parser.ParseBytes(ctx, []byte(source))
+	assert.NoError(t, err)
+	l := ledger.New()
+	assert.NoError(t, l.Process(ctx, tree))
+	budgets, errs := FromAST(tree)
+	assert.Zero(t, errs)
+	return l, budgets
+}
+
+func date(t *testing.T, value string) *ast.Date {
+	t.Helper()
+	d, err := ast.NewDate(value)
+	assert.NoError(t, err)
+	return d
+}
+
+// lineRow summarises a line for comparison.
+func lineRow(line *Line) []string {
+	return []string{
+		line.Start.String(), line.End.String(), string(line.Account), line.Currency,
+		line.Budget.String(), line.Actual.String(), line.Remaining().String(),
+	}
+}
+
+func TestCompareMonthly(t *testing.T) {
+	l, budgets := loadBudgets(t, budgetLedger)
+	assert.Equal(t, 3, len(budgets))
+
+	var rows [][]string
+	for _, line := range Compare(l, budgets, date(t, "2024-01-01"), date(t, "2024-03-15"), Monthly) {
+		rows = append(rows, lineRow(line))
+	}
+	assert.Equal(t, [][]string{
+		{"2024-01-01", "2024-01-31", "Expenses:Coffee", "EUR", "93", "0", "93"},
+		{"2024-01-01", "2024-01-31", "Expenses:Food", "EUR", "400", "205.5", "194.5"},
+		{"2024-02-01", "2024-02-29", "Expenses:Coffee", "EUR", "87", "4.5", "82.5"},
+		{"2024-02-01", "2024-02-29", "Expenses:Food", "EUR", "400", "430", "-30"},
+		{"2024-03-01", "2024-03-15", "Expenses:Coffee", "EUR", "45", "0", "45"},
+		// Half of March: 450 * 15 / 31.
+		{"2024-03-01", "2024-03-15", "Expenses:Food", "EUR", "217.74", "200", "17.74"},
+	}, rows)
+}
+
+func TestCompareYearlyProratesReplacedBudgets(t *testing.T) {
+	l, budgets := loadBudgets(t, budgetLedger)
+
+	lines := Compare(l, budgets, date(t, "2024-01-01"), date(t, "2024-12-31"), Yearly)
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "Expenses:Food", string(lines[1].Account))
+	// Two months at 400 and ten at 450.
+	assert.Equal(t, "5300", lines[1].Budget.String())
+	assert.Equal(t, "835.5", lines[1].Actual.String())
+	assert.Equal(t, "15.76", lines[1].Percent().StringFixed(2))
+}
+
+func TestCompareDaily(t *testing.T) {
+	l, budgets := loadBudgets(t, budgetLedger)
+
+	var rows [][]string
+	for _, line := range Compare(l, budgets, date(t, "2024-02-09"), date(t, "2024-02-10"), Daily) {
+		if line.Account == "Expenses:Coffee" {
+			rows = append(rows, lineRow(line))
+		}
+	}
+	assert.Equal(t, [][]string{
+		{"2024-02-09", "2024-02-09", "Expenses:Coffee", "EUR", "3", "0", "3"},
+		{"2024-02-10", "2024-02-10", "Expenses:Coffee", "EUR", "3", "4.5", "-1.5"},
+	}, rows)
+}
+
+func TestCompareBeforeFirstBudget(t *testing.T) {
+	l, budgets := loadBudgets(t, budgetLedger)
+
+	lines := Compare(l, budgets, date(t, "2023-11-01"), date(t, "2024-01-15"), Monthly)
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "2024-01-01", lines[0].Start.String())
+	assert.Equal(t, "2024-01-15", lines[0].End.String())
+	// Fifteen days of a 31-day month.
+	assert.Equal(t, "193.55", lines[1].Budget.String())
+}
+
+func TestPeriodStart(t *testing.T) {
+	day := time.Date(2024, time.August, 15, 0, 0, 0, 0, time.UTC) // A Thursday
+	for _, tt := range []struct {
+		period Period
+		start  string
+	}{
+		{Daily, "2024-08-15"},
+		{Weekly, "2024-08-12"},
+		{Monthly, "2024-08-01"},
+		{Quarterly, "2024-07-01"},
+		{Yearly, "2024-01-01"},
+	} {
+		assert.Equal(t, tt.start, tt.period.Start(day).Format(time.DateOnly), string(tt.period))
+	}
+}
+
+func TestFromASTErrors(t *testing.T) {
+	source := `
+2024-01-01 custom "budget" Expenses:Food "fortnightly" 400.00 EUR
+2024-01-01 custom "budget" Expenses:Food "monthly"
+2024-01-01 custom "budget" "food" "monthly" 400.00 EUR
+2024-01-01 custom "fava-option" "language" "en"
+`
+	tree, err := parser.ParseBytesWithFilename(context.Background(), "budget.beancount", []byte(source))
+	assert.NoError(t, err)
+
+	budgets, errs := FromAST(tree)
+	assert.Zero(t, budgets)
+	assert.EqualError(t, errors.Join(errs...), `budget.beancount:2: Invalid budget: unknown period "fortnightly" (expected daily, weekly, monthly, quarterly or yearly)
+budget.beancount:3: Invalid budget: expected an account, a period and an amount
+budget.beancount:4: Invalid budget: account must have at least two segments: food`)
+}
@@ -0,0 +1,158 @@
+package budget
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/shopspring/decimal"
+)
+
+// Line compares the budget of an account in one currency with what the
+// account and its subaccounts actually took over an interval.
+type Line struct {
+	Account  ast.Account
+	Currency string
+	Start    *ast.Date
+	End      *ast.Date // Inclusive
+	Budget   decimal.Decimal
+	Actual   decimal.Decimal
+}
+
+// Remaining returns how much of the budget is left, negative when the
+// account went over it.
+func (l *Line) Remaining() decimal.Decimal {
+	return l.Budget.Sub(l.Actual)
+}
+
+// Percent returns the actual amount as a percentage of the budget, or zero
+// when the budget is zero.
+func (l *Line) Percent() decimal.Decimal {
+	if l.Budget.IsZero() {
+		return decimal.Zero
+	}
+	return l.Actual.Div(l.Budget).Mul(decimal.NewFromInt(100))
+}
+
+// Compare splits the days from start to end inclusive into intervals
+// aligned to interval, the first and last clipped to the range, and returns
+// a line for each interval and each account and currency with a budget in
+// effect during it, sorted by interval, account, and currency. Budgets
+// prorated over part of their period are rounded to two places, or the
+// places of their amounts when those have more.
+func Compare(l *ledger.Ledger, budgets []*Budget, start, end *ast.Date, interval Period) []*Line {
+	type key struct {
+		account  ast.Account
+		currency string
+	}
+	groups := make(map[key][]*Budget)
+	for _, budget := range budgets {
+		k := key{budget.Account, budget.Currency}
+		groups[k] = append(groups[k], budget)
+	}
+	keys := make([]key, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b key) int {
+		return cmp.Or(cmp.Compare(a.account, b.account), cmp.Compare(a.currency, b.currency))
+	})
+
+	// The accounts whose postings count against each budgeted account.
+	accounts := make(map[ast.Account][]*ledger.Account)
+	for name, account := range l.Accounts() {
+		for _, k := range keys {
+			if name == string(k.account) || strings.HasPrefix(name, string(k.account)+":") {
+				accounts[k.account] = append(accounts[k.account], account)
+			}
+		}
+	}
+
+	var lines []*Line
+	for from := start.Time; !from.After(end.Time); {
+		next := interval.Next(interval.Start(from))
+		to := next.AddDate(0, 0, -1)
+		if to.After(end.Time) {
+			to = end.Time
+		}
+		for _, k := range keys {
+			budgeted, ok := prorate(groups[k], from, to)
+			if !ok {
+				continue
+			}
+			line := &Line{
+				Account:  k.account,
+				Currency: k.currency,
+				Start:    ast.NewDateFromTime(from),
+				End:      ast.NewDateFromTime(to),
+				Budget:   budgeted,
+			}
+			for _, account := range accounts[k.account] {
+				line.Actual = line.Actual.Add(spent(account, k.currency, line.Start, line.End))
+			}
+			lines = append(lines, line)
+		}
+		from = next
+	}
+	return lines
+}
+
+// prorate returns what the budgets, in date order, allow from start to end
+// inclusive. It reports false when none of them is in effect on any of
+// those days.
+func prorate(budgets []*Budget, start, end time.Time) (decimal.Decimal, bool) {
+	total := decimal.Zero
+	places := int32(2)
+	found := false
+	stop := end.AddDate(0, 0, 1)
+	for day := start; day.Before(stop); {
+		// The budget in effect is the latest one dated on or before day.
+		i := -1
+		for i+1 < len(budgets) && !budgets[i+1].Date.After(day) {
+			i++
+		}
+		if i < 0 {
+			if budgets[0].Date.After(end) {
+				break
+			}
+			day = budgets[0].Date.Time
+			continue
+		}
+		budget := budgets[i]
+		found = true
+		places = max(places, -budget.Amount.Exponent())
+
+		periodStart := budget.Period.Start(day)
+		periodEnd := budget.Period.Next(periodStart)
+		chunkEnd := periodEnd
+		if chunkEnd.After(stop) {
+			chunkEnd = stop
+		}
+		if i+1 < len(budgets) && budgets[i+1].Date.Before(chunkEnd) {
+			chunkEnd = budgets[i+1].Date.Time
+		}
+
+		days, periodDays := daysBetween(day, chunkEnd), daysBetween(periodStart, periodEnd)
+		if days == periodDays {
+			total = total.Add(budget.Amount)
+		} else {
+			total = total.Add(budget.Amount.Mul(decimal.NewFromInt(days)).Div(decimal.NewFromInt(periodDays)))
+		}
+		day = chunkEnd
+	}
+	return total.Round(places), found
+}
+
+// daysBetween returns the number of days from a to b.
+func daysBetween(a, b time.Time) int64 {
+	return int64(b.Sub(a) / (24 * time.Hour))
+}
+
+// spent returns the change in an account's balance in currency from start
+// to end inclusive.
+func spent(account *ledger.Account, currency string, start, end *ast.Date) decimal.Decimal {
+	return account.GetChangeInPeriod(*start, *end).Get(currency)
+}
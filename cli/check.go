@@ -12,6 +12,7 @@ import (
 	"github.com/robinvdvleuten/beancount/diagnostic"
 	"github.com/robinvdvleuten/beancount/ledger"
 	"github.com/robinvdvleuten/beancount/loader"
+	"github.com/robinvdvleuten/beancount/parser"
 	"github.com/robinvdvleuten/beancount/telemetry"
 )
 
@@ -55,7 +56,7 @@ func (cmd *CheckCmd) Run(ctx *kong.Context, globals *Globals) error {
 		return fmt.Errorf("failed to read file for error context: %w", err)
 	}
 
-	ldr := loader.New(loader.WithFollowIncludes(), loader.WithDocumentsDiscovery())
+	ldr := loader.New(loader.WithFollowIncludes(), loader.WithDocumentsDiscovery(), loader.WithErrorRecovery())
 	loadResult, err := cmd.File.LoadResult(runCtx, ldr)
 	if err != nil {
 		renderer := NewErrorRenderer(sourceContent)
@@ -71,8 +72,19 @@ func (cmd *CheckCmd) Run(ctx *kong.Context, globals *Globals) error {
 	for _, warning := range diagnostic.Warnings(loadResult.Diagnostics) {
 		printInfof(ctx.Stderr, "%s", warning)
 	}
+	// Syntax errors were recovered from: render each with its source context
+	// and go on validating the entries that did parse.
 	loadErrors := diagnostic.Errors(loadResult.Diagnostics)
+	parseErrors := 0
 	for _, loadErr := range loadErrors {
+		var parseErr *parser.ParseError
+		if stdErrors.As(loadErr, &parseErr) {
+			renderer := NewErrorRenderer(sourceContent)
+			_, _ = fmt.Fprintln(ctx.Stderr, renderer.Render(parseErr))
+			_, _ = fmt.Fprintln(ctx.Stderr)
+			parseErrors++
+			continue
+		}
 		printError(ctx.Stderr, loadErr.Error())
 	}
 	ast := loadResult.AST
@@ -86,7 +98,10 @@ func (cmd *CheckCmd) Run(ctx *kong.Context, globals *Globals) error {
 			_, _ = fmt.Fprintln(ctx.Stderr, formatted)
 
 			_, _ = fmt.Fprintln(ctx.Stderr)
-			total := len(validationErrors.Errors) + len(loadErrors)
+			if parseErrors > 0 {
+				printError(ctx.Stderr, fmt.Sprintf("%d parse error(s) found", parseErrors))
+			}
+			total := len(validationErrors.Errors) + len(loadErrors) - parseErrors
 			printError(ctx.Stderr, fmt.Sprintf("%d validation error(s) found", total))
 
 			reportTelemetry()
@@ -96,6 +111,9 @@ func (cmd *CheckCmd) Run(ctx *kong.Context, globals *Globals) error {
 	}
 
 	if len(loadErrors) > 0 {
+		if parseErrors > 0 {
+			printError(ctx.Stderr, fmt.Sprintf("%d parse error(s) found", parseErrors))
+		}
 		reportTelemetry()
 		return NewCommandError(1)
 	}
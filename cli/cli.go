@@ -160,11 +160,7 @@ func (f *FileOrStdin) LoadResult(ctx context.Context, ldr *loader.Loader) (*load
 	absFilename := f.GetAbsoluteFilename()
 
 	if f.Filename == "<stdin>" {
-		tree, err := ldr.LoadBytes(ctx, absFilename, f.Contents)
-		if err != nil {
-			return nil, err
-		}
-		return &loader.LoadResult{AST: tree, Root: absFilename}, nil
+		return ldr.LoadBytesResult(ctx, absFilename, f.Contents)
 	}
 	return ldr.Load(ctx, absFilename)
 }
@@ -16,6 +16,9 @@ type Commands struct {
 	Check  CheckCmd  `cmd:"" help:"Parse, check and realize a beancount input file."`
 	Doctor DoctorCmd `cmd:"" help:"Doctor utilities for debugging beancount files."`
 	Format FormatCmd `cmd:"" help:"Format a beancount file to align numbers and currencies."`
+	Import ImportCmd `cmd:"" help:"Extract transactions from downloaded statements."`
+	Lsp    LspCmd    `cmd:"" help:"Start a language server speaking LSP over stdio."`
 	Query  QueryCmd  `cmd:"" help:"Run a BQL query against a beancount input file."`
+	Report ReportCmd `cmd:"" help:"Render a balance sheet, income statement, trial balance, journal, holdings, capital gains or budget report."`
 	Web    WebCmd    `cmd:"" help:"Start a web server."`
 }
@@ -213,6 +213,28 @@ func TestStdinIntegration(t *testing.T) {
 		assert.Contains(t, string(output), "parse error")
 	})
 
+	t.Run("CheckStdinReportsEveryParseError", func(t *testing.T) {
+		binaryName := getBinaryName()
+		// Build the binary
+		cmd := exec.Command("go", "build", "-o", binaryName, "../cmd/beancount")
+		assert.NoError(t, cmd.Run())
+		defer cleanupBinary(binaryName)
+
+		// Both broken directives are reported, and the valid remainder is validated
+		checkCmd := exec.Command("./"+binaryName, "check", "-")
+		checkCmd.Stdin = strings.NewReader(`2024-01-01 open Assets:Checking USD
+2024-01-02 opne Assets:Savings
+2024-01-03 open Assets:Other USD USD
+2024-01-04 balance Assets:Checking 10 USD
+`)
+		output, err := checkCmd.CombinedOutput()
+		assert.Error(t, err)
+		assert.Contains(t, string(output), "<stdin>:2:")
+		assert.Contains(t, string(output), "<stdin>:3:")
+		assert.Contains(t, string(output), "2 parse error(s) found")
+		assert.Contains(t, string(output), "1 validation error(s) found")
+	})
+
 	t.Run("CheckStdinWithIncludesError", func(t *testing.T) {
 		binaryName := getBinaryName()
 		// Build the binary
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/kong"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/formatter"
+	"github.com/robinvdvleuten/beancount/importer"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/loader"
+	"github.com/robinvdvleuten/beancount/telemetry"
+)
+
+type ImportCmd struct {
+	Config        string   `short:"c" required:"" type:"existingfile" help:"JSON file configuring the importers."`
+	Ledger        string   `short:"l" type:"existingfile" help:"Ledger whose transactions are not imported again; duplicates are printed commented out."`
+	Categorize    bool     `help:"Add the counter-account of imported transactions, predicted from the ledger's history (requires --ledger)."`
+	MinConfidence float64  `default:"0.8" help:"Confidence a prediction needs to be used, between 0 and 1."`
+	Paths         []string `arg:"" type:"path" help:"Files or directories to import."`
+}
+
+func (cmd *ImportCmd) Run(ctx *kong.Context, globals *Globals) error {
+	runCtx := context.Background()
+
+	var collector telemetry.Collector
+	if globals.Telemetry {
+		collector = telemetry.NewTimingCollector()
+		runCtx = telemetry.WithCollector(runCtx, collector)
+
+		defer func() {
+			_, _ = fmt.Fprintln(ctx.Stderr)
+			collector.Report(ctx.Stderr)
+		}()
+	}
+
+	configFile, err := os.Open(cmd.Config)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	defer func() { _ = configFile.Close() }()
+
+	importers, err := importer.LoadConfig(configFile)
+	if err != nil {
+		printError(ctx.Stderr, err.Error())
+		return NewCommandError(1)
+	}
+
+	results, err := importer.Run(runCtx, importers, cmd.Paths)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Ledger != "" {
+		l, err := loadLedger(runCtx, cmd.Ledger)
+		if err != nil {
+			printError(ctx.Stderr, err.Error())
+			return NewCommandError(1)
+		}
+
+		dedup := importer.NewDeduplicator(l)
+		for _, result := range results {
+			dedup.MarkDuplicates(result.Directives)
+		}
+
+		if cmd.Categorize {
+			predictor := importer.NewPredictor(l)
+			for _, result := range results {
+				for _, directive := range result.Directives {
+					if txn, ok := directive.(*ast.Transaction); ok && !importer.IsDuplicate(txn) {
+						predictor.Fill(txn, cmd.MinConfidence)
+					}
+				}
+			}
+		}
+	} else if cmd.Categorize {
+		printError(ctx.Stderr, "--categorize requires --ledger")
+		return NewCommandError(1)
+	}
+
+	failed, err := writeImportResults(ctx.Stdout, ctx.Stderr, results)
+	if err != nil {
+		return err
+	}
+	if failed {
+		return NewCommandError(1)
+	}
+	return nil
+}
+
+// writeImportResults prints the directives extracted from each file under a
+// comment naming it, and reports the files that failed to extract.
+func writeImportResults(out, errOut io.Writer, results []*importer.Result) (failed bool, err error) {
+	first := true
+	for _, result := range results {
+		if result.Err != nil {
+			printError(errOut, result.Err.Error())
+			failed = true
+			continue
+		}
+		if len(result.Directives) == 0 {
+			continue
+		}
+
+		if !first {
+			_, _ = fmt.Fprintln(out)
+		}
+		first = false
+
+		_, _ = fmt.Fprintf(out, "; Imported from %s (%s)\n\n", result.File.Path, result.Importer.Name())
+		// Align the directives of a file on one currency column.
+		f := formatter.New()
+		if err := f.FormatDirectives(result.Directives, io.Discard); err != nil {
+			return failed, err
+		}
+		for i, directive := range result.Directives {
+			if i > 0 {
+				_, _ = fmt.Fprintln(out)
+			}
+			if err := writeImportedDirective(out, f, directive); err != nil {
+				return failed, err
+			}
+		}
+	}
+	return failed, nil
+}
+
+// writeImportedDirective formats a directive, commenting it out without its
+// marker when it duplicates one in the ledger.
+func writeImportedDirective(out io.Writer, f *formatter.Formatter, directive ast.Directive) error {
+	txn, ok := directive.(*ast.Transaction)
+	if !ok || !importer.IsDuplicate(txn) {
+		return f.FormatDirectives([]ast.Directive{directive}, out)
+	}
+
+	unmarked := *txn
+	unmarked.Metadata = slices.DeleteFunc(slices.Clone(txn.Metadata), func(meta *ast.Metadata) bool {
+		return meta.Key == importer.DuplicateKey
+	})
+	var buf strings.Builder
+	if err := f.FormatDirectives([]ast.Directive{&unmarked}, &buf); err != nil {
+		return err
+	}
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		_, _ = fmt.Fprintf(out, "; %s", line)
+	}
+	_, _ = fmt.Fprintln(out)
+	return nil
+}
+
+// loadLedger loads and processes the ledger at path to check imported
+// transactions against. Validation errors are ignored: the transactions that
+// were recorded are what matters.
+func loadLedger(ctx context.Context, path string) (*ledger.Ledger, error) {
+	result, err := loader.New(loader.WithFollowIncludes()).Load(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	l := ledger.New()
+	if err := l.Process(ctx, result.AST); err != nil {
+		var validationErrors *ledger.ValidationErrors
+		if !stdErrors.As(err, &validationErrors) {
+			return nil, err
+		}
+	}
+	return l, nil
+}
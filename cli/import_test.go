@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/importer"
+)
+
+func TestWriteImportResults(t *testing.T) {
+	csv, err := importer.NewCSV(importer.CSVConfig{
+		Account:  "Assets:Checking",
+		Currency: "USD",
+		Columns:  importer.CSVColumns{Date: "Date", Narration: "Description", Amount: "Amount"},
+	})
+	assert.NoError(t, err)
+
+	f := &importer.File{Path: "checking.csv", Contents: []byte("Date,Description,Amount\n2024-01-03,Grocer,-42.10\n")}
+	directives, err := csv.Extract(context.Background(), f)
+	assert.NoError(t, err)
+
+	results := []*importer.Result{
+		{File: f, Importer: csv, Directives: directives},
+		{File: &importer.File{Path: "broken.csv"}, Importer: csv, Err: errors.New("broken.csv:2: invalid date \"soon\"")},
+	}
+
+	var out, errOut bytes.Buffer
+	failed, err := writeImportResults(&out, &errOut, results)
+	assert.NoError(t, err)
+	assert.True(t, failed)
+	assert.Equal(t, `; Imported from checking.csv (csv Assets:Checking)
+
+2024-01-03 * "Grocer"
+    Assets:Checking  -42.10 USD
+`, out.String())
+	assert.Contains(t, errOut.String(), `broken.csv:2: invalid date "soon"`)
+}
+
+func TestWriteImportResultsCommentsOutDuplicates(t *testing.T) {
+	csv, err := importer.NewCSV(importer.CSVConfig{
+		Account:  "Assets:Checking",
+		Currency: "USD",
+		Columns:  importer.CSVColumns{Date: "Date", Narration: "Description", Amount: "Amount"},
+	})
+	assert.NoError(t, err)
+
+	f := &importer.File{Path: "checking.csv", Contents: []byte("Date,Description,Amount\n2024-01-03,Grocer,-42.10\n2024-01-04,Bakery,-3.20\n")}
+	directives, err := csv.Extract(context.Background(), f)
+	assert.NoError(t, err)
+
+	qctx, _ := newContextFromSource(t, `
+2024-01-01 open Assets:Checking
+2024-01-01 open Expenses:Food
+
+2024-01-03 * "Grocer"
+  Assets:Checking  -42.10 USD
+  Expenses:Food
+`)
+	assert.Equal(t, 1, importer.NewDeduplicator(qctx.Ledger).MarkDuplicates(directives))
+
+	var out, errOut bytes.Buffer
+	failed, err := writeImportResults(&out, &errOut, []*importer.Result{{File: f, Importer: csv, Directives: directives}})
+	assert.NoError(t, err)
+	assert.False(t, failed)
+	assert.Equal(t, `; Imported from checking.csv (csv Assets:Checking)
+
+; 2024-01-03 * "Grocer"
+;     Assets:Checking  -42.10 USD
+
+2024-01-04 * "Bakery"
+    Assets:Checking   -3.20 USD
+`, out.String())
+}
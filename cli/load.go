@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"io"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/budget"
+	"github.com/robinvdvleuten/beancount/config"
+	"github.com/robinvdvleuten/beancount/diagnostic"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/loader"
+	"github.com/robinvdvleuten/beancount/query"
+)
+
+// loadedLedger is an input file loaded and processed for querying.
+type loadedLedger struct {
+	tree             *ast.AST
+	ledger           *ledger.Ledger
+	config           *config.Config
+	validationErrors *ledger.ValidationErrors
+	source           []byte
+	macros           map[string]*query.Macro
+	budgets          []*budget.Budget
+}
+
+// loadInput loads and processes file for the query and report commands.
+// Like bean-query, load and validation problems are reported to stderr but
+// do not prevent querying the loadable portion of the ledger.
+func loadInput(ctx context.Context, stderr io.Writer, file *FileOrStdin) (*loadedLedger, error) {
+	source, err := file.GetSourceContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file for error context: %w", err)
+	}
+
+	ldr := loader.New(loader.WithFollowIncludes(), loader.WithDocumentsDiscovery(), loader.WithErrorRecovery())
+	loadResult, err := file.LoadResult(ctx, ldr)
+	if err != nil {
+		renderer := NewErrorRenderer(source)
+		_, _ = fmt.Fprintln(stderr, renderer.Render(err))
+		return nil, NewCommandError(1)
+	}
+	if loadErrors := diagnostic.Errors(loadResult.Diagnostics); len(loadErrors) > 0 {
+		renderer := NewErrorRenderer(source)
+		_, _ = fmt.Fprintln(stderr, renderer.RenderAll(loadErrors))
+	}
+	tree := loadResult.AST
+
+	var validationErrors *ledger.ValidationErrors
+	l := ledger.New()
+	if err := l.Process(ctx, tree); err != nil {
+		if stdErrors.As(err, &validationErrors) {
+			renderer := NewErrorRenderer(source)
+			_, _ = fmt.Fprintln(stderr, renderer.RenderAll(validationErrors.Errors))
+		} else {
+			return nil, err
+		}
+	}
+
+	cfg, err := config.FromAST(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	macros, macroErrors := query.MacrosFromAST(tree)
+	if len(macroErrors) > 0 {
+		renderer := NewErrorRenderer(source)
+		_, _ = fmt.Fprintln(stderr, renderer.RenderAll(macroErrors))
+	}
+
+	budgets, budgetErrors := budget.FromAST(tree)
+	if len(budgetErrors) > 0 {
+		renderer := NewErrorRenderer(source)
+		_, _ = fmt.Fprintln(stderr, renderer.RenderAll(budgetErrors))
+	}
+
+	return &loadedLedger{
+		tree:             tree,
+		ledger:           l,
+		config:           cfg,
+		validationErrors: validationErrors,
+		source:           source,
+		macros:           macros,
+		budgets:          budgets,
+	}, nil
+}
@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/alecthomas/kong"
+
+	"github.com/robinvdvleuten/beancount/lsp"
+)
+
+type LspCmd struct {
+	File string `help:"Root beancount ledger file (defaults to the journalFile initialization option or the first opened document)." arg:"" optional:""`
+}
+
+func (cmd *LspCmd) Run(ctx *kong.Context, globals *Globals) error {
+	server := lsp.New(cmd.File)
+	return server.Run(context.Background(), os.Stdin, ctx.Stdout)
+}
@@ -1,12 +1,12 @@
 package cli
 
 import (
-	"bufio"
 	"context"
 	stdErrors "errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
 
 	"golang.org/x/term"
@@ -14,17 +14,15 @@ import (
 	"github.com/alecthomas/kong"
 
 	"github.com/robinvdvleuten/beancount/ast"
-	"github.com/robinvdvleuten/beancount/config"
-	"github.com/robinvdvleuten/beancount/ledger"
-	"github.com/robinvdvleuten/beancount/loader"
 	"github.com/robinvdvleuten/beancount/query"
 	"github.com/robinvdvleuten/beancount/query/bql"
 )
 
 type QueryCmd struct {
-	Format    string      `short:"f" default:"text" enum:"text,csv" help:"Output format: text or csv."`
+	Format    string      `short:"f" default:"text" enum:"text,csv,json,jsonl,markdown,html,parquet" help:"Output format: text, csv, json, jsonl, markdown, html or parquet."`
 	Output    string      `short:"o" placeholder:"FILE" help:"Write output to FILE instead of stdout."`
-	Numberify bool        `short:"m" help:"Split amounts into per-currency number columns (csv only)."`
+	Numberify bool        `short:"m" help:"Split amounts into per-currency number columns (csv and parquet only)."`
+	V3        bool        `name:"v3" help:"Accept beanquery v3 syntax: HAVING, per-term ORDER BY directions, subqueries and #tables."`
 	File      FileOrStdin `help:"Beancount input filename (use '-' for stdin)." arg:""`
 	Query     []string    `help:"BQL query to run." arg:"" optional:""`
 }
@@ -37,45 +35,23 @@ func (cmd *QueryCmd) Run(ctx *kong.Context, globals *Globals) error {
 
 	runCtx := context.Background()
 
-	sourceContent, err := cmd.File.GetSourceContent()
-	if err != nil {
-		return fmt.Errorf("failed to read file for error context: %w", err)
-	}
-
-	ldr := loader.New(loader.WithFollowIncludes(), loader.WithDocumentsDiscovery())
-	loadResult, err := cmd.File.LoadResult(runCtx, ldr)
-	if err != nil {
-		renderer := NewErrorRenderer(sourceContent)
-		_, _ = fmt.Fprintln(ctx.Stderr, renderer.Render(err))
-		return NewCommandError(1)
-	}
-	tree := loadResult.AST
-
-	// Like bean-query, validation problems are reported but do not prevent
-	// querying the loadable portion of the ledger.
-	var validationErrors *ledger.ValidationErrors
-	l := ledger.New()
-	if err := l.Process(runCtx, tree); err != nil {
-		if stdErrors.As(err, &validationErrors) {
-			renderer := NewErrorRenderer(sourceContent)
-			_, _ = fmt.Fprintln(ctx.Stderr, renderer.RenderAll(validationErrors.Errors))
-		} else {
-			return err
-		}
-	}
-
-	cfg, err := config.FromAST(tree)
+	loaded, err := loadInput(runCtx, ctx.Stderr, &cmd.File)
 	if err != nil {
 		return err
 	}
+	tree := loaded.tree
 
-	qctx := &query.Context{Ledger: l, Config: cfg}
+	qctx := newQueryContext(loaded)
+	settings := querySettings{format: cmd.Format, numberify: cmd.Numberify, v3: cmd.V3}
 
 	// Without a query argument, a terminal gets the interactive shell and
 	// piped stdin is read as a single query, like bean-query.
 	if queryText == "" {
 		if cmd.File.Filename != "<stdin>" && term.IsTerminal(int(os.Stdin.Fd())) {
-			return runShell(runCtx, qctx, tree, cmd.Format, cmd.Numberify, os.Stdin, ctx.Stdout, validationErrors, sourceContent)
+			if err := cmd.validateShell(); err != nil {
+				return err
+			}
+			return runTerminalShell(runCtx, qctx, tree, settings, ctx.Stdout, loaded.validationErrors, loaded.source)
 		}
 		piped, err := io.ReadAll(os.Stdin)
 		if err != nil {
@@ -94,82 +70,101 @@ func (cmd *QueryCmd) Run(ctx *kong.Context, globals *Globals) error {
 			return fmt.Errorf("failed to create output file %s: %w", cmd.Output, err)
 		}
 		out = file
-		if runErr := runQuery(runCtx, qctx, tree, queryText, cmd.Format, cmd.Numberify, out); runErr != nil {
+		if runErr := runQuery(runCtx, qctx, tree, queryText, settings, out); runErr != nil {
 			_ = file.Close()
 			return runErr
 		}
 		return file.Close()
 	}
 
-	return runQuery(runCtx, qctx, tree, queryText, cmd.Format, cmd.Numberify, out)
+	return runQuery(runCtx, qctx, tree, queryText, settings, out)
+}
+
+// validateShell rejects the flags the interactive shell cannot honour: it
+// writes to the terminal, so neither a file nor binary output.
+func (cmd *QueryCmd) validateShell() error {
+	if cmd.Output != "" {
+		return fmt.Errorf("--output needs a query; the interactive shell writes to the terminal")
+	}
+	if !slices.Contains(queryFormats, cmd.Format) {
+		return fmt.Errorf("--format %s needs a query; the interactive shell writes text to the terminal", cmd.Format)
+	}
+	return nil
 }
 
-// runShell is the interactive query REPL: one query per line, with help,
-// errors, and exit commands.
-func runShell(ctx context.Context, qctx *query.Context, tree *ast.AST, format string, numberify bool, in io.Reader, out io.Writer, validationErrors *ledger.ValidationErrors, sourceContent []byte) error {
-	printShellBanner(out, tree)
-
-	scanner := bufio.NewScanner(in)
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
-	for {
-		_, _ = fmt.Fprint(out, "beancount> ")
-		if !scanner.Scan() {
-			_, _ = fmt.Fprintln(out)
-			return scanner.Err()
+// newQueryContext returns the context queries against a loaded ledger run
+// in, with the functions and budgets the ledger declares.
+func newQueryContext(loaded *loadedLedger) *query.Context {
+	return &query.Context{Ledger: loaded.ledger, Config: loaded.config, Macros: loaded.macros, Budgets: loaded.budgets}
+}
+
+// queryFormats are the output formats the shell can switch to; parquet is
+// binary and only written with --format.
+var queryFormats = []string{"text", "csv", "json", "jsonl", "markdown", "html"}
+
+// querySettings are the options queries run with.
+type querySettings struct {
+	format    string
+	numberify bool
+	v3        bool // accept beanquery v3 syntax
+}
+
+// set changes the setting named by the first word of args to the value
+// after it, or lists the settings when args is empty.
+func (s *querySettings) set(args string, out io.Writer) error {
+	name, value, _ := strings.Cut(args, " ")
+	name, value = strings.ToLower(name), strings.ToLower(strings.TrimSpace(value))
+	switch name {
+	case "":
+		_, _ = fmt.Fprintf(out, "format: %s\n", s.format)
+		_, _ = fmt.Fprintf(out, "numberify: %s\n", onOff(s.numberify))
+		_, err := fmt.Fprintf(out, "v3: %s\n", onOff(s.v3))
+		return err
+	case "format":
+		if !slices.Contains(queryFormats, value) {
+			return printQueryError(out, fmt.Errorf("Invalid format %q: expected one of %s.", value, strings.Join(queryFormats, ", ")))
 		}
-		line := strings.TrimSpace(strings.TrimSuffix(scanner.Text(), ";"))
-		switch strings.ToLower(line) {
-		case "":
-			continue
-		case "exit", "quit":
-			return nil
-		case "help":
-			_, _ = fmt.Fprintln(out, "Enter a BQL query (SELECT, BALANCES, JOURNAL, PRINT).")
-			_, _ = fmt.Fprintln(out, "Commands: errors (show ledger errors), exit or quit (leave the shell).")
-			continue
-		case "errors":
-			if validationErrors == nil || len(validationErrors.Errors) == 0 {
-				_, _ = fmt.Fprintln(out, "(no errors)")
-				continue
-			}
-			renderer := NewErrorRenderer(sourceContent)
-			_, _ = fmt.Fprintln(out, renderer.RenderAll(validationErrors.Errors))
-			continue
+		s.format = value
+	case "numberify", "v3":
+		var enabled bool
+		switch value {
+		case "on", "true", "1":
+			enabled = true
+		case "off", "false", "0":
+		default:
+			return printQueryError(out, fmt.Errorf("Invalid value %q for %s: expected on or off.", value, name))
 		}
-		if err := runQuery(ctx, qctx, tree, line, format, numberify, out); err != nil {
-			return err
+		if name == "numberify" {
+			s.numberify = enabled
+		} else {
+			s.v3 = enabled
 		}
+	default:
+		return printQueryError(out, fmt.Errorf("Unknown setting %q.", name))
 	}
+	return nil
 }
 
-// printShellBanner reports the ledger title and directive counts, following
-// the official shell greeting.
-func printShellBanner(out io.Writer, tree *ast.AST) {
-	title := ""
-	for _, option := range tree.Options {
-		if option.Name.String() == "title" {
-			title = option.Value.String()
-		}
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
 	}
-	if title != "" {
-		_, _ = fmt.Fprintf(out, "Input file: %q\n", title)
-	}
-	transactions, postings := 0, 0
-	for _, entry := range tree.Directives {
-		if txn, ok := entry.(*ast.Transaction); ok {
-			transactions++
-			postings += len(txn.Postings)
-		}
+	return "off"
+}
+
+// parseOptions returns the BQL parser options for the settings.
+func (s querySettings) parseOptions() []bql.Option {
+	if s.v3 {
+		return []bql.Option{bql.WithV3Syntax()}
 	}
-	_, _ = fmt.Fprintf(out, "Ready with %d directives (%d postings in %d transactions).\n",
-		len(tree.Directives), postings, transactions)
+	return nil
 }
 
 // runQuery parses, compiles, executes, and renders one BQL query. Query
 // errors print as "ERROR: ..." on the output stream with a zero exit status,
 // matching the official bean-query tool.
-func runQuery(ctx context.Context, qctx *query.Context, tree *ast.AST, queryText, format string, numberify bool, out io.Writer) error {
-	stmt, err := bql.Parse(queryText)
+func runQuery(ctx context.Context, qctx *query.Context, tree *ast.AST, queryText string, settings querySettings, out io.Writer) error {
+	stmt, err := bql.Parse(queryText, settings.parseOptions()...)
 	if err != nil {
 		return printQueryError(out, err)
 	}
@@ -194,14 +189,78 @@ func runQuery(ctx context.Context, qctx *query.Context, tree *ast.AST, queryText
 		return printQueryError(out, err)
 	}
 
-	switch format {
+	switch settings.format {
 	case "csv":
-		return query.RenderCSV(result, out, numberify)
+		return query.RenderCSV(result, out, settings.numberify)
+	case "json":
+		return query.RenderJSON(result, out)
+	case "jsonl":
+		return query.RenderJSONL(result, out)
+	case "markdown":
+		return query.RenderMarkdown(result, out)
+	case "html":
+		return query.RenderHTML(result, out)
+	case "parquet":
+		return query.RenderParquet(result, out, settings.numberify)
 	default:
 		return query.RenderText(result, out)
 	}
 }
 
+// runExplain prints the compiled plan of a query instead of running it.
+func runExplain(qctx *query.Context, queryText string, settings querySettings, out io.Writer) error {
+	if queryText == "" {
+		return printQueryError(out, stdErrors.New("EXPLAIN requires a query."))
+	}
+	stmt, err := bql.Parse(queryText, settings.parseOptions()...)
+	if err != nil {
+		return printQueryError(out, err)
+	}
+	if _, ok := stmt.(*bql.Print); ok {
+		return printQueryError(out, stdErrors.New("EXPLAIN is not supported for PRINT."))
+	}
+	compiled, err := query.Compile(qctx, stmt)
+	if err != nil {
+		return printQueryError(out, err)
+	}
+	return query.Explain(compiled, out)
+}
+
+// runStoredQuery runs the query directive called name, or lists the query
+// directives of the ledger when name is empty.
+func runStoredQuery(ctx context.Context, qctx *query.Context, tree *ast.AST, name string, settings querySettings, out io.Writer) error {
+	var stored []*ast.Query
+	for _, entry := range tree.Directives {
+		if q, ok := entry.(*ast.Query); ok {
+			stored = append(stored, q)
+		}
+	}
+
+	if name == "" {
+		if len(stored) == 0 {
+			_, err := fmt.Fprintln(out, "(no queries)")
+			return err
+		}
+		width := 0
+		for _, q := range stored {
+			width = max(width, len(q.Name.Value))
+		}
+		for _, q := range stored {
+			_, _ = fmt.Fprintf(out, "%-*s  %s\n", width, q.Name.Value, q.QueryString.Value)
+		}
+		return nil
+	}
+
+	name = strings.Trim(name, `"'`)
+	// A later directive with the same name redefines the query.
+	for i := len(stored) - 1; i >= 0; i-- {
+		if stored[i].Name.Value == name {
+			return runQuery(ctx, qctx, tree, stored[i].QueryString.Value, settings, out)
+		}
+	}
+	return printQueryError(out, fmt.Errorf("Query %q not found.", name))
+}
+
 func printQueryError(out io.Writer, err error) error {
 	message := err.Error()
 	// Positioned parse errors render as plain messages here; the position
@@ -80,7 +80,7 @@ func runOurQuery(t *testing.T, fixture queryFixture, format string, numberify bo
 
 	var out strings.Builder
 	qctx := &query.Context{Ledger: l, Config: cfg}
-	assert.NoError(t, runQuery(ctx, qctx, result.AST, fixture.query, format, numberify, &out))
+	assert.NoError(t, runQuery(ctx, qctx, result.AST, fixture.query, querySettings{format: format, numberify: numberify}, &out))
 	return out.String()
 }
 
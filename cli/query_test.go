@@ -2,37 +2,54 @@ package cli
 
 import (
 	"context"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
 	"github.com/robinvdvleuten/beancount/config"
 	"github.com/robinvdvleuten/beancount/ledger"
-	"github.com/robinvdvleuten/beancount/loader"
+	"github.com/robinvdvleuten/beancount/parser"
 	"github.com/robinvdvleuten/beancount/query"
 )
 
-func TestQueryShell(t *testing.T) {
+// newContextFromSource parses and processes source into a query Context
+// and the processed directive tree.
+func newContextFromSource(t *testing.T, source string) (*query.Context, *ast.AST) {
+	t.Helper()
 	ctx := context.Background()
-	ldr := loader.New(loader.WithFollowIncludes())
-	result, err := ldr.Load(ctx, "../testdata/compliance/query/ledger.beancount")
+	tree, err := parser.ParseBytesWithFilename(ctx, "test.beancount", []byte(source))
 	assert.NoError(t, err)
-
 	l := ledger.New()
-	assert.NoError(t, l.Process(ctx, result.AST))
-	cfg, err := config.FromAST(result.AST)
+	assert.NoError(t, l.Process(ctx, tree))
+	cfg, err := config.FromAST(tree)
+	assert.NoError(t, err)
+	return &query.Context{Ledger: l, Config: cfg}, tree
+}
+
+// newComplianceContext processes the query compliance ledger like
+// newContextFromSource.
+func newComplianceContext(t *testing.T) (*query.Context, *ast.AST) {
+	t.Helper()
+	source, err := os.ReadFile("../testdata/compliance/query/ledger.beancount")
 	assert.NoError(t, err)
-	qctx := &query.Context{Ledger: l, Config: cfg}
+	return newContextFromSource(t, string(source))
+}
+
+func TestQueryShell(t *testing.T) {
+	ctx := context.Background()
+	qctx, tree := newComplianceContext(t)
 
 	in := strings.NewReader("help\nerrors\nselect count(date);\nbogus query\nexit\n")
 	var out strings.Builder
-	assert.NoError(t, runShell(ctx, qctx, result.AST, "text", false, in, &out, nil, nil))
+	assert.NoError(t, runShell(ctx, qctx, tree, querySettings{format: "text"}, in, &out, nil, nil))
 
 	output := out.String()
 	assert.Contains(t, output, `Input file: "Query Compliance Ledger"`)
 	assert.Contains(t, output, "Ready with 26 directives (22 postings in 10 transactions).")
 	assert.Contains(t, output, "beancount> ")
-	assert.Contains(t, output, "Commands: errors")
+	assert.Contains(t, output, "  errors ")
 	assert.Contains(t, output, "(no errors)")
 	assert.Contains(t, output, "22")         // count(date) result
 	assert.Contains(t, output, "ERROR: ")    // bogus query reports, shell continues
@@ -41,16 +58,83 @@ func TestQueryShell(t *testing.T) {
 
 func TestQueryShellEOF(t *testing.T) {
 	ctx := context.Background()
-	ldr := loader.New(loader.WithFollowIncludes())
-	result, err := ldr.Load(ctx, "../testdata/compliance/query/ledger.beancount")
-	assert.NoError(t, err)
+	qctx, tree := newComplianceContext(t)
 
-	l := ledger.New()
-	assert.NoError(t, l.Process(ctx, result.AST))
-	cfg, err := config.FromAST(result.AST)
-	assert.NoError(t, err)
+	var out strings.Builder
+	assert.NoError(t, runShell(ctx, qctx, tree, querySettings{format: "text"}, strings.NewReader(""), &out, nil, nil))
+}
+
+func TestQueryShellCommands(t *testing.T) {
+	ctx := context.Background()
+	qctx, tree := newContextFromSource(t, `
+2014-01-01 open Assets:Cash
+2014-01-01 open Equity:Opening-Balances
+
+2014-01-02 * "Opening"
+  Assets:Cash  100.00 USD
+  Equity:Opening-Balances
+
+2014-01-03 query "cash" "SELECT account, sum(number) AS total WHERE account ~ 'Cash'"
+2014-01-03 query "accounts" "SELECT DISTINCT account"
+`)
+
+	in := strings.NewReader(strings.Join([]string{
+		"run",
+		"run cash",
+		"set format csv",
+		"run \"cash\"",
+		"run missing",
+		"set",
+		"set format xml",
+		"set numberify maybe",
+		"explain SELECT account WHERE number > 10;",
+		"explain PRINT;",
+		"set v3 on",
+		"SELECT account, count(date) AS n GROUP BY account HAVING count(date) > 0 ORDER BY account;",
+	}, "\n"))
+	var out strings.Builder
+	assert.NoError(t, runShell(ctx, qctx, tree, querySettings{format: "text"}, in, &out, nil, nil))
+
+	output := out.String()
+	assert.Contains(t, output, "cash      SELECT account, sum(number) AS total WHERE account ~ 'Cash'\n"+
+		"accounts  SELECT DISTINCT account\n")
+	assert.Contains(t, output, "  account   total \n"+
+		"----------- ------\n"+
+		"Assets:Cash 100.00\n")
+	assert.Contains(t, output, "account,total\r\nAssets:Cash,100.00\r\n")
+	assert.Contains(t, output, `ERROR: Query "missing" not found.`)
+	assert.Contains(t, output, "format: csv\nnumberify: off\nv3: off\n")
+	assert.Contains(t, output, `ERROR: Invalid format "xml": expected one of text, csv, json, jsonl, markdown, html.`)
+	assert.Contains(t, output, `ERROR: Invalid value "maybe" for numberify: expected on or off.`)
+	assert.Contains(t, output, "SELECT\n  environment: targets/column context\n")
+	assert.Contains(t, output, "  where: number > 10\n")
+	assert.Contains(t, output, "ERROR: EXPLAIN is not supported for PRINT.")
+	assert.Contains(t, output, "account,n\r\n"+
+		"Assets:Cash            ,1\r\n")
+}
+
+func TestQueryShellRejectsFileOutput(t *testing.T) {
+	assert.NoError(t, (&QueryCmd{Format: "csv"}).validateShell())
+	assert.EqualError(t, (&QueryCmd{Format: "parquet"}).validateShell(),
+		"--format parquet needs a query; the interactive shell writes text to the terminal")
+	assert.EqualError(t, (&QueryCmd{Format: "text", Output: "out.txt"}).validateShell(),
+		"--output needs a query; the interactive shell writes to the terminal")
+}
+
+func TestRunQueryV3Syntax(t *testing.T) {
+	ctx := context.Background()
+	qctx, tree := newComplianceContext(t)
+
+	queryText := "SELECT account, count(date) AS n GROUP BY account HAVING count(date) > 2 ORDER BY n DESC, account ASC"
 
 	var out strings.Builder
-	assert.NoError(t, runShell(ctx, &query.Context{Ledger: l, Config: cfg}, result.AST,
-		"text", false, strings.NewReader(""), &out, nil, nil))
+	assert.NoError(t, runQuery(ctx, qctx, tree, queryText, querySettings{format: "csv"}, &out))
+	assert.Equal(t, "ERROR: Syntax error: HAVING requires beanquery v3 syntax\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, runQuery(ctx, qctx, tree, queryText, querySettings{format: "csv", v3: true}, &out))
+	assert.Equal(t, "account,n\r\n"+
+		"Assets:Cash         ,4\r\n"+
+		"Assets:Bank:Checking,3\r\n"+
+		"Expenses:Food:Coffee,3\r\n", out.String())
 }
@@ -0,0 +1,727 @@
+package cli
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/shopspring/decimal"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/budget"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/query"
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+// ReportCmd renders one of the standard reports of a ledger.
+type ReportCmd struct {
+	Report    string      `arg:"" enum:"balsheet,income,trial,journal,holdings,gains,budget" help:"Report to render: balsheet, income, trial, journal, holdings, gains or budget."`
+	File      FileOrStdin `arg:"" help:"Beancount input filename (use '-' for stdin)."`
+	Format    string      `short:"f" default:"text" enum:"text,csv,json" help:"Output format: text, csv or json."`
+	Begin     string      `placeholder:"DATE" help:"First day of the reporting period (YYYY-MM-DD)."`
+	End       string      `placeholder:"DATE" help:"Last day of the reporting period, or the date balances are taken at (YYYY-MM-DD)."`
+	ConvertTo string      `name:"convert-to" placeholder:"CURRENCY" help:"Convert amounts to CURRENCY at the ledger's prices."`
+	Valuation string      `placeholder:"METHOD" help:"Value the amounts --convert-to converts at cost, market (default) or historical rates."`
+	Depth     int         `placeholder:"N" help:"Collapse accounts deeper than N components into their parents."`
+	Aggregate bool        `help:"Sum holdings of each commodity across accounts."`
+	Year      int         `placeholder:"YEAR" help:"Report the capital gains realized in tax year YEAR."`
+	Interval  string      `placeholder:"PERIOD" help:"Split the budget report into daily, weekly, monthly (default), quarterly or yearly periods."`
+}
+
+// currencyPattern matches the currencies --convert-to accepts.
+var currencyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9._-]*$`)
+
+func (cmd *ReportCmd) Run(ctx *kong.Context, globals *Globals) error {
+	if err := cmd.File.EnsureContents(); err != nil {
+		return err
+	}
+	begin, err := parseReportDate("begin", cmd.Begin)
+	if err != nil {
+		return err
+	}
+	end, err := parseReportDate("end", cmd.End)
+	if err != nil {
+		return err
+	}
+	if begin != nil && end != nil && begin.After(end.Time) {
+		return fmt.Errorf("--begin %s is after --end %s", begin, end)
+	}
+	if cmd.ConvertTo != "" && !currencyPattern.MatchString(cmd.ConvertTo) {
+		return fmt.Errorf("invalid currency %q for --convert-to", cmd.ConvertTo)
+	}
+	if cmd.Valuation != "" {
+		if cmd.ConvertTo == "" {
+			return fmt.Errorf("--valuation requires --convert-to")
+		}
+		if cmd.Report != "balsheet" && cmd.Report != "income" && cmd.Report != "trial" {
+			return fmt.Errorf("--valuation only applies to the balsheet, income and trial reports")
+		}
+		if _, err := ledger.ParseValuation(cmd.Valuation); err != nil {
+			return fmt.Errorf("invalid --valuation: %w", err)
+		}
+	}
+	if cmd.Depth < 0 {
+		return fmt.Errorf("--depth must not be negative")
+	}
+	if begin != nil && (cmd.Report == "balsheet" || cmd.Report == "holdings") {
+		return fmt.Errorf("the %s report takes balances at --end and has no --begin", cmd.Report)
+	}
+	if cmd.Year != 0 {
+		if cmd.Report != "gains" {
+			return fmt.Errorf("--year only applies to the gains report")
+		}
+		if begin != nil || end != nil {
+			return fmt.Errorf("--year cannot be combined with --begin or --end")
+		}
+		begin = ast.NewDateFromTime(time.Date(cmd.Year, time.January, 1, 0, 0, 0, 0, time.UTC))
+		end = ast.NewDateFromTime(time.Date(cmd.Year, time.December, 31, 0, 0, 0, 0, time.UTC))
+	}
+	if cmd.Interval != "" {
+		if cmd.Report != "budget" {
+			return fmt.Errorf("--interval only applies to the budget report")
+		}
+		if _, err := budget.ParsePeriod(cmd.Interval); err != nil {
+			return fmt.Errorf("invalid --interval: %w", err)
+		}
+	}
+	if cmd.Report == "budget" && cmd.ConvertTo != "" {
+		return fmt.Errorf("the budget report compares amounts in the budgets' currencies and has no --convert-to")
+	}
+
+	runCtx := context.Background()
+	loaded, err := loadInput(runCtx, ctx.Stderr, &cmd.File)
+	if err != nil {
+		return err
+	}
+
+	return cmd.render(runCtx, ctx.Stdout, loaded, begin, end)
+}
+
+// render writes the report over the period from begin to end, either of
+// which may be nil.
+func (cmd *ReportCmd) render(ctx context.Context, out io.Writer, loaded *loadedLedger, begin, end *ast.Date) error {
+	switch cmd.Report {
+	case "balsheet":
+		return cmd.renderTree(out, loaded, []ast.AccountType{ast.AccountTypeAssets, ast.AccountTypeLiabilities, ast.AccountTypeEquity}, begin, end)
+	case "income":
+		return cmd.renderTree(out, loaded, []ast.AccountType{ast.AccountTypeIncome, ast.AccountTypeExpenses}, begin, end)
+	case "trial":
+		return cmd.renderTree(out, loaded, nil, begin, end)
+	case "journal":
+		return cmd.renderQuery(ctx, out, loaded, cmd.journalQuery(begin, end))
+	case "holdings":
+		return cmd.renderHoldings(out, loaded, end)
+	case "budget":
+		return cmd.renderBudget(out, loaded, begin, end)
+	default:
+		return cmd.renderGains(out, loaded, begin, end)
+	}
+}
+
+func parseReportDate(flag, value string) (*ast.Date, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := ast.NewDate(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s date %q (expected YYYY-MM-DD)", flag, value)
+	}
+	return date, nil
+}
+
+// renderTree renders the balance tree of the accounts of types (all types
+// when empty). A period with only --end covers everything up to it, which
+// is the balance on that date; a period with only --begin runs to the last
+// entry of the ledger. A period of a single day holds that day's changes.
+// With --convert-to, the ledger converts the balances as --valuation says,
+// keeping the amounts it has no price for apart.
+func (cmd *ReportCmd) renderTree(out io.Writer, loaded *loadedLedger, types []ast.AccountType, begin, end *ast.Date) error {
+	start, stop := begin, end
+	switch {
+	case begin == nil && end != nil:
+		start = end
+	case begin != nil && end == nil:
+		stop = begin
+		if n := len(loaded.tree.Directives); n > 0 {
+			if last := loaded.tree.Directives[n-1].Date(); last.After(begin.Time) {
+				stop = last
+			}
+		}
+	}
+
+	// With --begin the dates are a period, even a single day, whose changes
+	// the tree holds.
+	var opts []ledger.BalanceTreeOption
+	if begin != nil {
+		opts = append(opts, ledger.WithPeriodChanges())
+	}
+	valuation := ledger.Valuation(cmp.Or(cmd.Valuation, string(ledger.ValuationMarket)))
+	tree, err := cmd.balanceTree(loaded.ledger, types, start, stop, valuation, opts...)
+	if err != nil {
+		return err
+	}
+	tree.Roots = shapeBalanceTree(tree.Roots, cmd.Depth)
+
+	switch cmd.Format {
+	case "json":
+		return renderBalanceTreeJSON(out, cmd.Report, tree)
+	case "csv":
+		return query.RenderCSV(balanceTreeResult(tree, true), out, true)
+	default:
+		return query.RenderText(balanceTreeResult(tree, false), out)
+	}
+}
+
+// balanceTree returns the balance tree of the period from start to stop,
+// converted to --convert-to as valuation says when it is set.
+func (cmd *ReportCmd) balanceTree(l *ledger.Ledger, types []ast.AccountType, start, stop *ast.Date, valuation ledger.Valuation, opts ...ledger.BalanceTreeOption) (*ledger.BalanceTree, error) {
+	if cmd.ConvertTo == "" {
+		return l.GetBalanceTree(types, start, stop, opts...)
+	}
+	return l.GetConvertedBalanceTree(types, start, stop, cmd.ConvertTo, valuation, opts...)
+}
+
+// reportDate is the date amounts are valued at: --end, or today.
+func reportDate(end *ast.Date) *ast.Date {
+	if end != nil {
+		return end
+	}
+	now := time.Now()
+	return ast.NewDateFromTime(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// shapeBalanceTree drops the nodes deeper than depth components, whose
+// balances their parents already include, and the subtrees without any
+// balance.
+func shapeBalanceTree(nodes []*ledger.BalanceNode, depth int) []*ledger.BalanceNode {
+	var kept []*ledger.BalanceNode
+	for _, node := range nodes {
+		if depth > 0 && node.Depth >= depth {
+			continue
+		}
+		node.Children = shapeBalanceTree(node.Children, depth)
+		if node.Balance.IsZero() && (node.Unconverted == nil || node.Unconverted.IsZero()) && len(node.Children) == 0 {
+			continue
+		}
+		kept = append(kept, node)
+	}
+	return kept
+}
+
+// balanceTreeResult flattens a balance tree into a query result so the
+// query renderers can draw it: one row per node, with the node's subtotal
+// as an inventory, and the amounts a conversion had no price for in a
+// column of their own when there are any. Text indents leaf names under
+// their parents; CSV keeps full account names.
+func balanceTreeResult(tree *ledger.BalanceTree, fullNames bool) *query.Result {
+	result := &query.Result{Columns: []query.ResultColumn{
+		{Name: "account", Type: query.TString},
+		{Name: "balance", Type: query.TInventory},
+	}}
+	unconverted := false
+	var walk func(nodes []*ledger.BalanceNode)
+	walk = func(nodes []*ledger.BalanceNode) {
+		for _, node := range nodes {
+			name := node.Name
+			if !fullNames {
+				name = strings.Repeat("  ", node.Depth) + name[strings.LastIndexByte(name, ':')+1:]
+			}
+			row := []any{name, balanceInventory(node.Balance), query.NewInventory()}
+			if node.Unconverted != nil && !node.Unconverted.IsZero() {
+				row[2] = balanceInventory(node.Unconverted)
+				unconverted = true
+			}
+			result.Rows = append(result.Rows, row)
+			walk(node.Children)
+		}
+	}
+	walk(tree.Roots)
+
+	if unconverted {
+		result.Columns = append(result.Columns, query.ResultColumn{Name: "unconverted", Type: query.TInventory})
+	} else {
+		for i, row := range result.Rows {
+			result.Rows[i] = row[:2]
+		}
+	}
+	return result
+}
+
+type balanceTreeJSON struct {
+	Report     string             `json:"report"`
+	Begin      *string            `json:"begin,omitempty"`
+	End        *string            `json:"end,omitempty"`
+	Currencies []string           `json:"currencies"`
+	Roots      []*balanceNodeJSON `json:"roots"`
+}
+
+type balanceNodeJSON struct {
+	Name        string             `json:"name"`
+	Account     string             `json:"account,omitempty"`
+	Balance     map[string]string  `json:"balance"`
+	Unconverted map[string]string  `json:"unconverted,omitempty"`
+	Children    []*balanceNodeJSON `json:"children,omitempty"`
+}
+
+// renderBalanceTreeJSON writes a balance tree as nested JSON nodes, with
+// amounts as strings that keep their precision. Nodes with amounts a
+// conversion had no price for list them under unconverted.
+func renderBalanceTreeJSON(out io.Writer, report string, tree *ledger.BalanceTree) error {
+	amounts := func(balance *ledger.Balance) map[string]string {
+		amounts := make(map[string]string)
+		for _, entry := range balance.Entries() {
+			if !entry.Amount.IsZero() {
+				amounts[entry.Currency] = query.DecimalString(entry.Amount)
+			}
+		}
+		return amounts
+	}
+	var convert func(nodes []*ledger.BalanceNode) []*balanceNodeJSON
+	convert = func(nodes []*ledger.BalanceNode) []*balanceNodeJSON {
+		converted := make([]*balanceNodeJSON, len(nodes))
+		for i, node := range nodes {
+			converted[i] = &balanceNodeJSON{
+				Name:     node.Name,
+				Account:  node.Account,
+				Balance:  amounts(node.Balance),
+				Children: convert(node.Children),
+			}
+			if node.Unconverted != nil {
+				if unconverted := amounts(node.Unconverted); len(unconverted) > 0 {
+					converted[i].Unconverted = unconverted
+				}
+			}
+		}
+		return converted
+	}
+
+	currencies := tree.Currencies
+	if currencies == nil {
+		currencies = []string{}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&balanceTreeJSON{
+		Report:     report,
+		Begin:      tree.StartDate,
+		End:        tree.EndDate,
+		Currencies: currencies,
+		Roots:      convert(tree.Roots),
+	})
+}
+
+// journalQuery lists the postings of the period, like the JOURNAL
+// statement without its running balance, which means nothing across every
+// account. Converted amounts are valued on their own dates. Long payees and
+// narrations are cut short in the text table only.
+func (cmd *ReportCmd) journalQuery(begin, end *ast.Date) string {
+	account, position := "account", "position"
+	payee, narration := "payee", "narration"
+	if cmd.Format == "text" {
+		payee, narration = "maxwidth(payee, 48) AS payee", "maxwidth(narration, 80) AS narration"
+	}
+	if cmd.Depth > 0 {
+		account = fmt.Sprintf("root(account, %d)", cmd.Depth)
+	}
+	if cmd.ConvertTo != "" {
+		position = fmt.Sprintf("convert(position, '%s', date)", cmd.ConvertTo)
+	}
+
+	q := fmt.Sprintf("SELECT date, flag, %s, %s, %s AS account, %s AS position", payee, narration, account, position)
+	var where []string
+	if begin != nil {
+		where = append(where, "date >= "+begin.String())
+	}
+	if end != nil {
+		where = append(where, "date <= "+end.String())
+	}
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	return q
+}
+
+// renderQuery runs a report query and renders its result.
+func (cmd *ReportCmd) renderQuery(ctx context.Context, out io.Writer, loaded *loadedLedger, q string) error {
+	stmt, err := bql.Parse(q)
+	if err != nil {
+		return err
+	}
+	qctx := newQueryContext(loaded)
+	compiled, err := query.Compile(qctx, stmt)
+	if err != nil {
+		return err
+	}
+	result, err := query.Execute(ctx, qctx, loaded.tree, compiled)
+	if err != nil {
+		return err
+	}
+	return cmd.renderResult(out, result)
+}
+
+func (cmd *ReportCmd) renderResult(out io.Writer, result *query.Result) error {
+	switch cmd.Format {
+	case "json":
+		return query.RenderJSON(result, out)
+	case "csv":
+		return query.RenderCSV(result, out, false)
+	default:
+		return query.RenderText(result, out)
+	}
+}
+
+// renderHoldings lists the asset holdings at --end, or today, with their
+// book and market value and unrealized gain, in the currency they were
+// bought in or in --convert-to.
+func (cmd *ReportCmd) renderHoldings(out io.Writer, loaded *loadedLedger, end *ast.Date) error {
+	date := reportDate(end)
+	holdings, err := loaded.ledger.Holdings(date, cmd.Aggregate)
+	if err != nil {
+		return err
+	}
+	if cmd.ConvertTo != "" {
+		for _, holding := range holdings {
+			convertHolding(loaded.ledger, holding, cmd.ConvertTo, date)
+		}
+	}
+	holdings = mergeHoldings(holdings, cmd.Depth)
+
+	var columns []query.ResultColumn
+	if !cmd.Aggregate {
+		columns = append(columns, query.ResultColumn{Name: "account", Type: query.TString})
+	}
+	columns = append(columns,
+		query.ResultColumn{Name: "units", Type: query.TAmount},
+		query.ResultColumn{Name: "book_value", Type: query.TAmount},
+		query.ResultColumn{Name: "market", Type: query.TAmount},
+		query.ResultColumn{Name: "gain", Type: query.TAmount},
+		query.ResultColumn{Name: "pct", Type: query.TDecimal},
+	)
+	result := &query.Result{Columns: columns}
+	for _, holding := range holdings {
+		var row []any
+		if !cmd.Aggregate {
+			row = append(row, holding.Account)
+		}
+		row = append(row,
+			&query.Amount{Number: holding.Units, Currency: holding.Commodity},
+			&query.Amount{Number: holding.BookValue, Currency: holding.CostCurrency},
+		)
+		if !holding.Priced {
+			// Without a price the market value is unknown, not the book
+			// value, so it is left empty with the gain.
+			result.Rows = append(result.Rows, append(row, nil, nil, nil))
+			continue
+		}
+		row = append(row,
+			&query.Amount{Number: holding.MarketValue, Currency: holding.CostCurrency},
+			&query.Amount{Number: holding.UnrealizedGain, Currency: holding.CostCurrency},
+			holding.UnrealizedPercent().Round(2),
+		)
+		result.Rows = append(result.Rows, row)
+	}
+	return cmd.renderResult(out, result)
+}
+
+// renderBudget compares the budgets with the actual amounts per interval
+// from begin, by default the date of the first budget, to end, by default
+// the last entry of the ledger.
+func (cmd *ReportCmd) renderBudget(out io.Writer, loaded *loadedLedger, begin, end *ast.Date) error {
+	interval := budget.Monthly
+	if cmd.Interval != "" {
+		interval = budget.Period(cmd.Interval)
+	}
+	if begin == nil && len(loaded.budgets) > 0 {
+		begin = loaded.budgets[0].Date
+	}
+	if end == nil {
+		if n := len(loaded.tree.Directives); n > 0 {
+			end = loaded.tree.Directives[n-1].Date()
+		}
+	}
+
+	result := &query.Result{Columns: []query.ResultColumn{
+		{Name: "date", Type: query.TDate},
+		{Name: "account", Type: query.TString},
+		{Name: "budget", Type: query.TAmount},
+		{Name: "actual", Type: query.TAmount},
+		{Name: "remaining", Type: query.TAmount},
+		{Name: "pct", Type: query.TDecimal},
+	}}
+	if begin != nil && end != nil {
+		for _, line := range budget.Compare(loaded.ledger, loaded.budgets, begin, end, interval) {
+			result.Rows = append(result.Rows, []any{
+				line.Start,
+				string(line.Account),
+				&query.Amount{Number: line.Budget, Currency: line.Currency},
+				&query.Amount{Number: line.Actual, Currency: line.Currency},
+				&query.Amount{Number: line.Remaining(), Currency: line.Currency},
+				line.Percent().Round(2),
+			})
+		}
+	}
+	return cmd.renderResult(out, result)
+}
+
+// convertHolding values a holding in currency at the price of its cost
+// currency on date, rounded to cents. Holdings without a price path keep
+// their cost currency.
+func convertHolding(l *ledger.Ledger, holding *ledger.Holding, currency string, date *ast.Date) {
+	if holding.CostCurrency == currency {
+		return
+	}
+	rate, ok := l.GetPrice(date, holding.CostCurrency, currency)
+	if !ok {
+		return
+	}
+	holding.CostCurrency = currency
+	holding.BookValue = holding.BookValue.Mul(rate).Round(2)
+	holding.MarketValue = holding.MarketValue.Mul(rate).Round(2)
+	holding.UnrealizedGain = holding.MarketValue.Sub(holding.BookValue)
+}
+
+// mergeHoldings sums the holdings whose accounts share their first depth
+// components. A depth of zero keeps every account.
+func mergeHoldings(holdings []*ledger.Holding, depth int) []*ledger.Holding {
+	type holdingKey struct {
+		account, commodity, costCurrency string
+	}
+	merged := make(map[holdingKey]*ledger.Holding)
+	var result []*ledger.Holding
+	for _, holding := range holdings {
+		key := holdingKey{holding.Account, holding.Commodity, holding.CostCurrency}
+		if depth > 0 {
+			if parts := strings.Split(key.account, ":"); len(parts) > depth {
+				key.account = strings.Join(parts[:depth], ":")
+			}
+		}
+		if m, ok := merged[key]; ok {
+			m.Units = m.Units.Add(holding.Units)
+			m.BookValue = m.BookValue.Add(holding.BookValue)
+			m.MarketValue = m.MarketValue.Add(holding.MarketValue)
+			m.UnrealizedGain = m.UnrealizedGain.Add(holding.UnrealizedGain)
+			m.Priced = m.Priced && holding.Priced
+			continue
+		}
+		m := *holding
+		m.Account = key.account
+		merged[key] = &m
+		result = append(result, &m)
+	}
+	slices.SortStableFunc(result, func(a, b *ledger.Holding) int {
+		return cmp.Or(
+			cmp.Compare(a.Account, b.Account),
+			cmp.Compare(a.Commodity, b.Commodity),
+			cmp.Compare(a.CostCurrency, b.CostCurrency),
+		)
+	})
+	return result
+}
+
+// renderGains lists the lots sold over the period with their cost basis,
+// proceeds and realized gain, followed in text by the short- and long-term
+// totals, per tax year unless --year picks one. Disposals without a sale
+// price have no proceeds or gain and are left out of the totals. With
+// --convert-to, the cost basis is converted at the rate on the acquisition
+// date and the proceeds at the rate on the sale date.
+func (cmd *ReportCmd) renderGains(out io.Writer, loaded *loadedLedger, begin, end *ast.Date) error {
+	disposals := loaded.ledger.RealizedGains(begin, end)
+
+	result := &query.Result{Columns: []query.ResultColumn{
+		{Name: "date", Type: query.TDate},
+		{Name: "account", Type: query.TString},
+		{Name: "units", Type: query.TAmount},
+		{Name: "acquired", Type: query.TDate},
+		{Name: "cost_basis", Type: query.TAmount},
+		{Name: "proceeds", Type: query.TAmount},
+		{Name: "gain", Type: query.TAmount},
+		{Name: "term", Type: query.TString},
+	}}
+	totals := make(map[string]*ledger.Balance)
+	years := make(map[int]map[string]*ledger.Balance)
+	unpriced := 0
+	for _, disposal := range disposals {
+		account := string(disposal.Account)
+		if cmd.Depth > 0 {
+			if parts := strings.Split(account, ":"); len(parts) > cmd.Depth {
+				account = strings.Join(parts[:cmd.Depth], ":")
+			}
+		}
+		term := "short"
+		if disposal.LongTerm() {
+			term = "long"
+		}
+		var acquired any
+		if disposal.Acquired != nil {
+			acquired = disposal.Acquired
+		}
+		costBasis := cmd.convertAmount(loaded.ledger, disposal.CostBasis(), disposal.CostCurrency, cmp.Or(disposal.Acquired, disposal.Date))
+		units := &query.Amount{Number: disposal.Units, Currency: disposal.Commodity}
+		if !disposal.Priced {
+			// Without a sale price the gain is unknown rather than zero, so
+			// it is left empty and out of the totals.
+			unpriced++
+			result.Rows = append(result.Rows, []any{disposal.Date, account, units, acquired, costBasis, nil, nil, term})
+			continue
+		}
+
+		proceeds := cmd.convertAmount(loaded.ledger, disposal.Proceeds(), disposal.CostCurrency, disposal.Date)
+		gain := &query.Amount{Number: proceeds.Number.Sub(costBasis.Number), Currency: proceeds.Currency}
+		if costBasis.Currency != proceeds.Currency {
+			// Only one of the two dates has a rate; leave the gain unconverted.
+			gain = &query.Amount{Number: disposal.Gain(), Currency: disposal.CostCurrency}
+		}
+		if totals[term] == nil {
+			totals[term] = ledger.NewBalance()
+		}
+		totals[term].Add(gain.Currency, gain.Number)
+		year := disposal.Date.Year()
+		if years[year] == nil {
+			years[year] = make(map[string]*ledger.Balance)
+		}
+		if years[year][term] == nil {
+			years[year][term] = ledger.NewBalance()
+		}
+		years[year][term].Add(gain.Currency, gain.Number)
+
+		result.Rows = append(result.Rows, []any{disposal.Date, account, units, acquired, costBasis, proceeds, gain, term})
+	}
+
+	if cmd.Year != 0 {
+		years = nil
+	}
+	switch cmd.Format {
+	case "json":
+		return renderGainsJSON(out, result, totals, years)
+	case "csv":
+		return query.RenderCSV(result, out, false)
+	}
+
+	if err := query.RenderText(result, out); err != nil || len(result.Rows) == 0 {
+		return err
+	}
+	// The summary holds one row per currency so that a zero gain still
+	// shows its amount.
+	summary := &query.Result{Columns: []query.ResultColumn{
+		{Name: "term", Type: query.TString},
+		{Name: "gain", Type: query.TAmount},
+	}}
+	total := ledger.NewBalance()
+	if years == nil {
+		for _, term := range []string{"short", "long"} {
+			if totals[term] != nil {
+				total.Merge(totals[term])
+				summary.Rows = appendBalanceRows(summary.Rows, totals[term], term)
+			}
+		}
+		summary.Rows = appendBalanceRows(summary.Rows, total, "total")
+	} else {
+		// Without --year the period may span tax years, each taxed apart.
+		summary.Columns = slices.Insert(summary.Columns, 0, query.ResultColumn{Name: "year", Type: query.TString})
+		for _, year := range slices.Sorted(maps.Keys(years)) {
+			for _, term := range []string{"short", "long"} {
+				if years[year][term] != nil {
+					total.Merge(years[year][term])
+					summary.Rows = appendBalanceRows(summary.Rows, years[year][term], strconv.Itoa(year), term)
+				}
+			}
+		}
+		summary.Rows = appendBalanceRows(summary.Rows, total, "total", "")
+	}
+	if len(summary.Rows) > 0 {
+		if _, err := io.WriteString(out, "\n"); err != nil {
+			return err
+		}
+		if err := query.RenderText(summary, out); err != nil {
+			return err
+		}
+	}
+	if unpriced > 0 {
+		_, err := fmt.Fprintf(out, "\n%d disposal(s) without a sale price are left out of the totals.\n", unpriced)
+		return err
+	}
+	return nil
+}
+
+// appendBalanceRows appends a row per currency of balance, each the labels
+// followed by the amount, zero amounts included.
+func appendBalanceRows(rows [][]any, balance *ledger.Balance, labels ...any) [][]any {
+	for _, entry := range balance.Entries() {
+		rows = append(rows, append(slices.Clone(labels), &query.Amount{Number: entry.Amount, Currency: entry.Currency}))
+	}
+	return rows
+}
+
+// convertAmount converts number in currency to --convert-to at the rate on
+// date, rounded to cents. Without --convert-to or a rate it is returned in
+// currency.
+func (cmd *ReportCmd) convertAmount(l *ledger.Ledger, number decimal.Decimal, currency string, date *ast.Date) *query.Amount {
+	if cmd.ConvertTo != "" && cmd.ConvertTo != currency {
+		if rate, ok := l.GetPrice(date, currency, cmd.ConvertTo); ok {
+			return &query.Amount{Number: number.Mul(rate).Round(2), Currency: cmd.ConvertTo}
+		}
+	}
+	return &query.Amount{Number: number, Currency: currency}
+}
+
+// balanceInventory turns the non-zero amounts of a balance into a query
+// inventory for rendering.
+func balanceInventory(balance *ledger.Balance) *query.Inventory {
+	inventory := query.NewInventory()
+	for _, entry := range balance.Entries() {
+		if !entry.Amount.IsZero() {
+			inventory.AddAmount(&query.Amount{Number: entry.Amount, Currency: entry.Currency})
+		}
+	}
+	return inventory
+}
+
+type gainsJSON struct {
+	Report    string                                  `json:"report"`
+	Disposals json.RawMessage                         `json:"disposals"`
+	Totals    map[string]map[string]string            `json:"totals"`
+	Years     map[string]map[string]map[string]string `json:"years,omitempty"`
+}
+
+// renderGainsJSON writes the disposals as the JSON query renderer does,
+// with the short- and long-term totals alongside, and per tax year when
+// years is set.
+func renderGainsJSON(out io.Writer, result *query.Result, totals map[string]*ledger.Balance, years map[int]map[string]*ledger.Balance) error {
+	var disposals bytes.Buffer
+	if err := query.RenderJSON(result, &disposals); err != nil {
+		return err
+	}
+	report := &gainsJSON{Report: "gains", Disposals: disposals.Bytes(), Totals: termTotalsJSON(totals)}
+	if years != nil {
+		report.Years = make(map[string]map[string]map[string]string, len(years))
+		for year, totals := range years {
+			report.Years[strconv.Itoa(year)] = termTotalsJSON(totals)
+		}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// termTotalsJSON maps each term to its amounts by currency.
+func termTotalsJSON(totals map[string]*ledger.Balance) map[string]map[string]string {
+	converted := make(map[string]map[string]string, len(totals))
+	for term, balance := range totals {
+		converted[term] = make(map[string]string)
+		for _, entry := range balance.Entries() {
+			converted[term][entry.Currency] = query.DecimalString(entry.Amount)
+		}
+	}
+	return converted
+}
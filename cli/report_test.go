@@ -0,0 +1,409 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/budget"
+)
+
+const reportLedger = `
+2014-01-01 open Assets:US:Checking USD
+2014-01-01 open Assets:US:Invest HOOL
+2014-01-01 open Liabilities:Card USD
+2014-01-01 open Equity:Opening-Balances USD
+2014-01-01 open Income:Salary USD
+2014-01-01 open Expenses:Food USD
+2014-01-01 open Expenses:Rent USD
+
+2014-01-02 * "Opening"
+  Assets:US:Checking  1000.00 USD
+  Equity:Opening-Balances
+
+2014-02-01 * "Acme" "Salary"
+  Assets:US:Checking  2500.00 USD
+  Income:Salary
+
+2014-02-03 * "Cafe" "Coffee"
+  Expenses:Food  4.50 USD
+  Liabilities:Card
+
+2014-02-05 * "Landlord" "Rent"
+  Expenses:Rent  1200.00 USD
+  Assets:US:Checking
+
+2014-03-01 * "Broker" "Buy HOOL"
+  Assets:US:Invest  2 HOOL {500.00 USD}
+  Assets:US:Checking
+
+2014-04-01 price HOOL 520.00 USD
+`
+
+func renderReport(t *testing.T, cmd *ReportCmd, begin, end string) string {
+	t.Helper()
+	return renderReportSource(t, reportLedger, cmd, begin, end)
+}
+
+func renderReportSource(t *testing.T, source string, cmd *ReportCmd, begin, end string) string {
+	t.Helper()
+	qctx, tree := newContextFromSource(t, source)
+	budgets, budgetErrors := budget.FromAST(tree)
+	assert.Zero(t, budgetErrors)
+
+	date := func(s string) *ast.Date {
+		if s == "" {
+			return nil
+		}
+		d, err := ast.NewDate(s)
+		assert.NoError(t, err)
+		return d
+	}
+	if cmd.Format == "" {
+		cmd.Format = "text"
+	}
+	var out strings.Builder
+	assert.NoError(t, cmd.render(context.Background(), &out, &loadedLedger{tree: tree, ledger: qctx.Ledger, config: qctx.Config, budgets: budgets}, date(begin), date(end)))
+	return out.String()
+}
+
+func TestReportBalanceSheet(t *testing.T) {
+	output := renderReport(t, &ReportCmd{Report: "balsheet"}, "", "2014-02-28")
+	assert.Equal(t, `     account         balance   
+------------------ ------------
+Assets              2300.00 USD
+  US                2300.00 USD
+    Checking        2300.00 USD
+Liabilities           -4.50 USD
+  Card                -4.50 USD
+Equity             -1000.00 USD
+  Opening-Balances -1000.00 USD
+`, output)
+}
+
+func TestReportIncomeStatementDepth(t *testing.T) {
+	output := renderReport(t, &ReportCmd{Report: "income", Format: "csv", Depth: 1}, "2014-02-01", "2014-02-28")
+	assert.Equal(t, "account,balance (USD)\r\nIncome  ,-2500.00\r\nExpenses, 1204.50\r\n", output)
+}
+
+func TestReportIncomeStatementSingleDay(t *testing.T) {
+	// A period of one day holds that day's changes, not the balances on it.
+	output := renderReport(t, &ReportCmd{Report: "income"}, "2014-02-03", "2014-02-03")
+	assert.Equal(t, `account  balance 
+-------- --------
+Expenses 4.50 USD
+  Food   4.50 USD
+`, output)
+
+	// So does --begin on the date of the last entry.
+	source := reportLedger + `
+2014-05-01 * "Cafe" "Lunch"
+  Expenses:Food  12.00 USD
+  Liabilities:Card
+`
+	output = renderReportSource(t, source, &ReportCmd{Report: "trial", Format: "json"}, "2014-05-01", "")
+	assert.Contains(t, output, `"currencies": [
+    "USD"
+  ]`)
+	assert.Contains(t, output, `"name": "Liabilities",
+      "balance": {
+        "USD": "-12.00"
+      }`)
+	assert.Contains(t, output, `"name": "Expenses",
+      "balance": {
+        "USD": "12.00"
+      }`)
+	assert.NotContains(t, output, "HOOL")
+}
+
+func TestReportConvertTo(t *testing.T) {
+	output := renderReport(t, &ReportCmd{Report: "trial", Format: "json", Depth: 2, ConvertTo: "USD"}, "", "2014-12-31")
+	assert.Contains(t, output, `"currencies": [
+    "USD"
+  ]`)
+	// 2 HOOL at 520.00 USD plus 1300.00 USD in checking.
+	assert.Contains(t, output, `"name": "Assets:US",
+          "account": "Assets:US",
+          "balance": {
+            "USD": "2340.00"
+          }`)
+	assert.Contains(t, output, `"name": "Assets",
+      "balance": {
+        "USD": "2340.00"
+      }`)
+}
+
+func TestReportConvertToValuation(t *testing.T) {
+	source := reportLedger + `
+2014-01-01 open Assets:UK:Cash
+2014-01-01 open Expenses:Travel
+
+2014-04-02 * "Travel" "Taxi"
+  Expenses:Travel  1250.00 GBP
+  Assets:UK:Cash
+`
+	// At cost the HOOL lots keep their 1000.00 USD basis; the GBP has no
+	// price to USD and is listed apart.
+	output := renderReportSource(t, source, &ReportCmd{Report: "trial", ConvertTo: "USD", Valuation: "cost", Depth: 1}, "", "2014-12-31")
+	assert.Equal(t, `  account     balance    unconverted 
+----------- ------------ ------------
+Assets       2300.00 USD -1250.00 GBP
+Liabilities    -4.50 USD             
+Equity      -1000.00 USD             
+Income      -2500.00 USD             
+Expenses     1204.50 USD  1250.00 GBP
+`, output)
+
+	output = renderReportSource(t, source, &ReportCmd{Report: "trial", Format: "json", ConvertTo: "USD", Depth: 1}, "", "2014-12-31")
+	assert.Contains(t, output, `"name": "Assets",
+      "balance": {
+        "USD": "2340.00"
+      }`)
+	assert.Contains(t, output, `"name": "Expenses",
+      "balance": {
+        "USD": "1204.50"
+      },
+      "unconverted": {
+        "GBP": "1250.00"
+      }`)
+}
+
+func TestReportJournal(t *testing.T) {
+	output := renderReport(t, &ReportCmd{Report: "journal", Format: "csv", Depth: 1}, "2014-02-03", "2014-02-05")
+	assert.Equal(t, "date,flag,payee,narration,account,position\r\n"+
+		"2014-02-03,*,Cafe    ,Coffee,Expenses   ,    4.50 USD\r\n"+
+		"2014-02-03,*,Cafe    ,Coffee,Liabilities,   -4.50 USD\r\n"+
+		"2014-02-05,*,Landlord,Rent  ,Expenses   , 1200.00 USD\r\n"+
+		"2014-02-05,*,Landlord,Rent  ,Assets     ,-1200.00 USD\r\n", output)
+}
+
+func TestReportJournalLongNarration(t *testing.T) {
+	narration := strings.Repeat("Groceries for the week ", 5)
+	source := reportLedger + `
+2014-02-06 * "Market" "` + narration + `"
+  Expenses:Food  20.00 USD
+  Liabilities:Card
+`
+	// Only the text table cuts the narration short.
+	output := renderReportSource(t, source, &ReportCmd{Report: "journal"}, "2014-02-06", "2014-02-06")
+	assert.NotContains(t, output, narration)
+	output = renderReportSource(t, source, &ReportCmd{Report: "journal", Format: "csv"}, "2014-02-06", "2014-02-06")
+	assert.Contains(t, output, narration)
+	output = renderReportSource(t, source, &ReportCmd{Report: "journal", Format: "json"}, "2014-02-06", "2014-02-06")
+	assert.Contains(t, output, narration)
+}
+
+func TestReportHoldings(t *testing.T) {
+	output := renderReport(t, &ReportCmd{Report: "holdings"}, "", "")
+	assert.Equal(t, `     account          units     book_value    market      gain    pct 
+------------------ ------------ ----------- ----------- --------- ----
+Assets:US:Checking 1300.00 USD  1300.00 USD 1300.00 USD  0.00 USD 0.00
+Assets:US:Invest      2    HOOL 1000.00 USD 1040.00 USD 40.00 USD 4.00
+`, output)
+
+	output = renderReport(t, &ReportCmd{Report: "holdings"}, "", "2014-01-31")
+	assert.Contains(t, output, "Assets:US:Checking 1000.00 USD 1000.00 USD 1000.00 USD 0.00 USD 0")
+	assert.NotContains(t, output, "HOOL")
+
+	// Before the first price, HOOL has no market value or gain.
+	output = renderReport(t, &ReportCmd{Report: "holdings", Format: "csv"}, "", "2014-03-15")
+	assert.Contains(t, output, "Assets:US:Invest  ,   2    HOOL,1000.00 USD,           ,        ,    \r\n")
+	output = renderReport(t, &ReportCmd{Report: "holdings"}, "", "2014-03-15")
+	assert.Contains(t, output, "Assets:US:Invest      2    HOOL 1000.00 USD                          \n")
+	output = renderReport(t, &ReportCmd{Report: "holdings", Format: "json"}, "", "2014-03-15")
+	assert.Contains(t, output, `"market": null,
+      "gain": null,
+      "pct": null`)
+}
+
+func TestReportHoldingsMerged(t *testing.T) {
+	output := renderReport(t, &ReportCmd{Report: "holdings", Format: "csv", Depth: 2}, "", "")
+	assert.Equal(t, "account,units,book_value,market,gain,pct\r\n"+
+		"Assets:US,   2    HOOL,1000.00 USD,1040.00 USD,40.00 USD,4.00\r\n"+
+		"Assets:US,1300.00 USD ,1300.00 USD,1300.00 USD, 0.00 USD,0.00\r\n", output)
+
+	output = renderReport(t, &ReportCmd{Report: "holdings", Format: "json", Aggregate: true}, "", "")
+	assert.NotContains(t, output, "Assets")
+	assert.Contains(t, output, `"market": {
+        "number": "1040.00",
+        "currency": "USD"
+      }`)
+}
+
+const gainsLedger = `
+2014-01-01 open Assets:Checking USD
+2014-01-01 open Assets:Invest HOOL "FIFO"
+2014-01-01 open Equity:Opening-Balances USD
+2014-01-01 open Income:Gains USD
+
+2014-01-02 * "Opening"
+  Assets:Checking  20000.00 USD
+  Equity:Opening-Balances
+
+2014-03-01 * "Broker" "Buy HOOL"
+  Assets:Invest  10 HOOL {500.00 USD}
+  Assets:Checking
+
+2014-06-01 * "Broker" "Buy HOOL"
+  Assets:Invest  5 HOOL {520.00 USD}
+  Assets:Checking
+
+2015-05-01 * "Broker" "Sell HOOL"
+  Assets:Invest  -12 HOOL {} @ 600.00 USD
+  Assets:Checking  7200.00 USD
+  Income:Gains
+
+2014-03-01 price USD 0.80 EUR
+2015-05-01 price USD 0.90 EUR
+`
+
+func TestReportGains(t *testing.T) {
+	output := renderReportSource(t, gainsLedger, &ReportCmd{Report: "gains", Year: 2015}, "2015-01-01", "2015-12-31")
+	assert.Equal(t, `   date       account     units   acquired  cost_basis   proceeds      gain     term 
+---------- ------------- ------- ---------- ----------- ----------- ----------- -----
+2015-05-01 Assets:Invest 10 HOOL 2014-03-01 5000.00 USD 6000.00 USD 1000.00 USD long 
+2015-05-01 Assets:Invest  2 HOOL 2014-06-01 1040.00 USD 1200.00 USD  160.00 USD short
+
+term     gain    
+----- -----------
+short  160.00 USD
+long  1000.00 USD
+total 1160.00 USD
+`, output)
+
+	output = renderReportSource(t, gainsLedger, &ReportCmd{Report: "gains"}, "2014-01-01", "2014-12-31")
+	assert.Equal(t, "(empty)\n", output)
+}
+
+func TestReportGainsPerTaxYear(t *testing.T) {
+	source := gainsLedger + `
+2016-02-01 * "Broker" "Sell HOOL"
+  Assets:Invest  -3 HOOL {} @ 500.00 USD
+  Assets:Checking  1500.00 USD
+  Income:Gains
+`
+	// Without --year the totals are split by tax year, then term.
+	output := renderReportSource(t, source, &ReportCmd{Report: "gains"}, "", "")
+	assert.Contains(t, output, `year  term     gain    
+----- ----- -----------
+2015  short  160.00 USD
+2015  long  1000.00 USD
+2016  long   -60.00 USD
+total       1100.00 USD
+`)
+
+	output = renderReportSource(t, source, &ReportCmd{Report: "gains", Format: "json"}, "", "")
+	assert.Contains(t, output, `"years": {
+    "2015": {
+      "long": {
+        "USD": "1000.00"
+      },
+      "short": {
+        "USD": "160.00"
+      }
+    },
+    "2016": {
+      "long": {
+        "USD": "-60.00"
+      }
+    }
+  }`)
+}
+
+func TestReportGainsUnpriced(t *testing.T) {
+	// A swap has no proceeds leg and HOOL no price, so its gain is left
+	// empty and out of the totals.
+	source := gainsLedger + `
+2014-01-01 open Assets:Invest:GOOG GOOG
+
+2015-07-01 * "Broker" "Swap HOOL for GOOG"
+  Assets:Invest  -1 HOOL {}
+  Assets:Invest:GOOG  1 GOOG {520.00 USD}
+`
+	output := renderReportSource(t, source, &ReportCmd{Report: "gains", Year: 2015}, "2015-01-01", "2015-12-31")
+	assert.Equal(t, `   date       account     units   acquired  cost_basis   proceeds      gain     term 
+---------- ------------- ------- ---------- ----------- ----------- ----------- -----
+2015-05-01 Assets:Invest 10 HOOL 2014-03-01 5000.00 USD 6000.00 USD 1000.00 USD long 
+2015-05-01 Assets:Invest  2 HOOL 2014-06-01 1040.00 USD 1200.00 USD  160.00 USD short
+2015-07-01 Assets:Invest  1 HOOL 2014-06-01  520.00 USD                         long 
+
+term     gain    
+----- -----------
+short  160.00 USD
+long  1000.00 USD
+total 1160.00 USD
+
+1 disposal(s) without a sale price are left out of the totals.
+`, output)
+
+	output = renderReportSource(t, source, &ReportCmd{Report: "gains", Format: "json"}, "2015-07-01", "2015-07-01")
+	assert.Contains(t, output, `"proceeds": null,
+        "gain": null,`)
+	assert.Contains(t, output, `"totals": {}`)
+}
+
+func TestReportGainsZero(t *testing.T) {
+	source := gainsLedger + `
+2015-07-01 * "Broker" "Sell HOOL at cost"
+  Assets:Invest  -1 HOOL {} @ 520.00 USD
+  Assets:Checking  520.00 USD
+`
+	output := renderReportSource(t, source, &ReportCmd{Report: "gains", Year: 2015}, "2015-07-01", "2015-07-01")
+	assert.Equal(t, `   date       account    units   acquired  cost_basis  proceeds    gain   term
+---------- ------------- ------ ---------- ---------- ---------- -------- ----
+2015-07-01 Assets:Invest 1 HOOL 2014-06-01 520.00 USD 520.00 USD 0.00 USD long
+
+term    gain  
+----- --------
+long  0.00 USD
+total 0.00 USD
+`, output)
+}
+
+func TestReportGainsConvertTo(t *testing.T) {
+	// The cost basis is converted on the acquisition date, the proceeds on
+	// the sale date.
+	output := renderReportSource(t, gainsLedger, &ReportCmd{Report: "gains", Format: "json", ConvertTo: "EUR"}, "", "")
+	assert.Contains(t, output, `"cost_basis": {
+          "number": "4000.00",
+          "currency": "EUR"
+        },
+        "proceeds": {
+          "number": "5400.00",
+          "currency": "EUR"
+        },
+        "gain": {
+          "number": "1400.00",
+          "currency": "EUR"
+        }`)
+	assert.Contains(t, output, `"totals": {
+    "long": {
+      "EUR": "1400.00"
+    },
+    "short": {`)
+}
+
+func TestReportBudget(t *testing.T) {
+	source := reportLedger + `
+2014-01-01 custom "budget" Expenses:Food "weekly" 25.00 USD
+2014-01-01 custom "budget" Expenses:Rent "monthly" 1000.00 USD
+`
+	output := renderReportSource(t, source, &ReportCmd{Report: "budget"}, "2014-02-01", "2014-03-31")
+	assert.Equal(t, `   date       account      budget      actual     remaining   pct  
+---------- ------------- ----------- ----------- ----------- ------
+2014-02-01 Expenses:Food  100.00 USD    4.50 USD   95.50 USD   4.50
+2014-02-01 Expenses:Rent 1000.00 USD 1200.00 USD -200.00 USD 120.00
+2014-03-01 Expenses:Food  110.71 USD    0.00 USD  110.71 USD   0.00
+2014-03-01 Expenses:Rent 1000.00 USD    0.00 USD 1000.00 USD   0.00
+`, output)
+
+	output = renderReportSource(t, source, &ReportCmd{Report: "budget", Interval: "quarterly", Format: "csv"}, "", "")
+	assert.Equal(t, strings.ReplaceAll(`date,account,budget,actual,remaining,pct
+2014-01-01,Expenses:Food, 321.43 USD,   4.50 USD, 316.93 USD, 1.40
+2014-01-01,Expenses:Rent,3000.00 USD,1200.00 USD,1800.00 USD,40.00
+2014-04-01,Expenses:Food,   3.57 USD,   0.00 USD,   3.57 USD, 0.00
+2014-04-01,Expenses:Rent,  33.33 USD,   0.00 USD,  33.33 USD, 0.00
+`, "\n", "\r\n"), output)
+}
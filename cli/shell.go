@@ -0,0 +1,442 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/query"
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+const (
+	shellPrompt             = "beancount> "
+	shellContinuationPrompt = "       ..> "
+
+	// shellHistorySize bounds the statements kept across sessions.
+	shellHistorySize = 1000
+)
+
+// shellInput reads the lines typed into the shell.
+type shellInput interface {
+	ReadLine() (string, error)
+	SetPrompt(prompt string)
+}
+
+// shell is the interactive query REPL. Queries run when a line ends with
+// `;` and may span several lines; shell commands take one line each.
+// Settings changed with set apply to the rest of the session.
+type shell struct {
+	qctx             *query.Context
+	tree             *ast.AST
+	settings         querySettings
+	out              io.Writer
+	validationErrors *ledger.ValidationErrors
+	sourceContent    []byte
+	history          *shellHistory // nil when statements are not remembered
+}
+
+// runShell runs the shell over plain input, printing the prompt to out.
+func runShell(ctx context.Context, qctx *query.Context, tree *ast.AST, settings querySettings, in io.Reader, out io.Writer, validationErrors *ledger.ValidationErrors, sourceContent []byte) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	s := &shell{qctx: qctx, tree: tree, settings: settings, out: out, validationErrors: validationErrors, sourceContent: sourceContent}
+	return s.run(ctx, &scannerInput{scanner: scanner, out: out, prompt: shellPrompt})
+}
+
+// runTerminalShell runs the shell on the terminal attached to stdin, with
+// line editing, tab completion, and history kept in the user's config
+// directory.
+func runTerminalShell(ctx context.Context, qctx *query.Context, tree *ast.AST, settings querySettings, stdout io.Writer, validationErrors *ledger.ValidationErrors, sourceContent []byte) error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, stdout}, shellPrompt)
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		_ = terminal.SetSize(width, height)
+	}
+	terminal.SetBracketedPasteMode(true)
+	defer terminal.SetBracketedPasteMode(false)
+
+	history := &shellHistory{}
+	if dir, err := os.UserConfigDir(); err == nil {
+		history.path = filepath.Join(dir, "beancount", "query_history")
+		history.load()
+	}
+	terminal.History = history
+
+	completer := newShellCompleter(qctx)
+	terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, candidates := completer.complete(line, pos)
+		if newLine == line && len(candidates) > 1 {
+			_, _ = fmt.Fprintln(terminal, strings.Join(candidates, "  "))
+		}
+		return newLine, newPos, true
+	}
+
+	s := &shell{qctx: qctx, tree: tree, settings: settings, out: terminal, validationErrors: validationErrors, sourceContent: sourceContent, history: history}
+	return s.run(ctx, &terminalInput{terminal})
+}
+
+func (s *shell) run(ctx context.Context, input shellInput) error {
+	printShellBanner(s.out, s.tree)
+
+	var pending []string
+	for {
+		line, err := input.ReadLine()
+		if err != nil {
+			if !stdErrors.Is(err, io.EOF) {
+				return err
+			}
+			// The statement being typed when input ends still runs.
+			if statement := strings.TrimSpace(strings.Join(pending, "\n")); statement != "" {
+				if err := s.statement(ctx, statement); err != nil {
+					return err
+				}
+			}
+			_, _ = fmt.Fprintln(s.out)
+			return nil
+		}
+
+		if len(pending) == 0 {
+			done, exit, err := s.command(ctx, line)
+			if err != nil || exit {
+				return err
+			}
+			if done {
+				continue
+			}
+		}
+
+		pending = append(pending, line)
+		statement := strings.TrimSpace(strings.Join(pending, "\n"))
+		if !strings.HasSuffix(statement, ";") && s.incomplete(statement) {
+			input.SetPrompt(shellContinuationPrompt)
+			continue
+		}
+		pending = nil
+		input.SetPrompt(shellPrompt)
+		if err := s.statement(ctx, statement); err != nil {
+			return err
+		}
+	}
+}
+
+// command runs the shell command on line, reporting done when line was
+// one, and exit when the shell should end.
+func (s *shell) command(ctx context.Context, line string) (done, exit bool, err error) {
+	line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+	command, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+	switch strings.ToLower(command) {
+	case "":
+		return true, false, nil
+	case "exit", "quit":
+		return true, true, nil
+	case "help":
+		_, _ = fmt.Fprintln(s.out, "Enter a BQL query (SELECT, BALANCES, JOURNAL, PRINT), ending with ; to run it.")
+		_, _ = fmt.Fprintln(s.out, "Commands:")
+		_, _ = fmt.Fprintln(s.out, "  errors               show ledger errors")
+		_, _ = fmt.Fprintln(s.out, "  explain QUERY;       show the compiled plan of a query")
+		_, _ = fmt.Fprintln(s.out, "  run [NAME]           run a stored query, or list them without a name")
+		_, _ = fmt.Fprintln(s.out, "  set [NAME VALUE]     change a setting, or list them without a name:")
+		_, _ = fmt.Fprintln(s.out, "                       format text|csv|json|jsonl|markdown|html,")
+		_, _ = fmt.Fprintln(s.out, "                       numberify on|off, v3 on|off")
+		_, _ = fmt.Fprintln(s.out, "  exit or quit         leave the shell")
+		_, _ = fmt.Fprintln(s.out, "Tab completes keywords, columns, functions, and account names.")
+		return true, false, nil
+	case "errors":
+		if s.validationErrors == nil || len(s.validationErrors.Errors) == 0 {
+			_, _ = fmt.Fprintln(s.out, "(no errors)")
+			return true, false, nil
+		}
+		renderer := NewErrorRenderer(s.sourceContent)
+		_, _ = fmt.Fprintln(s.out, renderer.RenderAll(s.validationErrors.Errors))
+		return true, false, nil
+	case "run":
+		s.history.record(line)
+		return true, false, runStoredQuery(ctx, s.qctx, s.tree, args, s.settings, s.out)
+	case "set":
+		s.history.record(line)
+		return true, false, s.settings.set(args, s.out)
+	}
+	return false, false, nil
+}
+
+// statement runs a query or explains it.
+func (s *shell) statement(ctx context.Context, statement string) error {
+	// History keeps one entry per line, so the lines of a statement are
+	// joined; whitespace inside them, as in string literals, is kept.
+	s.history.record(strings.ReplaceAll(statement, "\n", " "))
+	if args, ok := cutExplain(statement); ok {
+		return runExplain(s.qctx, args, s.settings, s.out)
+	}
+	return runQuery(ctx, s.qctx, s.tree, statement, s.settings, s.out)
+}
+
+// incomplete reports whether more lines could complete statement: it
+// parses, or only fails where the input ends. Other syntax errors are
+// reported straight away.
+func (s *shell) incomplete(statement string) bool {
+	if args, ok := cutExplain(statement); ok {
+		statement = args
+	}
+	_, err := bql.Parse(statement, s.settings.parseOptions()...)
+	var parseErr *bql.ParseError
+	if stdErrors.As(err, &parseErr) {
+		return parseErr.Pos.Offset >= len(statement)
+	}
+	return err == nil
+}
+
+// cutExplain returns the query of an explain statement, which may start on
+// the next line.
+func cutExplain(statement string) (string, bool) {
+	fields := strings.Fields(statement)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "explain") {
+		return "", false
+	}
+	return strings.TrimSpace(statement[len(fields[0]):]), true
+}
+
+// printShellBanner reports the ledger title and directive counts, following
+// the official shell greeting.
+func printShellBanner(out io.Writer, tree *ast.AST) {
+	title := ""
+	for _, option := range tree.Options {
+		if option.Name.String() == "title" {
+			title = option.Value.String()
+		}
+	}
+	if title != "" {
+		_, _ = fmt.Fprintf(out, "Input file: %q\n", title)
+	}
+	transactions, postings := 0, 0
+	for _, entry := range tree.Directives {
+		if txn, ok := entry.(*ast.Transaction); ok {
+			transactions++
+			postings += len(txn.Postings)
+		}
+	}
+	_, _ = fmt.Fprintf(out, "Ready with %d directives (%d postings in %d transactions).\n",
+		len(tree.Directives), postings, transactions)
+}
+
+// scannerInput reads lines from a non-terminal input, printing the prompt
+// before each.
+type scannerInput struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+	prompt  string
+}
+
+func (in *scannerInput) ReadLine() (string, error) {
+	_, _ = fmt.Fprint(in.out, in.prompt)
+	if !in.scanner.Scan() {
+		if err := in.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return in.scanner.Text(), nil
+}
+
+func (in *scannerInput) SetPrompt(prompt string) {
+	in.prompt = prompt
+}
+
+// terminalInput reads lines from a terminal with line editing.
+type terminalInput struct {
+	terminal *term.Terminal
+}
+
+func (in *terminalInput) ReadLine() (string, error) {
+	line, err := in.terminal.ReadLine()
+	if err == term.ErrPasteIndicator {
+		// Pasted lines are read like typed ones.
+		err = nil
+	}
+	return line, err
+}
+
+func (in *terminalInput) SetPrompt(prompt string) {
+	in.terminal.SetPrompt(prompt)
+}
+
+// shellHistory is the shell's statement history, saved to path when it is
+// set. It implements term.History; the terminal's own additions of every
+// line read are ignored so a statement spanning several lines is kept as
+// one entry, recorded by the shell once it runs.
+type shellHistory struct {
+	path    string
+	entries []string // Oldest first
+}
+
+// Add ignores the lines the terminal reads.
+func (h *shellHistory) Add(string) {}
+
+func (h *shellHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *shellHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+// load reads the saved history, rewriting the file when it has grown past
+// its bound. A missing or unreadable file leaves the history empty.
+func (h *shellHistory) load() {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > shellHistorySize {
+		h.entries = slices.Clone(h.entries[len(h.entries)-shellHistorySize:])
+		_ = os.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600)
+	}
+}
+
+// record adds an entry unless it repeats the latest one, and appends it to
+// the history file. Failing to save is not an error; the shell works
+// without it.
+func (h *shellHistory) record(entry string) {
+	if h == nil || entry == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > shellHistorySize {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintln(file, entry)
+	_ = file.Close()
+}
+
+// shellCompleter completes the word before the cursor: BQL keywords,
+// columns, and functions in a query, including those the ledger declares,
+// and account names inside a string.
+type shellCompleter struct {
+	words    []string
+	accounts []string
+}
+
+func newShellCompleter(qctx *query.Context) *shellCompleter {
+	c := &shellCompleter{}
+	c.words = append(c.words, bql.Keywords()...)
+	c.words = append(c.words, query.ColumnNames()...)
+	c.words = append(c.words, query.FunctionNames()...)
+	c.words = append(c.words, slices.Sorted(maps.Keys(qctx.Macros))...)
+	if qctx.Ledger != nil {
+		for name := range qctx.Ledger.Accounts() {
+			c.accounts = append(c.accounts, name)
+		}
+		slices.Sort(c.accounts)
+	}
+	return c
+}
+
+// complete extends the word before pos to the longest prefix its
+// candidates share, returning the new line and cursor position and the
+// candidates. Keywords follow the case of the word typed.
+func (c *shellCompleter) complete(line string, pos int) (string, int, []string) {
+	start := pos
+	for start > 0 && isCompletionByte(line[start-1]) {
+		start--
+	}
+	word := line[start:pos]
+	if word == "" {
+		return line, pos, nil
+	}
+
+	var candidates []string
+	if inString(line[:start]) {
+		for _, account := range c.accounts {
+			if strings.HasPrefix(account, word) {
+				candidates = append(candidates, account)
+			}
+		}
+	} else {
+		lower := strings.ToLower(word)
+		for _, candidate := range c.words {
+			if !strings.HasPrefix(strings.ToLower(candidate), lower) {
+				continue
+			}
+			if word == lower {
+				candidate = strings.ToLower(candidate)
+			}
+			candidates = append(candidates, candidate)
+		}
+		slices.Sort(candidates)
+		candidates = slices.Compact(candidates)
+	}
+	if len(candidates) == 0 {
+		return line, pos, nil
+	}
+
+	prefix := candidates[0]
+	for _, candidate := range candidates[1:] {
+		n := 0
+		for n < len(prefix) && n < len(candidate) && prefix[n] == candidate[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	if len(prefix) <= len(word) {
+		return line, pos, candidates
+	}
+	return line[:start] + prefix + line[pos:], start + len(prefix), candidates
+}
+
+func isCompletionByte(b byte) bool {
+	return b == '_' || b == ':' || b == '-' || b == '.' ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b >= 0x80
+}
+
+// inString reports whether text ends inside a quoted string.
+func inString(text string) bool {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote == 0 && (text[i] == '\'' || text[i] == '"'):
+			quote = text[i]
+		case text[i] == quote:
+			quote = 0
+		}
+	}
+	return quote != 0
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+const shellLedger = `
+2014-01-01 open Assets:Cash
+2014-01-01 open Assets:Checking
+2014-01-01 open Equity:Opening-Balances
+
+2014-01-02 * "Opening"
+  Assets:Cash  100.00 USD
+  Equity:Opening-Balances
+`
+
+func TestQueryShellMultiline(t *testing.T) {
+	qctx, tree := newContextFromSource(t, shellLedger)
+	in := strings.NewReader(strings.Join([]string{
+		"SELECT account,",
+		"  sum(number) AS total",
+		"WHERE account ~ 'Cash'",
+		"GROUP BY account;",
+		"SELECT account WHERE WHERE",
+		"explain",
+		"SELECT account",
+		";",
+		"SELECT count(date)",
+	}, "\n"))
+	var out strings.Builder
+	assert.NoError(t, runShell(context.Background(), qctx, tree, querySettings{format: "text"}, in, &out, nil, nil))
+
+	output := out.String()
+	assert.Contains(t, output, "beancount>        ..>        ..>        ..>   account   total \n"+
+		"----------- ------\n"+
+		"Assets:Cash 100.00\n")
+	// A syntax error before the end of the input is reported at once.
+	assert.Contains(t, output, "beancount> ERROR: Syntax error: ")
+	assert.Contains(t, output, "SELECT\n  environment: targets/column context\n")
+	// The statement still being typed at the end of the input runs.
+	assert.Contains(t, output, "beancount>        ..> c\n-\n2\n")
+}
+
+func TestShellCompleter(t *testing.T) {
+	qctx, _ := newContextFromSource(t, shellLedger)
+	c := newShellCompleter(qctx)
+
+	tests := []struct {
+		line       string
+		want       string
+		candidates []string
+	}{
+		{"sel", "select", []string{"select"}},
+		{"SEL", "SELECT", []string{"SELECT"}},
+		{"SELECT acc", "SELECT account", []string{"account", "account_sortkey"}},
+		{"SELECT su", "SELECT su", []string{"subst", "sum"}},
+		{"SELECT date WHERE account ~ 'Assets:C", "SELECT date WHERE account ~ 'Assets:C", []string{"Assets:Cash", "Assets:Checking"}},
+		{"SELECT date WHERE account = 'Eq", "SELECT date WHERE account = 'Equity:Opening-Balances", []string{"Equity:Opening-Balances"}},
+		{"SELECT Eq", "SELECT Eq", nil},
+		{"SELECT ", "SELECT ", nil},
+	}
+	for _, test := range tests {
+		line, pos, candidates := c.complete(test.line, len(test.line))
+		assert.Equal(t, test.want, line, test.line)
+		assert.Equal(t, len(test.want), pos, test.line)
+		assert.Equal(t, test.candidates, candidates, test.line)
+	}
+
+	// Completion applies to the word before the cursor.
+	line, pos, _ := c.complete("SELECT dat FROM x", 10)
+	assert.Equal(t, "SELECT date FROM x", line)
+	assert.Equal(t, 11, pos)
+}
+
+func TestShellHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beancount", "query_history")
+	history := &shellHistory{path: path}
+	history.load()
+	assert.Equal(t, 0, history.Len())
+
+	history.Add("ignored")
+	history.record("SELECT account;")
+	history.record("SELECT account;")
+	history.record("set format csv")
+	assert.Equal(t, 2, history.Len())
+	assert.Equal(t, "set format csv", history.At(0))
+
+	reloaded := &shellHistory{path: path}
+	reloaded.load()
+	assert.Equal(t, []string{"SELECT account;", "set format csv"}, reloaded.entries)
+
+	// A file grown past the bound is trimmed to its latest entries.
+	var lines []string
+	for i := range shellHistorySize + 5 {
+		lines = append(lines, fmt.Sprintf("SELECT %d;", i))
+	}
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+	bounded := &shellHistory{path: path}
+	bounded.load()
+	assert.Equal(t, shellHistorySize, bounded.Len())
+	assert.Equal(t, "SELECT 5;", bounded.entries[0])
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, shellHistorySize, strings.Count(string(data), "\n"))
+
+	var none *shellHistory
+	none.record("SELECT 1;")
+}
+
+func TestShellHistoryKeepsStatementText(t *testing.T) {
+	qctx, tree := newContextFromSource(t, shellLedger)
+	in := strings.NewReader("SELECT account\nWHERE narration = 'Opening  balance';\n")
+	var out strings.Builder
+	history := &shellHistory{}
+	s := &shell{qctx: qctx, tree: tree, settings: querySettings{format: "text"}, out: &out, history: history}
+	assert.NoError(t, s.run(context.Background(), &scannerInput{scanner: bufio.NewScanner(in), out: &out, prompt: shellPrompt}))
+
+	assert.Equal(t, []string{"SELECT account WHERE narration = 'Opening  balance';"}, history.entries)
+}
@@ -683,6 +683,27 @@ func (f *Formatter) FormatTransaction(txn *ast.Transaction, w io.Writer) error {
 	return err
 }
 
+// FormatDirectives formats directives that were not parsed from a source file,
+// such as imported transactions, separated by blank lines.
+// The currency column is calculated from the directives if not explicitly set.
+func (f *Formatter) FormatDirectives(directives []ast.Directive, w io.Writer) error {
+	if f.CurrencyColumn == 0 {
+		tree := &ast.AST{Directives: directives}
+		f.CurrencyColumn = f.determineCurrencyColumn(tree)
+	}
+
+	var buf strings.Builder
+	for i, directive := range directives {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		f.formatDirective(directive, &buf)
+	}
+
+	_, err := w.Write([]byte(buf.String()))
+	return err
+}
+
 // formatDirective formats a directive based on its type.
 func (f *Formatter) formatDirective(d ast.Directive, buf *strings.Builder) {
 	switch directive := d.(type) {
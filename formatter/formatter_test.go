@@ -294,6 +294,28 @@ func TestFormatUsesRawParserSourceOrder(t *testing.T) {
 	assert.Equal(t, source, buf.String())
 }
 
+func TestFormatBuiltDirectives(t *testing.T) {
+	date, _ := ast.NewDate("2024-01-03")
+	account, _ := ast.NewAccount("Assets:Checking")
+	directives := []ast.Directive{
+		ast.NewTransaction(date, "Weekly shop",
+			ast.WithFlag("*"),
+			ast.WithPayee("Grocer"),
+			ast.WithPostings(ast.NewPosting(account, ast.WithAmount("-42.10", "USD"))),
+		),
+		ast.NewBalance(date, account, ast.NewAmount("957.90", "USD")),
+	}
+
+	var buf bytes.Buffer
+	err := New().FormatDirectives(directives, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `2024-01-03 * "Grocer" "Weekly shop"
+    Assets:Checking                 -42.10 USD
+
+2024-01-03 balance Assets:Checking  957.90 USD
+`, buf.String())
+}
+
 func TestFormatDirectives(t *testing.T) {
 	t.Run("Option", func(t *testing.T) {
 		source := `option "title" "My Ledger"`
@@ -0,0 +1,217 @@
+package importer
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/shopspring/decimal"
+)
+
+// Predictor suggests the counter-account of imported transactions from the
+// ones recorded in a ledger, with a naive Bayes classifier over the words of
+// the payee and narration and the accounts and signs of the known postings.
+//
+// The model is built from counts only, so the same ledger always gives the
+// same predictions.
+type Predictor struct {
+	classes    map[ast.Account]*predictorClass
+	vocabulary map[string]bool
+	samples    int
+	accounts   map[string]*ledger.Account
+}
+
+type predictorClass struct {
+	samples  int
+	features map[string]int
+	total    int
+}
+
+// Prediction is the counter-account suggested for a transaction.
+type Prediction struct {
+	Account ast.Account
+	// Confidence is the posterior probability of Account among the accounts
+	// the model knows, between 0 and 1.
+	Confidence float64
+}
+
+// NewPredictor trains a predictor on the transactions of a processed ledger.
+// Every posting of a transaction is a sample, labeled with its account and
+// described by the transaction's words and its other postings.
+func NewPredictor(l *ledger.Ledger) *Predictor {
+	p := &Predictor{
+		classes:    make(map[ast.Account]*predictorClass),
+		vocabulary: make(map[string]bool),
+		accounts:   l.Accounts(),
+	}
+
+	seen := make(map[*ast.Transaction]bool)
+	for _, account := range l.Accounts() {
+		for _, posting := range account.Postings {
+			if seen[posting.Transaction] {
+				continue
+			}
+			seen[posting.Transaction] = true
+			p.train(posting.Transaction)
+		}
+	}
+	return p
+}
+
+func (p *Predictor) train(txn *ast.Transaction) {
+	if len(txn.Postings) < 2 {
+		return
+	}
+	words := transactionWords(txn)
+	for i, target := range txn.Postings {
+		class := p.classes[target.Account]
+		if class == nil {
+			class = &predictorClass{features: make(map[string]int)}
+			p.classes[target.Account] = class
+		}
+		class.samples++
+		p.samples++
+
+		features := slices.Clip(words)
+		for j, posting := range txn.Postings {
+			if j != i {
+				features = append(features, postingFeatures(posting)...)
+			}
+		}
+		for _, feature := range features {
+			class.features[feature]++
+			class.total++
+			p.vocabulary[feature] = true
+		}
+	}
+}
+
+// Predict returns the most likely counter-account of txn. Accounts already
+// posted to and accounts not open on the transaction's date are not
+// considered. It returns false when the model knows no candidate.
+func (p *Predictor) Predict(txn *ast.Transaction) (Prediction, bool) {
+	features := transactionWords(txn)
+	posted := make(map[ast.Account]bool)
+	for _, posting := range txn.Postings {
+		features = append(features, postingFeatures(posting)...)
+		posted[posting.Account] = true
+	}
+
+	vocabulary := float64(len(p.vocabulary) + 1)
+	scores := make(map[ast.Account]float64)
+	for account, class := range p.classes {
+		if posted[account] {
+			continue
+		}
+		if ledgerAccount, ok := p.accounts[string(account)]; ok && !ledgerAccount.IsOpen(txn.Date()) {
+			continue
+		}
+
+		// Laplace smoothing keeps unseen words from ruling out a class.
+		score := math.Log(float64(class.samples) / float64(p.samples))
+		for _, feature := range features {
+			score += math.Log((float64(class.features[feature]) + 1) / (float64(class.total) + vocabulary))
+		}
+		scores[account] = score
+	}
+	if len(scores) == 0 {
+		return Prediction{}, false
+	}
+
+	// Accounts are visited in order so that ties and the floating-point
+	// sum below do not depend on map iteration.
+	accounts := slices.Sorted(maps.Keys(scores))
+	best := accounts[0]
+	for _, account := range accounts[1:] {
+		if scores[account] > scores[best] {
+			best = account
+		}
+	}
+	total := 0.0
+	for _, account := range accounts {
+		total += math.Exp(scores[account] - scores[best])
+	}
+	return Prediction{Account: best, Confidence: 1 / total}, true
+}
+
+// Fill adds a posting to the predicted account to txn when it does not
+// balance and the prediction is at least minConfidence confident. The
+// posting has no amount, so it is interpolated when the transaction is
+// processed. It returns the prediction, or nil when txn was left alone.
+func (p *Predictor) Fill(txn *ast.Transaction, minConfidence float64) *Prediction {
+	if !needsBalancing(txn) {
+		return nil
+	}
+	prediction, ok := p.Predict(txn)
+	if !ok || prediction.Confidence < minConfidence {
+		return nil
+	}
+	txn.Postings = append(txn.Postings, ast.NewPosting(prediction.Account))
+	return &prediction
+}
+
+// Categorize trains a predictor on l and fills the balancing posting of each
+// of txns, like Predictor.Fill. The predictions are returned in the order of
+// txns, nil for the transactions that were left alone.
+func Categorize(l *ledger.Ledger, txns []*ast.Transaction, minConfidence float64) []*Prediction {
+	p := NewPredictor(l)
+	predictions := make([]*Prediction, len(txns))
+	for i, txn := range txns {
+		predictions[i] = p.Fill(txn, minConfidence)
+	}
+	return predictions
+}
+
+// needsBalancing reports whether txn is missing a posting: every posting has
+// a plain amount and they do not add up to zero.
+func needsBalancing(txn *ast.Transaction) bool {
+	sums := make(map[string]decimal.Decimal)
+	for _, posting := range txn.Postings {
+		number, currency, ok := postingAmount(posting)
+		if !ok || posting.Cost != nil || posting.Price != nil {
+			return false
+		}
+		sums[currency] = sums[currency].Add(number)
+	}
+	for _, sum := range sums {
+		if !sum.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// transactionWords returns the lowercased words of the payee and narration,
+// leaving out numbers, which are mostly references and dates.
+func transactionWords(txn *ast.Transaction) []string {
+	var features []string
+	for _, word := range words(txn.Payee.Value + " " + txn.Narration.Value) {
+		features = append(features, "word:"+word)
+	}
+	return features
+}
+
+// postingFeatures describes a known posting by its account and the
+// direction of its amount, which tells refunds from purchases.
+func postingFeatures(posting *ast.Posting) []string {
+	features := []string{"account:" + string(posting.Account)}
+	if number, _, ok := postingAmount(posting); ok {
+		sign := "+"
+		if number.IsNegative() {
+			sign = "-"
+		}
+		features = append(features, "sign:"+string(posting.Account)+sign)
+	}
+	return features
+}
+
+// words splits s into lowercased runs of letters.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
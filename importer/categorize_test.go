@@ -0,0 +1,122 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const categorizeLedger = `2024-01-01 open Assets:Checking USD
+2024-01-01 open Liabilities:CreditCard USD
+2024-01-01 open Expenses:Groceries
+2024-01-01 open Expenses:Dining
+2024-01-01 open Expenses:Transport
+2024-01-01 open Income:Salary
+2024-01-01 open Income:Refunds
+2024-01-01 open Expenses:Old
+2024-01-02 close Expenses:Old
+
+2024-01-03 * "Whole Foods" "Groceries"
+  Assets:Checking  -82.10 USD
+  Expenses:Groceries
+
+2024-01-10 * "Whole Foods Market"
+  Assets:Checking  -41.00 USD
+  Expenses:Groceries
+
+2024-01-12 * "Pizza Place"
+  Liabilities:CreditCard  -23.50 USD
+  Expenses:Dining
+
+2024-01-14 * "Metro Transit" "Monthly pass"
+  Liabilities:CreditCard  -90.00 USD
+  Expenses:Transport
+
+2024-01-25 * "ACME Corp" "Payroll"
+  Assets:Checking  3500.00 USD
+  Income:Salary
+
+2024-01-28 * "Whole Foods" "Return"
+  Assets:Checking  12.00 USD
+  Income:Refunds
+`
+
+func TestPredictorPredict(t *testing.T) {
+	p := NewPredictor(processLedger(t, categorizeLedger))
+
+	tests := []struct {
+		narration string
+		account   string
+		amount    string
+		want      string
+	}{
+		{"WHOLE FOODS #1234", "Assets:Checking", "-55.20", "Expenses:Groceries"},
+		{"WHOLE FOODS RETURN", "Assets:Checking", "8.00", "Income:Refunds"},
+		{"PIZZA PLACE 02/03", "Liabilities:CreditCard", "-18.00", "Expenses:Dining"},
+		{"ACME CORP PAYROLL", "Assets:Checking", "3500.00", "Income:Salary"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			date, _ := ast.NewDate("2024-02-01")
+			txn := ast.NewTransaction(date, test.narration,
+				ast.WithPostings(ast.NewPosting(ast.Account(test.account), ast.WithAmount(test.amount, "USD"))))
+
+			prediction, ok := p.Predict(txn)
+			assert.True(t, ok)
+			assert.Equal(t, test.want, string(prediction.Account))
+			assert.True(t, prediction.Confidence > 0 && prediction.Confidence <= 1)
+		})
+	}
+}
+
+func TestPredictorSkipsClosedAndPostedAccounts(t *testing.T) {
+	p := NewPredictor(processLedger(t, categorizeLedger))
+
+	date, _ := ast.NewDate("2024-02-01")
+	txn := ast.NewTransaction(date, "Whole Foods",
+		ast.WithPostings(
+			ast.NewPosting("Assets:Checking", ast.WithAmount("-10.00", "USD")),
+			ast.NewPosting("Expenses:Groceries", ast.WithAmount("5.00", "USD")),
+		))
+	prediction, ok := p.Predict(txn)
+	assert.True(t, ok)
+	assert.NotEqual(t, "Expenses:Groceries", string(prediction.Account))
+	assert.NotEqual(t, "Expenses:Old", string(prediction.Account))
+}
+
+func TestCategorize(t *testing.T) {
+	date, _ := ast.NewDate("2024-02-01")
+	unbalanced := ast.NewTransaction(date, "Whole Foods",
+		ast.WithPostings(ast.NewPosting("Assets:Checking", ast.WithAmount("-30.00", "USD"))))
+	balanced := ast.NewTransaction(date, "Whole Foods",
+		ast.WithPostings(
+			ast.NewPosting("Assets:Checking", ast.WithAmount("-30.00", "USD")),
+			ast.NewPosting("Expenses:Groceries", ast.WithAmount("30.00", "USD")),
+		))
+	unknown := ast.NewTransaction(date, "Hardware store",
+		ast.WithPostings(ast.NewPosting("Assets:Checking", ast.WithAmount("-30.00", "USD"))))
+
+	l := processLedger(t, categorizeLedger)
+	predictions := Categorize(l, []*ast.Transaction{unbalanced, balanced, unknown}, 0.8)
+
+	assert.Equal(t, 3, len(predictions))
+	assert.NotZero(t, predictions[0])
+	assert.Equal(t, "Expenses:Groceries", string(predictions[0].Account))
+	assert.Equal(t, 2, len(unbalanced.Postings))
+	assert.Equal(t, "Expenses:Groceries", string(unbalanced.Postings[1].Account))
+	assert.Zero(t, unbalanced.Postings[1].Amount)
+
+	assert.Zero(t, predictions[1])
+	assert.Equal(t, 2, len(balanced.Postings))
+
+	// Nothing in the ledger resembles a hardware store, so the prediction
+	// is not confident enough to be used.
+	assert.Zero(t, predictions[2])
+	assert.Equal(t, 1, len(unknown.Postings))
+
+	// The same ledger gives the same predictions.
+	again := Categorize(l, []*ast.Transaction{ast.NewTransaction(date, "Whole Foods",
+		ast.WithPostings(ast.NewPosting("Assets:Checking", ast.WithAmount("-30.00", "USD"))))}, 0.8)
+	assert.Equal(t, *predictions[0], *again[0])
+}
@@ -0,0 +1,384 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+func init() {
+	Register("csv", func(config json.RawMessage) (Importer, error) {
+		var cfg CSVConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, err
+		}
+		return NewCSV(cfg)
+	})
+}
+
+// CSVConfig configures a CSV importer.
+type CSVConfig struct {
+	// Account receives the postings and files the statements.
+	Account string `json:"account"`
+	// Currency of the amounts, unless a currency column is mapped.
+	Currency string `json:"currency"`
+	// Match is a glob the file's base name must match. Without it, any file
+	// with a .csv extension and the mapped header columns is identified.
+	Match string `json:"match"`
+	// Delimiter separates fields; a comma by default.
+	Delimiter string `json:"delimiter"`
+	// SkipLines are skipped before the header, for exports that start
+	// with a preamble.
+	SkipLines int `json:"skip_lines"`
+	// NoHeader marks files without a header row; columns are then mapped
+	// by zero-based index.
+	NoHeader bool `json:"no_header"`
+	// Columns maps fields to header names or zero-based column indexes.
+	Columns CSVColumns `json:"columns"`
+	// DateLayouts are tried in order to parse dates, as Go time layouts.
+	// The default is "2006-01-02".
+	DateLayouts []string `json:"date_layouts"`
+	// DecimalSeparator is "." (the default) or ",".
+	DecimalSeparator string `json:"decimal_separator"`
+	// Negate flips the sign of amounts, for statements that list charges
+	// as positive numbers such as most credit card exports.
+	Negate bool `json:"negate"`
+	// Flag marks the imported transactions; "*" by default.
+	Flag string `json:"flag"`
+}
+
+// CSVColumns maps transaction fields to columns. Either Amount or at least
+// one of Debit and Credit is required, together with Date.
+type CSVColumns struct {
+	Date      string `json:"date"`
+	Payee     string `json:"payee"`
+	Narration string `json:"narration"`
+	// Amount holds signed amounts, positive for money coming in.
+	Amount string `json:"amount"`
+	// Debit holds money going out and Credit money coming in, both as
+	// positive numbers, for statements that split them.
+	Debit  string `json:"debit"`
+	Credit string `json:"credit"`
+	// Currency holds the currency of each row, overriding CSVConfig.Currency.
+	Currency string `json:"currency"`
+	// Balance holds the running balance. The balance after the latest row
+	// is asserted on the following day.
+	Balance string `json:"balance"`
+}
+
+// CSV imports delimited bank statements.
+type CSV struct {
+	config  CSVConfig
+	account ast.Account
+}
+
+var _ Importer = &CSV{}
+
+// NewCSV creates a CSV importer, validating its configuration.
+func NewCSV(config CSVConfig) (*CSV, error) {
+	account, err := ast.NewAccount(config.Account)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account: %w", err)
+	}
+	if config.Currency == "" && config.Columns.Currency == "" {
+		return nil, fmt.Errorf("currency or a currency column is required")
+	}
+	if config.Columns.Date == "" {
+		return nil, fmt.Errorf("a date column is required")
+	}
+	if config.Columns.Amount == "" && config.Columns.Debit == "" && config.Columns.Credit == "" {
+		return nil, fmt.Errorf("an amount, debit or credit column is required")
+	}
+	if config.Match != "" {
+		if _, err := filepath.Match(config.Match, ""); err != nil {
+			return nil, fmt.Errorf("invalid match pattern %q: %w", config.Match, err)
+		}
+	}
+	if config.Delimiter != "" && utf8.RuneCountInString(config.Delimiter) != 1 {
+		return nil, fmt.Errorf("delimiter must be a single character")
+	}
+	if config.DecimalSeparator != "" && config.DecimalSeparator != "." && config.DecimalSeparator != "," {
+		return nil, fmt.Errorf("decimal separator must be \".\" or \",\"")
+	}
+	if len(config.DateLayouts) == 0 {
+		config.DateLayouts = []string{time.DateOnly}
+	}
+	if config.Flag == "" {
+		config.Flag = "*"
+	}
+	return &CSV{config: config, account: account}, nil
+}
+
+// Name implements Importer.
+func (c *CSV) Name() string {
+	return "csv " + string(c.account)
+}
+
+// Identify implements Importer. The file must match the configured glob, or
+// have a .csv extension, and contain every mapped header column.
+func (c *CSV) Identify(f *File) bool {
+	name := filepath.Base(f.Path)
+	if c.config.Match != "" {
+		if ok, _ := filepath.Match(c.config.Match, name); !ok {
+			return false
+		}
+	} else if !strings.EqualFold(filepath.Ext(name), ".csv") {
+		return false
+	}
+
+	rows, err := c.read(f)
+	if err != nil {
+		return false
+	}
+	_, err = c.mapColumns(rows)
+	return err == nil
+}
+
+// Extract implements Importer. It returns a transaction per row, sorted by
+// date, followed by a balance assertion when a balance column is mapped.
+// Rows without a date, such as totals, are skipped.
+func (c *CSV) Extract(ctx context.Context, f *File) ([]ast.Directive, error) {
+	rows, err := c.read(f)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := c.mapColumns(rows)
+	if err != nil {
+		return nil, err
+	}
+	if !c.config.NoHeader {
+		rows = rows[1:]
+	}
+
+	var directives []ast.Directive
+	var balances []*ast.Balance
+	descending := false
+	for i, row := range rows {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		line := c.config.SkipLines + i + 1
+		if !c.config.NoHeader {
+			line++
+		}
+		value := func(column int) string {
+			if column < 0 || column >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[column])
+		}
+
+		if value(columns.date) == "" {
+			continue
+		}
+		date, err := c.parseDate(value(columns.date))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", f.Path, line, err)
+		}
+		amount, err := c.amount(value(columns.amount), value(columns.debit), value(columns.credit))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", f.Path, line, err)
+		}
+		currency := c.config.Currency
+		if value(columns.currency) != "" {
+			currency = value(columns.currency)
+		}
+
+		opts := []ast.TransactionOption{
+			ast.WithFlag(c.config.Flag),
+			ast.WithPostings(ast.NewPosting(c.account, ast.WithAmount(formatNumber(amount), currency))),
+		}
+		if payee := value(columns.payee); payee != "" {
+			opts = append(opts, ast.WithPayee(payee))
+		}
+		directives = append(directives, ast.NewTransaction(date, value(columns.narration), opts...))
+
+		if n := len(directives); n > 1 && date.Before(directives[n-2].Date().Time) {
+			descending = true
+		}
+
+		if value(columns.balance) != "" {
+			number, err := parseNumber(value(columns.balance), c.config.DecimalSeparator)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", f.Path, line, err)
+			}
+			balances = append(balances, ast.NewBalance(date, c.account, ast.NewAmount(formatNumber(number), currency)))
+		}
+	}
+
+	slices.SortStableFunc(directives, func(a, b ast.Directive) int {
+		return a.Date().Compare(b.Date().Time)
+	})
+	if balance := closingBalance(balances, descending); balance != nil {
+		balance.SetDate(ast.NewDateFromTime(balance.Date().AddDate(0, 0, 1)))
+		directives = append(directives, balance)
+	}
+	return directives, nil
+}
+
+// Account implements Importer.
+func (c *CSV) Account(f *File) (ast.Account, error) {
+	return c.account, nil
+}
+
+// Date implements Importer. A statement is dated by its latest transaction.
+func (c *CSV) Date(f *File) (*ast.Date, error) {
+	directives, err := c.Extract(context.Background(), f)
+	if err != nil {
+		return nil, err
+	}
+	if date := latestDate(directives); date != nil {
+		return date, nil
+	}
+	return nil, fmt.Errorf("%s: no transactions", f.Path)
+}
+
+// closingBalance returns the running balance after the latest row: the last
+// one listed for that date, or the first one when the file lists rows
+// newest first.
+func closingBalance(balances []*ast.Balance, descending bool) *ast.Balance {
+	var closing *ast.Balance
+	for _, balance := range balances {
+		if closing == nil || balance.Date().After(closing.Date().Time) ||
+			(!descending && balance.Date().Equal(closing.Date().Time)) {
+			closing = balance
+		}
+	}
+	return closing
+}
+
+// read parses the records after the skipped lines.
+func (c *CSV) read(f *File) ([][]string, error) {
+	data := f.Contents
+	for range c.config.SkipLines {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			return nil, fmt.Errorf("%s: fewer than %d lines", f.Path, c.config.SkipLines)
+		}
+		data = data[idx+1:]
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if c.config.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(c.config.Delimiter)
+	}
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: empty file", f.Path)
+	}
+	return rows, nil
+}
+
+// csvColumns holds the resolved column indexes; -1 means unmapped.
+type csvColumns struct {
+	date, payee, narration, amount, debit, credit, currency, balance int
+}
+
+// mapColumns resolves the configured column references against the header.
+func (c *CSV) mapColumns(rows [][]string) (*csvColumns, error) {
+	var header []string
+	if !c.config.NoHeader {
+		header = rows[0]
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+	}
+
+	resolve := func(ref string) (int, error) {
+		if ref == "" {
+			return -1, nil
+		}
+		if idx := slices.Index(header, ref); idx >= 0 {
+			return idx, nil
+		}
+		if idx, err := strconv.Atoi(ref); err == nil && idx >= 0 {
+			return idx, nil
+		}
+		return -1, fmt.Errorf("column %q not found", ref)
+	}
+
+	var columns csvColumns
+	refs := []struct {
+		ref    string
+		target *int
+	}{
+		{c.config.Columns.Date, &columns.date},
+		{c.config.Columns.Payee, &columns.payee},
+		{c.config.Columns.Narration, &columns.narration},
+		{c.config.Columns.Amount, &columns.amount},
+		{c.config.Columns.Debit, &columns.debit},
+		{c.config.Columns.Credit, &columns.credit},
+		{c.config.Columns.Currency, &columns.currency},
+		{c.config.Columns.Balance, &columns.balance},
+	}
+	for _, r := range refs {
+		idx, err := resolve(r.ref)
+		if err != nil {
+			return nil, err
+		}
+		*r.target = idx
+	}
+	return &columns, nil
+}
+
+func (c *CSV) parseDate(value string) (*ast.Date, error) {
+	for _, layout := range c.config.DateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return ast.NewDateFromTime(t), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date %q", value)
+}
+
+// amount returns the signed amount of a row, positive for money coming in.
+func (c *CSV) amount(amount, debit, credit string) (decimal.Decimal, error) {
+	var total decimal.Decimal
+	if amount != "" {
+		number, err := parseNumber(amount, c.config.DecimalSeparator)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = number
+	}
+	if debit != "" {
+		number, err := parseNumber(debit, c.config.DecimalSeparator)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Sub(number.Abs())
+	}
+	if credit != "" {
+		number, err := parseNumber(credit, c.config.DecimalSeparator)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(number.Abs())
+	}
+	if amount == "" && debit == "" && credit == "" {
+		return decimal.Zero, fmt.Errorf("missing amount")
+	}
+	if c.config.Negate {
+		total = total.Neg()
+	}
+	return total, nil
+}
@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/formatter"
+)
+
+func formatDirectives(t *testing.T, directives []ast.Directive) string {
+	t.Helper()
+	var buf bytes.Buffer
+	assert.NoError(t, formatter.New(formatter.WithCurrencyColumn(50)).FormatDirectives(directives, &buf))
+	return buf.String()
+}
+
+func TestCSVExtract(t *testing.T) {
+	imp, err := NewCSV(CSVConfig{
+		Account:  "Assets:Checking",
+		Currency: "USD",
+		Columns: CSVColumns{
+			Date: "Date", Payee: "Payee", Narration: "Description",
+			Amount: "Amount", Balance: "Balance",
+		},
+	})
+	assert.NoError(t, err)
+
+	f := &File{Path: "checking.csv", Contents: []byte(`Date,Payee,Description,Amount,Balance
+2024-01-05,ACME,Salary,"1,000.00",1957.90
+2024-01-03,Grocer,Weekly shop,-42.10,957.90
+,,Total,957.90,
+`)}
+	assert.True(t, imp.Identify(f))
+
+	directives, err := imp.Extract(context.Background(), f)
+	assert.NoError(t, err)
+	assert.Equal(t, `2024-01-03 * "Grocer" "Weekly shop"
+    Assets:Checking                       -42.10 USD
+
+2024-01-05 * "ACME" "Salary"
+    Assets:Checking                      1000.00 USD
+
+2024-01-06 balance Assets:Checking       1957.90 USD
+`, formatDirectives(t, directives))
+
+	date, err := imp.Date(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-05", date.Format("2006-01-02"))
+}
+
+func TestCSVDebitCreditAndLayouts(t *testing.T) {
+	imp, err := NewCSV(CSVConfig{
+		Account:          "Liabilities:CreditCard",
+		Currency:         "EUR",
+		Delimiter:        ";",
+		SkipLines:        2,
+		Columns:          CSVColumns{Date: "Datum", Narration: "Omschrijving", Debit: "Af", Credit: "Bij"},
+		DateLayouts:      []string{"02-01-2006", "2.1.2006"},
+		DecimalSeparator: ",",
+		Flag:             "!",
+	})
+	assert.NoError(t, err)
+
+	f := &File{Path: "export.csv", Contents: []byte(`Rekening 1234
+Periode januari
+
+Datum;Omschrijving;Af;Bij
+03-01-2024;Bakker;1.234,50;
+5.1.2024;Terugbetaling;;12,00
+`)}
+	assert.True(t, imp.Identify(f))
+
+	directives, err := imp.Extract(context.Background(), f)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(directives))
+	first := directives[0].(*ast.Transaction)
+	assert.Equal(t, "!", first.Flag)
+	assert.Equal(t, "Bakker", first.Narration.Value)
+	assert.Equal(t, "-1234.50", first.Postings[0].Amount.Value)
+	assert.Equal(t, "EUR", first.Postings[0].Amount.Currency)
+	second := directives[1].(*ast.Transaction)
+	assert.Equal(t, "2024-01-05", second.Date().Format("2006-01-02"))
+	assert.Equal(t, "12.00", second.Postings[0].Amount.Value)
+}
+
+func TestCSVNegateAndIndexes(t *testing.T) {
+	imp, err := NewCSV(CSVConfig{
+		Account:  "Liabilities:CreditCard",
+		Currency: "USD",
+		Match:    "card-*.csv",
+		NoHeader: true,
+		Columns:  CSVColumns{Date: "0", Narration: "1", Amount: "2"},
+		Negate:   true,
+	})
+	assert.NoError(t, err)
+
+	contents := []byte("2024-03-01,Coffee,4.50\n2024-03-02,Refund,(10.00)\n")
+	assert.False(t, imp.Identify(&File{Path: "checking.csv", Contents: contents}))
+
+	f := &File{Path: "downloads/card-march.csv", Contents: contents}
+	assert.True(t, imp.Identify(f))
+	directives, err := imp.Extract(context.Background(), f)
+	assert.NoError(t, err)
+	assert.Equal(t, "-4.50", directives[0].(*ast.Transaction).Postings[0].Amount.Value)
+	assert.Equal(t, "10.00", directives[1].(*ast.Transaction).Postings[0].Amount.Value)
+}
+
+func TestCSVIdentifyRequiresColumns(t *testing.T) {
+	imp, err := NewCSV(CSVConfig{
+		Account:  "Assets:Checking",
+		Currency: "USD",
+		Columns:  CSVColumns{Date: "Date", Amount: "Amount"},
+	})
+	assert.NoError(t, err)
+
+	assert.False(t, imp.Identify(&File{Path: "other.csv", Contents: []byte("When,Value\n2024-01-01,1\n")}))
+	assert.False(t, imp.Identify(&File{Path: "statement.txt", Contents: []byte("Date,Amount\n2024-01-01,1\n")}))
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		input     string
+		separator string
+		want      string
+	}{
+		{"1,234.56", ".", "1234.56"},
+		{"1.234,56", ",", "1234.56"},
+		{"-42.10", ".", "-42.1"},
+		{"42.10-", ".", "-42.1"},
+		{"(1,234.00)", ".", "-1234"},
+		{"$ 12.00", ".", "12"},
+		{"1'000.00", ".", "1000"},
+		{"−3,50 €", ",", "-3.5"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := parseNumber(test.input, test.separator)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got.String())
+		})
+	}
+
+	_, err := parseNumber("n/a", ".")
+	assert.EqualError(t, err, `invalid number "n/a"`)
+}
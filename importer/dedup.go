@@ -0,0 +1,343 @@
+package importer
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/shopspring/decimal"
+)
+
+// DuplicateKey is the metadata key MarkDuplicates sets on transactions that
+// are likely already in the ledger.
+const DuplicateKey = "__duplicate__"
+
+// Deduplicator finds imported transactions that are already recorded, such
+// as the overlapping days of two consecutive statements.
+//
+// A candidate matches an existing transaction when every posting the
+// importer produced has a counterpart in that transaction with the same
+// account and amount, dated within the date window. Of those, the one
+// scoring best on date distance and payee/narration similarity is taken if
+// its score reaches the threshold. Transactions carrying the same "fitid"
+// metadata always match, whatever their postings and dates.
+type Deduplicator struct {
+	window    int
+	threshold float64
+	postings  map[ast.Account][]indexedPosting
+	fitids    map[string][]*ast.Transaction
+	used      map[*ast.Transaction]bool
+}
+
+type indexedPosting struct {
+	date        time.Time
+	number      decimal.Decimal
+	currency    string
+	transaction *ast.Transaction
+}
+
+// DedupOption configures a Deduplicator.
+type DedupOption func(*Deduplicator)
+
+// WithDateWindow sets how many days apart a duplicate may be dated, to allow
+// for the difference between transaction and posting dates. The default is
+// 3 days.
+func WithDateWindow(days int) DedupOption {
+	return func(d *Deduplicator) {
+		d.window = max(days, 0)
+	}
+}
+
+// WithThreshold sets the score, between 0 and 1, a match needs to count as
+// a duplicate. Half of the score comes from the date distance and half from
+// payee/narration similarity, so with the default of 0.5 a transaction on
+// the same day with the same amounts always matches, and one a few days
+// apart needs a similar description as well.
+func WithThreshold(threshold float64) DedupOption {
+	return func(d *Deduplicator) {
+		d.threshold = threshold
+	}
+}
+
+// NewDeduplicator indexes the transactions of a processed ledger.
+func NewDeduplicator(l *ledger.Ledger, opts ...DedupOption) *Deduplicator {
+	d := &Deduplicator{
+		window:    3,
+		threshold: 0.5,
+		postings:  make(map[ast.Account][]indexedPosting),
+		fitids:    make(map[string][]*ast.Transaction),
+		used:      make(map[*ast.Transaction]bool),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if l != nil {
+		indexed := make(map[*ast.Transaction]bool)
+		for _, account := range l.Accounts() {
+			for _, posting := range account.Postings {
+				d.add(posting.Transaction, posting.Posting)
+				if !indexed[posting.Transaction] {
+					indexed[posting.Transaction] = true
+					d.addFitid(posting.Transaction)
+				}
+			}
+		}
+		for id := range d.fitids {
+			slices.SortStableFunc(d.fitids[id], func(a, b *ast.Transaction) int {
+				return a.Date().Compare(b.Date().Time)
+			})
+		}
+		for account := range d.postings {
+			slices.SortStableFunc(d.postings[account], func(a, b indexedPosting) int {
+				return a.date.Compare(b.date)
+			})
+		}
+	}
+	return d
+}
+
+// Match returns the existing transaction txn duplicates, or nil. One with
+// the same fitid is taken first; otherwise, of equally scoring candidates,
+// the earliest in the ledger is taken.
+func (d *Deduplicator) Match(txn *ast.Transaction) *ast.Transaction {
+	if id := metadataString(txn, "fitid"); id != "" {
+		for _, existing := range d.fitids[id] {
+			if !d.used[existing] {
+				return existing
+			}
+		}
+	}
+
+	var best *ast.Transaction
+	bestScore := 0.0
+	for _, c := range d.candidates(txn) {
+		if d.used[c.transaction] {
+			continue
+		}
+		score := d.score(txn, c.transaction, c.days)
+		if score >= d.threshold && (best == nil || score > bestScore) {
+			best, bestScore = c.transaction, score
+		}
+	}
+	return best
+}
+
+// MarkDuplicates sets DuplicateKey on the transactions among directives that
+// duplicate one in the ledger or an earlier one in directives, and returns
+// how many it marked. Each existing transaction is matched at most once, so
+// two identical purchases on one day are only both marked if both were
+// recorded already.
+func (d *Deduplicator) MarkDuplicates(directives []ast.Directive) int {
+	marked := 0
+	for _, directive := range directives {
+		txn, ok := directive.(*ast.Transaction)
+		if !ok || IsDuplicate(txn) {
+			continue
+		}
+		if existing := d.Match(txn); existing != nil {
+			d.used[existing] = true
+			value := true
+			txn.AddMetadata(&ast.Metadata{Key: DuplicateKey, Value: &ast.MetadataValue{Boolean: &value}})
+			marked++
+			continue
+		}
+
+		// Statements downloaded in one go may overlap each other too.
+		for _, posting := range txn.Postings {
+			d.insert(txn, posting)
+		}
+		d.addFitid(txn)
+	}
+	return marked
+}
+
+// IsDuplicate reports whether MarkDuplicates marked txn.
+func IsDuplicate(txn *ast.Transaction) bool {
+	for _, meta := range txn.Metadata {
+		if meta.Key == DuplicateKey {
+			return meta.Value == nil || meta.Value.Boolean == nil || *meta.Value.Boolean
+		}
+	}
+	return false
+}
+
+// candidate is an existing transaction txn may duplicate, dated days apart.
+type candidate struct {
+	transaction *ast.Transaction
+	days        int
+}
+
+// candidates returns the transactions that have a matching posting for
+// every posting of txn with an amount, with their distance in days, in
+// date and then ledger order.
+func (d *Deduplicator) candidates(txn *ast.Transaction) []candidate {
+	var result []candidate
+	matched := false
+	date := txn.Date().Time
+	for _, posting := range txn.Postings {
+		number, currency, ok := postingAmount(posting)
+		if !ok {
+			continue
+		}
+
+		var allowed map[*ast.Transaction]bool
+		if matched {
+			allowed = make(map[*ast.Transaction]bool, len(result))
+			for _, c := range result {
+				allowed[c.transaction] = true
+			}
+		}
+
+		var matches []candidate
+		seen := make(map[*ast.Transaction]bool)
+		postings := d.postings[posting.Account]
+		from := date.AddDate(0, 0, -d.window)
+		to := date.AddDate(0, 0, d.window)
+		start, _ := slices.BinarySearchFunc(postings, from, func(p indexedPosting, t time.Time) int {
+			return p.date.Compare(t)
+		})
+		for _, existing := range postings[start:] {
+			if existing.date.After(to) {
+				break
+			}
+			if existing.currency != currency || !existing.number.Equal(number) {
+				continue
+			}
+			if seen[existing.transaction] || (allowed != nil && !allowed[existing.transaction]) {
+				continue
+			}
+			seen[existing.transaction] = true
+			matches = append(matches, candidate{existing.transaction, daysBetween(date, existing.date)})
+		}
+		result, matched = matches, true
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+// score rates how likely txn duplicates existing, from 0 to 1.
+func (d *Deduplicator) score(txn, existing *ast.Transaction, days int) float64 {
+	dateScore := 1 - float64(days)/float64(d.window+1)
+	return dateScore/2 + description(txn, existing)/2
+}
+
+// description returns the best similarity between the payee and narration
+// of txn and those of existing, comparing across fields because importers
+// and people rarely agree on which one holds the counterparty.
+func description(txn, existing *ast.Transaction) float64 {
+	texts := func(t *ast.Transaction) []string {
+		var out []string
+		for _, s := range []string{t.Payee.Value, t.Narration.Value, t.Payee.Value + " " + t.Narration.Value} {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+
+	best := 0.0
+	for _, a := range texts(txn) {
+		for _, b := range texts(existing) {
+			best = max(best, similarity(a, b))
+		}
+	}
+	return best
+}
+
+// similarity returns the Dice coefficient of the letter pairs of a and b,
+// ignoring case, punctuation and digits, which banks add as references.
+func similarity(a, b string) float64 {
+	pa, pb := bigrams(a), bigrams(b)
+	if len(pa) == 0 || len(pb) == 0 {
+		return 0
+	}
+	total := len(pa) + len(pb)
+	shared := 0
+	for _, pair := range pa {
+		if idx := slices.Index(pb, pair); idx >= 0 {
+			pb = slices.Delete(pb, idx, idx+1)
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(total)
+}
+
+func bigrams(s string) []string {
+	var pairs []string
+	for _, word := range words(s) {
+		runes := []rune(word)
+		if len(runes) == 1 {
+			pairs = append(pairs, word)
+		}
+		for i := 0; i+1 < len(runes); i++ {
+			pairs = append(pairs, string(runes[i:i+2]))
+		}
+	}
+	return pairs
+}
+
+func (d *Deduplicator) add(txn *ast.Transaction, posting *ast.Posting) {
+	if number, currency, ok := postingAmount(posting); ok {
+		d.postings[posting.Account] = append(d.postings[posting.Account], indexedPosting{
+			date: txn.Date().Time, number: number, currency: currency, transaction: txn,
+		})
+	}
+}
+
+// addFitid indexes txn by its fitid metadata, if it has one.
+func (d *Deduplicator) addFitid(txn *ast.Transaction) {
+	if id := metadataString(txn, "fitid"); id != "" {
+		d.fitids[id] = append(d.fitids[id], txn)
+	}
+}
+
+// insert adds a posting keeping the account's postings sorted by date.
+func (d *Deduplicator) insert(txn *ast.Transaction, posting *ast.Posting) {
+	number, currency, ok := postingAmount(posting)
+	if !ok {
+		return
+	}
+	postings := d.postings[posting.Account]
+	idx, _ := slices.BinarySearchFunc(postings, txn.Date().Time, func(p indexedPosting, t time.Time) int {
+		if p.date.After(t) {
+			return 1
+		}
+		return -1
+	})
+	d.postings[posting.Account] = slices.Insert(postings, idx, indexedPosting{
+		date: txn.Date().Time, number: number, currency: currency, transaction: txn,
+	})
+}
+
+func postingAmount(posting *ast.Posting) (decimal.Decimal, string, bool) {
+	if posting.Amount == nil || posting.Amount.Value == "" {
+		return decimal.Zero, "", false
+	}
+	number, err := decimal.NewFromString(posting.Amount.Value)
+	if err != nil {
+		return decimal.Zero, "", false
+	}
+	return number, posting.Amount.Currency, true
+}
+
+func metadataString(txn *ast.Transaction, key string) string {
+	for _, meta := range txn.Metadata {
+		if meta.Key == key && meta.Value != nil && meta.Value.StringValue != nil {
+			return meta.Value.StringValue.Value
+		}
+	}
+	return ""
+}
+
+func daysBetween(a, b time.Time) int {
+	days := int(a.Sub(b).Hours() / 24)
+	if days < 0 {
+		return -days
+	}
+	return days
+}
@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const dedupLedger = `2024-01-01 open Assets:Checking USD
+2024-01-01 open Expenses:Food
+2024-01-01 open Expenses:Utilities
+2024-01-01 open Income:Salary
+
+2024-01-03 * "Grocery store" "Weekly shop"
+  Assets:Checking  -42.10 USD
+  Expenses:Food
+
+2024-01-10 * "City Water"
+  fitid: "2024011001"
+  Assets:Checking  -25.00 USD
+  Expenses:Utilities
+
+2024-01-15 * "Coffee"
+  Assets:Checking  -4.50 USD
+  Expenses:Food
+`
+
+func importedTransaction(t *testing.T, date, payee, narration, amount string, metadata ...*ast.Metadata) *ast.Transaction {
+	t.Helper()
+	d, err := ast.NewDate(date)
+	assert.NoError(t, err)
+	opts := []ast.TransactionOption{
+		ast.WithFlag("*"),
+		ast.WithPostings(ast.NewPosting("Assets:Checking", ast.WithAmount(amount, "USD"))),
+		ast.WithTransactionMetadata(metadata...),
+	}
+	if payee != "" {
+		opts = append(opts, ast.WithPayee(payee))
+	}
+	return ast.NewTransaction(d, narration, opts...)
+}
+
+func TestDeduplicatorMatch(t *testing.T) {
+	dedup := NewDeduplicator(processLedger(t, dedupLedger))
+
+	tests := []struct {
+		name      string
+		txn       *ast.Transaction
+		duplicate bool
+	}{
+		{"SameDayDifferentDescription", importedTransaction(t, "2024-01-03", "", "POS 4411 FRESHMART", "-42.10"), true},
+		{"DaysApartSimilarDescription", importedTransaction(t, "2024-01-05", "GROCERY STORE #12", "", "-42.10"), true},
+		{"DaysApartOtherDescription", importedTransaction(t, "2024-01-05", "", "POS 4411 FRESHMART", "-42.10"), false},
+		{"OutsideWindow", importedTransaction(t, "2024-01-08", "Grocery store", "Weekly shop", "-42.10"), false},
+		{"OtherAmount", importedTransaction(t, "2024-01-03", "Grocery store", "Weekly shop", "-42.00"), false},
+		{"SameFITID", importedTransaction(t, "2024-01-13", "", "DIRECT DEBIT", "-25.00", ast.NewMetadata("fitid", "2024011001")), true},
+		{"SameFITIDOtherAmountOutsideWindow", importedTransaction(t, "2024-01-20", "", "DIRECT DEBIT", "-25.50", ast.NewMetadata("fitid", "2024011001")), true},
+		{"OtherFITID", importedTransaction(t, "2024-01-20", "", "DIRECT DEBIT", "-25.50", ast.NewMetadata("fitid", "2024012001")), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.duplicate, dedup.Match(test.txn) != nil)
+		})
+	}
+}
+
+func TestDeduplicatorMarkDuplicates(t *testing.T) {
+	dedup := NewDeduplicator(processLedger(t, dedupLedger), WithDateWindow(1))
+
+	// Two coffees on a day only one was recorded for, and a statement
+	// overlapping the one imported before it.
+	directives := []ast.Directive{
+		importedTransaction(t, "2024-01-15", "", "COFFEE BAR", "-4.50"),
+		importedTransaction(t, "2024-01-15", "", "COFFEE BAR", "-4.50"),
+		importedTransaction(t, "2024-01-20", "", "BOOKSHOP", "-12.00"),
+		importedTransaction(t, "2024-01-20", "", "BOOKSHOP", "-12.00"),
+	}
+	directives = append(directives, ast.NewBalance(directives[3].Date(), "Assets:Checking", ast.NewAmount("100.00", "USD")))
+
+	assert.Equal(t, 2, dedup.MarkDuplicates(directives))
+	assert.True(t, IsDuplicate(directives[0].(*ast.Transaction)))
+	assert.False(t, IsDuplicate(directives[1].(*ast.Transaction)))
+	assert.False(t, IsDuplicate(directives[2].(*ast.Transaction)))
+	assert.True(t, IsDuplicate(directives[3].(*ast.Transaction)))
+
+	// Marking again leaves marked transactions alone.
+	assert.Equal(t, 0, dedup.MarkDuplicates(directives[:1]))
+}
+
+func TestDeduplicatorMatchTies(t *testing.T) {
+	source := `2024-01-01 open Assets:Checking USD
+2024-01-01 open Expenses:Food
+
+2024-01-14 * "Coffee"
+  ref: "earlier"
+  Assets:Checking  -4.50 USD
+  Expenses:Food
+
+2024-01-15 * "Coffee"
+  ref: "first"
+  Assets:Checking  -4.50 USD
+  Expenses:Food
+
+2024-01-15 * "Coffee"
+  ref: "second"
+  Assets:Checking  -4.50 USD
+  Expenses:Food
+
+2024-01-16 * "Coffee"
+  ref: "later"
+  Assets:Checking  -4.50 USD
+  Expenses:Food
+`
+	l := processLedger(t, source)
+
+	// Equally scoring candidates are taken in ledger order, however the
+	// accounts and transactions happen to be indexed.
+	for range 20 {
+		dedup := NewDeduplicator(l, WithThreshold(0))
+		var refs []string
+		for range 4 {
+			existing := dedup.Match(importedTransaction(t, "2024-01-15", "", "Coffee", "-4.50"))
+			dedup.used[existing] = true
+			refs = append(refs, metadataString(existing, "ref"))
+		}
+		assert.Equal(t, []string{"first", "second", "earlier", "later"}, refs)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, similarity("Grocery Store", "GROCERY STORE #12"))
+	assert.Equal(t, 0.0, similarity("Coffee", "Bookshop"))
+	assert.Equal(t, 0.0, similarity("", "Coffee"))
+	assert.True(t, similarity("Amazon Marketplace", "AMAZON MKTPLACE") > 0.7)
+}
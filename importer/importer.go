@@ -0,0 +1,238 @@
+// Package importer turns downloaded statements into Beancount directives,
+// like beancount v2's ingest framework.
+//
+// An Importer recognizes one kind of file, such as the CSV export of a
+// particular bank account, and extracts transactions and balance assertions
+// from it. It also names the account and date a file belongs to, so
+// statements can be filed away next to the ledger.
+//
+// Importers are usually configured rather than written. Register makes an
+// importer type available under a name, and LoadConfig builds importers from
+// a JSON document listing them by type:
+//
+//	{
+//	  "importers": [
+//	    {"type": "csv", "account": "Assets:Checking", "currency": "USD",
+//	     "match": "checking-*.csv",
+//	     "columns": {"date": "Date", "payee": "Payee", "amount": "Amount"}},
+//	    {"type": "ofx", "account": "Liabilities:CreditCard", "account_id": "4111"}
+//	  ]
+//	}
+//
+// The csv and ofx types are built in.
+//
+// Example usage:
+//
+//	importers, err := importer.LoadConfig(configFile)
+//	results, err := importer.Run(ctx, importers, []string{"downloads"})
+//	for _, result := range results {
+//	    err := formatter.New().FormatDirectives(result.Directives, os.Stdout)
+//	}
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+// Importer recognizes and extracts one kind of file.
+type Importer interface {
+	// Name identifies the importer in messages.
+	Name() string
+
+	// Identify reports whether the importer handles the file.
+	Identify(f *File) bool
+
+	// Extract returns the directives found in the file, sorted by date.
+	// Transactions carry a single posting to the file's account; the
+	// balancing posting is left to the user or a categorizer.
+	Extract(ctx context.Context, f *File) ([]ast.Directive, error)
+
+	// Account returns the account the file belongs to.
+	Account(f *File) (ast.Account, error)
+
+	// Date returns the date of the statement, such as its closing date.
+	Date(f *File) (*ast.Date, error)
+}
+
+// File is an input file with its contents read once and shared by every
+// importer asked to identify it.
+type File struct {
+	Path     string
+	Contents []byte
+}
+
+// ReadFile reads the file at path.
+func ReadFile(path string) (*File, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: path, Contents: contents}, nil
+}
+
+// Factory builds an importer from its JSON configuration.
+type Factory func(config json.RawMessage) (Importer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes an importer type available to LoadConfig under the given
+// name. It panics if the name is empty, the factory is nil, or the name is
+// already registered, like database/sql.Register.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" {
+		panic("importer: Register called with empty name")
+	}
+	if factory == nil {
+		panic("importer: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("importer: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Registered returns the names of all registered importer types, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// LoadConfig builds the importers listed in a JSON configuration, in order.
+func LoadConfig(r io.Reader) ([]Importer, error) {
+	var config struct {
+		Importers []json.RawMessage `json:"importers"`
+	}
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid importer configuration: %w", err)
+	}
+
+	importers := make([]Importer, 0, len(config.Importers))
+	for i, raw := range config.Importers {
+		var entry struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, fmt.Errorf("importer %d: %w", i+1, err)
+		}
+
+		registryMu.RLock()
+		factory, ok := registry[entry.Type]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("importer %d: unknown type %q", i+1, entry.Type)
+		}
+
+		imp, err := factory(raw)
+		if err != nil {
+			return nil, fmt.Errorf("importer %d (%s): %w", i+1, entry.Type, err)
+		}
+		importers = append(importers, imp)
+	}
+	return importers, nil
+}
+
+// Result is the outcome of importing one file.
+type Result struct {
+	File       *File
+	Importer   Importer
+	Account    ast.Account
+	Date       *ast.Date
+	Directives []ast.Directive
+	Err        error // Extraction failure; the other results are still valid
+}
+
+// Identify returns the first importer that handles f, or nil.
+func Identify(importers []Importer, f *File) Importer {
+	for _, imp := range importers {
+		if imp.Identify(f) {
+			return imp
+		}
+	}
+	return nil
+}
+
+// Run imports every file under paths, walking directories recursively in
+// lexical order. Files no importer identifies are skipped. A file that
+// fails to extract is reported in its Result; I/O errors stop the run.
+func Run(ctx context.Context, importers []Importer, paths []string) ([]*Result, error) {
+	var results []*Result
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			f, err := ReadFile(path)
+			if err != nil {
+				return err
+			}
+			imp := Identify(importers, f)
+			if imp == nil {
+				return nil
+			}
+			results = append(results, extract(ctx, imp, f))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func extract(ctx context.Context, imp Importer, f *File) *Result {
+	result := &Result{File: f, Importer: imp}
+	if result.Directives, result.Err = imp.Extract(ctx, f); result.Err != nil {
+		return result
+	}
+	if result.Account, result.Err = imp.Account(f); result.Err != nil {
+		return result
+	}
+	result.Date, result.Err = imp.Date(f)
+	return result
+}
+
+// latestDate returns the date of the latest transaction, or nil.
+func latestDate(directives []ast.Directive) *ast.Date {
+	var latest *ast.Date
+	for _, directive := range directives {
+		if _, ok := directive.(*ast.Transaction); !ok {
+			continue
+		}
+		if date := directive.Date(); latest == nil || date.After(latest.Time) {
+			latest = date
+		}
+	}
+	return latest
+}
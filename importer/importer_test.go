@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+// processLedger parses and processes source into a ledger.
+func processLedger(t *testing.T, source string) *ledger.Ledger {
+	t.Helper()
+	l := ledger.New()
+	assert.NoError(t, l.Process(context.Background(), parser.MustParseString(context.Background(), source)))
+	return l
+}
+
+func TestRegistered(t *testing.T) {
+	assert.Equal(t, []string{"csv", "ofx"}, Registered())
+}
+
+func TestRegisterPanics(t *testing.T) {
+	factory := func(json.RawMessage) (Importer, error) { return nil, nil }
+	assert.Panics(t, func() { Register("", factory) })
+	assert.Panics(t, func() { Register("nil", nil) })
+	assert.Panics(t, func() { Register("csv", factory) })
+}
+
+func TestLoadConfig(t *testing.T) {
+	importers, err := LoadConfig(strings.NewReader(`{"importers": [
+		{"type": "csv", "account": "Assets:Checking", "currency": "USD",
+		 "columns": {"date": "Date", "amount": "Amount"}},
+		{"type": "ofx", "account": "Liabilities:CreditCard", "account_id": "4111"}
+	]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(importers))
+	assert.Equal(t, "csv Assets:Checking", importers[0].Name())
+	assert.Equal(t, "ofx Liabilities:CreditCard", importers[1].Name())
+
+	_, err = LoadConfig(strings.NewReader(`{"importers": [{"type": "qif"}]}`))
+	assert.EqualError(t, err, `importer 1: unknown type "qif"`)
+
+	_, err = LoadConfig(strings.NewReader(`{"importers": [{"type": "csv", "account": "Assets:Checking"}]}`))
+	assert.EqualError(t, err, "importer 1 (csv): currency or a currency column is required")
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+	writeFile("notes.txt", "not a statement")
+	writeFile("checking/2024-01.csv", "Date,Amount\n2024-01-03,-42.10\n2024-01-05,1000.00\n")
+	writeFile("checking/2024-02.csv", "Date,Amount\n2024-02-30,1.00\n")
+
+	csv, err := NewCSV(CSVConfig{
+		Account:  "Assets:Checking",
+		Currency: "USD",
+		Columns:  CSVColumns{Date: "Date", Amount: "Amount"},
+	})
+	assert.NoError(t, err)
+
+	results, err := Run(context.Background(), []Importer{csv}, []string{dir})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(results))
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, filepath.Join(dir, "checking", "2024-01.csv"), results[0].File.Path)
+	assert.Equal(t, "Assets:Checking", string(results[0].Account))
+	assert.Equal(t, "2024-01-05", results[0].Date.Format("2006-01-02"))
+	assert.Equal(t, 2, len(results[0].Directives))
+
+	assert.Error(t, results[1].Err)
+	assert.Contains(t, results[1].Err.Error(), "2024-02.csv:2: invalid date")
+}
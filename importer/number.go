@@ -0,0 +1,51 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// parseNumber parses an amount as banks write it. decimalSeparator is "."
+// or ","; the other one, spaces and apostrophes are taken as thousands
+// separators. Currency symbols are ignored, and a leading or trailing minus
+// sign or enclosing parentheses make the number negative.
+func parseNumber(s, decimalSeparator string) (decimal.Decimal, error) {
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+
+	var b strings.Builder
+	negative := false
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case string(r) == decimalSeparator:
+			b.WriteByte('.')
+		case r == '-' || r == '(' || r == '−':
+			negative = !negative
+		}
+	}
+	if b.Len() == 0 {
+		return decimal.Zero, fmt.Errorf("invalid number %q", s)
+	}
+
+	number, err := decimal.NewFromString(b.String())
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid number %q", s)
+	}
+	if negative {
+		number = number.Neg()
+	}
+	return number, nil
+}
+
+// formatNumber renders number with the precision it was written with.
+func formatNumber(number decimal.Decimal) string {
+	if exp := number.Exponent(); exp < 0 {
+		return number.StringFixed(-exp)
+	}
+	return number.String()
+}
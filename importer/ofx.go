@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+func init() {
+	Register("ofx", func(config json.RawMessage) (Importer, error) {
+		var cfg OFXConfig
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, err
+		}
+		return NewOFX(cfg)
+	})
+}
+
+// OFXConfig configures an OFX importer.
+type OFXConfig struct {
+	// Account receives the postings and files the statements.
+	Account string `json:"account"`
+	// AccountID is matched against the statement's ACCTID, so several
+	// accounts at one bank can be told apart. Empty matches any statement;
+	// otherwise a suffix such as the last four digits is enough.
+	AccountID string `json:"account_id"`
+	// Currency overrides the statement's CURDEF.
+	Currency string `json:"currency"`
+	// Flag marks the imported transactions; "*" by default.
+	Flag string `json:"flag"`
+}
+
+// OFX imports Open Financial Exchange statements, including Quicken's QFX
+// variant, in both the SGML (1.x) and XML (2.x) syntax.
+type OFX struct {
+	config  OFXConfig
+	account ast.Account
+}
+
+var _ Importer = &OFX{}
+
+// NewOFX creates an OFX importer, validating its configuration.
+func NewOFX(config OFXConfig) (*OFX, error) {
+	account, err := ast.NewAccount(config.Account)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account: %w", err)
+	}
+	if config.Flag == "" {
+		config.Flag = "*"
+	}
+	return &OFX{config: config, account: account}, nil
+}
+
+// Name implements Importer.
+func (o *OFX) Name() string {
+	return "ofx " + string(o.account)
+}
+
+// Identify implements Importer. The file must be an OFX document for the
+// configured account ID.
+func (o *OFX) Identify(f *File) bool {
+	ext := strings.ToLower(filepath.Ext(f.Path))
+	if ext != ".ofx" && ext != ".qfx" && !bytes.Contains(bytes.ToUpper(f.Contents), []byte("<OFX>")) {
+		return false
+	}
+	statement := parseOFX(f.Contents)
+	if statement.accountID == "" {
+		return false
+	}
+	return strings.HasSuffix(statement.accountID, o.config.AccountID)
+}
+
+// Extract implements Importer. It returns a transaction per STMTTRN, sorted
+// by date, followed by the statement's ledger balance asserted on the day
+// after it was reported.
+func (o *OFX) Extract(ctx context.Context, f *File) ([]ast.Directive, error) {
+	statement := parseOFX(f.Contents)
+	currency := o.config.Currency
+	if currency == "" {
+		currency = statement.currency
+	}
+	if currency == "" {
+		return nil, fmt.Errorf("%s: no currency in statement or configuration", f.Path)
+	}
+
+	var directives []ast.Directive
+	for _, txn := range statement.transactions {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		date, err := parseOFXDate(txn["DTPOSTED"])
+		if err != nil {
+			return nil, fmt.Errorf("%s: transaction %s: %w", f.Path, txn["FITID"], err)
+		}
+		amount, err := parseNumber(txn["TRNAMT"], ".")
+		if err != nil {
+			return nil, fmt.Errorf("%s: transaction %s: %w", f.Path, txn["FITID"], err)
+		}
+
+		payee, narration := txn["NAME"], txn["MEMO"]
+		if narration == "" {
+			payee, narration = "", payee
+		}
+		opts := []ast.TransactionOption{
+			ast.WithFlag(o.config.Flag),
+			ast.WithPostings(ast.NewPosting(o.account, ast.WithAmount(formatNumber(amount), currency))),
+		}
+		if payee != "" {
+			opts = append(opts, ast.WithPayee(payee))
+		}
+		if fitid := txn["FITID"]; fitid != "" {
+			opts = append(opts, ast.WithTransactionMetadata(ast.NewMetadata("fitid", fitid)))
+		}
+		directives = append(directives, ast.NewTransaction(date, narration, opts...))
+	}
+	slices.SortStableFunc(directives, func(a, b ast.Directive) int {
+		return a.Date().Compare(b.Date().Time)
+	})
+
+	if statement.balance != "" && statement.balanceDate != "" {
+		date, err := parseOFXDate(statement.balanceDate)
+		if err != nil {
+			return nil, fmt.Errorf("%s: ledger balance: %w", f.Path, err)
+		}
+		amount, err := parseNumber(statement.balance, ".")
+		if err != nil {
+			return nil, fmt.Errorf("%s: ledger balance: %w", f.Path, err)
+		}
+		date = ast.NewDateFromTime(date.AddDate(0, 0, 1))
+		directives = append(directives, ast.NewBalance(date, o.account, ast.NewAmount(formatNumber(amount), currency)))
+	}
+	return directives, nil
+}
+
+// Account implements Importer.
+func (o *OFX) Account(f *File) (ast.Account, error) {
+	return o.account, nil
+}
+
+// Date implements Importer. A statement is dated by the end of the period it
+// covers, or its latest transaction when it does not say.
+func (o *OFX) Date(f *File) (*ast.Date, error) {
+	statement := parseOFX(f.Contents)
+	if statement.end != "" {
+		return parseOFXDate(statement.end)
+	}
+	directives, err := o.Extract(context.Background(), f)
+	if err != nil {
+		return nil, err
+	}
+	if date := latestDate(directives); date != nil {
+		return date, nil
+	}
+	return nil, fmt.Errorf("%s: no statement date", f.Path)
+}
+
+// ofxStatement holds the parts of an OFX document the importer uses.
+type ofxStatement struct {
+	accountID    string
+	currency     string
+	end          string
+	balance      string
+	balanceDate  string
+	transactions []map[string]string
+}
+
+// ofxTag matches an OFX tag with the text that follows it. SGML documents
+// leave leaf elements unclosed, so a tag followed by text is a value and a
+// tag followed directly by another tag opens or closes an aggregate.
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// parseOFX scans an OFX document. It accepts both syntaxes and ignores the
+// header and any elements it does not know.
+func parseOFX(data []byte) *ofxStatement {
+	statement := &ofxStatement{}
+	var txn map[string]string
+	var path []string
+
+	for _, match := range ofxTag.FindAllSubmatch(data, -1) {
+		closing := len(match[1]) > 0
+		name := strings.ToUpper(string(match[2]))
+		text := html.UnescapeString(strings.TrimSpace(string(match[3])))
+
+		if closing {
+			if idx := slices.Index(path, name); idx >= 0 {
+				path = path[:idx]
+			}
+			if name == "STMTTRN" && txn != nil {
+				statement.transactions = append(statement.transactions, txn)
+				txn = nil
+			}
+			continue
+		}
+		if text == "" {
+			path = append(path, name)
+			if name == "STMTTRN" {
+				txn = make(map[string]string)
+			}
+			continue
+		}
+
+		switch {
+		case txn != nil:
+			txn[name] = text
+		case name == "ACCTID" && statement.accountID == "":
+			statement.accountID = text
+		case name == "CURDEF" && statement.currency == "":
+			statement.currency = text
+		case name == "DTEND" && statement.end == "":
+			statement.end = text
+		case slices.Contains(path, "LEDGERBAL") && name == "BALAMT":
+			statement.balance = text
+		case slices.Contains(path, "LEDGERBAL") && name == "DTASOF":
+			statement.balanceDate = text
+		}
+	}
+	return statement
+}
+
+// parseOFXDate parses the date part of an OFX datetime such as
+// "20240131120000.000[-5:EST]".
+func parseOFXDate(value string) (*ast.Date, error) {
+	if len(value) < 8 {
+		return nil, fmt.Errorf("invalid date %q", value)
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q", value)
+	}
+	return ast.NewDateFromTime(t), nil
+}
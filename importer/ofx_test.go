@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKACCTFROM><BANKID>121000358<ACCTID>000012344111<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20240101
+<DTEND>20240131120000.000[-5:EST]
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240115<TRNAMT>-25.00<FITID>2024011501<NAME>CITY WATER &amp; POWER<MEMO>Utilities</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20240110<TRNAMT>1500.00<FITID>2024011001<NAME>Payroll</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>2475.00<DTASOF>20240131</LEDGERBAL>
+<AVAILBAL><BALAMT>2400.00<DTASOF>20240131</AVAILBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+    <CURDEF>EUR</CURDEF>
+    <CCACCTFROM><ACCTID>9999</ACCTID></CCACCTFROM>
+    <BANKTRANLIST>
+      <STMTTRN>
+        <DTPOSTED>20240302</DTPOSTED>
+        <TRNAMT>-4.50</TRNAMT>
+        <FITID>A1</FITID>
+        <NAME>Coffee</NAME>
+      </STMTTRN>
+    </BANKTRANLIST>
+  </CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestOFXExtractSGML(t *testing.T) {
+	imp, err := NewOFX(OFXConfig{Account: "Assets:Checking", AccountID: "4111"})
+	assert.NoError(t, err)
+
+	f := &File{Path: "statement.qfx", Contents: []byte(sgmlStatement)}
+	assert.True(t, imp.Identify(f))
+
+	directives, err := imp.Extract(context.Background(), f)
+	assert.NoError(t, err)
+	assert.Equal(t, `2024-01-10 * "Payroll"
+    fitid: "2024011001"
+    Assets:Checking                      1500.00 USD
+
+2024-01-15 * "CITY WATER & POWER" "Utilities"
+    fitid: "2024011501"
+    Assets:Checking                       -25.00 USD
+
+2024-02-01 balance Assets:Checking       2475.00 USD
+`, formatDirectives(t, directives))
+
+	date, err := imp.Date(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-31", date.Format("2006-01-02"))
+}
+
+func TestOFXExtractXML(t *testing.T) {
+	imp, err := NewOFX(OFXConfig{Account: "Liabilities:CreditCard"})
+	assert.NoError(t, err)
+
+	f := &File{Path: "download", Contents: []byte(xmlStatement)}
+	assert.True(t, imp.Identify(f))
+
+	directives, err := imp.Extract(context.Background(), f)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(directives))
+	txn := directives[0].(*ast.Transaction)
+	assert.Equal(t, "Coffee", txn.Narration.Value)
+	assert.Equal(t, "-4.50", txn.Postings[0].Amount.Value)
+	assert.Equal(t, "EUR", txn.Postings[0].Amount.Currency)
+
+	date, err := imp.Date(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-03-02", date.Format("2006-01-02"))
+}
+
+func TestOFXIdentifyMatchesAccountID(t *testing.T) {
+	imp, err := NewOFX(OFXConfig{Account: "Assets:Savings", AccountID: "5555"})
+	assert.NoError(t, err)
+
+	assert.False(t, imp.Identify(&File{Path: "statement.ofx", Contents: []byte(sgmlStatement)}))
+	assert.False(t, imp.Identify(&File{Path: "statement.csv", Contents: []byte("Date,Amount\n")}))
+}
@@ -31,6 +31,7 @@ type Account struct {
 	Metadata             []*ast.Metadata
 	Inventory            *Inventory        // Inventory with lot tracking
 	Postings             []*AccountPosting // Transaction history in chronological order
+	Disposals            []*Disposal       // Lot reductions in processing order
 }
 
 // IsOpen returns true if the account is open at the given date
@@ -125,6 +126,19 @@ func (a *Account) GetChildren(l *Ledger) []*Account {
 	return children
 }
 
+// SearchPostings returns the index of the first posting dated on or after
+// date, by binary search over the chronological history. It returns
+// len(a.Postings) when every posting is earlier.
+func (a *Account) SearchPostings(date *ast.Date) int {
+	i, _ := slices.BinarySearchFunc(a.Postings, date, func(posting *AccountPosting, date *ast.Date) int {
+		if posting.Transaction.Date().Before(date.Time) {
+			return -1
+		}
+		return 1
+	})
+	return i
+}
+
 // GetPostingsInPeriod returns postings within [start, end] inclusive.
 // When start == end, returns all postings up to and including that date (point-in-time).
 // When start < end, returns postings within the period (for income statements).
@@ -141,7 +155,13 @@ func (a *Account) GetPostingsInPeriod(start, end ast.Date) []*AccountPosting {
 		return result
 	}
 
-	// Period: return postings within [start, end]
+	return a.GetPostingsBetween(start, end)
+}
+
+// GetPostingsBetween returns postings within [start, end] inclusive, also
+// when start == end, in which case they are that day's postings.
+func (a *Account) GetPostingsBetween(start, end ast.Date) []*AccountPosting {
+	var result []*AccountPosting
 	for _, posting := range a.Postings {
 		txnDate := posting.Transaction.Date()
 		if !txnDate.Before(start.Time) && !txnDate.After(end.Time) {
@@ -155,9 +175,18 @@ func (a *Account) GetPostingsInPeriod(start, end ast.Date) []*AccountPosting {
 // When start == end, returns point-in-time balance (all postings up to that date).
 // When start < end, returns net change within the period.
 func (a *Account) GetBalanceInPeriod(start, end ast.Date) *Balance {
-	balance := NewBalance()
-	postings := a.GetPostingsInPeriod(start, end)
+	return postingsBalance(a.GetPostingsInPeriod(start, end))
+}
 
+// GetChangeInPeriod returns the net change of this account's balance within
+// [start, end] inclusive, also when start == end.
+func (a *Account) GetChangeInPeriod(start, end ast.Date) *Balance {
+	return postingsBalance(a.GetPostingsBetween(start, end))
+}
+
+// postingsBalance sums the amounts of postings per currency.
+func postingsBalance(postings []*AccountPosting) *Balance {
+	balance := NewBalance()
 	for _, posting := range postings {
 		if posting.Posting.Amount == nil {
 			continue
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
 	"github.com/robinvdvleuten/beancount/ledger"
 	"github.com/robinvdvleuten/beancount/parser"
 )
@@ -215,3 +216,44 @@ func TestGetChildren_ImplicitParent(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchPostings(t *testing.T) {
+	source := `
+		2020-01-01 open Assets:Checking
+		2020-01-01 open Income:Salary
+
+		2020-01-10 * "Salary"
+		  Assets:Checking  500.00 USD
+		  Income:Salary
+		2020-02-10 * "Salary"
+		  Assets:Checking  500.00 USD
+		  Income:Salary
+		2020-02-10 * "Bonus"
+		  Assets:Checking  100.00 USD
+		  Income:Salary
+		2020-03-10 * "Salary"
+		  Assets:Checking  500.00 USD
+		  Income:Salary
+	`
+
+	tree := parser.MustParseBytes(context.Background(), []byte(source))
+
+	l := ledger.New()
+	assert.NoError(t, l.Process(context.Background(), tree))
+
+	account, ok := l.GetAccount("Assets:Checking")
+	assert.True(t, ok)
+
+	for date, want := range map[string]int{
+		"2019-12-31": 0,
+		"2020-01-10": 0,
+		"2020-01-11": 1,
+		"2020-02-10": 1,
+		"2020-03-01": 3,
+		"2020-03-11": 4,
+	} {
+		d, err := ast.NewDate(date)
+		assert.NoError(t, err)
+		assert.Equal(t, want, account.SearchPostings(d), date)
+	}
+}
@@ -179,11 +179,17 @@ type BalanceTree struct {
 	Currencies []string
 
 	// StartDate and EndDate define the period for the balance calculation.
-	// When StartDate == EndDate, this is a point-in-time balance (balance sheet).
+	// When StartDate == EndDate, this is a point-in-time balance (balance sheet),
+	// or that day's changes when built WithPeriodChanges.
 	// When StartDate < EndDate, this is a period change (income statement).
 	// When both are nil, this represents the current inventory state.
 	StartDate *string
 	EndDate   *string
+
+	// Currency and Valuation are set on trees from GetConvertedBalanceTree:
+	// the currency balances were converted to and how they were valued.
+	Currency  string
+	Valuation Valuation
 }
 
 // BalanceNode represents a single node in the balance tree hierarchy.
@@ -205,6 +211,11 @@ type BalanceNode struct {
 	// For parent accounts and roots, this includes all children's balances.
 	Balance *Balance
 
+	// Unconverted holds the amounts of this node and its descendants that
+	// GetConvertedBalanceTree found no price for, in their own currencies.
+	// It is nil on trees that were not converted.
+	Unconverted *Balance
+
 	// Children contains direct child nodes, sorted by name.
 	Children []*BalanceNode
 }
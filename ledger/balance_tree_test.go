@@ -2,6 +2,7 @@ package ledger_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -123,6 +124,44 @@ func TestGetBalanceTree_BalanceSheet(t *testing.T) {
 	assert.True(t, equityTotal.Equal(decimal.NewFromInt(-1000)), "Equity should be -1000, got %s", equityTotal)
 }
 
+func TestGetBalanceTree_PeriodChanges(t *testing.T) {
+	// A single-day period holds that day's changes, converted or not
+	source := `
+2024-01-01 open Assets:Checking USD
+2024-01-01 open Equity:Opening USD
+2024-01-01 open Expenses:Food USD
+
+2024-01-15 * "Opening"
+  Assets:Checking  1000.00 USD
+  Equity:Opening
+
+2024-02-01 * "Groceries"
+  Expenses:Food  40.00 USD
+  Assets:Checking
+
+2024-02-01 * "Lunch"
+  Expenses:Food  10.00 USD
+  Assets:Checking
+`
+	l := processSource(t, source)
+
+	date, _ := ast.NewDate("2024-02-01")
+	balanceTree, err := l.GetBalanceTree(nil, date, date, ledger.WithPeriodChanges())
+	assert.NoError(t, err)
+	assert.Equal(t, "-50", findRoot(balanceTree, "Assets").Balance.Get("USD").String())
+	assert.Equal(t, "50", findRoot(balanceTree, "Expenses").Balance.Get("USD").String())
+	assert.Equal(t, "0", findRoot(balanceTree, "Equity").Balance.Get("USD").String())
+
+	converted, err := l.GetConvertedBalanceTree(nil, date, date, "USD", ledger.ValuationMarket, ledger.WithPeriodChanges())
+	assert.NoError(t, err)
+	assert.Equal(t, "-50", findRoot(converted, "Assets").Balance.Get("USD").String())
+
+	// Without the option the same dates give the balance on that day.
+	balanceTree, err = l.GetBalanceTree(nil, date, date)
+	assert.NoError(t, err)
+	assert.Equal(t, "950", findRoot(balanceTree, "Assets").Balance.Get("USD").String())
+}
+
 func TestGetBalanceTree_IncomeStatement(t *testing.T) {
 	// Income statement: Income and Expenses for a period
 	l := ledger.New()
@@ -398,6 +437,85 @@ func TestGetBalanceTree_ZeroBalancesIncluded(t *testing.T) {
 	assert.True(t, checkingNode.Balance.Get("USD").IsZero())
 }
 
+func TestGetConvertedBalanceTree_Valuations(t *testing.T) {
+	l := ledger.New()
+	source := `
+2024-01-01 open Assets:Cash:EUR EUR
+2024-01-01 open Assets:Cash:USD USD
+2024-01-01 open Assets:Cash:GBP GBP
+2024-01-01 open Assets:Broker:HOOL HOOL
+2024-01-01 open Equity:Opening
+
+2024-01-01 price USD 0.90 EUR
+2024-03-01 price USD 0.80 EUR
+2024-03-01 price HOOL 120.00 EUR
+
+2024-01-10 * "Opening"
+  Assets:Cash:EUR  1000.00 EUR
+  Assets:Cash:USD   100.00 USD
+  Assets:Cash:GBP    50.00 GBP
+  Equity:Opening  -1000.00 EUR
+  Equity:Opening   -100.00 USD
+  Equity:Opening    -50.00 GBP
+
+2024-02-01 * "Buy"
+  Assets:Broker:HOOL  2 HOOL {100.00 EUR}
+  Assets:Cash:EUR
+`
+	ctx := context.Background()
+	tree, err := parser.ParseBytes(ctx, []byte(source))
+	assert.NoError(t, err)
+	assert.NoError(t, l.Process(ctx, tree))
+
+	date, _ := ast.NewDate("2024-03-31")
+	types := []ast.AccountType{ast.AccountTypeAssets}
+	for _, tt := range []struct {
+		valuation   ledger.Valuation
+		total       string
+		unconverted string
+	}{
+		// 800 EUR, 100 USD at 0.80 and 2 HOOL at 120.
+		{ledger.ValuationMarket, "1120.00", "50 GBP"},
+		// 800 EUR, 100 USD at 0.90 and 2 HOOL at their cost of 100.
+		{ledger.ValuationCost, "1090.00", "50 GBP"},
+		// HOOL had no price yet when it was bought.
+		{ledger.ValuationHistorical, "890.00", "50 GBP, 2 HOOL"},
+	} {
+		balanceTree, err := l.GetConvertedBalanceTree(types, date, date, "EUR", tt.valuation)
+		assert.NoError(t, err)
+		assert.Equal(t, "EUR", balanceTree.Currency)
+		assert.Equal(t, tt.valuation, balanceTree.Valuation)
+
+		assets := findRoot(balanceTree, "Assets")
+		assert.Equal(t, tt.total, assets.Balance.Get("EUR").StringFixed(2), string(tt.valuation))
+		assert.Equal(t, []string{"EUR"}, assets.Balance.Currencies(), string(tt.valuation))
+		var residues []string
+		for _, entry := range assets.Unconverted.Entries() {
+			residues = append(residues, entry.Amount.String()+" "+entry.Currency)
+		}
+		assert.Equal(t, tt.unconverted, strings.Join(residues, ", "), string(tt.valuation))
+	}
+
+	// Residues stay on the accounts that hold them.
+	balanceTree, err := l.GetConvertedBalanceTree(types, date, date, "EUR", ledger.ValuationMarket)
+	assert.NoError(t, err)
+	cash := findRoot(balanceTree, "Assets").Children[1]
+	assert.Equal(t, "Assets:Cash", cash.Name)
+	assert.Equal(t, "80", cash.Children[2].Balance.Get("EUR").String())
+	assert.True(t, cash.Children[1].Unconverted.Get("GBP").Equal(decimal.NewFromInt(50)))
+	assert.True(t, cash.Children[0].Unconverted.IsZero())
+
+	// Plain trees carry no residues.
+	balanceTree, err = l.GetBalanceTree(types, date, date)
+	assert.NoError(t, err)
+	assert.Zero(t, findRoot(balanceTree, "Assets").Unconverted)
+}
+
+func TestGetConvertedBalanceTree_InvalidValuation(t *testing.T) {
+	_, err := ledger.New().GetConvertedBalanceTree(nil, nil, nil, "EUR", "spot")
+	assert.EqualError(t, err, `unknown valuation "spot" (expected cost, market or historical)`)
+}
+
 // Helper function to find a root node by name
 func findRoot(tree *ledger.BalanceTree, name string) *ledger.BalanceNode {
 	for _, root := range tree.Roots {
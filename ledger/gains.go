@@ -0,0 +1,196 @@
+package ledger
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+// Disposal records units of a lot held at cost that a posting reduced,
+// such as a sale, with what the lot cost and what it was sold for.
+type Disposal struct {
+	Account      ast.Account
+	Commodity    string
+	Units        decimal.Decimal // Units reduced, positive
+	Cost         decimal.Decimal // Cost per unit of the lot
+	CostCurrency string
+	// Acquired is the lot's acquisition date. It is nil when the units
+	// came from lots merged at their average cost.
+	Acquired *ast.Date
+	Date     *ast.Date // Date of the reducing transaction
+	// Price is the sale price per unit in CostCurrency, taken from the
+	// posting's price. Priced is false when the posting has none in that
+	// currency; the proceeds and gain are then unknown, and Proceeds falls
+	// back to the cost basis.
+	Price       decimal.Decimal
+	Priced      bool
+	Transaction *ast.Transaction
+	Posting     *ast.Posting
+}
+
+// CostBasis returns what the reduced units cost.
+func (d *Disposal) CostBasis() decimal.Decimal {
+	return d.Units.Mul(d.Cost)
+}
+
+// Proceeds returns what the reduced units were sold for, or the cost basis
+// when the disposal is not priced.
+func (d *Disposal) Proceeds() decimal.Decimal {
+	if !d.Priced {
+		return d.CostBasis()
+	}
+	return d.Units.Mul(d.Price)
+}
+
+// Gain returns the realized gain, negative for a loss.
+func (d *Disposal) Gain() decimal.Decimal {
+	return d.Proceeds().Sub(d.CostBasis())
+}
+
+// LongTerm reports whether the lot was held for more than a year. Units
+// without an acquisition date count as short-term.
+func (d *Disposal) LongTerm() bool {
+	return d.Acquired != nil && d.Date.After(d.Acquired.AddDate(1, 0, 0))
+}
+
+// newDisposals records the reductions a posting made to lots held at cost.
+func newDisposals(account *Account, txn *ast.Transaction, posting *ast.Posting, reductions []lotReduction) []*Disposal {
+	var disposals []*Disposal
+	for _, reduction := range reductions {
+		spec := reduction.lot.Spec
+		if spec == nil || spec.Cost == nil || spec.CostCurrency == "" {
+			continue
+		}
+		disposal := &Disposal{
+			Account:      account.Name,
+			Commodity:    reduction.lot.Commodity,
+			Units:        reduction.amount,
+			Cost:         *spec.Cost,
+			CostCurrency: spec.CostCurrency,
+			Acquired:     spec.Date,
+			Date:         txn.Date(),
+			Transaction:  txn,
+			Posting:      posting,
+		}
+		disposal.Price, disposal.Priced = postingUnitPrice(posting, spec.CostCurrency)
+		disposals = append(disposals, disposal)
+	}
+	return disposals
+}
+
+// postingUnitPrice returns the per-unit price of a posting when it is
+// stated in currency.
+func postingUnitPrice(posting *ast.Posting, currency string) (decimal.Decimal, bool) {
+	if posting.Price == nil || posting.Price.Currency != currency {
+		return decimal.Zero, false
+	}
+	price, err := ParseAmount(posting.Price)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	if posting.PriceTotal {
+		units, err := ParseAmount(posting.Amount)
+		if err != nil || units.IsZero() {
+			return decimal.Zero, false
+		}
+		price = price.Div(units.Abs())
+	}
+	return price, true
+}
+
+// RealizedGains returns the disposals dated from start to end inclusive,
+// either of which may be nil for no bound, sorted by date and account.
+// Disposals whose posting has no price are priced from the transaction's
+// other legs, as the sellgains plugin does, or else from the ledger's
+// prices on their date. Those that still have no price are left unpriced.
+func (l *Ledger) RealizedGains(start, end *ast.Date) []*Disposal {
+	var disposals []*Disposal
+	transactions := make(map[*ast.Transaction][]*Disposal)
+	l.forEachAccount(func(account *Account) bool {
+		for _, disposal := range account.Disposals {
+			if start != nil && disposal.Date.Before(start.Time) {
+				continue
+			}
+			if end != nil && disposal.Date.After(end.Time) {
+				continue
+			}
+			if !disposal.Priced {
+				priced := *disposal
+				disposal = &priced
+			}
+			disposals = append(disposals, disposal)
+			transactions[disposal.Transaction] = append(transactions[disposal.Transaction], disposal)
+		}
+		return true
+	})
+	for txn, group := range transactions {
+		if price, ok := l.proceedsPrice(txn, group); ok {
+			for _, disposal := range group {
+				disposal.Price, disposal.Priced = price, true
+			}
+			continue
+		}
+		for _, disposal := range group {
+			if disposal.Priced {
+				continue
+			}
+			if price, ok := l.GetPrice(disposal.Date, disposal.Commodity, disposal.CostCurrency); ok {
+				disposal.Price, disposal.Priced = price, true
+			}
+		}
+	}
+	slices.SortStableFunc(disposals, func(a, b *Disposal) int {
+		return cmp.Or(
+			a.Date.Compare(b.Date.Time),
+			cmp.Compare(a.Account, b.Account),
+		)
+	})
+	return disposals
+}
+
+// proceedsPrice works out the per-unit sale price of a transaction's
+// disposals from its legs that are neither held at cost nor income, the
+// proceeds as the sellgains plugin counts them. It fails unless none of the
+// disposals has a price of its own, they share one commodity and cost
+// currency, and every proceeds leg weighs in that currency.
+func (l *Ledger) proceedsPrice(txn *ast.Transaction, disposals []*Disposal) (decimal.Decimal, bool) {
+	commodity, currency := disposals[0].Commodity, disposals[0].CostCurrency
+	units := decimal.Zero
+	for _, disposal := range disposals {
+		if disposal.Priced || disposal.Commodity != commodity || disposal.CostCurrency != currency {
+			return decimal.Zero, false
+		}
+		units = units.Add(disposal.Units)
+	}
+
+	proceeds := decimal.Zero
+	legs := 0
+	for _, posting := range txn.Postings {
+		if posting.Cost != nil || posting.Account.Root() == l.config.AccountNames.Income {
+			continue
+		}
+		amount, err := ParseAmount(posting.Amount)
+		if err != nil {
+			return decimal.Zero, false
+		}
+		legCurrency := posting.Amount.Currency
+		if posting.Price != nil {
+			price, ok := postingUnitPrice(posting, posting.Price.Currency)
+			if !ok {
+				return decimal.Zero, false
+			}
+			amount, legCurrency = amount.Mul(price), posting.Price.Currency
+		}
+		if legCurrency != currency {
+			return decimal.Zero, false
+		}
+		proceeds = proceeds.Add(amount)
+		legs++
+	}
+	if legs == 0 || units.IsZero() {
+		return decimal.Zero, false
+	}
+	return proceeds.Div(units), true
+}
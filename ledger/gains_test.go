@@ -0,0 +1,169 @@
+package ledger_test
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+)
+
+const gainsSource = `
+2014-01-01 open Assets:Checking USD
+2014-01-01 open Assets:Invest HOOL "FIFO"
+2014-01-01 open Assets:Roth HOOL
+2014-01-01 open Equity:Opening USD
+2014-01-01 open Income:Gains USD
+
+2014-01-02 * "Opening"
+  Assets:Checking  20000.00 USD
+  Equity:Opening
+
+2014-03-01 * "Buy HOOL"
+  Assets:Invest  10 HOOL {500.00 USD}
+  Assets:Checking
+
+2014-06-01 * "Buy HOOL"
+  Assets:Invest  5 HOOL {520.00 USD}
+  Assets:Checking
+
+2014-07-01 * "Buy HOOL"
+  Assets:Roth  4 HOOL {510.00 USD}
+  Assets:Checking
+
+2015-05-01 * "Sell HOOL"
+  Assets:Invest  -12 HOOL {} @ 600.00 USD
+  Assets:Checking  7200.00 USD
+  Income:Gains
+
+2015-07-01 price HOOL 650.00 USD
+
+2015-08-01 * "Sell HOOL without a price"
+  Assets:Invest  -1 HOOL {}
+  Assets:Checking  650.00 USD
+  Income:Gains
+
+2016-02-01 * "Sell HOOL"
+  Assets:Roth  -4 HOOL {510.00 USD} @@ 2000.00 USD
+  Assets:Checking  2000.00 USD
+  Income:Gains
+`
+
+// disposalRow summarises a disposal for comparison.
+func disposalRow(d *ledger.Disposal) []string {
+	acquired := ""
+	if d.Acquired != nil {
+		acquired = d.Acquired.String()
+	}
+	term := "short"
+	if d.LongTerm() {
+		term = "long"
+	}
+	return []string{
+		d.Date.String(), string(d.Account), d.Units.String() + " " + d.Commodity, acquired,
+		d.CostBasis().String(), d.Proceeds().String(), d.Gain().String(), term,
+	}
+}
+
+func TestRealizedGains(t *testing.T) {
+	l := processSource(t, gainsSource)
+
+	var rows [][]string
+	for _, d := range l.RealizedGains(nil, nil) {
+		rows = append(rows, disposalRow(d))
+	}
+	assert.Equal(t, [][]string{
+		{"2015-05-01", "Assets:Invest", "10 HOOL", "2014-03-01", "5000", "6000", "1000", "long"},
+		{"2015-05-01", "Assets:Invest", "2 HOOL", "2014-06-01", "1040", "1200", "160", "short"},
+		{"2015-08-01", "Assets:Invest", "1 HOOL", "2014-06-01", "520", "650", "130", "long"},
+		{"2016-02-01", "Assets:Roth", "4 HOOL", "2014-07-01", "2040", "2000", "-40", "long"},
+	}, rows)
+
+	start, _ := ast.NewDate("2015-01-01")
+	end, _ := ast.NewDate("2015-12-31")
+	disposals := l.RealizedGains(start, end)
+	assert.Equal(t, 3, len(disposals))
+	assert.Zero(t, disposals[2].Posting.Price)
+	assert.True(t, disposals[2].Priced)
+
+	// The recorded disposal itself keeps no price of its own.
+	invest := l.Accounts()["Assets:Invest"]
+	assert.Equal(t, 3, len(invest.Disposals))
+	assert.False(t, invest.Disposals[2].Priced)
+	assert.Equal(t, "520", invest.Disposals[2].Proceeds().String())
+}
+
+func TestRealizedGainsAverageCost(t *testing.T) {
+	source := `
+2014-01-01 open Assets:Checking USD
+2014-01-01 open Assets:Invest HOOL
+2014-01-01 open Equity:Opening USD
+2014-01-01 open Income:Gains USD
+
+2014-01-02 * "Opening"
+  Assets:Checking  20000.00 USD
+  Equity:Opening
+
+2014-03-01 * "Buy HOOL"
+  Assets:Invest  10 HOOL {500.00 USD}
+  Assets:Checking
+
+2014-06-01 * "Buy HOOL"
+  Assets:Invest  10 HOOL {600.00 USD}
+  Assets:Checking
+
+2014-09-01 * "Sell HOOL"
+  Assets:Invest  -5 HOOL {*} @ 700.00 USD
+  Assets:Checking  3500.00 USD
+  Income:Gains
+`
+	l := processSource(t, source)
+
+	var rows [][]string
+	for _, d := range l.RealizedGains(nil, nil) {
+		rows = append(rows, disposalRow(d))
+	}
+	assert.Equal(t, [][]string{
+		{"2014-09-01", "Assets:Invest", "5 HOOL", "", "2750", "3500", "750", "short"},
+	}, rows)
+}
+
+func TestRealizedGainsFromProceeds(t *testing.T) {
+	source := `
+2014-01-01 open Assets:Checking USD
+2014-01-01 open Assets:Invest
+2014-01-01 open Equity:Opening USD
+2014-01-01 open Expenses:Fees USD
+2014-01-01 open Income:Gains USD
+
+2014-01-02 * "Opening"
+  Assets:Checking  20000.00 USD
+  Equity:Opening
+
+2014-03-01 * "Buy HOOL"
+  Assets:Invest  10 HOOL {500.00 USD}
+  Assets:Checking
+
+2014-06-01 * "Swap HOOL for GOOG"
+  Assets:Invest  -2 HOOL {}
+  Assets:Invest  1 GOOG {1000.00 USD}
+
+2014-09-01 price HOOL 800.00 USD
+
+2014-09-01 * "Sell HOOL"
+  Assets:Invest  -4 HOOL {}
+  Assets:Checking  2790.00 USD
+  Expenses:Fees  10.00 USD
+  Income:Gains
+`
+	l := processSource(t, source)
+
+	// The swap has no proceeds leg nor price, so it stays unpriced. The
+	// sale is priced from its cash and fee legs, not the price entry.
+	disposals := l.RealizedGains(nil, nil)
+	assert.Equal(t, 2, len(disposals))
+	assert.False(t, disposals[0].Priced)
+	assert.True(t, disposals[1].Priced)
+	assert.Equal(t, "2800", disposals[1].Proceeds().String())
+	assert.Equal(t, "800", disposals[1].Gain().String())
+}
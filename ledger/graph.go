@@ -193,6 +193,20 @@ func (g *Graph) GetPriceEdgesOnDate(date *ast.Date) []*Edge {
 	return result
 }
 
+// PriceEdges returns the price edges declared by price directives in
+// chronological order, leaving out the inferred inverse edges.
+func (g *Graph) PriceEdges() []*Edge {
+	var result []*Edge
+	for _, date := range g.sortedDates {
+		for _, edge := range g.priceEdgesByDate[date.Time] {
+			if !edge.Inferred {
+				result = append(result, edge)
+			}
+		}
+	}
+	return result
+}
+
 // FindPath performs breadth-first search to find a path from source to target node.
 // Used for currency conversion pathfinding (e.g., USD→EUR→GBP).
 //
@@ -131,6 +131,21 @@ func TestGraph_GetOutgoingEdges(t *testing.T) {
 	assert.Equal(t, len(missing), 0)
 }
 
+func TestGraph_PriceEdges(t *testing.T) {
+	g := NewGraph()
+
+	later := &Edge{From: "HOOL", To: "USD", Kind: EdgePrice, Date: newTestDate("2024-02-01"), Weight: mustParseDec("520")}
+	earlier := &Edge{From: "EUR", To: "USD", Kind: EdgePrice, Date: newTestDate("2024-01-15"), Weight: mustParseDec("1.08")}
+	inverse := &Edge{From: "USD", To: "EUR", Kind: EdgePrice, Date: newTestDate("2024-01-15"), Weight: mustParseDec("0.92"), Inferred: true}
+
+	g.AddEdge(later)
+	g.AddEdge(earlier)
+	g.AddEdge(inverse)
+
+	// Declared edges come back in chronological order, without inverses
+	assert.Equal(t, g.PriceEdges(), []*Edge{earlier, later})
+}
+
 func TestGraph_FindPath_Direct(t *testing.T) {
 	g := NewGraph()
 	date := newTestDate("2024-01-15")
@@ -520,6 +520,12 @@ func TestCommodityIntegrationWithOtherDirectives(t *testing.T) {
 	assert.NotZero(t, commodityNode, "commodity node should exist")
 	assert.Equal(t, commodityNode.Kind, "commodity", "commodity node kind should be correct")
 
+	// Verify the declaration's metadata replaced the implicit currency node's
+	commodityMeta, ok := commodityNode.Meta.(*CommodityNode)
+	assert.True(t, ok, "commodity node should carry the declaration")
+	assert.Equal(t, len(commodityMeta.Metadata), 1)
+	assert.Equal(t, commodityMeta.Metadata[0].Key, "name")
+
 	// Verify account node also exists
 	accountNode := ledger.graph.GetNode("Assets:Checking")
 	assert.NotZero(t, accountNode, "account node should exist")
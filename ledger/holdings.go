@@ -0,0 +1,119 @@
+package ledger
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+// Holding is the position in one commodity held in an asset account,
+// valued in the currency it was bought in.
+type Holding struct {
+	Account      string // Empty when aggregated across accounts
+	Commodity    string
+	Units        decimal.Decimal
+	CostCurrency string // The commodity itself for amounts held without cost
+	BookValue    decimal.Decimal
+	// Price is the commodity's price in CostCurrency on the valuation
+	// date. Priced is false when the ledger has none, in which case the
+	// holding is valued at its book value.
+	Price          decimal.Decimal
+	Priced         bool
+	MarketValue    decimal.Decimal
+	UnrealizedGain decimal.Decimal
+}
+
+// UnrealizedPercent returns the unrealized gain as a percentage of the
+// book value, or zero when the book value is zero.
+func (h *Holding) UnrealizedPercent() decimal.Decimal {
+	if h.BookValue.IsZero() {
+		return decimal.Zero
+	}
+	return h.UnrealizedGain.Div(h.BookValue.Abs()).Mul(decimal.NewFromInt(100))
+}
+
+// Holdings returns the holdings of the asset accounts at the end of date,
+// valued at the prices on that date, sorted by account, commodity and cost
+// currency. With aggregate set, holdings of the same commodity and cost
+// currency are summed across accounts.
+//
+// Inventories are rebuilt from the account postings up to date, so lots
+// reduced later still count.
+func (l *Ledger) Holdings(date *ast.Date, aggregate bool) ([]*Holding, error) {
+	if date == nil {
+		return nil, fmt.Errorf("holdings require a valuation date")
+	}
+	next := ast.NewDateFromTime(date.AddDate(0, 0, 1))
+	assets := l.config.ToAccountTypeName(ast.AccountTypeAssets)
+
+	type holdingKey struct {
+		account, commodity, costCurrency string
+	}
+	holdings := make(map[holdingKey]*Holding)
+
+	var err error
+	l.forEachAccount(func(account *Account) bool {
+		if account.Type != assets {
+			return true
+		}
+		inv := NewInventory()
+		for _, posting := range account.Postings[:account.SearchPostings(next)] {
+			if _, err = bookPosting(inv, posting.Transaction, posting.Posting, account.BookingMethod); err != nil {
+				err = fmt.Errorf("%s: %w", account.Name, err)
+				return false
+			}
+		}
+
+		for commodity, lots := range inv.lots {
+			for _, lot := range lots {
+				key := holdingKey{commodity: commodity, costCurrency: commodity}
+				if !aggregate {
+					key.account = string(account.Name)
+				}
+				bookValue := lot.Amount
+				if lot.Spec != nil && lot.Spec.Cost != nil {
+					key.costCurrency = lot.Spec.CostCurrency
+					bookValue = lot.Amount.Mul(*lot.Spec.Cost)
+				}
+
+				holding, ok := holdings[key]
+				if !ok {
+					holding = &Holding{Account: key.account, Commodity: commodity, CostCurrency: key.costCurrency}
+					holdings[key] = holding
+				}
+				holding.Units = holding.Units.Add(lot.Amount)
+				holding.BookValue = holding.BookValue.Add(bookValue)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Holding, 0, len(holdings))
+	for _, holding := range holdings {
+		if holding.Units.IsZero() {
+			continue
+		}
+		holding.MarketValue = holding.BookValue
+		if price, ok := l.GetPrice(date, holding.Commodity, holding.CostCurrency); ok {
+			holding.Price = price
+			holding.Priced = true
+			holding.MarketValue = holding.Units.Mul(price)
+		}
+		holding.UnrealizedGain = holding.MarketValue.Sub(holding.BookValue)
+		result = append(result, holding)
+	}
+	slices.SortFunc(result, func(a, b *Holding) int {
+		return cmp.Or(
+			cmp.Compare(a.Account, b.Account),
+			cmp.Compare(a.Commodity, b.Commodity),
+			cmp.Compare(a.CostCurrency, b.CostCurrency),
+		)
+	})
+	return result, nil
+}
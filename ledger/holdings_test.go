@@ -0,0 +1,128 @@
+package ledger_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+const holdingsSource = `
+2014-01-01 open Assets:Checking USD
+2014-01-01 open Assets:Invest
+2014-01-01 open Assets:Roth
+2014-01-01 open Assets:Art
+2014-01-01 open Equity:Opening USD
+2014-01-01 open Income:Gains USD
+
+2014-01-02 * "Opening"
+  Assets:Checking  20000.00 USD
+  Equity:Opening
+
+2014-03-01 * "Buy HOOL"
+  Assets:Invest  10 HOOL {500.00 USD}
+  Assets:Checking
+
+2014-06-01 * "Buy HOOL"
+  Assets:Invest  5 HOOL {520.00 USD}
+  Assets:Checking
+
+2014-07-01 * "Buy HOOL"
+  Assets:Roth  2 HOOL {510.00 USD}
+  Assets:Checking
+
+2014-08-01 * "Buy painting"
+  Assets:Art  1 PAINTING {1000.00 USD}
+  Assets:Checking
+
+2014-12-31 price HOOL 550.00 USD
+
+2015-02-01 * "Sell HOOL"
+  Assets:Invest  -4 HOOL {500.00 USD}
+  Assets:Checking  2400.00 USD
+  Income:Gains
+
+2015-06-01 price HOOL 600.00 USD
+`
+
+// processSource parses and processes source into a ledger.
+func processSource(t *testing.T, source string) *ledger.Ledger {
+	t.Helper()
+	ctx := context.Background()
+	tree, err := parser.ParseBytes(ctx, []byte(source))
+	assert.NoError(t, err)
+	l := ledger.New()
+	assert.NoError(t, l.Process(ctx, tree))
+	return l
+}
+
+// holdingRow summarises a holding for comparison.
+func holdingRow(h *ledger.Holding) []string {
+	return []string{
+		h.Account, h.Commodity, h.Units.String(), h.CostCurrency,
+		h.BookValue.String(), h.MarketValue.String(), h.UnrealizedGain.String(),
+		h.UnrealizedPercent().StringFixed(2),
+	}
+}
+
+func TestHoldings(t *testing.T) {
+	l := processSource(t, holdingsSource)
+	date, _ := ast.NewDate("2015-06-30")
+	holdings, err := l.Holdings(date, false)
+	assert.NoError(t, err)
+
+	var rows [][]string
+	for _, h := range holdings {
+		rows = append(rows, holdingRow(h))
+	}
+	assert.Equal(t, [][]string{
+		{"Assets:Art", "PAINTING", "1", "USD", "1000", "1000", "0", "0.00"},
+		{"Assets:Checking", "USD", "12780", "USD", "12780", "12780", "0", "0.00"},
+		{"Assets:Invest", "HOOL", "11", "USD", "5600", "6600", "1000", "17.86"},
+		{"Assets:Roth", "HOOL", "2", "USD", "1020", "1200", "180", "17.65"},
+	}, rows)
+	assert.False(t, holdings[0].Priced)
+	assert.True(t, holdings[2].Priced)
+	assert.Equal(t, "600", holdings[2].Price.String())
+}
+
+func TestHoldingsAtPastDate(t *testing.T) {
+	l := processSource(t, holdingsSource)
+	date, _ := ast.NewDate("2015-01-31")
+	holdings, err := l.Holdings(date, false)
+	assert.NoError(t, err)
+
+	invest := holdings[2]
+	assert.Equal(t, "Assets:Invest", invest.Account)
+	assert.Equal(t, "15", invest.Units.String())
+	assert.Equal(t, "7600", invest.BookValue.String())
+	assert.Equal(t, "8250", invest.MarketValue.String())
+
+	date, _ = ast.NewDate("2014-01-01")
+	holdings, err = l.Holdings(date, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(holdings))
+}
+
+func TestHoldingsAggregate(t *testing.T) {
+	l := processSource(t, holdingsSource)
+	date, _ := ast.NewDate("2015-06-30")
+	holdings, err := l.Holdings(date, true)
+	assert.NoError(t, err)
+
+	var rows [][]string
+	for _, h := range holdings {
+		rows = append(rows, holdingRow(h))
+	}
+	assert.Equal(t, [][]string{
+		{"", "HOOL", "13", "USD", "6620", "7800", "1180", "17.82"},
+		{"", "PAINTING", "1", "USD", "1000", "1000", "0", "0.00"},
+		{"", "USD", "12780", "USD", "12780", "12780", "0", "0.00"},
+	}, rows)
+
+	_, err = l.Holdings(nil, true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+// checkpoint is a snapshot of the ledger state taken right before the
+// directive at index was processed.
+type checkpoint struct {
+	index int
+	state *Ledger
+}
+
+// ProcessIncremental processes tree like Process, but resumes from the state
+// prev reached before the first directive that differs between the two trees,
+// so only the directives from the earliest changed date onward are validated
+// again. prev may be nil or a ledger built with Process, in which case the
+// whole tree is processed. A change to the options or plugins also processes
+// the whole tree.
+//
+// Directives are matched by identity first, which a loader with a Cache
+// preserves for every unchanged entry, and otherwise by deep equality, which
+// covers the directives plugins synthesize on every run.
+//
+// The ledger snapshots its state at the first directive of every month, so a
+// later call can resume from it in turn. prev is only read and stays usable.
+func (l *Ledger) ProcessIncremental(ctx context.Context, tree *ast.AST, prev *Ledger) error {
+	return l.process(ctx, tree, prev, true)
+}
+
+// resume restores the latest snapshot of prev taken at or before the first
+// directive that differs from l.directives, and returns the index to
+// continue processing from. It returns 0 when nothing can be reused.
+func (l *Ledger) resume(prev *Ledger) int {
+	if prev == nil || len(prev.checkpoints) == 0 || prev.settings != l.settings {
+		return 0
+	}
+
+	changed := 0
+	for changed < len(l.directives) && changed < len(prev.directives) &&
+		sameDirective(l.directives[changed], prev.directives[changed]) {
+		changed++
+	}
+
+	n := 0
+	for n < len(prev.checkpoints) && prev.checkpoints[n].index <= changed {
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+
+	cp := prev.checkpoints[n-1]
+	l.restore(cp.state)
+	l.checkpoints = slices.Clip(prev.checkpoints[:n])
+	return cp.index
+}
+
+// checkpoint snapshots the state before the directive at index when it
+// starts a new month.
+func (l *Ledger) checkpoint(index int) {
+	if n := len(l.checkpoints); n > 0 {
+		last := l.checkpoints[n-1].index
+		if last >= index || sameMonth(l.directives[index-1].Date(), l.directives[index].Date()) {
+			return
+		}
+	}
+	l.checkpoints = append(l.checkpoints, &checkpoint{index: index, state: l.snapshot()})
+}
+
+func sameMonth(a, b *ast.Date) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month()
+}
+
+// sameDirective reports whether a previously processed directive can stand
+// in for a new one.
+func sameDirective(a, b ast.Directive) bool {
+	return a == b || reflect.DeepEqual(a, b)
+}
+
+// settingsKey describes the options and plugins of tree, which affect how
+// every directive is processed.
+func settingsKey(tree *ast.AST) string {
+	var b strings.Builder
+	for _, option := range tree.Options {
+		fmt.Fprintf(&b, "option %q %q\n", option.Name.Value, option.Value.Value)
+	}
+	for _, p := range tree.Plugins {
+		fmt.Fprintf(&b, "plugin %q %q\n", p.Name.Value, p.Config.Value)
+	}
+	return b.String()
+}
+
+// snapshot returns a copy of the state built while processing directives
+// that is unaffected by processing more of them.
+func (l *Ledger) snapshot() *Ledger {
+	accounts := make(map[string]*Account, len(l.accounts))
+	for name, account := range l.accounts {
+		accounts[name] = account.clone()
+	}
+
+	l.priceGraphMu.RLock()
+	priceGraphs := maps.Clone(l.priceGraphs)
+	l.priceGraphMu.RUnlock()
+
+	return &Ledger{
+		graph:                 l.graph.clone(accounts),
+		accounts:              accounts,
+		errors:                slices.Clip(l.errors),
+		padEntries:            maps.Clone(l.padEntries),
+		usedPads:              maps.Clone(l.usedPads),
+		syntheticTransactions: slices.Clip(l.syntheticTransactions),
+		priceGraphs:           priceGraphs,
+		prelude:               l.prelude,
+	}
+}
+
+// restore replaces the processing state of l with a copy of state, keeping
+// the errors l collected from plugins and options.
+func (l *Ledger) restore(state *Ledger) {
+	restored := state.snapshot()
+	l.graph = restored.graph
+	l.accounts = restored.accounts
+	l.errors = append(l.errors, restored.errors[restored.prelude:]...)
+	l.padEntries = restored.padEntries
+	l.usedPads = restored.usedPads
+	l.syntheticTransactions = restored.syntheticTransactions
+	l.priceGraphs = restored.priceGraphs
+}
+
+// clone copies the account with its own inventory. Postings and disposals
+// are shared up to their current length; appending to either copy
+// reallocates.
+func (a *Account) clone() *Account {
+	clone := *a
+	clone.Inventory = a.Inventory.clone()
+	clone.Postings = slices.Clip(a.Postings)
+	clone.Disposals = slices.Clip(a.Disposals)
+	return &clone
+}
+
+// clone copies the inventory and its lots.
+func (inv *Inventory) clone() *Inventory {
+	clone := NewInventory()
+	for commodity, lots := range inv.lots {
+		copied := make([]*lot, len(lots))
+		for i, l := range lots {
+			lot := *l
+			copied[i] = &lot
+		}
+		clone.lots[commodity] = copied
+	}
+	return clone
+}
+
+// clone copies the graph, pointing account nodes at the given accounts.
+// Edges are immutable and shared.
+func (g *Graph) clone(accounts map[string]*Account) *Graph {
+	clone := &Graph{
+		nodes:            make(map[string]*Node, len(g.nodes)),
+		edges:            make(map[string][]*Edge, len(g.edges)),
+		incomingEdges:    make(map[string][]*Edge, len(g.incomingEdges)),
+		priceEdgesByDate: make(map[time.Time][]*Edge, len(g.priceEdgesByDate)),
+		sortedDates:      slices.Clone(g.sortedDates),
+		priceDates:       maps.Clone(g.priceDates),
+	}
+	for id, node := range g.nodes {
+		copied := *node
+		if _, ok := node.Meta.(*Account); ok {
+			copied.Meta = accounts[id]
+		}
+		clone.nodes[id] = &copied
+	}
+	for id, edges := range g.edges {
+		clone.edges[id] = slices.Clip(edges)
+	}
+	for id, edges := range g.incomingEdges {
+		clone.incomingEdges[id] = slices.Clip(edges)
+	}
+	for date, edges := range g.priceEdgesByDate {
+		clone.priceEdgesByDate[date] = slices.Clip(edges)
+	}
+	return clone
+}
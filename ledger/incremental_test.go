@@ -0,0 +1,123 @@
+package ledger
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+const incrementalBase = `2024-01-01 open Assets:Checking USD
+2024-01-01 open Assets:Brokerage
+2024-01-01 open Expenses:Food USD
+2024-01-01 open Equity:Opening USD
+
+2024-01-02 * "Opening"
+  Assets:Checking  1000.00 USD
+  Equity:Opening
+
+2024-01-15 balance Assets:Checking 999.00 USD
+
+2024-02-03 * "Buy"
+  Assets:Brokerage  10 HOOL {50.00 USD}
+  Assets:Checking
+
+2024-03-04 * "Grocer"
+  Expenses:Food  40.00 USD
+  Assets:Checking
+`
+
+const incrementalEdit = `2024-03-10 * "Sell"
+  Assets:Brokerage  -4 HOOL {50.00 USD}
+  Assets:Checking
+
+2024-03-11 balance Assets:Checking 100.00 USD
+`
+
+// processIncremental parses source and adds its directives to the ones prev
+// processed, as a loader with a Cache hands out unchanged entries.
+func processIncremental(t *testing.T, prev *Ledger, source string) *Ledger {
+	t.Helper()
+	tree := parser.MustParseString(context.Background(), source)
+	if prev != nil {
+		tree.Directives = append(slices.Clone(prev.directives), tree.Directives...)
+	}
+	l := New()
+	_ = l.ProcessIncremental(context.Background(), tree, prev)
+	return l
+}
+
+func errorStrings(errs []error) []string {
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return out
+}
+
+func TestProcessIncrementalMatchesProcess(t *testing.T) {
+	first := processIncremental(t, nil, incrementalBase)
+	assert.Equal(t, 3, len(first.checkpoints))
+
+	second := processIncremental(t, first, incrementalEdit)
+
+	full := New()
+	_ = full.Process(context.Background(), parser.MustParseString(context.Background(), incrementalBase+incrementalEdit))
+
+	assert.Equal(t, errorStrings(full.Diagnostics()), errorStrings(second.Diagnostics()))
+	assert.Equal(t, 2, len(second.Errors()))
+	for _, name := range []string{"Assets:Checking", "Assets:Brokerage", "Expenses:Food"} {
+		want, _ := full.GetAccount(name)
+		got, _ := second.GetAccount(name)
+		assert.Equal(t, want.Inventory.String(), got.Inventory.String(), name)
+		assert.Equal(t, len(want.Postings), len(got.Postings), name)
+		assert.Equal(t, len(want.Disposals), len(got.Disposals), name)
+	}
+
+	// Processing resumed from the March snapshot; January and February were
+	// not processed again.
+	assert.Equal(t, 3, len(second.checkpoints))
+	for i := range second.checkpoints {
+		assert.True(t, first.checkpoints[i] == second.checkpoints[i])
+	}
+
+	// The snapshots of the previous ledger are left untouched.
+	checking, _ := first.GetAccount("Assets:Checking")
+	assert.Equal(t, "{460 USD}", checking.Inventory.String())
+	assert.Equal(t, 1, len(first.Errors()))
+}
+
+func TestProcessIncrementalResumesBeforeEarliestChange(t *testing.T) {
+	first := processIncremental(t, nil, incrementalBase)
+
+	// Parse the ledger again, keeping only the January entries. The February
+	// purchase no longer matches and processing resumes from February.
+	tree := parser.MustParseString(context.Background(), incrementalBase)
+	for i, directive := range first.directives {
+		if directive.Date().Month() == 1 {
+			tree.Directives[i] = directive
+		}
+	}
+	second := New()
+	_ = second.ProcessIncremental(context.Background(), tree, first)
+
+	assert.Equal(t, 3, len(second.checkpoints))
+	assert.True(t, first.checkpoints[1] == second.checkpoints[1])
+	assert.True(t, first.checkpoints[2] != second.checkpoints[2])
+	assert.Equal(t, errorStrings(first.Diagnostics()), errorStrings(second.Diagnostics()))
+}
+
+func TestProcessIncrementalReprocessesOnOptionChange(t *testing.T) {
+	first := processIncremental(t, nil, incrementalBase)
+
+	tree := &ast.AST{Directives: slices.Clone(first.directives)}
+	tree.Options = parser.MustParseString(context.Background(), `option "operating_currency" "USD"`).Options
+	second := New()
+	_ = second.ProcessIncremental(context.Background(), tree, first)
+
+	assert.True(t, first.checkpoints[0] != second.checkpoints[0])
+	assert.Equal(t, errorStrings(first.Diagnostics()), errorStrings(second.Diagnostics()))
+}
@@ -113,12 +113,18 @@ func (inv *Inventory) GetLots(commodity string) []*lot {
 
 // ReduceLot reduces from a specific lot or uses booking method
 func (inv *Inventory) ReduceLot(commodity string, amount decimal.Decimal, spec *lotSpec, bookingMethod BookingMethod) error {
+	_, err := inv.reduceLot(commodity, amount, spec, bookingMethod)
+	return err
+}
+
+// reduceLot is ReduceLot returning the lots the reduction was booked
+// against.
+func (inv *Inventory) reduceLot(commodity string, amount decimal.Decimal, spec *lotSpec, bookingMethod BookingMethod) ([]lotReduction, error) {
 	plan, err := inv.planReduction(commodity, amount, spec, bookingMethod)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	inv.applyReduction(plan)
-	return nil
+	return inv.applyReduction(plan), nil
 }
 
 // removeLot removes a lot from the inventory
@@ -416,13 +422,16 @@ func (inv *Inventory) planMergeReduction(
 			commodity, totalUnits.String(), amount.String())
 	}
 
+	// The reduction is booked against the merged lot at the average cost.
+	averageCost := totalCost.Div(totalUnits)
+	averageSpec := &lotSpec{Cost: &averageCost, CostCurrency: costCurrency}
 	plan := &reductionPlan{
 		commodity:   commodity,
+		reductions:  []lotReduction{{lot: newLot(commodity, totalUnits, averageSpec), amount: amount}},
 		replaceLots: true,
 	}
 	remainingUnits := totalUnits.Sub(amount)
 	if remainingUnits.GreaterThan(decimal.Zero) {
-		averageCost := totalCost.Div(totalUnits)
 		plan.replacementLots = []*lot{newLot(commodity, remainingUnits, &lotSpec{
 			Cost:         &averageCost,
 			CostCurrency: costCurrency,
@@ -431,19 +440,22 @@ func (inv *Inventory) planMergeReduction(
 	return plan, nil
 }
 
-func (inv *Inventory) applyReduction(plan *reductionPlan) {
+// applyReduction mutates the inventory according to plan and returns the
+// lot reductions it made, which are none when the amount was added as a
+// lot of its own.
+func (inv *Inventory) applyReduction(plan *reductionPlan) []lotReduction {
 	if plan.replaceLots {
 		if len(plan.replacementLots) == 0 {
 			delete(inv.lots, plan.commodity)
 		} else {
 			inv.lots[plan.commodity] = plan.replacementLots
 		}
-		return
+		return plan.reductions
 	}
 
 	if plan.addAmount != nil {
 		inv.AddLot(plan.commodity, *plan.addAmount, plan.addSpec)
-		return
+		return nil
 	}
 
 	for _, reduction := range plan.reductions {
@@ -452,6 +464,7 @@ func (inv *Inventory) applyReduction(plan *reductionPlan) {
 			inv.removeLot(plan.commodity, reduction.lot)
 		}
 	}
+	return plan.reductions
 }
 
 func sortedLotsForBooking(lots []*lot, bookingMethod BookingMethod) []*lot {
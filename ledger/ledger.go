@@ -42,6 +42,7 @@ import (
 
 	"github.com/robinvdvleuten/beancount/ast"
 	"github.com/robinvdvleuten/beancount/diagnostic"
+	"github.com/robinvdvleuten/beancount/plugin"
 	"github.com/robinvdvleuten/beancount/telemetry"
 	"github.com/shopspring/decimal"
 )
@@ -68,6 +69,12 @@ type Ledger struct {
 	syntheticTransactions []*ast.Transaction  // Padding transactions to insert into AST
 	priceGraphMu          sync.RWMutex
 	priceGraphs           map[string]*Graph
+
+	// State kept by ProcessIncremental so a later call can resume from it
+	directives  []ast.Directive // Directives processed, in order
+	settings    string          // Options and plugins the tree was processed with
+	prelude     int             // Number of plugin and option errors in errors
+	checkpoints []*checkpoint   // Snapshots taken at the start of every month
 }
 
 // ValidationErrors wraps multiple validation errors
@@ -122,6 +129,13 @@ func (l *Ledger) GetAccountTypeFromName(name string) (ast.AccountType, bool) {
 
 // Process processes an AST and builds the ledger state
 func (l *Ledger) Process(ctx context.Context, tree *ast.AST) error {
+	return l.process(ctx, tree, nil, false)
+}
+
+// process builds the ledger state for tree. When incremental is set, it
+// resumes from the state prev reached before the first changed directive and
+// snapshots its own state along the way.
+func (l *Ledger) process(ctx context.Context, tree *ast.AST, prev *Ledger, incremental bool) error {
 	// Extract telemetry collector from context
 	collector := telemetry.FromContext(ctx)
 
@@ -130,20 +144,19 @@ func (l *Ledger) Process(ctx context.Context, tree *ast.AST) error {
 		prepareTimer.End()
 		return err
 	}
+	// Expand recurring transactions before sorting so their future instances
+	// are validated, and seen by plugins, like any other transaction.
+	l.errors = append(l.errors, expandRecurring(tree)...)
 	if err := ast.SortDirectives(tree); err != nil {
 		prepareTimer.End()
 		return err
 	}
 	prepareTimer.End()
 
-	// Enrich AST with semantic information (currencies, accounts)
-	enriched := tree.Enrich()
-
-	// Pre-populate graph with currency nodes (they're not explicitly opened)
-	// Account nodes are created by Open directives with full metadata
-	for currency := range enriched.Currencies {
-		l.graph.AddNode(currency, NodeCurrency, nil)
-	}
+	// Run plugins before validation so directives they synthesize (e.g.
+	// auto_accounts opens) are validated like any other. Plugin errors are
+	// collected with the validation errors.
+	l.errors = append(l.errors, plugin.Run(ctx, tree)...)
 
 	// Parse configuration from AST options
 	cfg, err := configFromAST(tree)
@@ -153,6 +166,23 @@ func (l *Ledger) Process(ctx context.Context, tree *ast.AST) error {
 	}
 	l.config = cfg
 
+	start := 0
+	if incremental {
+		l.directives = slices.Clone(tree.Directives)
+		l.settings = settingsKey(tree)
+		l.prelude = len(l.errors)
+		start = l.resume(prev)
+	}
+
+	// Enrich AST with semantic information (currencies, accounts)
+	enriched := tree.Enrich()
+
+	// Pre-populate graph with currency nodes (they're not explicitly opened)
+	// Account nodes are created by Open directives with full metadata
+	for currency := range enriched.Currencies {
+		l.graph.AddNode(currency, NodeCurrency, nil)
+	}
+
 	// Process directives in semantic date order.
 	processTimer := collector.StartStructured(telemetry.TimerConfig{
 		Name:  "ledger.processing",
@@ -177,7 +207,7 @@ func (l *Ledger) Process(ctx context.Context, tree *ast.AST) error {
 		})
 	}
 
-	for _, directive := range tree.Directives {
+	for i, directive := range tree.Directives[start:] {
 		// Check for cancellation
 		select {
 		case <-ctx.Done():
@@ -189,6 +219,9 @@ func (l *Ledger) Process(ctx context.Context, tree *ast.AST) error {
 		default:
 		}
 
+		if incremental {
+			l.checkpoint(start + i)
+		}
 		l.processDirective(ctx, directive)
 	}
 
@@ -227,6 +260,23 @@ func (l *Ledger) Process(ctx context.Context, tree *ast.AST) error {
 			}
 		}
 
+		// Padding transactions were applied after the directives that
+		// follow them, so the histories they touched need re-sorting to stay
+		// chronological.
+		touched := make(map[string]bool)
+		for _, txn := range l.syntheticTransactions {
+			for _, posting := range txn.Postings {
+				touched[string(posting.Account)] = true
+			}
+		}
+		for name := range touched {
+			if account, ok := l.accounts[name]; ok {
+				slices.SortStableFunc(account.Postings, func(a, b *AccountPosting) int {
+					return a.Transaction.Date().Compare(b.Transaction.Date().Time)
+				})
+			}
+		}
+
 		insertTimer.End()
 	}
 
@@ -395,20 +445,71 @@ func (l *Ledger) forEachAccount(fn func(*Account) bool) {
 	}
 }
 
+// BalanceTreeOption configures GetBalanceTree and GetConvertedBalanceTree.
+type BalanceTreeOption func(*balanceTreeOptions)
+
+type balanceTreeOptions struct {
+	periodChanges bool
+}
+
+// WithPeriodChanges reads startDate and endDate as an inclusive period and
+// returns its changes also when the dates are equal, so a single day holds
+// that day's changes instead of the balance on it.
+func WithPeriodChanges() BalanceTreeOption {
+	return func(o *balanceTreeOptions) {
+		o.periodChanges = true
+	}
+}
+
+func newBalanceTreeOptions(opts []BalanceTreeOption) *balanceTreeOptions {
+	o := &balanceTreeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// periodPostings returns the postings of account the options select for
+// the dates.
+func (o *balanceTreeOptions) periodPostings(account *Account, start, end ast.Date) []*AccountPosting {
+	if o.periodChanges {
+		return account.GetPostingsBetween(start, end)
+	}
+	return account.GetPostingsInPeriod(start, end)
+}
+
 // GetBalanceTree returns a hierarchical view of account balances for reporting.
 //
 // Parameters:
 //   - types: Account types to include (e.g., Assets, Liabilities). Empty means all types (trial balance).
 //   - startDate, endDate: Date range for balance calculation.
 //   - Both nil: Current inventory state (all postings).
-//   - startDate == endDate: Point-in-time balance (balance sheet).
+//   - startDate == endDate: Point-in-time balance (balance sheet), or that
+//     day's changes with WithPeriodChanges.
 //   - startDate < endDate: Period change (income statement).
 //
 // Returns an error if only one date is provided or startDate > endDate.
 //
 // The tree is organized with account types as virtual root nodes. Balances are
 // aggregated bottom-up so parent nodes include the sum of all their descendants.
-func (l *Ledger) GetBalanceTree(types []ast.AccountType, startDate, endDate *ast.Date) (*BalanceTree, error) {
+func (l *Ledger) GetBalanceTree(types []ast.AccountType, startDate, endDate *ast.Date, opts ...BalanceTreeOption) (*BalanceTree, error) {
+	o := newBalanceTreeOptions(opts)
+	return l.balanceTree(types, startDate, endDate, func(account *Account) balanceTreeEntry {
+		// Calculate balance for the period
+		var balance *Balance
+		if startDate == nil && endDate == nil {
+			// Current inventory state
+			balance = l.getAccountCurrentBalance(account)
+		} else {
+			balance = postingsBalance(o.periodPostings(account, *startDate, *endDate))
+		}
+		return balanceTreeEntry{account: account, balance: balance}
+	})
+}
+
+// balanceTree validates the date range and builds the balance tree of the
+// accounts of types from the entries entry returns for them.
+func (l *Ledger) balanceTree(types []ast.AccountType, startDate, endDate *ast.Date, entry func(*Account) balanceTreeEntry) (*BalanceTree, error) {
 	// Validate date range
 	if (startDate == nil) != (endDate == nil) {
 		return nil, fmt.Errorf("startDate and endDate must both be set or both be nil")
@@ -433,22 +534,11 @@ func (l *Ledger) GetBalanceTree(types []ast.AccountType, startDate, endDate *ast
 			return true
 		}
 
-		// Calculate balance for the period
-		var balance *Balance
-		if startDate == nil && endDate == nil {
-			// Current inventory state
-			balance = l.getAccountCurrentBalance(account)
-		} else {
-			// Use GetBalanceInPeriod with the dates
-			start := *startDate
-			end := *endDate
-			balance = account.GetBalanceInPeriod(start, end)
-		}
-
-		entries = append(entries, balanceTreeEntry{account: account, balance: balance})
+		e := entry(account)
+		entries = append(entries, e)
 
 		// Track currencies
-		for _, currency := range balance.Currencies() {
+		for _, currency := range e.balance.Currencies() {
 			currencySet[currency] = true
 		}
 
@@ -495,8 +585,9 @@ func (l *Ledger) getAccountCurrentBalance(account *Account) *Balance {
 // buildBalanceTree constructs the hierarchical tree structure from account entries.
 // balanceTreeEntry is used internally by GetBalanceTree.
 type balanceTreeEntry struct {
-	account *Account
-	balance *Balance
+	account     *Account
+	balance     *Balance
+	unconverted *Balance // Amounts without a conversion, nil when not converting
 }
 
 func (l *Ledger) buildBalanceTree(entries []balanceTreeEntry, typeFilter map[string]bool) *BalanceTree {
@@ -567,6 +658,9 @@ func (l *Ledger) buildTypeSubtree(typeName string, entries []balanceTreeEntry) *
 			Balance:  entry.balance.Copy(),
 			Children: nil,
 		}
+		if entry.unconverted != nil {
+			nodeMap[accountName].Unconverted = entry.unconverted.Copy()
+		}
 	}
 
 	// Build parent-child relationships and create intermediate nodes
@@ -625,7 +719,7 @@ func (l *Ledger) buildTypeSubtree(typeName string, entries []balanceTreeEntry) *
 	aggregate = func(node *BalanceNode) {
 		for _, child := range node.Children {
 			aggregate(child)
-			node.Balance.Merge(child.Balance)
+			mergeBalanceNode(node, child)
 		}
 	}
 
@@ -659,12 +753,23 @@ func (l *Ledger) buildTypeSubtree(typeName string, entries []balanceTreeEntry) *
 	// Aggregate balances from children to root
 	for _, child := range root.Children {
 		aggregate(child)
-		root.Balance.Merge(child.Balance)
+		mergeBalanceNode(root, child)
 	}
 
 	return root
 }
 
+// mergeBalanceNode adds the balances of child to node.
+func mergeBalanceNode(node, child *BalanceNode) {
+	node.Balance.Merge(child.Balance)
+	if child.Unconverted != nil {
+		if node.Unconverted == nil {
+			node.Unconverted = NewBalance()
+		}
+		node.Unconverted.Merge(child.Unconverted)
+	}
+}
+
 // processDirective processes a single directive
 func (l *Ledger) processDirective(ctx context.Context, directive ast.Directive) {
 	handler := GetHandler(directive.Kind())
@@ -784,49 +889,12 @@ func (l *Ledger) applyTransaction(txn *ast.Transaction, delta *TransactionDelta)
 			panic(fmt.Sprintf("BUG: account %s not found after validation", accountName))
 		}
 
-		amount, err := ParseAmount(posting.Amount)
+		reductions, err := bookPosting(account.Inventory, txn, posting, account.BookingMethod)
 		if err != nil {
 			// This should never happen after validation - panic to catch bugs
-			panic(fmt.Sprintf("BUG: amount parsing failed after validation: %v", err))
-		}
-		currency := posting.Amount.Currency
-
-		// Update inventory if posting has cost specification
-		if posting.Cost != nil {
-			lotSpec, err := ParseLotSpec(posting.Cost)
-			if err != nil {
-				// This should never happen after validation - panic to catch bugs
-				panic(fmt.Sprintf("BUG: lot spec parsing failed after validation: %v", err))
-			}
-
-			// Convert total cost to per-unit cost for inventory operations
-			err = normalizeLotSpecForPosting(lotSpec, posting)
-			if err != nil {
-				// This should never happen after validation - panic to catch bugs
-				panic(fmt.Sprintf("BUG: lot spec normalization failed after validation: %v", err))
-			}
-
-			if amount.IsZero() {
-				// Zero amount with cost spec is a no-op for inventory
-			} else if amount.GreaterThan(decimal.Zero) {
-				// Beancount records an acquisition date on every lot,
-				// defaulting to the transaction date; LIFO/FIFO ordering
-				// and dated lot specs depend on it.
-				if lotSpec != nil && lotSpec.Date == nil {
-					lotSpec.Date = txn.Date()
-				}
-				account.Inventory.AddLot(currency, amount, lotSpec)
-			} else {
-				bookingMethod := defaultBookingMethod(account.BookingMethod)
-				err := account.Inventory.ReduceLot(currency, amount, lotSpec, bookingMethod)
-				if err != nil {
-					// This should never happen after validateInventoryOperations - panic to catch bugs
-					panic(fmt.Sprintf("BUG: lot reduction failed after validation: %v", err))
-				}
-			}
-		} else {
-			account.Inventory.Add(currency, amount)
+			panic(fmt.Sprintf("BUG: %v after validation", err))
 		}
+		account.Disposals = append(account.Disposals, newDisposals(account, txn, posting, reductions)...)
 
 		// Record posting in account history (after mutation for correct ordering)
 		account.Postings = append(account.Postings, &AccountPosting{
@@ -836,6 +904,51 @@ func (l *Ledger) applyTransaction(txn *ast.Transaction, delta *TransactionDelta)
 	}
 }
 
+// bookPosting applies a posting to an inventory: amounts at cost add or
+// reduce lots under the account's booking method, other amounts are added
+// as they are. It returns the lot reductions the posting made.
+func bookPosting(inv *Inventory, txn *ast.Transaction, posting *ast.Posting, bookingMethod BookingMethod) ([]lotReduction, error) {
+	amount, err := ParseAmount(posting.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("amount parsing failed: %w", err)
+	}
+	currency := posting.Amount.Currency
+
+	if posting.Cost == nil {
+		inv.Add(currency, amount)
+		return nil, nil
+	}
+
+	lotSpec, err := ParseLotSpec(posting.Cost)
+	if err != nil {
+		return nil, fmt.Errorf("lot spec parsing failed: %w", err)
+	}
+	// Convert total cost to per-unit cost for inventory operations
+	if err := normalizeLotSpecForPosting(lotSpec, posting); err != nil {
+		return nil, fmt.Errorf("lot spec normalization failed: %w", err)
+	}
+
+	switch {
+	case amount.IsZero():
+		// Zero amount with cost spec is a no-op for inventory
+	case amount.GreaterThan(decimal.Zero):
+		// Beancount records an acquisition date on every lot,
+		// defaulting to the transaction date; LIFO/FIFO ordering
+		// and dated lot specs depend on it.
+		if lotSpec != nil && lotSpec.Date == nil {
+			lotSpec.Date = txn.Date()
+		}
+		inv.AddLot(currency, amount, lotSpec)
+	default:
+		reductions, err := inv.reduceLot(currency, amount, lotSpec, defaultBookingMethod(bookingMethod))
+		if err != nil {
+			return nil, fmt.Errorf("lot reduction failed: %w", err)
+		}
+		return reductions, nil
+	}
+	return nil, nil
+}
+
 // applyBalance applies the balance delta to the ledger (mutation only)
 func (l *Ledger) applyBalance(delta *BalanceDelta) {
 	// Note: Padding adjustments are applied by processing synthetic transactions
@@ -889,15 +1002,17 @@ func (l *Ledger) applyPrice(price *ast.Price) {
 func (l *Ledger) applyCommodity(commodity *ast.Commodity, delta *CommodityDelta) {
 	// Create or upgrade the commodity node with metadata
 	// This upgrades implicit "currency" nodes to explicit "commodity" nodes
-	node := l.graph.AddNode(delta.CommodityID, NodeCommodity, &CommodityNode{
+	meta := &CommodityNode{
 		ID:       delta.CommodityID,
 		Date:     delta.Date,
 		Metadata: delta.Metadata,
-	})
+	}
+	node := l.graph.AddNode(delta.CommodityID, NodeCommodity, meta)
 
-	// Ensure the node kind is set to "commodity" (not "currency")
-	// This handles the case where the node was previously created as "currency"
+	// Ensure the node kind and metadata are set, as the node was usually
+	// created as a "currency" before the directive was processed
 	node.Kind = NodeCommodity
+	node.Meta = meta
 }
 
 // CommodityNode represents a commodity or currency as an explicit graph node.
@@ -2,11 +2,13 @@ package ledger
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
 	"github.com/robinvdvleuten/beancount/ast"
 	"github.com/robinvdvleuten/beancount/parser"
+	"github.com/robinvdvleuten/beancount/plugin"
 )
 
 func TestLedger_ProcessOpen(t *testing.T) {
@@ -1165,3 +1167,46 @@ func TestLedger_PricesWithAccounts(t *testing.T) {
 	_, found2 := ledger.GetPrice(date, "EUR", "USD")
 	assert.True(t, found2)
 }
+
+func TestLedger_ProcessRunsPlugins(t *testing.T) {
+	plugin.Register("test.ledger.open_cash", plugin.Func(func(ctx context.Context, tree *ast.AST, config string) []error {
+		date, _ := ast.NewDate("2020-01-01")
+		tree.Directives = append(tree.Directives, ast.NewOpen(date, ast.Account(config), nil, ""))
+		return nil
+	}))
+	plugin.Register("test.ledger.reject", plugin.Func(func(ctx context.Context, tree *ast.AST, config string) []error {
+		return []error{plugin.NewError("test.ledger.reject", tree.Directives[0], "rejected")}
+	}))
+
+	t.Run("synthesized directives are validated", func(t *testing.T) {
+		tree, err := parser.ParseString(context.Background(), `
+plugin "test.ledger.open_cash" "Assets:Cash"
+2020-01-02 open Equity:Opening
+2020-01-02 * "Opening"
+  Assets:Cash      10 USD
+  Equity:Opening
+`)
+		assert.NoError(t, err)
+
+		l := New()
+		assert.NoError(t, l.Process(context.Background(), tree))
+		_, ok := l.GetAccount("Assets:Cash")
+		assert.True(t, ok)
+	})
+
+	t.Run("plugin errors are reported", func(t *testing.T) {
+		tree, err := parser.ParseString(context.Background(), `
+plugin "test.ledger.reject"
+2020-01-01 open Assets:Cash
+`)
+		assert.NoError(t, err)
+
+		l := New()
+		assert.Error(t, l.Process(context.Background(), tree))
+		errs := l.Errors()
+		assert.Equal(t, 1, len(errs))
+		var pluginErr *plugin.Error
+		assert.True(t, errors.As(errs[0], &pluginErr))
+		assert.Equal(t, "test.ledger.reject", pluginErr.Plugin)
+	})
+}
@@ -239,3 +239,28 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestPadKeepsAccountPostingsChronological(t *testing.T) {
+	source := `
+		2020-01-01 open Assets:Checking
+		2020-01-01 open Equity:Opening-Balances
+		2020-01-01 open Income:Salary
+
+		2020-01-01 pad Assets:Checking Equity:Opening-Balances
+		2020-01-10 * "Salary"
+		  Assets:Checking  500.00 USD
+		  Income:Salary
+		2020-01-15 balance Assets:Checking 1500.00 USD
+	`
+
+	tree := parser.MustParseBytes(context.Background(), []byte(source))
+
+	ledger := New()
+	assert.NoError(t, ledger.Process(context.Background(), tree))
+
+	account, ok := ledger.GetAccount("Assets:Checking")
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(account.Postings))
+	assert.Equal(t, "P", account.Postings[0].Transaction.Flag)
+	assert.Equal(t, "2020-01-10", account.Postings[1].Transaction.Date().String())
+}
@@ -0,0 +1,208 @@
+package ledger
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+// recurringTag marks a transaction as the template of a recurring one, and
+// recurKey is the metadata key holding its recurrence rule:
+//
+//	2024-01-25 * "Employer" "Salary" #recurring
+//	  recur: "monthly until 2024-12-31"
+//	  Assets:Checking   3000.00 EUR
+//	  Income:Salary
+//
+// A rule is "daily", "weekly", "monthly", "quarterly", "yearly" or
+// "every N days", "every N weeks", "every N months" or "every N years",
+// followed by its end, "until YYYY-MM-DD" or "N times". The end is required
+// so that the ledger does not depend on the day it is processed.
+const (
+	recurringTag = "recurring"
+	recurKey     = "recur"
+)
+
+// recurrence is a parsed recurrence rule.
+type recurrence struct {
+	days   int       // Days between instances, when stepping by days
+	months int       // Months between instances, when stepping by months
+	until  time.Time // Last day an instance may fall on, when count is 0
+	count  int       // Number of occurrences including the template, or 0
+}
+
+// expandRecurring appends an instance of every recurring template in tree
+// for each later date its rule produces, and returns an error for each
+// template whose rule cannot be read. The template itself is the first
+// occurrence. Instances are copies dated on their occurrence, without the
+// recur metadata and marked as generated. The instances of an
+// earlier expansion are dropped first, so a tree processed again ends up
+// with the same instances.
+func expandRecurring(tree *ast.AST) []error {
+	tree.Directives = slices.DeleteFunc(tree.Directives, isRecurringInstance)
+
+	var errs []error
+	var instances []ast.Directive
+	for _, directive := range tree.Directives {
+		txn, ok := directive.(*ast.Transaction)
+		if !ok || !slices.Contains(txn.Tags, ast.Tag(recurringTag)) {
+			continue
+		}
+		var meta *ast.Metadata
+		for _, m := range txn.Metadata {
+			if m.Key == recurKey {
+				meta = m
+				break
+			}
+		}
+		if meta == nil {
+			continue
+		}
+		if meta.Value == nil || meta.Value.StringValue == nil {
+			errs = append(errs, NewInvalidMetadataError(txn, "", meta.Key, meta.Value, "expected a recurrence rule string"))
+			continue
+		}
+		rule, err := parseRecurrence(meta.Value.StringValue.Value)
+		if err != nil {
+			errs = append(errs, NewInvalidMetadataError(txn, "", meta.Key, meta.Value, err.Error()))
+			continue
+		}
+		for _, date := range rule.dates(txn.Date().Time) {
+			instances = append(instances, recurringInstance(txn, ast.NewDateFromTime(date)))
+		}
+	}
+	tree.Directives = append(tree.Directives, instances...)
+	return errs
+}
+
+// parseRecurrence reads a recurrence rule such as "every 2 weeks until
+// 2024-12-31".
+func parseRecurrence(rule string) (*recurrence, error) {
+	fields := strings.Fields(strings.ToLower(rule))
+	r := &recurrence{}
+
+	invalid := fmt.Errorf("unknown recurrence rule %q (expected e.g. \"monthly until 2024-12-31\", \"every 2 weeks 6 times\" or \"yearly 5 times\")", rule)
+	if len(fields) == 0 {
+		return nil, invalid
+	}
+
+	n := 1
+	unit := fields[0]
+	switch unit {
+	case "daily":
+		unit = "day"
+	case "weekly":
+		unit = "week"
+	case "monthly":
+		unit = "month"
+	case "quarterly":
+		unit, n = "month", 3
+	case "yearly":
+		unit = "year"
+	case "every":
+		if len(fields) < 3 {
+			return nil, invalid
+		}
+		var err error
+		if n, err = strconv.Atoi(fields[1]); err != nil || n < 1 {
+			return nil, invalid
+		}
+		unit = strings.TrimSuffix(fields[2], "s")
+		fields = fields[2:]
+	default:
+		return nil, invalid
+	}
+	switch unit {
+	case "day":
+		r.days = n
+	case "week":
+		r.days = 7 * n
+	case "month":
+		r.months = n
+	case "year":
+		r.months = 12 * n
+	default:
+		return nil, invalid
+	}
+
+	switch rest := fields[1:]; {
+	case len(rest) == 0:
+		return nil, fmt.Errorf("recurrence rule %q has no end (expected \"until YYYY-MM-DD\" or \"N times\")", rule)
+	case len(rest) == 2 && rest[0] == "until":
+		until, err := ast.NewDate(rest[1])
+		if err != nil {
+			return nil, invalid
+		}
+		r.until = until.Time
+	case len(rest) == 2 && (rest[1] == "times" || rest[1] == "time"):
+		count, err := strconv.Atoi(rest[0])
+		if err != nil || count < 1 {
+			return nil, invalid
+		}
+		r.count = count
+	default:
+		return nil, invalid
+	}
+	return r, nil
+}
+
+// dates returns the occurrences after start. Monthly steps are counted from
+// start and clamped to the end of shorter months, so a rule starting on the
+// 31st falls on the 30th in April and back on the 31st in May.
+func (r *recurrence) dates(start time.Time) []time.Time {
+	var dates []time.Time
+	for i := 1; r.count == 0 || i < r.count; i++ {
+		var date time.Time
+		if r.days > 0 {
+			date = start.AddDate(0, 0, i*r.days)
+		} else {
+			date = addMonths(start, i*r.months)
+		}
+		if r.count == 0 && date.After(r.until) {
+			break
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// addMonths adds months to t, clamping the day to the end of the month.
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// isRecurringInstance reports whether directive was generated by
+// expandRecurring.
+func isRecurringInstance(directive ast.Directive) bool {
+	txn, ok := directive.(*ast.Transaction)
+	return ok && txn.Generated
+}
+
+// recurringInstance returns a copy of template dated on date. Postings are
+// copied too, since processing fills in their inferred amounts and costs.
+func recurringInstance(template *ast.Transaction, date *ast.Date) *ast.Transaction {
+	txn := *template
+	txn.SetDate(date)
+	txn.Metadata = slices.DeleteFunc(slices.Clone(template.Metadata), func(m *ast.Metadata) bool {
+		return m.Key == recurKey
+	})
+	txn.Generated = true
+	txn.BodyItems = nil
+	txn.Postings = make([]*ast.Posting, len(template.Postings))
+	for i, posting := range template.Postings {
+		p := *posting
+		if posting.Cost != nil {
+			cost := *posting.Cost
+			p.Cost = &cost
+		}
+		txn.Postings[i] = &p
+	}
+	return &txn
+}
@@ -0,0 +1,177 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+// recurringDates returns the dates of the transactions in tree with the
+// given narration.
+func recurringDates(tree *ast.AST, narration string) []string {
+	var dates []string
+	for _, directive := range tree.Directives {
+		if txn, ok := directive.(*ast.Transaction); ok && txn.Narration.Value == narration {
+			dates = append(dates, txn.Date().String())
+		}
+	}
+	return dates
+}
+
+func TestRecurringTransactions(t *testing.T) {
+	source := `
+2024-01-01 open Assets:Checking EUR
+2024-01-01 open Expenses:Rent
+2024-01-01 open Income:Salary
+
+2024-01-31 * "Landlord" "Rent" #recurring
+  recur: "monthly until 2024-05-31"
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+
+2024-01-05 * "Employer" "Salary" #recurring
+  recur: "every 2 weeks 3 times"
+  Assets:Checking  1500.00 EUR
+  Income:Salary
+`
+	tree := parser.MustParseBytes(context.Background(), []byte(source))
+	l := New()
+	assert.NoError(t, l.Process(context.Background(), tree))
+
+	// Month ends are clamped and recover in longer months.
+	assert.Equal(t, []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"}, recurringDates(tree, "Rent"))
+	assert.Equal(t, []string{"2024-01-05", "2024-01-19", "2024-02-02"}, recurringDates(tree, "Salary"))
+
+	account, ok := l.GetAccount("Assets:Checking")
+	assert.True(t, ok)
+	assert.Equal(t, "-500", account.Inventory.Get("EUR").String())
+	end, err := ast.NewDate("2024-02-15")
+	assert.NoError(t, err)
+	assert.Equal(t, "3500", account.GetBalanceInPeriod(*end, *end).Get("EUR").String())
+
+	// Instances keep the tag but not the rule, are marked as generated,
+	// and own their postings.
+	var template, instance *ast.Transaction
+	for _, directive := range tree.Directives {
+		if txn, ok := directive.(*ast.Transaction); ok && txn.Narration.Value == "Rent" {
+			if template == nil {
+				template = txn
+			} else if instance == nil {
+				instance = txn
+			}
+		}
+	}
+	assert.Equal(t, 1, len(template.Metadata))
+	assert.Equal(t, 0, len(instance.Metadata))
+	assert.False(t, template.Generated)
+	assert.True(t, instance.Generated)
+	assert.Equal(t, []ast.Tag{"recurring"}, instance.Tags)
+	assert.True(t, template.Postings[1] != instance.Postings[1])
+	assert.Equal(t, "-1000.00", instance.Postings[1].Amount.Value)
+}
+
+func TestRecurringProcessedTwice(t *testing.T) {
+	source := `
+2024-01-01 open Assets:Checking EUR
+2024-01-01 open Expenses:Rent
+
+2024-01-31 * "Landlord" "Rent" #recurring
+  recur: "monthly 3 times"
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+
+2024-01-01 * "Landlord" "Deposit"
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+`
+	// Only the generated instances are replaced, not transactions that
+	// carry metadata of their own, such as a plugin's.
+	tree := parser.MustParseBytes(context.Background(), []byte(source))
+	deposit := tree.Directives[3].(*ast.Transaction)
+	deposit.Metadata = append(deposit.Metadata, ast.NewMetadata("__recurring__", "2024-01-31"))
+	for range 2 {
+		l := New()
+		assert.NoError(t, l.Process(context.Background(), tree))
+
+		assert.Equal(t, 6, len(tree.Directives))
+		assert.Equal(t, []string{"2024-01-31", "2024-02-29", "2024-03-31"}, recurringDates(tree, "Rent"))
+		account, ok := l.GetAccount("Assets:Checking")
+		assert.True(t, ok)
+		assert.Equal(t, "-4000", account.Inventory.Get("EUR").String())
+	}
+}
+
+func TestRecurringErrors(t *testing.T) {
+	source := `
+2024-01-01 open Assets:Checking EUR
+2024-01-01 open Expenses:Rent
+
+2024-01-31 * "Rent" #recurring
+  recur: "fortnightly"
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+
+2024-01-31 * "Rent" #recurring
+  recur: "every 0 days"
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+
+2024-01-31 * "Rent" #recurring
+  recur: TRUE
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+
+2024-01-31 * "Rent" #recurring
+  recur: "monthly"
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+
+2024-01-31 * "Rent"
+  recur: "weekly"
+  Expenses:Rent  1000.00 EUR
+  Assets:Checking
+`
+	tree, err := parser.ParseBytesWithFilename(context.Background(), "rent.beancount", []byte(source))
+	assert.NoError(t, err)
+	err = New().Process(context.Background(), tree)
+
+	var verr *ValidationErrors
+	assert.True(t, errors.As(err, &verr))
+	assert.Equal(t, 4, len(verr.Errors))
+	assert.Contains(t, verr.Errors[0].Error(), `rent.beancount:5: Invalid metadata: key="recur", value="fortnightly": unknown recurrence rule "fortnightly"`)
+	assert.Contains(t, verr.Errors[1].Error(), `unknown recurrence rule "every 0 days"`)
+	assert.Contains(t, verr.Errors[2].Error(), "expected a recurrence rule string")
+	assert.Contains(t, verr.Errors[3].Error(), `recurrence rule "monthly" has no end`)
+	// Only the templates themselves were booked.
+	assert.Equal(t, 5, len(recurringDates(tree, "Rent")))
+}
+
+func TestParseRecurrence(t *testing.T) {
+	for _, tt := range []struct {
+		rule   string
+		days   int
+		months int
+		count  int
+	}{
+		{"daily 10 times", 1, 0, 10},
+		{"Weekly until 2030-01-01", 7, 0, 0},
+		{"quarterly 4 times", 0, 3, 4},
+		{"yearly 1 time", 0, 12, 1},
+		{"every 1 day 2 times", 1, 0, 2},
+		{"every 3 weeks until 2030-01-01", 21, 0, 0},
+		{"every 6 months until 2030-01-01", 0, 6, 0},
+	} {
+		r, err := parseRecurrence(tt.rule)
+		assert.NoError(t, err, tt.rule)
+		assert.Equal(t, []int{tt.days, tt.months, tt.count}, []int{r.days, r.months, r.count}, tt.rule)
+	}
+
+	for _, rule := range []string{"", "every", "every two weeks", "every 2 fortnights", "monthly until tomorrow", "monthly 0 times", "monthly forever", "monthly", "every 2 weeks"} {
+		_, err := parseRecurrence(rule)
+		assert.Error(t, err, rule)
+	}
+}
@@ -0,0 +1,139 @@
+package ledger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+// Valuation selects how GetConvertedBalanceTree values amounts in another
+// currency.
+type Valuation string
+
+const (
+	// ValuationCost values positions held at cost at their cost basis, and
+	// converts amounts in other currencies at the rate on their transaction
+	// date.
+	ValuationCost Valuation = "cost"
+
+	// ValuationMarket converts balances at the prices on the end date.
+	ValuationMarket Valuation = "market"
+
+	// ValuationHistorical converts every posting at the rate on its
+	// transaction date.
+	ValuationHistorical Valuation = "historical"
+)
+
+// ParseValuation returns the valuation with the given name.
+func ParseValuation(name string) (Valuation, error) {
+	switch valuation := Valuation(name); valuation {
+	case ValuationCost, ValuationMarket, ValuationHistorical:
+		return valuation, nil
+	}
+	return "", fmt.Errorf("unknown valuation %q (expected cost, market or historical)", name)
+}
+
+// GetConvertedBalanceTree returns the balance tree GetBalanceTree returns for
+// the same arguments and options, with every balance converted to currency
+// as valuation says. Market values without dates use today's prices.
+//
+// Amounts without a price path to currency are not dropped: they stay in
+// their own currency in each node's Unconverted balance. Converted amounts
+// are rounded per account to the places currency has in the ledger, or two
+// when it has none, and parents sum their children, so subtotals add up.
+//
+// Example:
+//
+//	tree, err := l.GetConvertedBalanceTree(types, end, end, "EUR", ledger.ValuationMarket)
+//	total := tree.Roots[0].Balance.Get("EUR")
+func (l *Ledger) GetConvertedBalanceTree(types []ast.AccountType, startDate, endDate *ast.Date, currency string, valuation Valuation, opts ...BalanceTreeOption) (*BalanceTree, error) {
+	if _, err := ParseValuation(string(valuation)); err != nil {
+		return nil, err
+	}
+
+	date := endDate
+	if date == nil {
+		now := time.Now()
+		date = ast.NewDateFromTime(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	}
+
+	o := newBalanceTreeOptions(opts)
+	places := l.currencyPlaces(currency)
+	tree, err := l.balanceTree(types, startDate, endDate, func(account *Account) balanceTreeEntry {
+		postings := account.Postings
+		if startDate != nil && endDate != nil {
+			postings = o.periodPostings(account, *startDate, *endDate)
+		}
+
+		balance, unconverted := NewBalance(), NewBalance()
+		convert := func(amount decimal.Decimal, from string, on *ast.Date) {
+			if from == currency {
+				balance.Add(currency, amount)
+			} else if value, err := l.forwardFillGraph(on).ConvertAmount(amount, from, currency, on); err == nil {
+				balance.Add(currency, value)
+			} else {
+				unconverted.Add(from, amount)
+			}
+		}
+
+		units := NewBalance()
+		for _, posting := range postings {
+			if posting.Posting.Amount == nil {
+				continue
+			}
+			amount, err := ParseAmount(posting.Posting.Amount)
+			if err != nil {
+				continue
+			}
+			from := posting.Posting.Amount.Currency
+			on := posting.Transaction.Date()
+
+			switch valuation {
+			case ValuationMarket:
+				units.Add(from, amount)
+			case ValuationCost:
+				if posting.Posting.Cost != nil {
+					if weights, err := calculateWeights(posting.Posting); err == nil && len(weights) > 0 {
+						convert(weights[0].Amount, weights[0].Currency, on)
+						continue
+					}
+				}
+				convert(amount, from, on)
+			case ValuationHistorical:
+				convert(amount, from, on)
+			}
+		}
+		for _, entry := range units.Entries() {
+			convert(entry.Amount, entry.Currency, date)
+		}
+
+		if !balance.IsZero() {
+			balance.Set(currency, balance.Get(currency).Round(places))
+		}
+		return balanceTreeEntry{account: account, balance: balance, unconverted: unconverted}
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree.Currency = currency
+	tree.Valuation = valuation
+	return tree, nil
+}
+
+// currencyPlaces returns the most decimal places of the amounts in currency
+// posted to the ledger's accounts, or two when there are none with more.
+func (l *Ledger) currencyPlaces(currency string) int32 {
+	places := int32(2)
+	for _, account := range l.accounts {
+		for _, posting := range account.Postings {
+			if amount := posting.Posting.Amount; amount != nil && amount.Currency == currency {
+				if value, err := ParseAmount(amount); err == nil {
+					places = max(places, -value.Exponent())
+				}
+			}
+		}
+	}
+	return places
+}
@@ -0,0 +1,154 @@
+package loader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sync"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+// Fingerprint returns a hash of file content for change detection.
+func Fingerprint(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// Cache keeps the parsed trees of loaded files between loads, keyed by path
+// and content fingerprint, so a reload only parses the files that changed.
+// When a file changed, its entries that precede the first changed byte are
+// taken from the previous parse, so they keep their identity and
+// ledger.ProcessIncremental can skip them.
+//
+// Cached directives are shared by every load that uses them. The ledger
+// fills in interpolated amounts on them, which gives the same result when
+// they are processed again.
+//
+// A Cache is safe for concurrent use. It is used when following includes.
+type Cache struct {
+	mu    sync.Mutex
+	files map[string]*cachedFile
+}
+
+type cachedFile struct {
+	fingerprint   string
+	data          []byte
+	recoverErrors bool
+	tree          *ast.AST // Push/pop directives applied and sorted
+	diagnostics   []error
+}
+
+// NewCache creates an empty cache.
+func NewCache() *Cache {
+	return &Cache{files: make(map[string]*cachedFile)}
+}
+
+// WithCache configures the loader to reuse the files parsed by earlier
+// loads through cache.
+func WithCache(cache *Cache) Option {
+	return func(l *Loader) {
+		l.Cache = cache
+	}
+}
+
+// parse returns the prepared tree of the file at absPath, parsing data only
+// if it differs from the cached version.
+func (c *Cache) parse(ctx context.Context, absPath, filename string, data []byte, recoverErrors bool) (*ast.AST, []error, error) {
+	fingerprint := Fingerprint(data)
+
+	c.mu.Lock()
+	previous := c.files[absPath]
+	c.mu.Unlock()
+	if previous != nil && previous.recoverErrors != recoverErrors {
+		previous = nil
+	}
+	if previous != nil && previous.fingerprint == fingerprint {
+		return shareTree(previous.tree), slices.Clone(previous.diagnostics), nil
+	}
+
+	tree, diagnostics, err := parseFile(ctx, filename, data, recoverErrors)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := prepareLoadedAST(tree); err != nil {
+		return nil, nil, err
+	}
+	if previous != nil {
+		reuseUnchanged(tree, data, previous)
+	}
+
+	c.mu.Lock()
+	c.files[absPath] = &cachedFile{
+		fingerprint:   fingerprint,
+		data:          data,
+		recoverErrors: recoverErrors,
+		tree:          tree,
+		diagnostics:   diagnostics,
+	}
+	c.mu.Unlock()
+
+	return shareTree(tree), slices.Clone(diagnostics), nil
+}
+
+// shareTree returns a copy of a cached tree that the loader and the ledger
+// can add directives to without changing the cached one.
+func shareTree(tree *ast.AST) *ast.AST {
+	shared := *tree
+	shared.Directives = slices.Clone(tree.Directives)
+	shared.Options = slices.Clip(tree.Options)
+	shared.Includes = slices.Clip(tree.Includes)
+	shared.Plugins = slices.Clip(tree.Plugins)
+	return &shared
+}
+
+// reuseUnchanged replaces the directives of tree whose source lies entirely
+// before the first byte that differs from the previous version of the file
+// with the directives parsed from that version.
+func reuseUnchanged(tree *ast.AST, data []byte, previous *cachedFile) {
+	prefix := 0
+	for prefix < len(data) && prefix < len(previous.data) && data[prefix] == previous.data[prefix] {
+		prefix++
+	}
+
+	before := directiveExtents(previous.tree, len(previous.data))
+	after := directiveExtents(tree, len(data))
+	for i, directive := range tree.Directives {
+		start := directive.Position().Offset
+		end := after[start].end
+		if end > prefix {
+			continue
+		}
+		if old, ok := before[start]; ok && old.end == end && old.directive.Kind() == directive.Kind() {
+			tree.Directives[i] = old.directive
+		}
+	}
+}
+
+type directiveExtent struct {
+	directive ast.Directive
+	end       int
+}
+
+// directiveExtents maps the offset of each directive in a file to the
+// directive and the offset where the next one starts.
+func directiveExtents(tree *ast.AST, size int) map[int]directiveExtent {
+	starts := make([]int, 0, len(tree.Directives))
+	extents := make(map[int]directiveExtent, len(tree.Directives))
+	for _, directive := range tree.Directives {
+		start := directive.Position().Offset
+		starts = append(starts, start)
+		extents[start] = directiveExtent{directive: directive}
+	}
+	slices.Sort(starts)
+	for i, start := range starts {
+		extent := extents[start]
+		extent.end = size
+		if i+1 < len(starts) {
+			extent.end = starts[i+1]
+		}
+		extents[start] = extent
+	}
+	return extents
+}
@@ -99,6 +99,16 @@ type Loader struct {
 	// beancount.ops.documents plugin. Formatting-only consumers should
 	// leave this off: bean-format never runs document discovery.
 	DiscoverDocuments bool
+
+	// RecoverErrors keeps parsing past syntax errors. Broken entries are
+	// left out of the AST and reported as *parser.ParseError values in
+	// LoadResult.Diagnostics, so callers can still work with the rest of
+	// the file. I/O errors and errors in include resolution stay fatal.
+	RecoverErrors bool
+
+	// Cache, when set, reuses the trees of files parsed by earlier loads
+	// while following includes.
+	Cache *Cache
 }
 
 // Option configures how files are loaded.
@@ -129,6 +139,15 @@ func WithDocumentsDiscovery() Option {
 	}
 }
 
+// WithErrorRecovery configures the loader to report every syntax error as a
+// diagnostic and continue with the entries that parsed, instead of failing on
+// the first one.
+func WithErrorRecovery() Option {
+	return func(l *Loader) {
+		l.RecoverErrors = true
+	}
+}
+
 // New creates a new Loader with the given options.
 func New(opts ...Option) *Loader {
 	l := &Loader{
@@ -162,14 +181,12 @@ func (l *Loader) Load(ctx context.Context, filename string) (*LoadResult, error)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
 		}
-		result, err := parser.ParseBytesWithFilename(ctx, filename, data)
+		result, diagnostics, err := parseFile(ctx, filename, data, l.RecoverErrors)
 		if err != nil {
-			// Wrap parser errors for consistent formatting
-			return nil, parser.NewParseErrorWithSource(filename, err, data)
+			return nil, err
 		}
-		var diagnostics []error
 		if l.DiscoverDocuments {
-			diagnostics = discoverDocuments(result, absPath)
+			diagnostics = append(diagnostics, discoverDocuments(result, absPath)...)
 		}
 		return &LoadResult{
 			AST:         result,
@@ -183,10 +200,12 @@ func (l *Loader) Load(ctx context.Context, filename string) (*LoadResult, error)
 	// Use root timer for hierarchy if available, otherwise create flat timers
 	rootTimer := telemetry.RootTimerFromContext(ctx)
 	state := &loaderState{
-		visited:   make(map[string]bool),
-		collector: collector,
-		rootTimer: rootTimer,
-		root:      absPath,
+		visited:       make(map[string]bool),
+		collector:     collector,
+		rootTimer:     rootTimer,
+		root:          absPath,
+		recoverErrors: l.RecoverErrors,
+		cache:         l.Cache,
 	}
 
 	ast, err := state.loadRecursive(ctx, filename)
@@ -320,6 +339,17 @@ func (l *Loader) MustLoad(ctx context.Context, filename string) *LoadResult {
 //	// Parse from bytes with file context for includes
 //	ast, err := ldr.LoadBytes(ctx, "/path/to/main.beancount", mainBytes)
 func (l *Loader) LoadBytes(ctx context.Context, filename string, data []byte) (*ast.AST, error) {
+	result, err := l.LoadBytesResult(ctx, filename, data)
+	if err != nil {
+		return nil, err
+	}
+	return result.AST, nil
+}
+
+// LoadBytesResult is like LoadBytes, but also returns the diagnostics
+// collected while parsing, such as the syntax errors skipped when
+// RecoverErrors is enabled. Root is set to filename.
+func (l *Loader) LoadBytesResult(ctx context.Context, filename string, data []byte) (*LoadResult, error) {
 	collector := telemetry.FromContext(ctx)
 
 	// For display in telemetry, use basename
@@ -327,9 +357,9 @@ func (l *Loader) LoadBytes(ctx context.Context, filename string, data []byte) (*
 	parseTimer := collector.Start(fmt.Sprintf("loader.parse %s", displayName))
 	defer parseTimer.End()
 
-	result, err := parser.ParseBytesWithFilename(ctx, filename, data)
+	result, diagnostics, err := parseFile(ctx, filename, data, l.RecoverErrors)
 	if err != nil {
-		return nil, parser.NewParseErrorWithSource(filename, err, data)
+		return nil, err
 	}
 
 	// If following includes is requested but we're parsing from stdin,
@@ -347,7 +377,34 @@ func (l *Loader) LoadBytes(ctx context.Context, filename string, data []byte) (*
 		return nil, fmt.Errorf("include directives found; use Load() instead of LoadBytes() to resolve includes")
 	}
 
-	return result, nil
+	return &LoadResult{
+		AST:         result,
+		Root:        filename,
+		Diagnostics: diagnostics,
+	}, nil
+}
+
+// parseFile parses one file's contents. Parser errors are wrapped for
+// consistent formatting; with recovery enabled they are returned as
+// diagnostics alongside the partial AST instead of failing the load.
+func parseFile(ctx context.Context, filename string, data []byte, recoverErrors bool) (*ast.AST, []error, error) {
+	if !recoverErrors {
+		result, err := parser.ParseBytesWithFilename(ctx, filename, data)
+		if err != nil {
+			return nil, nil, parser.NewParseErrorWithSource(filename, err, data)
+		}
+		return result, nil, nil
+	}
+
+	result, errs := parser.ParseBytesWithRecovery(ctx, filename, data)
+	if result == nil {
+		return nil, nil, parser.NewParseErrorWithSource(filename, errs[0], data)
+	}
+	diagnostics := make([]error, 0, len(errs))
+	for _, err := range errs {
+		diagnostics = append(diagnostics, parser.NewParseErrorWithSource(filename, err, data))
+	}
+	return result, diagnostics, nil
 }
 
 // MustLoadBytes parses beancount content from bytes, panicking on error.
@@ -367,11 +424,13 @@ func (l *Loader) MustLoadBytes(ctx context.Context, filename string, data []byte
 
 // loaderState tracks state during recursive loading.
 type loaderState struct {
-	visited     map[string]bool     // Absolute paths of files already loaded
-	collector   telemetry.Collector // Telemetry collector for tracking load operations
-	rootTimer   telemetry.Timer     // Root check timer from context
-	root        string
-	diagnostics []error
+	visited       map[string]bool     // Absolute paths of files already loaded
+	collector     telemetry.Collector // Telemetry collector for tracking load operations
+	rootTimer     telemetry.Timer     // Root check timer from context
+	root          string
+	recoverErrors bool   // Collect syntax errors as diagnostics instead of failing
+	cache         *Cache // Parsed files from earlier loads, if any
+	diagnostics   []error
 }
 
 // loadRecursive recursively loads a file and all its includes.
@@ -419,19 +478,14 @@ func (l *loaderState) loadRecursive(ctx context.Context, filename string) (*ast.
 		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
 	}
 
-	result, err := parser.ParseBytesWithFilename(ctx, filename, data)
+	result, parseErrs, err := l.parse(ctx, absPath, filename, data)
 	parseTimer.End()
 
 	if err != nil {
-		loadTimer.End()
-		// Wrap parser errors for consistent formatting
-		return nil, parser.NewParseErrorWithSource(filename, err, data)
-	}
-
-	if err := prepareLoadedAST(result); err != nil {
 		loadTimer.End()
 		return nil, err
 	}
+	l.diagnostics = append(l.diagnostics, parseErrs...)
 
 	if absPath != l.root {
 		for _, option := range result.Options {
@@ -497,6 +551,22 @@ func (l *loaderState) loadRecursive(ctx context.Context, filename string) (*ast.
 	return merged, nil
 }
 
+// parse parses and prepares one file, through the cache if there is one.
+func (l *loaderState) parse(ctx context.Context, absPath, filename string, data []byte) (*ast.AST, []error, error) {
+	if l.cache != nil {
+		return l.cache.parse(ctx, absPath, filename, data, l.recoverErrors)
+	}
+
+	result, diagnostics, err := parseFile(ctx, filename, data, l.recoverErrors)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := prepareLoadedAST(result); err != nil {
+		return nil, nil, err
+	}
+	return result, diagnostics, nil
+}
+
 // mergeASTs combines a main AST with multiple included ASTs.
 // The main AST's options take precedence over included files' options.
 // All directives are combined and sorted for ledger processing.
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -585,6 +586,110 @@ include "included.beancount"
 	assert.Equal(t, "beancount.plugins.auto_accounts", pluginNames[1])
 }
 
+func TestLoadWithErrorRecovery(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	includedFile := filepath.Join(tmpDir, "included.beancount")
+	err := os.WriteFile(includedFile, []byte(`
+2024-01-01 open Assets:Savings USD
+2024-01-02 opne Assets:Broken
+`), 0644)
+	assert.NoError(t, err)
+
+	mainFile := filepath.Join(tmpDir, "main.beancount")
+	err = os.WriteFile(mainFile, []byte(`
+include "included.beancount"
+
+2024-01-01 open Assets:Checking USD
+2024-01-02 open Assets:Other USD USD
+2024-01-03 open Income:Salary USD
+`), 0644)
+	assert.NoError(t, err)
+
+	// Without recovery the first syntax error is fatal
+	_, err = New(WithFollowIncludes()).Load(context.Background(), mainFile)
+	var parseErr *parser.ParseError
+	assert.True(t, errors.As(err, &parseErr))
+
+	result, err := New(WithFollowIncludes(), WithErrorRecovery()).Load(context.Background(), mainFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(result.AST.Directives))
+	assert.Equal(t, 2, len(diagnostic.Errors(result.Diagnostics)))
+
+	var files []string
+	for _, diag := range result.Diagnostics {
+		assert.True(t, errors.As(diag, &parseErr))
+		files = append(files, filepath.Base(parseErr.Pos.Filename))
+	}
+	assert.Equal(t, []string{"main.beancount", "included.beancount"}, files)
+}
+
+func TestLoadWithCache(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	accountsFile := filepath.Join(tmpDir, "accounts.beancount")
+	err := os.WriteFile(accountsFile, []byte(`2024-01-01 open Assets:Checking USD
+2024-01-01 open Expenses:Food USD
+`), 0644)
+	assert.NoError(t, err)
+
+	main := `include "accounts.beancount"
+
+2024-01-02 * "Grocer"
+  Expenses:Food  10.00 USD
+  Assets:Checking
+
+2024-02-02 * "Grocer"
+  Expenses:Food  20.00 USD
+  Assets:Checking
+`
+	mainFile := filepath.Join(tmpDir, "main.beancount")
+	assert.NoError(t, os.WriteFile(mainFile, []byte(main), 0644))
+
+	cache := NewCache()
+	ldr := New(WithFollowIncludes(), WithCache(cache))
+
+	first, err := ldr.Load(context.Background(), mainFile)
+	assert.NoError(t, err)
+	assert.NoError(t, ledger.New().Process(context.Background(), first.AST))
+
+	// Unchanged files hand out the same directives
+	second, err := ldr.Load(context.Background(), mainFile)
+	assert.NoError(t, err)
+	assert.Equal(t, len(first.AST.Directives), len(second.AST.Directives))
+	for i := range first.AST.Directives {
+		assert.True(t, first.AST.Directives[i] == second.AST.Directives[i])
+	}
+
+	// Entries before the first change keep their identity
+	edited := strings.Replace(main, "20.00 USD", "25.00 USD", 1) + `
+2024-02-03 * "Grocer"
+  Expenses:Food  5.00 USD
+  Assets:Checking
+`
+	assert.NoError(t, os.WriteFile(mainFile, []byte(edited), 0644))
+	third, err := ldr.Load(context.Background(), mainFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(third.AST.Directives))
+	for i := range 3 {
+		assert.True(t, first.AST.Directives[i] == third.AST.Directives[i])
+	}
+	assert.True(t, first.AST.Directives[3] != third.AST.Directives[3])
+	amount := third.AST.Directives[3].(*ast.Transaction).Postings[0].Amount
+	assert.Equal(t, "25.00", amount.Value)
+}
+
+func TestLoadBytesResultWithErrorRecovery(t *testing.T) {
+	result, err := New(WithErrorRecovery()).LoadBytesResult(context.Background(), "<stdin>", []byte(`
+2024-01-01 open Assets:Checking USD
+2024-01-01 open Assets:Savings USD USD
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "<stdin>", result.Root)
+	assert.Equal(t, 1, len(result.AST.Directives))
+	assert.Equal(t, 1, len(result.Diagnostics))
+}
+
 func TestLoadBytes(t *testing.T) {
 	t.Run("BasicLoadBytes", func(t *testing.T) {
 		testData := []byte(`
@@ -0,0 +1,338 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/formatter"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+// index holds the names offered for completion and the open directive of
+// every account, collected once per load.
+type index struct {
+	accounts   []string
+	currencies []string
+	payees     []string
+	tags       []string
+	links      []string
+	opens      map[ast.Account]*ast.Open
+}
+
+func newIndex(tree *ast.AST) *index {
+	enriched := tree.Enrich()
+	idx := &index{
+		accounts:   enriched.AccountList(),
+		currencies: enriched.CurrencyList(),
+		opens:      make(map[ast.Account]*ast.Open),
+	}
+
+	payees := make(map[string]bool)
+	tags := make(map[string]bool)
+	links := make(map[string]bool)
+	for _, directive := range tree.Directives {
+		switch d := directive.(type) {
+		case *ast.Open:
+			if _, ok := idx.opens[d.Account]; !ok {
+				idx.opens[d.Account] = d
+			}
+		case *ast.Transaction:
+			if d.Payee.Value != "" {
+				payees[d.Payee.Value] = true
+			}
+			for _, tag := range d.Tags {
+				tags[string(tag)] = true
+			}
+			for _, link := range d.Links {
+				links[string(link)] = true
+			}
+		}
+	}
+	idx.payees = sortedKeys(payees)
+	idx.tags = sortedKeys(tags)
+	idx.links = sortedKeys(links)
+	return idx
+}
+
+// accountDirectives are the directive keywords followed by an account.
+var accountDirectives = map[string]bool{
+	"open": true, "close": true, "balance": true, "pad": true, "note": true, "document": true,
+}
+
+// completion proposes names based on what precedes the cursor: tags after
+// #, links after ^, payees inside a transaction's first string, currencies
+// after a number or an open account, and accounts elsewhere.
+func (s *Server) completion(params TextDocumentPositionParams) []CompletionItem {
+	items := []CompletionItem{}
+	snap := s.current()
+	if snap == nil {
+		return items
+	}
+
+	text, _ := s.document(uriToPath(params.TextDocument.URI))
+	lines := splitLines(text)
+	if params.Position.Line >= len(lines) {
+		return items
+	}
+	line := lines[params.Position.Line]
+	before := line[:byteOffset(line, params.Position.Character)]
+
+	propose := func(names []string, start int, prefix string, kind int, detail string) []CompletionItem {
+		editRange := Range{
+			Start: Position{Line: params.Position.Line, Character: utf16Len(line[:start])},
+			End:   params.Position,
+		}
+		for _, name := range names {
+			items = append(items, CompletionItem{
+				Label:    prefix + name,
+				Kind:     kind,
+				Detail:   detail,
+				TextEdit: &TextEdit{Range: editRange, NewText: prefix + name},
+			})
+		}
+		return items
+	}
+
+	if strings.Count(before, `"`)%2 == 1 {
+		quote := strings.LastIndexByte(before, '"')
+		if !strings.Contains(before[:quote], `"`) && isTransactionHeader(before[:quote]) {
+			return propose(snap.index.payees, quote+1, "", CompletionKindValue, "payee")
+		}
+		return items
+	}
+
+	start := strings.LastIndexAny(before, " \t") + 1
+	token := before[start:]
+	fields := strings.Fields(before[:start])
+
+	switch {
+	case strings.HasPrefix(token, "#"):
+		return propose(snap.index.tags, start, "#", CompletionKindReference, "tag")
+	case strings.HasPrefix(token, "^"):
+		return propose(snap.index.links, start, "^", CompletionKindReference, "link")
+	case len(fields) > 0 && isNumberLike(fields[len(fields)-1]):
+		return propose(snap.index.currencies, start, "", CompletionKindUnit, "currency")
+	case len(fields) >= 3 && isDate(fields[0]) && fields[1] == "open":
+		return propose(snap.index.currencies, start, "", CompletionKindUnit, "currency")
+	case len(fields) == 2 && isDate(fields[0]) && (fields[1] == "commodity" || fields[1] == "price"):
+		return propose(snap.index.currencies, start, "", CompletionKindUnit, "currency")
+	case len(fields) == 0 && start > 0, // Posting or metadata line
+		len(fields) == 2 && isDate(fields[0]) && accountDirectives[fields[1]],
+		len(fields) == 3 && isDate(fields[0]) && fields[1] == "pad",
+		strings.Contains(token, ":"):
+		return propose(snap.index.accounts, start, "", CompletionKindModule, "account")
+	}
+	return items
+}
+
+func isDate(field string) bool {
+	return ast.IsDateLiteralShape([]byte(field))
+}
+
+// isTransactionHeader reports whether prefix is a date followed by a
+// transaction flag, optionally with more text.
+func isTransactionHeader(prefix string) bool {
+	fields := strings.Fields(prefix)
+	if len(fields) < 2 || !isDate(fields[0]) {
+		return false
+	}
+	flag := fields[1]
+	return flag == "txn" || len(flag) == 1 && strings.ContainsAny(flag, "*!#&?%PSTCURM")
+}
+
+// isNumberLike reports whether field ends a number or an arithmetic
+// expression, such as "10.00", "{100" or "(1+2)".
+func isNumberLike(field string) bool {
+	last := field[len(field)-1]
+	return last >= '0' && last <= '9' || last == ')'
+}
+
+// cursor returns the line under the cursor and the byte offset within it.
+func (s *Server) cursor(params TextDocumentPositionParams) (string, string, int) {
+	path := uriToPath(params.TextDocument.URI)
+	text, _ := s.document(path)
+	lines := splitLines(text)
+	if params.Position.Line >= len(lines) {
+		return path, "", 0
+	}
+	line := lines[params.Position.Line]
+	return path, line, byteOffset(line, params.Position.Character)
+}
+
+// definition resolves an account usage to its open directive.
+func (s *Server) definition(params TextDocumentPositionParams) []Location {
+	locations := []Location{}
+	snap := s.current()
+	if snap == nil {
+		return locations
+	}
+
+	_, line, col := s.cursor(params)
+	account := accountAt(line, col)
+	if account == "" {
+		return locations
+	}
+	open, ok := snap.index.opens[ast.Account(account)]
+	if !ok || !isSourcePosition(open.Position()) {
+		return locations
+	}
+	lines := newLineCache(s)
+	return append(locations, Location{
+		URI:   pathToURI(open.Position().Filename),
+		Range: lines.wordRange(open.Position(), account),
+	})
+}
+
+// hover describes the account under the cursor. On a posting line it shows
+// the running balance after that posting, otherwise the final balance.
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	snap := s.current()
+	if snap == nil {
+		return nil
+	}
+
+	path, line, col := s.cursor(params)
+	name := accountAt(line, col)
+	if name == "" {
+		return nil
+	}
+	account, ok := snap.ledger.GetAccount(name)
+	if !ok {
+		return nil
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "**%s**\n\n", account.Name)
+	if account.OpenDate != nil {
+		fmt.Fprintf(&buf, "Opened %s", account.OpenDate.String())
+		if account.CloseDate != nil {
+			fmt.Fprintf(&buf, ", closed %s", account.CloseDate.String())
+		}
+		buf.WriteString("\n\n")
+	}
+	if len(account.ConstraintCurrencies) > 0 {
+		fmt.Fprintf(&buf, "Currencies: %s\n\n", strings.Join(account.ConstraintCurrencies, ", "))
+	}
+
+	balance := ledger.NewBalance()
+	label := "Balance"
+	for _, posting := range account.Postings {
+		if posting.Posting.Amount != nil {
+			if amount, err := ledger.ParseAmount(posting.Posting.Amount); err == nil {
+				balance.Add(posting.Posting.Amount.Currency, amount)
+			}
+		}
+		pos := posting.Posting.Position()
+		if pos.Filename == path && pos.Line == params.Position.Line+1 {
+			label = "Running balance"
+			break
+		}
+	}
+	fmt.Fprintf(&buf, "%s: %s", label, balance.String())
+
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: buf.String()}}
+}
+
+// references finds every use of the account or link under the cursor
+// across the root ledger and its includes.
+func (s *Server) references(params ReferenceParams) []Location {
+	locations := []Location{}
+	snap := s.current()
+	if snap == nil {
+		return locations
+	}
+
+	_, line, col := s.cursor(params.TextDocumentPositionParams)
+	lines := newLineCache(s)
+	add := func(pos ast.Position, word string) {
+		if isSourcePosition(pos) {
+			locations = append(locations, Location{URI: pathToURI(pos.Filename), Range: lines.wordRange(pos, word)})
+		}
+	}
+
+	if link := linkAt(line, col); link != "" {
+		for _, directive := range snap.tree.Directives {
+			if txn, ok := directive.(*ast.Transaction); ok && slices.Contains(txn.Links, ast.Link(link)) {
+				add(txn.Position(), "^"+link)
+			}
+		}
+		return locations
+	}
+
+	name := accountAt(line, col)
+	if name == "" {
+		return locations
+	}
+	account := ast.Account(name)
+	for _, directive := range snap.tree.Directives {
+		switch d := directive.(type) {
+		case *ast.Open:
+			if d.Account == account && params.Context.IncludeDeclaration {
+				add(d.Position(), name)
+			}
+		case *ast.Close:
+			if d.Account == account {
+				add(d.Position(), name)
+			}
+		case *ast.Balance:
+			if d.Account == account {
+				add(d.Position(), name)
+			}
+		case *ast.Note:
+			if d.Account == account {
+				add(d.Position(), name)
+			}
+		case *ast.Document:
+			if d.Account == account {
+				add(d.Position(), name)
+			}
+		case *ast.Pad:
+			if d.Account == account || d.AccountPad == account {
+				add(d.Position(), name)
+			}
+		case *ast.Transaction:
+			for _, posting := range d.Postings {
+				if posting.Account == account {
+					add(posting.Position(), name)
+				}
+			}
+		}
+	}
+	return locations
+}
+
+// isSourcePosition reports whether pos points into a ledger file, rather
+// than at a directive synthesized by a plugin or the loader.
+func isSourcePosition(pos ast.Position) bool {
+	return pos.Filename != "" && !strings.HasPrefix(pos.Filename, "<") && pos.Line > 0
+}
+
+// formatting formats the whole document like `beancount format`.
+func (s *Server) formatting(ctx context.Context, params formattingParams) ([]TextEdit, error) {
+	path := uriToPath(params.TextDocument.URI)
+	text, ok := s.document(path)
+	if !ok {
+		return nil, fmt.Errorf("document not found: %s", params.TextDocument.URI)
+	}
+
+	tree, err := parser.ParseBytesWithFilename(ctx, path, []byte(text))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := formatter.New().Format(ctx, tree, []byte(text), &buf); err != nil {
+		return nil, err
+	}
+	if buf.String() == text {
+		return []TextEdit{}, nil
+	}
+
+	lines := splitLines(text)
+	end := Position{Line: len(lines) - 1, Character: utf16Len(lines[len(lines)-1])}
+	return []TextEdit{{Range: Range{End: end}, NewText: buf.String()}}, nil
+}
@@ -0,0 +1,434 @@
+// Package lsp implements a Language Server Protocol server for Beancount
+// ledgers, speaking JSON-RPC over a pair of streams (usually stdio).
+//
+// The server loads the root ledger with its includes through the loader,
+// validates it with the ledger package and publishes the resulting
+// diagnostics whenever a document is opened or saved. On top of the last
+// loaded ledger it provides completion of accounts, currencies, payees, tags
+// and links, document formatting, go-to-definition from an account to its
+// open directive, hover with the account's running balance, and references
+// for accounts and links across included files.
+//
+// The root ledger is the file passed to New, the journalFile initialization
+// option, or else the first document opened.
+//
+// Example usage:
+//
+//	server := lsp.New("main.beancount")
+//	err := server.Run(ctx, os.Stdin, os.Stdout)
+package lsp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/diagnostic"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/loader"
+)
+
+// diagnosticSource is reported as the source of every diagnostic.
+const diagnosticSource = "beancount"
+
+// Server is a language server for one root ledger.
+type Server struct {
+	conn *conn
+
+	mu        sync.Mutex
+	root      string            // Absolute path of the root ledger file
+	docs      map[string]string // Open document contents, by absolute path
+	snapshot  *snapshot         // Last successful load
+	published map[string]bool   // URIs holding diagnostics from the last publish
+	cache     *loader.Cache     // Parsed files kept between reloads
+	shutdown  bool
+}
+
+// snapshot is a loaded and validated ledger.
+type snapshot struct {
+	tree   *ast.AST
+	ledger *ledger.Ledger
+	index  *index
+}
+
+// New creates a server for the given root ledger file. root may be empty to
+// take it from the client instead.
+func New(root string) *Server {
+	if root != "" {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+	}
+	return &Server{
+		root:      root,
+		docs:      make(map[string]string),
+		published: make(map[string]bool),
+		cache:     loader.NewCache(),
+	}
+}
+
+// Run serves requests read from r, writing responses to w, until the client
+// sends exit or r is closed. It returns an error if the client exits without
+// a prior shutdown request.
+func (s *Server) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		msg, err := s.conn.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var respErr *ResponseError
+			if errors.As(err, &respErr) {
+				continue // Malformed body; the frame was consumed, keep going.
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			s.mu.Lock()
+			shutdown := s.shutdown
+			s.mu.Unlock()
+			if !shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(ctx, msg)
+		if msg.ID == nil {
+			continue // Notifications get no response.
+		}
+		if err := s.conn.reply(*msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches a single request or notification.
+func (s *Server) handle(ctx context.Context, msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params), nil
+
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		path := uriToPath(params.TextDocument.URI)
+		s.mu.Lock()
+		s.docs[path] = params.TextDocument.Text
+		if s.root == "" {
+			s.root = path
+		}
+		s.mu.Unlock()
+		return nil, s.reload(ctx)
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		// Full document sync: the last change holds the whole text.
+		if n := len(params.ContentChanges); n > 0 {
+			s.mu.Lock()
+			s.docs[uriToPath(params.TextDocument.URI)] = params.ContentChanges[n-1].Text
+			s.mu.Unlock()
+		}
+		return nil, nil
+
+	case "textDocument/didSave":
+		var params didSaveParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if params.Text != nil {
+			s.mu.Lock()
+			s.docs[uriToPath(params.TextDocument.URI)] = *params.Text
+			s.mu.Unlock()
+		}
+		return nil, s.reload(ctx)
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		delete(s.docs, uriToPath(params.TextDocument.URI))
+		s.mu.Unlock()
+		return nil, nil
+
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params), nil
+
+	case "textDocument/formatting":
+		var params formattingParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.formatting(ctx, params)
+
+	default:
+		if msg.ID == nil {
+			return nil, nil // Unknown notifications such as $/cancelRequest are ignored.
+		}
+		return nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+func unmarshalParams(msg *message, v any) error {
+	if len(msg.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// initialize records the root ledger and advertises the server capabilities.
+func (s *Server) initialize(params InitializeParams) any {
+	s.mu.Lock()
+	if s.root == "" && params.InitializationOptions.JournalFile != "" {
+		journal := params.InitializationOptions.JournalFile
+		if !filepath.IsAbs(journal) && params.RootURI != "" {
+			journal = filepath.Join(uriToPath(params.RootURI), journal)
+		}
+		if abs, err := filepath.Abs(journal); err == nil {
+			s.root = abs
+		}
+	}
+	s.mu.Unlock()
+
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    1, // Full
+				"save":      map[string]any{"includeText": false},
+			},
+			"completionProvider": map[string]any{
+				"triggerCharacters": []string{":", "#", "^", "\""},
+			},
+			"definitionProvider":         true,
+			"hoverProvider":              true,
+			"referencesProvider":         true,
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]any{"name": "beancount"},
+	}
+}
+
+// reload loads and validates the root ledger from disk and publishes its
+// diagnostics. Only changed files are parsed again and validation resumes
+// from the earliest change. A ledger that fails to load keeps the previous snapshot, so
+// completion and navigation keep working while the file is broken.
+func (s *Server) reload(ctx context.Context) error {
+	s.mu.Lock()
+	root := s.root
+	var prev *ledger.Ledger
+	if s.snapshot != nil {
+		prev = s.snapshot.ledger
+	}
+	s.mu.Unlock()
+	if root == "" {
+		return nil
+	}
+
+	ldr := loader.New(loader.WithFollowIncludes(), loader.WithDocumentsDiscovery(), loader.WithErrorRecovery(), loader.WithCache(s.cache))
+	result, err := ldr.Load(ctx, root)
+	if err != nil {
+		return s.publish(root, []string{root}, []error{err})
+	}
+
+	l := ledger.New()
+	_ = l.ProcessIncremental(ctx, result.AST, prev) // Validation errors in l.Diagnostics()
+
+	s.mu.Lock()
+	s.snapshot = &snapshot{tree: result.AST, ledger: l, index: newIndex(result.AST)}
+	s.mu.Unlock()
+
+	files := append([]string{result.Root}, result.Includes...)
+	diagnostics := append(result.Diagnostics, l.Diagnostics()...)
+	return s.publish(root, files, diagnostics)
+}
+
+// publish sends the diagnostics grouped by file. Every ledger file gets a
+// notification, and files that had diagnostics before but none now are
+// cleared explicitly.
+func (s *Server) publish(root string, files []string, errs []error) error {
+	byURI := make(map[string][]Diagnostic)
+	for _, file := range files {
+		byURI[pathToURI(file)] = []Diagnostic{}
+	}
+
+	lines := newLineCache(s)
+	for _, err := range errs {
+		pos := errorPosition(err)
+		if pos.Filename == "" {
+			pos = ast.Position{Filename: root, Line: 1}
+		}
+
+		severity := SeverityError
+		if diagnostic.SeverityOf(err) == diagnostic.SeverityWarning {
+			severity = SeverityWarning
+		}
+
+		uri := pathToURI(pos.Filename)
+		byURI[uri] = append(byURI[uri], Diagnostic{
+			Range:    lines.lineRange(pos),
+			Severity: severity,
+			Source:   diagnosticSource,
+			Message:  diagnosticMessage(err, pos),
+		})
+	}
+
+	s.mu.Lock()
+	previous := s.published
+	s.published = make(map[string]bool)
+	for uri, diagnostics := range byURI {
+		if len(diagnostics) > 0 {
+			s.published[uri] = true
+		}
+	}
+	s.mu.Unlock()
+	for uri := range previous {
+		if _, ok := byURI[uri]; !ok {
+			byURI[uri] = []Diagnostic{}
+		}
+	}
+
+	for _, uri := range sortedKeys(byURI) {
+		diagnostics := byURI[uri]
+		slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+			return cmp.Compare(a.Range.Start.Line, b.Range.Start.Line)
+		})
+		params := &PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics}
+		if err := s.conn.notify("textDocument/publishDiagnostics", params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errorPosition returns where err occurred. Loader, parser and plugin errors
+// expose GetPosition; ledger errors expose the directive's Position.
+func errorPosition(err error) ast.Position {
+	switch e := err.(type) {
+	case interface{ GetPosition() ast.Position }:
+		return e.GetPosition()
+	case interface{ Position() ast.Position }:
+		return e.Position()
+	default:
+		return ast.Position{}
+	}
+}
+
+// diagnosticMessage strips the location prefix most errors carry, since the
+// client shows the diagnostic at that location already.
+func diagnosticMessage(err error, pos ast.Position) string {
+	message := err.Error()
+	for _, prefix := range []string{
+		pos.String() + ": ",
+		fmt.Sprintf("%s:%d: ", pos.Filename, pos.Line),
+	} {
+		if trimmed, ok := strings.CutPrefix(message, prefix); ok {
+			return trimmed
+		}
+	}
+	return message
+}
+
+// document returns the text of path, preferring the open editor buffer.
+// Must not be called with s.mu held.
+func (s *Server) document(path string) (string, bool) {
+	s.mu.Lock()
+	text, ok := s.docs[path]
+	s.mu.Unlock()
+	if ok {
+		return text, true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// current returns the last loaded snapshot, or nil.
+func (s *Server) current() *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot
+}
+
+// uriToPath converts a file:// URI to an absolute path.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path))
+}
+
+// pathToURI converts an absolute path to a file:// URI.
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,276 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+const testMain = `include "accounts.beancount"
+
+2024-01-02 * "Grocer" "Weekly shopping" #food ^receipt-1
+  Assets:Checking  -40.00 USD
+  Expenses:Food
+
+2024-01-09 * "Grocer" "More shopping" ^receipt-1
+  Assets:Checking  -10.00 USD
+  Expenses:Food
+`
+
+const testAccounts = `2024-01-01 open Assets:Checking USD
+2024-01-01 open Expenses:Food
+2024-01-01 open Equity:Opening
+
+2024-01-01 pad Assets:Checking Equity:Opening
+`
+
+// session scripts a client conversation and collects the server output.
+type session struct {
+	t     *testing.T
+	input bytes.Buffer
+	id    int
+}
+
+func (s *session) send(method string, params any, request bool) int {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if request {
+		s.id++
+		msg["id"] = s.id
+	}
+	body, err := json.Marshal(msg)
+	assert.NoError(s.t, err)
+	fmt.Fprintf(&s.input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return s.id
+}
+
+// run executes the scripted conversation and returns the responses by id
+// and the notifications in order.
+func (s *session) run(server *Server) (map[int]json.RawMessage, []map[string]json.RawMessage) {
+	s.send("shutdown", nil, true)
+	s.send("exit", nil, false)
+
+	var output bytes.Buffer
+	assert.NoError(s.t, server.Run(context.Background(), &s.input, &output))
+
+	responses := make(map[int]json.RawMessage)
+	var notifications []map[string]json.RawMessage
+	r := bufio.NewReader(&output)
+	for {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		var length int
+		_, err = fmt.Sscanf(header, "Content-Length: %d", &length)
+		assert.NoError(s.t, err)
+		_, _ = r.ReadString('\n')
+		body := make([]byte, length)
+		_, err = io.ReadFull(r, body)
+		assert.NoError(s.t, err)
+
+		var msg map[string]json.RawMessage
+		assert.NoError(s.t, json.Unmarshal(body, &msg))
+		if raw, ok := msg["id"]; ok {
+			var id int
+			assert.NoError(s.t, json.Unmarshal(raw, &id))
+			if errRaw, ok := msg["error"]; ok {
+				responses[id] = errRaw
+			} else {
+				responses[id] = msg["result"]
+			}
+		} else {
+			notifications = append(notifications, msg)
+		}
+	}
+	return responses, notifications
+}
+
+func writeLedger(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.beancount")
+	accountsFile := filepath.Join(dir, "accounts.beancount")
+	assert.NoError(t, os.WriteFile(mainFile, []byte(testMain), 0o600))
+	assert.NoError(t, os.WriteFile(accountsFile, []byte(testAccounts), 0o600))
+	return mainFile, accountsFile
+}
+
+func position(uri string, line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+func TestServerLifecycle(t *testing.T) {
+	s := &session{t: t}
+	initID := s.send("initialize", map[string]any{}, true)
+	unknownID := s.send("workspace/unknown", nil, true)
+
+	responses, _ := s.run(New(""))
+
+	var result struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	assert.NoError(t, json.Unmarshal(responses[initID], &result))
+	assert.Equal(t, true, result.Capabilities["definitionProvider"])
+	assert.Contains(t, string(responses[unknownID]), "method not found")
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	var input bytes.Buffer
+	body := `{"jsonrpc":"2.0","method":"exit"}`
+	fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	err := New("").Run(context.Background(), &input, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestServerPublishesDiagnostics(t *testing.T) {
+	mainFile, accountsFile := writeLedger(t)
+	broken := testMain + "\n2024-01-10 balance Expenses:Food 1.00 USD\n2024-01-11 opne Assets:Savings\n"
+	assert.NoError(t, os.WriteFile(mainFile, []byte(broken), 0o600))
+
+	s := &session{t: t}
+	s.send("initialize", map[string]any{}, true)
+	s.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": pathToURI(mainFile), "version": 1, "text": broken},
+	}, false)
+
+	_, notifications := s.run(New(mainFile))
+
+	published := make(map[string][]Diagnostic)
+	for _, n := range notifications {
+		var params PublishDiagnosticsParams
+		assert.NoError(t, json.Unmarshal(n["params"], &params))
+		published[params.URI] = params.Diagnostics
+	}
+
+	// The pad is never used, which is reported in the included file
+	assert.Equal(t, 1, len(published[pathToURI(accountsFile)]))
+	assert.True(t, strings.HasPrefix(published[pathToURI(accountsFile)][0].Message, "Unused Pad entry"))
+	diagnostics := published[pathToURI(mainFile)]
+	assert.Equal(t, 2, len(diagnostics))
+	assert.Equal(t, 10, diagnostics[0].Range.Start.Line)
+	assert.Equal(t, 11, diagnostics[1].Range.Start.Line)
+	assert.Equal(t, SeverityError, diagnostics[1].Severity)
+	assert.True(t, strings.HasPrefix(diagnostics[0].Message, "Balance mismatch for Expenses:Food"))
+}
+
+func TestServerCompletion(t *testing.T) {
+	mainFile, _ := writeLedger(t)
+	uri := pathToURI(mainFile)
+	text := testMain + "\n2024-01-10 * \"Gr\" #f\n  Assets:Ch\n  Expenses:Food  5.00 U\n"
+
+	s := &session{t: t}
+	s.send("initialize", map[string]any{}, true)
+	s.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 1, "text": testMain},
+	}, false)
+	s.send("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri},
+		"contentChanges": []map[string]any{{"text": text}},
+	}, false)
+	payeeID := s.send("textDocument/completion", position(uri, 10, 16), true)
+	tagID := s.send("textDocument/completion", position(uri, 10, 20), true)
+	accountID := s.send("textDocument/completion", position(uri, 11, 11), true)
+	currencyID := s.send("textDocument/completion", position(uri, 12, 22), true)
+
+	responses, _ := s.run(New(""))
+
+	labels := func(id int) []string {
+		var items []CompletionItem
+		assert.NoError(t, json.Unmarshal(responses[id], &items))
+		var out []string
+		for _, item := range items {
+			out = append(out, item.Label)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"Grocer"}, labels(payeeID))
+	assert.Equal(t, []string{"#food"}, labels(tagID))
+	assert.Equal(t, []string{"Assets:Checking", "Equity:Opening", "Expenses:Food"}, labels(accountID))
+	assert.Equal(t, []string{"USD"}, labels(currencyID))
+}
+
+func TestServerNavigation(t *testing.T) {
+	mainFile, accountsFile := writeLedger(t)
+	uri := pathToURI(mainFile)
+
+	s := &session{t: t}
+	s.send("initialize", map[string]any{}, true)
+	s.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 1, "text": testMain},
+	}, false)
+	definitionID := s.send("textDocument/definition", position(uri, 3, 5), true)
+	hoverID := s.send("textDocument/hover", position(uri, 7, 5), true)
+	refs := position(uri, 3, 5)
+	refs["context"] = map[string]any{"includeDeclaration": true}
+	referencesID := s.send("textDocument/references", refs, true)
+	linkID := s.send("textDocument/references", position(uri, 2, 50), true)
+
+	responses, _ := s.run(New(mainFile))
+
+	var definition []Location
+	assert.NoError(t, json.Unmarshal(responses[definitionID], &definition))
+	assert.Equal(t, []Location{{
+		URI:   pathToURI(accountsFile),
+		Range: Range{Start: Position{Line: 0, Character: 16}, End: Position{Line: 0, Character: 31}},
+	}}, definition)
+
+	var hover Hover
+	assert.NoError(t, json.Unmarshal(responses[hoverID], &hover))
+	assert.Contains(t, hover.Contents.Value, "**Assets:Checking**")
+	assert.Contains(t, hover.Contents.Value, "Running balance: -50 USD")
+
+	var references []Location
+	assert.NoError(t, json.Unmarshal(responses[referencesID], &references))
+	var where []string
+	for _, ref := range references {
+		where = append(where, fmt.Sprintf("%s:%d", filepath.Base(uriToPath(ref.URI)), ref.Range.Start.Line))
+	}
+	assert.Equal(t, []string{"accounts.beancount:0", "accounts.beancount:4", "main.beancount:3", "main.beancount:7"}, where)
+
+	var links []Location
+	assert.NoError(t, json.Unmarshal(responses[linkID], &links))
+	assert.Equal(t, 2, len(links))
+	assert.Equal(t, 6, links[1].Range.Start.Line)
+}
+
+func TestServerFormatting(t *testing.T) {
+	mainFile, _ := writeLedger(t)
+	uri := pathToURI(mainFile)
+	text := "2024-01-01 open Assets:Checking USD\n2024-01-02 * \"Shop\"\n  Assets:Checking -1.00 USD\n  Expenses:Food 1.00 USD\n"
+
+	s := &session{t: t}
+	s.send("initialize", map[string]any{}, true)
+	s.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 1, "text": text},
+	}, false)
+	formatID := s.send("textDocument/formatting", map[string]any{"textDocument": map[string]any{"uri": uri}}, true)
+	s.send("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri},
+		"contentChanges": []map[string]any{{"text": "2024-01-01 opne"}},
+	}, false)
+	brokenID := s.send("textDocument/formatting", map[string]any{"textDocument": map[string]any{"uri": uri}}, true)
+
+	responses, _ := s.run(New(mainFile))
+
+	var edits []TextEdit
+	assert.NoError(t, json.Unmarshal(responses[formatID], &edits))
+	assert.Equal(t, 1, len(edits))
+	assert.Equal(t, Position{Line: 4, Character: 0}, edits[0].Range.End)
+	assert.Contains(t, edits[0].NewText, "  Assets:Checking  -1.00 USD\n")
+
+	var respErr ResponseError
+	assert.NoError(t, json.Unmarshal(responses[brokenID], &respErr))
+	assert.Equal(t, codeRequestFailed, respErr.Code)
+}
@@ -0,0 +1,161 @@
+package lsp
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+// lineCache reads ledger files at most once while converting positions.
+type lineCache struct {
+	server *Server
+	files  map[string][]string
+}
+
+func newLineCache(s *Server) *lineCache {
+	return &lineCache{server: s, files: make(map[string][]string)}
+}
+
+// line returns the text of the 1-based line in filename, or "".
+func (c *lineCache) line(filename string, line int) string {
+	lines, ok := c.files[filename]
+	if !ok {
+		text, _ := c.server.document(filename)
+		lines = splitLines(text)
+		c.files[filename] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return lines[line-1]
+}
+
+// lineRange spans from the position's column to the end of its line.
+func (c *lineCache) lineRange(pos ast.Position) Range {
+	text := c.line(pos.Filename, pos.Line)
+	line := max(pos.Line-1, 0)
+	start := 0
+	if pos.Column > 1 {
+		start = utf16Len(text[:min(pos.Column-1, len(text))])
+	}
+	end := utf16Len(text)
+	if end < start {
+		end = start
+	}
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+// wordRange spans the first occurrence of word on the position's line at
+// or after its column, falling back to the whole line.
+func (c *lineCache) wordRange(pos ast.Position, word string) Range {
+	text := c.line(pos.Filename, pos.Line)
+	from := 0
+	if pos.Column > 1 {
+		from = min(pos.Column-1, len(text))
+	}
+	idx := strings.Index(text[from:], word)
+	if idx < 0 {
+		if idx = strings.Index(text, word); idx < 0 {
+			return c.lineRange(pos)
+		}
+	} else {
+		idx += from
+	}
+	line := max(pos.Line-1, 0)
+	start := utf16Len(text[:idx])
+	return Range{
+		Start: Position{Line: line, Character: start},
+		End:   Position{Line: line, Character: start + utf16Len(word)},
+	}
+}
+
+// splitLines splits text on \n, \r\n and \r line breaks.
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(text, "\n")
+}
+
+// utf16Len returns the length of s in UTF-16 code units, the unit LSP
+// character offsets are measured in.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// byteOffset converts a UTF-16 character offset into a byte offset in line,
+// clamped to the line length.
+func byteOffset(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// isAccountByte reports whether b can appear in an account name. Bytes of
+// multi-byte UTF-8 sequences are accepted, like the lexer does.
+func isAccountByte(b byte) bool {
+	return b == ':' || b == '-' || b >= utf8.RuneSelf ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// isTagByte reports whether b can appear in a tag or link name.
+func isTagByte(b byte) bool {
+	return b == '-' || b == '_' || b == '/' || b == '.' ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// wordAt returns the run of bytes accepted by valid around byte offset col,
+// with its start offset.
+func wordAt(line string, col int, valid func(byte) bool) (string, int) {
+	start, end := col, col
+	for start > 0 && valid(line[start-1]) {
+		start--
+	}
+	for end < len(line) && valid(line[end]) {
+		end++
+	}
+	return line[start:end], start
+}
+
+// accountAt returns the account name under the cursor, if any.
+func accountAt(line string, col int) string {
+	word, _ := wordAt(line, col, isAccountByte)
+	word = strings.Trim(word, ":")
+	if !strings.Contains(word, ":") || word[0] < 'A' || word[0] > 'Z' {
+		return ""
+	}
+	return word
+}
+
+// linkAt returns the link name (without ^) under the cursor, if any.
+func linkAt(line string, col int) string {
+	word, start := wordAt(line, col, isTagByte)
+	if start == 0 || line[start-1] != '^' {
+		if col < len(line) && line[col] == '^' {
+			word, _ = wordAt(line, col+1, isTagByte)
+			return word
+		}
+		return ""
+	}
+	return word
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -37,96 +37,152 @@ func NewParser(source []byte, tokens []Token, filename string, interner *Interne
 
 // Parse parses the token stream into an AST.
 func (p *Parser) Parse() (*ast.AST, error) {
+	tree, errs := p.parse(false)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return tree, nil
+}
+
+// ParseRecover parses the token stream like Parse, but does not stop at the
+// first syntax error. After an error it skips ahead to the next line starting
+// with a date or keyword, so every broken entry is reported once and the
+// returned AST holds all entries that did parse.
+func (p *Parser) ParseRecover() (*ast.AST, []error) {
+	return p.parse(true)
+}
+
+func (p *Parser) parse(recovering bool) (*ast.AST, []error) {
 	tree := &ast.AST{}
 
+	var errs []error
 	for !p.isAtEnd() {
-		tokType := p.peek().Type
-
-		// Dispatch by token type
-		switch tokType {
-		case COMMENT:
-			comment := p.parseComment()
-			tree.Comments = append(tree.Comments, comment)
-
-		case NEWLINE:
-			blankLine := p.parseBlankLine()
-			tree.BlankLines = append(tree.BlankLines, blankLine)
-
-		case ASTERISK:
-			if p.isOrgHeaderStart() {
-				header := p.parseOrgHeader()
-				tree.Comments = append(tree.Comments, header)
-				continue
+		start := p.pos
+		if err := p.parseTopLevel(tree); err != nil {
+			errs = append(errs, err)
+			if !recovering {
+				return nil, errs
 			}
-			tok := p.peek()
-			return nil, p.errorAtToken(tok, "unexpected token %s %q", tok.Type, tok.String(p.source))
+			p.synchronize(start)
+		}
+	}
 
-		case OPTION:
-			opt, err := p.parseOption()
-			if err != nil {
-				return nil, err
-			}
-			tree.Options = append(tree.Options, opt)
+	return tree, errs
+}
 
-		case INCLUDE:
-			inc, err := p.parseInclude()
-			if err != nil {
-				return nil, err
-			}
-			tree.Includes = append(tree.Includes, inc)
+// synchronize skips the remainder of a broken entry that started at token
+// index start, stopping at the next date or keyword that begins a line.
+func (p *Parser) synchronize(start int) {
+	if p.pos == start {
+		p.advance()
+	}
+	for !p.isAtEnd() {
+		tok := p.peek()
+		if tok.Column == 1 && (tok.Type == DATE || p.isTopLevelKeyword(tok.Type)) {
+			return
+		}
+		p.advance()
+	}
+}
 
-		case PLUGIN:
-			plugin, err := p.parsePlugin()
-			if err != nil {
-				return nil, err
-			}
-			tree.Plugins = append(tree.Plugins, plugin)
+// isTopLevelKeyword reports whether typ starts an undated entry.
+func (p *Parser) isTopLevelKeyword(typ TokenType) bool {
+	switch typ {
+	case OPTION, INCLUDE, PLUGIN, PUSHTAG, POPTAG, PUSHMETA, POPMETA:
+		return true
+	default:
+		return false
+	}
+}
 
-		case PUSHTAG:
-			pushtag, err := p.parsePushtag()
-			if err != nil {
-				return nil, err
-			}
-			tree.Pushtags = append(tree.Pushtags, pushtag)
+// parseTopLevel parses one top-level entry into tree.
+func (p *Parser) parseTopLevel(tree *ast.AST) error {
+	tokType := p.peek().Type
+
+	// Dispatch by token type
+	switch tokType {
+	case COMMENT:
+		comment := p.parseComment()
+		tree.Comments = append(tree.Comments, comment)
+
+	case NEWLINE:
+		blankLine := p.parseBlankLine()
+		tree.BlankLines = append(tree.BlankLines, blankLine)
+
+	case ASTERISK:
+		if p.isOrgHeaderStart() {
+			header := p.parseOrgHeader()
+			tree.Comments = append(tree.Comments, header)
+			return nil
+		}
+		tok := p.peek()
+		return p.errorAtToken(tok, "unexpected token %s %q", tok.Type, tok.String(p.source))
 
-		case POPTAG:
-			poptag, err := p.parsePoptag()
-			if err != nil {
-				return nil, err
-			}
-			tree.Poptags = append(tree.Poptags, poptag)
+	case OPTION:
+		opt, err := p.parseOption()
+		if err != nil {
+			return err
+		}
+		tree.Options = append(tree.Options, opt)
 
-		case PUSHMETA:
-			pushmeta, err := p.parsePushmeta()
-			if err != nil {
-				return nil, err
-			}
-			tree.Pushmetas = append(tree.Pushmetas, pushmeta)
+	case INCLUDE:
+		inc, err := p.parseInclude()
+		if err != nil {
+			return err
+		}
+		tree.Includes = append(tree.Includes, inc)
 
-		case POPMETA:
-			popmeta, err := p.parsePopmeta()
-			if err != nil {
-				return nil, err
-			}
-			tree.Popmetas = append(tree.Popmetas, popmeta)
+	case PLUGIN:
+		plugin, err := p.parsePlugin()
+		if err != nil {
+			return err
+		}
+		tree.Plugins = append(tree.Plugins, plugin)
 
-		case DATE:
-			directive, err := p.parseDirective()
-			if err != nil {
-				return nil, err
-			}
-			tree.Directives = append(tree.Directives, directive)
+	case PUSHTAG:
+		pushtag, err := p.parsePushtag()
+		if err != nil {
+			return err
+		}
+		tree.Pushtags = append(tree.Pushtags, pushtag)
 
-		case EOF:
-			// Done - loop will exit via !p.isAtEnd()
+	case POPTAG:
+		poptag, err := p.parsePoptag()
+		if err != nil {
+			return err
+		}
+		tree.Poptags = append(tree.Poptags, poptag)
+
+	case PUSHMETA:
+		pushmeta, err := p.parsePushmeta()
+		if err != nil {
+			return err
+		}
+		tree.Pushmetas = append(tree.Pushmetas, pushmeta)
+
+	case POPMETA:
+		popmeta, err := p.parsePopmeta()
+		if err != nil {
+			return err
+		}
+		tree.Popmetas = append(tree.Popmetas, popmeta)
 
-		default:
-			tok := p.peek()
-			return nil, p.errorAtToken(tok, "unexpected token %s %q", tok.Type, tok.String(p.source))
+	case DATE:
+		directive, err := p.parseDirective()
+		if err != nil {
+			return err
 		}
+		tree.Directives = append(tree.Directives, directive)
+
+	case EOF:
+		// Done - loop will exit via !p.isAtEnd()
+
+	default:
+		tok := p.peek()
+		return p.errorAtToken(tok, "unexpected token %s %q", tok.Type, tok.String(p.source))
 	}
 
-	return tree, nil
+	return nil
 }
 
 // parseComment parses a comment token into a Comment AST node.
@@ -557,6 +613,38 @@ func ParseBytesWithFilename(ctx context.Context, filename string, data []byte) (
 	return tree, nil
 }
 
+// ParseBytesWithRecovery parses a raw source AST like ParseBytesWithFilename,
+// but reports every syntax error instead of stopping at the first one. The
+// returned AST holds the entries that parsed; broken entries are left out and
+// described by one *ParseError each. The AST is nil only when the source
+// cannot be tokenized at all (invalid UTF-8) or ctx is done.
+func ParseBytesWithRecovery(ctx context.Context, filename string, data []byte) (*ast.AST, []error) {
+	// Check for cancellation
+	select {
+	case <-ctx.Done():
+		return nil, []error{ctx.Err()}
+	default:
+	}
+
+	collector := telemetry.FromContext(ctx)
+
+	lexTimer := collector.Start("parser.lexing")
+	lexer := NewLexer(data, filename)
+	tokens, err := lexer.ScanAll()
+	lexTimer.End()
+
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	parseTimer := collector.Start("parser.parsing")
+	parser := NewParser(data, tokens, filename, lexer.Interner())
+	tree, errs := parser.ParseRecover()
+	parseTimer.End()
+
+	return tree, errs
+}
+
 // MustParseBytesWithFilename parses AST from bytes with a filename, panicking on error.
 // Intended for use in tests and examples where error handling is not needed.
 //
@@ -127,3 +127,38 @@ popmeta location:
 	assert.Equal(t, 0, len(txn.Metadata))
 	assert.Equal(t, 0, len(txn.Tags))
 }
+
+func TestParseBytesWithRecovery(t *testing.T) {
+	source := `option "title" "Test"
+2024-01-01 open Assets:Checking USD
+2024-01-01 opne Assets:Broken
+2024-01-02 * "Groceries"
+  Assets:Checking  -10.00 USD USD
+  Expenses:Food     10.00 USD
+option "operating_currency"
+2024-01-03 * "Dinner"
+  Assets:Checking  -20.00 USD
+  Expenses:Food     20.00 USD
+`
+
+	tree, errs := ParseBytesWithRecovery(context.Background(), "main.beancount", []byte(source))
+	assert.Equal(t, 3, len(errs))
+	var lines []int
+	for _, err := range errs {
+		parseErr, ok := err.(*ParseError)
+		assert.True(t, ok)
+		assert.Equal(t, "main.beancount", parseErr.Pos.Filename)
+		lines = append(lines, parseErr.Pos.Line)
+	}
+	assert.Equal(t, []int{3, 5, 7}, lines)
+
+	assert.Equal(t, 1, len(tree.Options))
+	assert.Equal(t, 2, len(tree.Directives))
+	assert.Equal(t, "Dinner", tree.Directives[1].(*ast.Transaction).Narration.Value)
+}
+
+func TestParseBytesWithRecoveryValidSource(t *testing.T) {
+	tree, errs := ParseBytesWithRecovery(context.Background(), "", []byte("2024-01-01 open Assets:Checking\n"))
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, 1, len(tree.Directives))
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+	"slices"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const autoAccountsName = "beancount.plugins.auto_accounts"
+
+func init() {
+	Register(autoAccountsName, Func(autoAccounts))
+}
+
+// autoAccounts inserts an Open directive for every account that is used but
+// never opened, dated on its first use, like beancount.plugins.auto_accounts.
+func autoAccounts(ctx context.Context, tree *ast.AST, config string) []error {
+	opened := make(map[ast.Account]bool)
+	firstUse := make(map[ast.Account]*ast.Date)
+	for _, directive := range tree.Directives {
+		if open, ok := directive.(*ast.Open); ok {
+			opened[open.Account] = true
+		}
+		for _, account := range directiveAccounts(directive) {
+			if _, seen := firstUse[account]; !seen {
+				firstUse[account] = directive.Date()
+			}
+		}
+	}
+
+	accounts := make([]ast.Account, 0, len(firstUse))
+	for account := range firstUse {
+		accounts = append(accounts, account)
+	}
+	slices.Sort(accounts)
+
+	for index, account := range accounts {
+		if opened[account] {
+			continue
+		}
+		open := ast.NewOpen(firstUse[account], account, nil, "")
+		pos := syntheticPosition(autoAccountsName)
+		pos.Line = index
+		open.SetPosition(pos)
+		tree.Directives = append(tree.Directives, open)
+	}
+
+	return nil
+}
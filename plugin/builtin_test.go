@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+func directivesOf[T ast.Directive](tree *ast.AST) []T {
+	var out []T
+	for _, d := range tree.Directives {
+		if v, ok := d.(T); ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func TestAutoAccountsOpensOnFirstUse(t *testing.T) {
+	tree := parse(t, `
+plugin "beancount.plugins.auto_accounts"
+2020-01-01 open Assets:Bank
+2020-02-01 * "pay"
+  Assets:Bank      -5 USD
+  Expenses:Coffee   5 USD
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 0, len(errs))
+
+	opens := directivesOf[*ast.Open](tree)
+	assert.Equal(t, 2, len(opens))
+	assert.Equal(t, ast.Account("Expenses:Coffee"), opens[1].Account)
+	assert.Equal(t, "2020-02-01", opens[1].Date().String())
+	assert.Equal(t, "<auto_accounts>", opens[1].Position().Filename)
+}
+
+func TestImplicitPrices(t *testing.T) {
+	tree := parse(t, `
+plugin "beancount.plugins.implicit_prices"
+2020-01-02 * "buy"
+  Assets:Stock  10 HOOL {100 USD}
+  Assets:Cash  -1000 USD
+2020-02-01 * "sell"
+  Assets:Stock  -10 HOOL {100 USD} @ 120 USD
+  Assets:Cash   1200 USD
+  Income:Gains  -200 USD
+2020-03-01 * "exchange"
+  Assets:Cash  -110 USD @@ 100 EUR
+  Assets:Euro   100 EUR
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 0, len(errs))
+
+	prices := directivesOf[*ast.Price](tree)
+	var got []string
+	for _, p := range prices {
+		got = append(got, p.Date().String()+" "+p.Commodity+" "+p.Amount.Value+" "+p.Amount.Currency)
+	}
+	assert.Equal(t, []string{
+		"2020-01-02 HOOL 100 USD",
+		"2020-02-01 HOOL 120 USD",
+		"2020-03-01 USD 0.9090909090909091 EUR",
+	}, got)
+	assert.Equal(t, "from_cost", metadataValue(prices[0].Metadata, "__implicit_prices__").String())
+	assert.Equal(t, "from_price", metadataValue(prices[1].Metadata, "__implicit_prices__").String())
+}
+
+func TestCheckCommodityIgnore(t *testing.T) {
+	tree := parse(t, `
+plugin "beancount.plugins.check_commodity" "{'Assets:Options': r'SPX_\\d+'}"
+2020-01-01 commodity USD
+2020-01-01 open Assets:Options
+2020-01-01 open Assets:Cash
+2020-01-02 * "buy"
+  Assets:Options  1 SPX_1 {10 USD}
+  Assets:Cash   -10 USD
+2020-01-02 * "buy"
+  Assets:Cash   1 SPX_2 {10 USD}
+  Assets:Cash   -10 USD
+2020-01-03 price SPX_1 11 USD
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "Missing Commodity directive for 'SPX_2' in 'Assets:Cash'")
+}
+
+func TestParseDictConfig(t *testing.T) {
+	pairs, err := parseDictConfig(`{"A:.*": 'X\.Y', r'B': "Z"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]string{{"A:.*", `X\.Y`}, {"B", "Z"}}, pairs)
+
+	_, err = parseDictConfig(`{"A": }`)
+	assert.Error(t, err)
+}
+
+func TestNoDuplicatesIgnoresMetadata(t *testing.T) {
+	tree := parse(t, `
+plugin "beancount.plugins.noduplicates"
+2020-01-01 price HOOL 100 USD
+  source: "a"
+2020-01-01 price HOOL 100 USD
+  source: "b"
+2020-01-01 price HOOL 100.0 USD
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "Duplicate entry")
+}
+
+func TestCloseTreeClosesDescendants(t *testing.T) {
+	tree := parse(t, `
+plugin "beancount.plugins.close_tree"
+2020-01-01 open Assets:Bank:Checking
+2020-01-01 open Assets:Bank:Savings
+2020-01-01 open Assets:Banker
+2020-01-15 close Assets:Bank:Savings
+2020-02-01 close Assets:Bank
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 0, len(errs))
+
+	var closed []string
+	for _, c := range directivesOf[*ast.Close](tree) {
+		closed = append(closed, c.Date().String()+" "+string(c.Account))
+	}
+	assert.Equal(t, []string{
+		"2020-01-15 Assets:Bank:Savings",
+		"2020-02-01 Assets:Bank:Checking",
+	}, closed)
+}
+
+func TestLeafOnly(t *testing.T) {
+	tree := parse(t, `
+plugin "beancount.plugins.leafonly"
+2020-01-01 open Assets:Bank
+2020-01-02 * "x"
+  Assets:Bank          1 USD
+  Assets:Bank:Checking -1 USD
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "Non-leaf account 'Assets:Bank' has postings on it")
+}
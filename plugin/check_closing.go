@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const checkClosingName = "beancount.plugins.check_closing"
+
+func init() {
+	Register(checkClosingName, Func(checkClosing))
+}
+
+// checkClosing asserts that a position is fully closed by a posting carrying
+// "closing: TRUE" metadata, like beancount.plugins.check_closing: a zero
+// balance assertion for the posting's currency is inserted the day after.
+func checkClosing(ctx context.Context, tree *ast.AST, config string) []error {
+	var balances []ast.Directive
+	for _, directive := range tree.Directives {
+		txn, ok := directive.(*ast.Transaction)
+		if !ok {
+			continue
+		}
+		for _, posting := range txn.Postings {
+			if posting.Amount == nil || !isTrue(metadataValue(posting.Metadata, "closing")) {
+				continue
+			}
+			date := ast.NewDateFromTime(txn.Date().AddDate(0, 0, 1))
+			balance := ast.NewBalance(date, posting.Account, ast.NewAmount("0", posting.Amount.Currency))
+			balance.SetPosition(syntheticPosition(checkClosingName))
+			balances = append(balances, balance)
+		}
+	}
+	tree.Directives = append(tree.Directives, balances...)
+	return nil
+}
@@ -0,0 +1,197 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const checkCommodityName = "beancount.plugins.check_commodity"
+
+func init() {
+	Register(checkCommodityName, Func(checkCommodity))
+}
+
+// commodityIgnore exempts currencies matching Currency when used in
+// accounts matching Account.
+type commodityIgnore struct {
+	Account  *regexp.Regexp
+	Currency *regexp.Regexp
+}
+
+// checkCommodity reports every currency used without a Commodity directive,
+// like beancount.plugins.check_commodity. The optional config is a Python
+// dict literal mapping account regexps to currency regexps to ignore, e.g.
+// {"Assets:Options:.*": "SPX_.*"}. A currency ignored in some account is
+// also ignored in Price directives.
+func checkCommodity(ctx context.Context, tree *ast.AST, config string) []error {
+	pairs, err := parseDictConfig(config)
+	if err != nil {
+		return []error{NewError(checkCommodityName, nil, "Invalid configuration for check_commodity plugin: %v", err)}
+	}
+	var ignores []commodityIgnore
+	for _, pair := range pairs {
+		accountRe, err := compileMatch(pair[0])
+		if err != nil {
+			return []error{NewError(checkCommodityName, nil, "Invalid account regexp %q for check_commodity plugin: %v", pair[0], err)}
+		}
+		currencyRe, err := compileMatch(pair[1])
+		if err != nil {
+			return []error{NewError(checkCommodityName, nil, "Invalid currency regexp %q for check_commodity plugin: %v", pair[1], err)}
+		}
+		ignores = append(ignores, commodityIgnore{Account: accountRe, Currency: currencyRe})
+	}
+
+	declared := make(map[string]bool)
+	for _, directive := range tree.Directives {
+		if commodity, ok := directive.(*ast.Commodity); ok {
+			declared[commodity.Currency] = true
+		}
+	}
+
+	var errs []error
+	reported := make(map[string]bool)
+	ignored := make(map[string]bool)
+
+	check := func(d ast.Directive, account ast.Account, currency string) {
+		if currency == "" || declared[currency] || reported[currency] {
+			return
+		}
+		for _, ignore := range ignores {
+			if ignore.Account.MatchString(string(account)) && ignore.Currency.MatchString(currency) {
+				ignored[currency] = true
+				return
+			}
+		}
+		reported[currency] = true
+		errs = append(errs, NewError(checkCommodityName, d, "Missing Commodity directive for '%s' in '%s'", currency, account))
+	}
+
+	for _, directive := range tree.Directives {
+		switch d := directive.(type) {
+		case *ast.Open:
+			for _, currency := range d.ConstraintCurrencies {
+				check(d, d.Account, currency)
+			}
+		case *ast.Transaction:
+			for _, posting := range d.Postings {
+				if posting.Amount != nil {
+					check(d, posting.Account, posting.Amount.Currency)
+				}
+				if posting.Cost != nil && posting.Cost.Amount != nil {
+					check(d, posting.Account, posting.Cost.Amount.Currency)
+				}
+				if posting.Price != nil {
+					check(d, posting.Account, posting.Price.Currency)
+				}
+			}
+		case *ast.Balance:
+			if d.Amount != nil {
+				check(d, d.Account, d.Amount.Currency)
+			}
+		}
+	}
+
+	for _, directive := range tree.Directives {
+		price, ok := directive.(*ast.Price)
+		if !ok {
+			continue
+		}
+		currencies := []string{price.Commodity}
+		if price.Amount != nil {
+			currencies = append(currencies, price.Amount.Currency)
+		}
+		for _, currency := range currencies {
+			if currency == "" || declared[currency] || reported[currency] || ignored[currency] {
+				continue
+			}
+			reported[currency] = true
+			errs = append(errs, NewError(checkCommodityName, price, "Missing Commodity directive for '%s' in price", currency))
+		}
+	}
+
+	return errs
+}
+
+// compileMatch compiles a Python-style regexp used with re.match, which
+// anchors at the start of the subject.
+func compileMatch(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + expr + `)`)
+}
+
+// parseDictConfig parses the Python dict literal of string keys and values
+// beancount plugins accept as configuration, e.g. {'a': 'b', "c": "d"}.
+// An empty config yields no pairs.
+func parseDictConfig(config string) ([][2]string, error) {
+	s := strings.TrimSpace(config)
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("expected a dict literal, got %q", config)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+
+	var pairs [][2]string
+	for s != "" {
+		key, rest, err := parsePythonString(s)
+		if err != nil {
+			return nil, err
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, ":") {
+			return nil, fmt.Errorf("expected ':' after key %q", key)
+		}
+		value, rest, err := parsePythonString(strings.TrimSpace(rest[1:]))
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, [2]string{key, value})
+
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		if !strings.HasPrefix(rest, ",") {
+			return nil, fmt.Errorf("expected ',' after value %q", value)
+		}
+		s = strings.TrimSpace(rest[1:])
+	}
+	return pairs, nil
+}
+
+// parsePythonString parses a leading single- or double-quoted string
+// literal, optionally r-prefixed, and returns it with the remainder. Like
+// Python, unknown escapes keep their backslash so regexps like '\d+' work.
+func parsePythonString(s string) (string, string, error) {
+	raw := false
+	if strings.HasPrefix(s, "r") || strings.HasPrefix(s, "R") {
+		raw = true
+		s = s[1:]
+	}
+	if s == "" || (s[0] != '\'' && s[0] != '"') {
+		return "", "", fmt.Errorf("expected a quoted string at %q", s)
+	}
+	quote := s[0]
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			if !raw && (s[i] == quote || s[i] == '\\') {
+				value.WriteByte(s[i])
+			} else {
+				value.WriteByte('\\')
+				value.WriteByte(s[i])
+			}
+		case c == quote:
+			return value.String(), s[i+1:], nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %q", s)
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const closeTreeName = "beancount.plugins.close_tree"
+
+func init() {
+	Register(closeTreeName, Func(closeTree))
+}
+
+// closeTree closes every open descendant of a closed account on the same
+// date, like beancount.plugins.close_tree. Closing an account that was
+// never opened itself is allowed: the Close is dropped once it has been
+// propagated to its sub-accounts.
+func closeTree(ctx context.Context, tree *ast.AST, config string) []error {
+	var opens []ast.Account
+	closed := make(map[ast.Account]bool)
+	opened := make(map[ast.Account]bool)
+	for _, directive := range tree.Directives {
+		switch d := directive.(type) {
+		case *ast.Open:
+			if !opened[d.Account] {
+				opened[d.Account] = true
+				opens = append(opens, d.Account)
+			}
+		case *ast.Close:
+			closed[d.Account] = true
+		}
+	}
+	slices.Sort(opens)
+
+	directives := make(ast.Directives, 0, len(tree.Directives))
+	for _, directive := range tree.Directives {
+		close, ok := directive.(*ast.Close)
+		if !ok {
+			directives = append(directives, directive)
+			continue
+		}
+
+		prefix := string(close.Account) + ":"
+		for _, account := range opens {
+			if !strings.HasPrefix(string(account), prefix) || closed[account] {
+				continue
+			}
+			child := ast.NewClose(close.Date(), account)
+			child.SetPosition(syntheticPosition(closeTreeName))
+			directives = append(directives, child)
+			closed[account] = true
+		}
+		if opened[close.Account] {
+			directives = append(directives, close)
+		}
+	}
+	tree.Directives = directives
+	return nil
+}
@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+	"slices"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const coherentCostName = "beancount.plugins.coherent_cost"
+
+func init() {
+	Register(coherentCostName, Func(coherentCost))
+}
+
+// coherentCost reports currencies held both with and without cost, like
+// beancount.plugins.coherent_cost. The error points at the first
+// transaction using the currency without cost.
+func coherentCost(ctx context.Context, tree *ast.AST, config string) []error {
+	withCost := make(map[string]*ast.Transaction)
+	withoutCost := make(map[string]*ast.Transaction)
+	for _, directive := range tree.Directives {
+		txn, ok := directive.(*ast.Transaction)
+		if !ok {
+			continue
+		}
+		for _, posting := range txn.Postings {
+			if posting.Amount == nil {
+				continue
+			}
+			target := withoutCost
+			if posting.Cost != nil {
+				target = withCost
+			}
+			if _, seen := target[posting.Amount.Currency]; !seen {
+				target[posting.Amount.Currency] = txn
+			}
+		}
+	}
+
+	var currencies []string
+	for currency := range withCost {
+		if _, ok := withoutCost[currency]; ok {
+			currencies = append(currencies, currency)
+		}
+	}
+	slices.Sort(currencies)
+
+	errs := make([]error, 0, len(currencies))
+	for _, currency := range currencies {
+		errs = append(errs, NewError(coherentCostName, withoutCost[currency],
+			"Currency '%s' is used both with and without cost", currency))
+	}
+	return errs
+}
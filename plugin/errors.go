@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+// Error is a diagnostic reported by a plugin. It carries the offending
+// directive when there is one so renderers can show it as context.
+type Error struct {
+	Plugin    string
+	Message   string
+	Pos       ast.Position
+	Directive ast.Directive
+}
+
+// NewError creates an error reported by plugin about directive d.
+// d may be nil for errors that are not tied to a single directive.
+func NewError(plugin string, d ast.Directive, format string, args ...any) *Error {
+	e := &Error{
+		Plugin:    plugin,
+		Message:   fmt.Sprintf(format, args...),
+		Directive: d,
+	}
+	if d != nil {
+		e.Pos = d.Position()
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Pos.Filename != "":
+		return fmt.Sprintf("%s:%d: %s", e.Pos.Filename, e.Pos.Line, e.Message)
+	case e.Directive != nil && e.Directive.Date() != nil:
+		return fmt.Sprintf("%s: %s", e.Directive.Date().String(), e.Message)
+	default:
+		return e.Message
+	}
+}
+
+func (e *Error) GetPosition() ast.Position   { return e.Pos }
+func (e *Error) GetDirective() ast.Directive { return e.Directive }
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":     "PluginError",
+		"message":  e.Error(),
+		"position": e.Pos,
+		"plugin":   e.Plugin,
+	})
+}
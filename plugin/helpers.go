@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+// syntheticPosition returns the position given to directives a plugin
+// creates, following beancount's "<plugin_name>" pseudo filenames.
+func syntheticPosition(name string) ast.Position {
+	return ast.Position{Filename: "<" + name[strings.LastIndexByte(name, '.')+1:] + ">"}
+}
+
+// parseNumber parses an amount's canonical value.
+func parseNumber(amount *ast.Amount) (decimal.Decimal, bool) {
+	if amount == nil || amount.Value == "" {
+		return decimal.Zero, false
+	}
+	number, err := decimal.NewFromString(amount.Value)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	return number, true
+}
+
+// formatNumber renders a decimal the way amounts are written in source.
+func formatNumber(d decimal.Decimal) string {
+	return d.String()
+}
+
+// directiveAccounts returns the accounts a directive references, like
+// beancount's getters.get_entry_accounts.
+func directiveAccounts(d ast.Directive) []ast.Account {
+	switch d := d.(type) {
+	case *ast.Open:
+		return []ast.Account{d.Account}
+	case *ast.Close:
+		return []ast.Account{d.Account}
+	case *ast.Balance:
+		return []ast.Account{d.Account}
+	case *ast.Note:
+		return []ast.Account{d.Account}
+	case *ast.Document:
+		return []ast.Account{d.Account}
+	case *ast.Pad:
+		return []ast.Account{d.Account, d.AccountPad}
+	case *ast.Transaction:
+		accounts := make([]ast.Account, 0, len(d.Postings))
+		for _, posting := range d.Postings {
+			accounts = append(accounts, posting.Account)
+		}
+		return accounts
+	default:
+		return nil
+	}
+}
+
+// metadataValue returns the value stored under key, or nil.
+func metadataValue(metadata []*ast.Metadata, key string) *ast.MetadataValue {
+	for _, m := range metadata {
+		if m.Key == key {
+			return m.Value
+		}
+	}
+	return nil
+}
+
+// isTrue reports whether a metadata value is the boolean TRUE.
+func isTrue(value *ast.MetadataValue) bool {
+	return value != nil && value.Boolean != nil && *value.Boolean
+}
+
+// isFalse reports whether a metadata value is the boolean FALSE.
+func isFalse(value *ast.MetadataValue) bool {
+	return value != nil && value.Boolean != nil && !*value.Boolean
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+const implicitPricesName = "beancount.plugins.implicit_prices"
+
+// implicitPricesMetaKey marks synthesized prices with their origin,
+// "from_price" or "from_cost", like beancount's METADATA_FIELD.
+const implicitPricesMetaKey = "__implicit_prices__"
+
+func init() {
+	Register(implicitPricesName, Func(implicitPrices))
+}
+
+// implicitPrices synthesizes Price directives from posting prices and from
+// the costs of augmenting lots, like beancount.plugins.implicit_prices.
+// Prices on reducing postings are used; their costs are not, since those
+// are historical. Zero prices are skipped: a zero rate cannot be inverted.
+func implicitPrices(ctx context.Context, tree *ast.AST, config string) []error {
+	type priceKey struct {
+		date      string
+		commodity string
+		number    string
+		currency  string
+	}
+	seen := make(map[priceKey]bool)
+	balances := make(map[ast.Account]map[string]decimal.Decimal)
+	var prices []ast.Directive
+
+	for _, directive := range tree.Directives {
+		txn, ok := directive.(*ast.Transaction)
+		if !ok {
+			continue
+		}
+		for _, posting := range txn.Postings {
+			units, ok := parseNumber(posting.Amount)
+			if !ok {
+				continue
+			}
+			currency := posting.Amount.Currency
+
+			accountBalances := balances[posting.Account]
+			if accountBalances == nil {
+				accountBalances = make(map[string]decimal.Decimal)
+				balances[posting.Account] = accountBalances
+			}
+			previous := accountBalances[currency]
+			reduced := !previous.IsZero() && previous.Sign() != units.Sign()
+			accountBalances[currency] = previous.Add(units)
+
+			var price *ast.Amount
+			var origin string
+			switch {
+			case posting.Price != nil:
+				number, ok := parseNumber(posting.Price)
+				if !ok || number.IsZero() {
+					continue
+				}
+				if posting.PriceTotal {
+					if units.IsZero() {
+						continue
+					}
+					number = number.Div(units.Abs())
+				}
+				price = ast.NewAmount(formatNumber(number), posting.Price.Currency)
+				origin = "from_price"
+			case posting.Cost != nil && posting.Cost.Amount != nil && !posting.Cost.IsTotal && !reduced:
+				if number, ok := parseNumber(posting.Cost.Amount); !ok || number.IsZero() {
+					continue
+				}
+				price = ast.NewAmount(posting.Cost.Amount.Value, posting.Cost.Amount.Currency)
+				origin = "from_cost"
+			default:
+				continue
+			}
+
+			key := priceKey{txn.Date().String(), currency, price.Value, price.Currency}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			entry := ast.NewPrice(txn.Date(), currency, price)
+			entry.SetPosition(txn.Position())
+			entry.AddMetadata(ast.NewMetadata(implicitPricesMetaKey, origin))
+			prices = append(prices, entry)
+		}
+	}
+
+	tree.Directives = append(tree.Directives, prices...)
+	return nil
+}
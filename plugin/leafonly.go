@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const leafOnlyName = "beancount.plugins.leafonly"
+
+func init() {
+	Register(leafOnlyName, Func(leafOnly))
+}
+
+// leafOnly reports accounts that are referenced directly while also having
+// sub-accounts, like beancount.plugins.leafonly. As in beancount's
+// realization, every directive naming an account counts as a reference,
+// including Open.
+func leafOnly(ctx context.Context, tree *ast.AST, config string) []error {
+	used := make(map[ast.Account]bool)
+	opens := make(map[ast.Account]*ast.Open)
+	for _, directive := range tree.Directives {
+		if open, ok := directive.(*ast.Open); ok {
+			if _, seen := opens[open.Account]; !seen {
+				opens[open.Account] = open
+			}
+		}
+		for _, account := range directiveAccounts(directive) {
+			used[account] = true
+		}
+	}
+
+	parents := make(map[ast.Account]bool)
+	for account := range used {
+		name := string(account)
+		for i := strings.LastIndexByte(name, ':'); i > 0; i = strings.LastIndexByte(name, ':') {
+			name = name[:i]
+			parents[ast.Account(name)] = true
+		}
+	}
+
+	var nonLeaf []ast.Account
+	for account := range used {
+		if parents[account] {
+			nonLeaf = append(nonLeaf, account)
+		}
+	}
+	slices.Sort(nonLeaf)
+
+	errs := make([]error, 0, len(nonLeaf))
+	for _, account := range nonLeaf {
+		var directive ast.Directive
+		if open, ok := opens[account]; ok {
+			directive = open
+		}
+		err := NewError(leafOnlyName, directive, "Non-leaf account '%s' has postings on it", account)
+		if directive == nil {
+			err.Pos = syntheticPosition(leafOnlyName)
+		}
+		errs = append(errs, err)
+	}
+	return errs
+}
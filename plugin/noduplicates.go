@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const noDuplicatesName = "beancount.plugins.noduplicates"
+
+func init() {
+	Register(noDuplicatesName, Func(noDuplicates))
+}
+
+// noDuplicates reports directives that are identical to an earlier one,
+// ignoring metadata and source location, like beancount.plugins.noduplicates.
+func noDuplicates(ctx context.Context, tree *ast.AST, config string) []error {
+	var errs []error
+	seen := make(map[string]ast.Directive)
+	for _, directive := range tree.Directives {
+		key := directiveKey(directive)
+		if previous, ok := seen[key]; ok {
+			errs = append(errs, NewError(noDuplicatesName, directive, "Duplicate entry: %s == %s",
+				describeDirective(directive), describeDirective(previous)))
+			continue
+		}
+		seen[key] = directive
+	}
+	return errs
+}
+
+// describeDirective returns a short description used in error messages.
+func describeDirective(d ast.Directive) string {
+	pos := d.Position()
+	if pos.Filename != "" {
+		return fmt.Sprintf("%s %s (%s:%d)", d.Date().String(), d.Kind(), pos.Filename, pos.Line)
+	}
+	return fmt.Sprintf("%s %s", d.Date().String(), d.Kind())
+}
+
+// directiveKey returns a canonical string for a directive's contents that
+// excludes metadata, comments and position, like beancount's
+// compare.hash_entry with exclude_meta. Tag, link and posting order is
+// irrelevant, as in the official hash.
+func directiveKey(d ast.Directive) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s", d.Kind(), d.Date().String())
+
+	switch d := d.(type) {
+	case *ast.Open:
+		fmt.Fprintf(&b, "|%s|%s|%s", d.Account, strings.Join(d.ConstraintCurrencies, ","), d.BookingMethod)
+	case *ast.Close:
+		fmt.Fprintf(&b, "|%s", d.Account)
+	case *ast.Commodity:
+		fmt.Fprintf(&b, "|%s", d.Currency)
+	case *ast.Balance:
+		fmt.Fprintf(&b, "|%s|%s|%s", d.Account, amountKey(d.Amount), amountKey(d.Tolerance))
+	case *ast.Pad:
+		fmt.Fprintf(&b, "|%s|%s", d.Account, d.AccountPad)
+	case *ast.Note:
+		fmt.Fprintf(&b, "|%s|%q", d.Account, d.Description.Value)
+	case *ast.Document:
+		fmt.Fprintf(&b, "|%s|%q|%s|%s", d.Account, d.PathToDocument.Value, sortedTags(d.Tags), sortedLinks(d.Links))
+	case *ast.Price:
+		fmt.Fprintf(&b, "|%s|%s", d.Commodity, amountKey(d.Amount))
+	case *ast.Event:
+		fmt.Fprintf(&b, "|%q|%q", d.Name.Value, d.Value.Value)
+	case *ast.Query:
+		fmt.Fprintf(&b, "|%q|%q", d.Name.Value, d.QueryString.Value)
+	case *ast.Custom:
+		fmt.Fprintf(&b, "|%q", d.Type.Value)
+		for _, value := range d.Values {
+			fmt.Fprintf(&b, "|%v", customValueKey(value))
+		}
+	case *ast.Transaction:
+		fmt.Fprintf(&b, "|%s|%q|%q|%s|%s", d.Flag, d.Payee.Value, d.Narration.Value, sortedTags(d.Tags), sortedLinks(d.Links))
+		postings := make([]string, 0, len(d.Postings))
+		for _, posting := range d.Postings {
+			postings = append(postings, postingKey(posting))
+		}
+		slices.Sort(postings)
+		for _, posting := range postings {
+			b.WriteString("|")
+			b.WriteString(posting)
+		}
+	}
+
+	return b.String()
+}
+
+func postingKey(p *ast.Posting) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", p.Flag, p.Account, amountKey(p.Amount))
+	if c := p.Cost; c != nil {
+		fmt.Fprintf(&b, " {%t %t %s %s %s %q}", c.IsMerge, c.IsTotal, amountKey(c.Amount), amountKey(c.Total), c.Date.String(), c.Label)
+	}
+	if p.Price != nil {
+		fmt.Fprintf(&b, " @%t %s", p.PriceTotal, amountKey(p.Price))
+	}
+	return b.String()
+}
+
+// amountKey renders an amount with its number as written; like the
+// official hash, 1.0 and 1.00 are different amounts.
+func amountKey(a *ast.Amount) string {
+	if a == nil {
+		return "-"
+	}
+	return a.Value + " " + a.Currency
+}
+
+func customValueKey(v *ast.CustomValue) string {
+	switch {
+	case v.Amount != nil:
+		return amountKey(v.Amount)
+	case v.Date != nil:
+		return v.Date.String()
+	default:
+		return fmt.Sprintf("%q", fmt.Sprint(v.GetValue()))
+	}
+}
+
+func sortedTags(tags []ast.Tag) string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, string(tag))
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+	return strings.Join(names, ",")
+}
+
+func sortedLinks(links []ast.Link) string {
+	names := make([]string, 0, len(links))
+	for _, link := range links {
+		names = append(names, string(link))
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+	return strings.Join(names, ",")
+}
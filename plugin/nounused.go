@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const noUnusedName = "beancount.plugins.nounused"
+
+func init() {
+	Register(noUnusedName, Func(noUnused))
+}
+
+// noUnused reports accounts that are opened but never referenced by any
+// other directive, like beancount.plugins.nounused. A Close counts as a
+// reference.
+func noUnused(ctx context.Context, tree *ast.AST, config string) []error {
+	var opens []*ast.Open
+	referenced := make(map[ast.Account]bool)
+	for _, directive := range tree.Directives {
+		if open, ok := directive.(*ast.Open); ok {
+			opens = append(opens, open)
+			continue
+		}
+		for _, account := range directiveAccounts(directive) {
+			referenced[account] = true
+		}
+	}
+
+	var errs []error
+	for _, open := range opens {
+		if !referenced[open.Account] {
+			errs = append(errs, NewError(noUnusedName, open, "Unused account '%s'", open.Account))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const oneCommodityName = "beancount.plugins.onecommodity"
+
+func init() {
+	Register(oneCommodityName, Func(oneCommodity))
+}
+
+// oneCommodity reports accounts holding more than one units currency, or
+// lots at more than one cost currency, like beancount.plugins.onecommodity.
+// Accounts are exempt when their Open declares several currencies or has
+// "onecommodity: FALSE" metadata; the optional config is a regexp that
+// accounts must match to be checked.
+func oneCommodity(ctx context.Context, tree *ast.AST, config string) []error {
+	var accountsRe *regexp.Regexp
+	if config != "" {
+		var err error
+		if accountsRe, err = compileMatch(config); err != nil {
+			return []error{NewError(oneCommodityName, nil, "Invalid regexp %q for onecommodity plugin: %v", config, err)}
+		}
+	}
+
+	skip := make(map[ast.Account]bool)
+	for _, directive := range tree.Directives {
+		open, ok := directive.(*ast.Open)
+		if !ok {
+			continue
+		}
+		if isFalse(metadataValue(open.Metadata, "onecommodity")) ||
+			(accountsRe != nil && !accountsRe.MatchString(string(open.Account))) ||
+			len(open.ConstraintCurrencies) > 1 {
+			skip[open.Account] = true
+		}
+	}
+
+	type usage struct {
+		currencies []string
+		source     ast.Directive
+	}
+	units := make(map[ast.Account]*usage)
+	costs := make(map[ast.Account]*usage)
+	record := func(m map[ast.Account]*usage, account ast.Account, currency string, d ast.Directive) {
+		u := m[account]
+		if u == nil {
+			u = &usage{}
+			m[account] = u
+		}
+		if !slices.Contains(u.currencies, currency) {
+			u.currencies = append(u.currencies, currency)
+		}
+		if len(u.currencies) > 1 && u.source == nil {
+			u.source = d
+		}
+	}
+
+	for _, directive := range tree.Directives {
+		switch d := directive.(type) {
+		case *ast.Transaction:
+			for _, posting := range d.Postings {
+				if skip[posting.Account] || posting.Amount == nil {
+					continue
+				}
+				record(units, posting.Account, posting.Amount.Currency, d)
+				if posting.Cost != nil && posting.Cost.Amount != nil {
+					record(costs, posting.Account, posting.Cost.Amount.Currency, d)
+				}
+			}
+		case *ast.Balance:
+			if skip[d.Account] || d.Amount == nil {
+				continue
+			}
+			record(units, d.Account, d.Amount.Currency, d)
+		}
+	}
+
+	var errs []error
+	report := func(m map[ast.Account]*usage, format string) {
+		accounts := make([]ast.Account, 0, len(m))
+		for account, u := range m {
+			if len(u.currencies) > 1 {
+				accounts = append(accounts, account)
+			}
+		}
+		slices.Sort(accounts)
+		for _, account := range accounts {
+			u := m[account]
+			errs = append(errs, NewError(oneCommodityName, u.source, format, account, strings.Join(u.currencies, ",")))
+		}
+	}
+	report(units, "More than one currency in account '%s': %s")
+	report(costs, "More than one cost currency in account '%s': %s")
+	return errs
+}
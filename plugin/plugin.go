@@ -0,0 +1,123 @@
+// Package plugin runs the transformations named by `plugin` directives.
+//
+// Beancount v2 plugins are Python callables of the form
+// (entries, options_map, config) -> (entries, errors). This package provides
+// the Go equivalent: a Transformer receives the complete, date-sorted AST
+// (including its options) plus the optional configuration string from the
+// directive, may add, remove or rewrite directives in place, and returns any
+// errors it wants reported.
+//
+// Transformers are registered under the module name used in ledger files:
+//
+//	plugin.Register("myorg.plugins.tag_vendors", plugin.Func(tagVendors))
+//
+// Run executes the plugins a tree declares, in declaration order. Directives
+// naming a module with no registered transformer are skipped, since they
+// usually refer to Python modules this implementation cannot load.
+//
+// Example usage:
+//
+//	tree, _ := parser.ParseBytes(ctx, source)
+//	errs := plugin.Run(ctx, tree)
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/telemetry"
+)
+
+// Transformer is implemented by plugins. Transform may mutate tree in place;
+// Run re-sorts the directives afterwards, so transformers can append new
+// directives without maintaining date order themselves.
+type Transformer interface {
+	Transform(ctx context.Context, tree *ast.AST, config string) []error
+}
+
+// Func adapts an ordinary function to the Transformer interface.
+type Func func(ctx context.Context, tree *ast.AST, config string) []error
+
+// Transform calls f(ctx, tree, config).
+func (f Func) Transform(ctx context.Context, tree *ast.AST, config string) []error {
+	return f(ctx, tree, config)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Transformer)
+)
+
+// Register makes a transformer available under the given module name.
+// It panics if the name is empty, the transformer is nil, or the name is
+// already registered, like database/sql.Register.
+func Register(name string, t Transformer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" {
+		panic("plugin: Register called with empty name")
+	}
+	if t == nil {
+		panic("plugin: Register transformer is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("plugin: Register called twice for " + name)
+	}
+	registry[name] = t
+}
+
+// Lookup returns the transformer registered under name.
+func Lookup(name string) (Transformer, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	t, ok := registry[name]
+	return t, ok
+}
+
+// Registered returns the names of all registered plugins, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Run executes the registered plugins named by tree.Plugins in declaration
+// order and returns the errors they reported. Unregistered plugins are
+// skipped. The directives are re-sorted after each plugin so the next one
+// sees the same ordering guarantees as the first.
+func Run(ctx context.Context, tree *ast.AST) []error {
+	collector := telemetry.FromContext(ctx)
+
+	var errs []error
+	for _, directive := range tree.Plugins {
+		select {
+		case <-ctx.Done():
+			return append(errs, ctx.Err())
+		default:
+		}
+
+		name := directive.Name.Value
+		t, ok := Lookup(name)
+		if !ok {
+			continue
+		}
+
+		timer := collector.Start(fmt.Sprintf("plugin.run %s", name))
+		errs = append(errs, t.Transform(ctx, tree, directive.Config.Value)...)
+		_ = ast.SortDirectives(tree)
+		timer.End()
+	}
+
+	return errs
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+func parse(t *testing.T, source string) *ast.AST {
+	t.Helper()
+	tree, err := parser.ParseString(context.Background(), source)
+	assert.NoError(t, err)
+	return tree
+}
+
+func TestRunDeclarationOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Func {
+		return func(ctx context.Context, tree *ast.AST, config string) []error {
+			calls = append(calls, name+":"+config)
+			return nil
+		}
+	}
+	Register("test.order.first", record("first"))
+	Register("test.order.second", record("second"))
+
+	tree := parse(t, `
+plugin "test.order.second" "b"
+plugin "test.order.unknown"
+plugin "test.order.first" "a"
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, []string{"second:b", "first:a"}, calls)
+}
+
+func TestRunSortsSynthesizedDirectives(t *testing.T) {
+	Register("test.sort.prepend", Func(func(ctx context.Context, tree *ast.AST, config string) []error {
+		date, _ := ast.NewDate("2020-01-01")
+		tree.Directives = append(tree.Directives, ast.NewOpen(date, "Assets:Cash", nil, ""))
+		return []error{NewError("test.sort.prepend", tree.Directives[0], "synthesized")}
+	}))
+
+	tree := parse(t, `
+plugin "test.sort.prepend"
+2021-01-01 open Assets:Bank
+`)
+	errs := Run(context.Background(), tree)
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "synthesized")
+	assert.Equal(t, 2, len(tree.Directives))
+	assert.Equal(t, ast.Account("Assets:Cash"), tree.Directives[0].(*ast.Open).Account)
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	noop := Func(func(ctx context.Context, tree *ast.AST, config string) []error { return nil })
+	Register("test.dup", noop)
+	assert.Panics(t, func() { Register("test.dup", noop) })
+}
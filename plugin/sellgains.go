@@ -0,0 +1,225 @@
+package plugin
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/config"
+	"github.com/shopspring/decimal"
+)
+
+const sellGainsName = "beancount.plugins.sellgains"
+
+// sellGainsToleranceMultiplier loosens the inferred tolerance, as rounding
+// of prices and of proceeds happen independently.
+var sellGainsToleranceMultiplier = decimal.NewFromInt(2)
+
+func init() {
+	Register(sellGainsName, Func(sellGains))
+}
+
+// sellGains cross-checks the price of sold lots against the proceeds, like
+// beancount.plugins.sellgains: for transactions whose postings at cost all
+// carry a price, the priced value of those lots must equal the weight of
+// the non-income legs. Transactions whose weights cannot be determined
+// before booking (e.g. a reduction with an empty cost spec) are skipped.
+func sellGains(ctx context.Context, tree *ast.AST, configStr string) []error {
+	cfg, err := config.FromAST(tree)
+	if err != nil {
+		cfg = config.New()
+	}
+
+	var errs []error
+	for _, directive := range tree.Directives {
+		txn, ok := directive.(*ast.Transaction)
+		if !ok || !allCostPostingsPriced(txn) {
+			continue
+		}
+
+		totalPrice := make(map[string]decimal.Decimal)
+		totalProceeds := make(map[string]decimal.Decimal)
+		residual := make(map[string]decimal.Decimal)
+		var missing *ast.Posting
+		determinable := true
+
+		for _, posting := range txn.Postings {
+			if posting.Amount == nil {
+				if missing != nil {
+					determinable = false
+					break
+				}
+				missing = posting
+				continue
+			}
+			units, ok := parseNumber(posting.Amount)
+			if !ok {
+				determinable = false
+				break
+			}
+			weight, currency, ok := postingWeight(posting, units)
+			if !ok {
+				determinable = false
+				break
+			}
+			residual[currency] = residual[currency].Add(weight)
+
+			if posting.Cost != nil {
+				price, _ := parseNumber(posting.Price)
+				if posting.PriceTotal {
+					price = price.Div(units.Abs())
+				}
+				currency := posting.Price.Currency
+				totalPrice[currency] = totalPrice[currency].Add(price.Mul(units.Neg()))
+			} else if isProceedsAccount(posting.Account, cfg) {
+				totalProceeds[currency] = totalProceeds[currency].Add(weight)
+			}
+		}
+		if !determinable {
+			continue
+		}
+		if missing != nil && isProceedsAccount(missing.Account, cfg) {
+			for currency, number := range residual {
+				totalProceeds[currency] = totalProceeds[currency].Sub(number)
+			}
+		}
+		dropZeros(totalPrice)
+		dropZeros(totalProceeds)
+
+		tolerances := inferTolerances(txn, cfg)
+		invalid := false
+		for currency, priceNumber := range totalPrice {
+			tolerance := tolerances(currency).Mul(sellGainsToleranceMultiplier)
+			proceedsNumber := totalProceeds[currency]
+			if priceNumber.Sub(proceedsNumber).Abs().GreaterThan(tolerance) {
+				invalid = true
+			}
+		}
+		for currency := range totalProceeds {
+			if _, ok := totalPrice[currency]; !ok {
+				invalid = true
+			}
+		}
+		if invalid {
+			errs = append(errs, NewError(sellGainsName, txn, "Invalid price vs. proceeds/gains: %s vs. %s",
+				formatInventory(totalPrice), formatInventory(totalProceeds)))
+		}
+	}
+	return errs
+}
+
+func allCostPostingsPriced(txn *ast.Transaction) bool {
+	atCost := false
+	for _, posting := range txn.Postings {
+		if posting.Cost == nil {
+			continue
+		}
+		atCost = true
+		if _, ok := parseNumber(posting.Price); !ok {
+			return false
+		}
+	}
+	return atCost
+}
+
+// postingWeight returns a posting's balancing weight: units at cost,
+// converted at price, or plain units.
+func postingWeight(posting *ast.Posting, units decimal.Decimal) (decimal.Decimal, string, bool) {
+	switch {
+	case posting.Cost != nil:
+		cost, ok := parseNumber(posting.Cost.Amount)
+		if !ok || posting.Cost.IsMerge {
+			return decimal.Zero, "", false
+		}
+		currency := posting.Cost.Amount.Currency
+		if posting.Cost.IsTotal {
+			if units.IsNegative() {
+				cost = cost.Neg()
+			}
+			return cost, currency, true
+		}
+		weight := units.Mul(cost)
+		if total, ok := parseNumber(posting.Cost.Total); ok {
+			if units.IsNegative() {
+				total = total.Neg()
+			}
+			weight = weight.Add(total)
+		}
+		return weight, currency, true
+	case posting.Price != nil:
+		price, ok := parseNumber(posting.Price)
+		if !ok {
+			return decimal.Zero, "", false
+		}
+		if posting.PriceTotal {
+			if units.IsNegative() {
+				price = price.Neg()
+			}
+			return price, posting.Price.Currency, true
+		}
+		return units.Mul(price), posting.Price.Currency, true
+	default:
+		return units, posting.Amount.Currency, true
+	}
+}
+
+// isProceedsAccount reports whether postings to account count as proceeds:
+// every account type except income.
+func isProceedsAccount(account ast.Account, cfg *config.Config) bool {
+	return account.Root() != cfg.AccountNames.Income
+}
+
+// inferTolerances infers per-currency tolerances from the precision of a
+// transaction's explicit units, like beancount's interpolate.infer_tolerances.
+func inferTolerances(txn *ast.Transaction, cfg *config.Config) func(string) decimal.Decimal {
+	inferred := make(map[string]decimal.Decimal)
+	for currency, tolerance := range cfg.Tolerance.Defaults {
+		if currency != "*" {
+			inferred[currency] = tolerance
+		}
+	}
+	for _, posting := range txn.Postings {
+		if posting.Amount == nil || posting.Inferred {
+			continue
+		}
+		number, ok := parseNumber(posting.Amount)
+		if !ok || number.Exponent() >= 0 {
+			continue
+		}
+		tolerance := decimal.New(1, number.Exponent()).Mul(cfg.Tolerance.Multiplier)
+		currency := posting.Amount.Currency
+		if current, ok := inferred[currency]; !ok || tolerance.GreaterThan(current) {
+			inferred[currency] = tolerance
+		}
+	}
+	return func(currency string) decimal.Decimal {
+		if tolerance, ok := inferred[currency]; ok {
+			return tolerance
+		}
+		return cfg.Tolerance.GetDefault(currency)
+	}
+}
+
+func dropZeros(m map[string]decimal.Decimal) {
+	for currency, number := range m {
+		if number.IsZero() {
+			delete(m, currency)
+		}
+	}
+}
+
+// formatInventory renders per-currency totals like beancount's Inventory.
+func formatInventory(m map[string]decimal.Decimal) string {
+	currencies := make([]string, 0, len(m))
+	for currency := range m {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+
+	parts := make([]string, 0, len(currencies))
+	for _, currency := range currencies {
+		parts = append(parts, m[currency].String()+" "+currency)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/robinvdvleuten/beancount/ast"
+)
+
+const uniquePricesName = "beancount.plugins.unique_prices"
+
+func init() {
+	Register(uniquePricesName, Func(uniquePrices))
+}
+
+// uniquePrices reports Price directives that disagree with another price
+// for the same commodity, quote currency and date, like
+// beancount.plugins.unique_prices. Identical duplicates are accepted.
+func uniquePrices(ctx context.Context, tree *ast.AST, config string) []error {
+	type priceKey struct {
+		date      string
+		commodity string
+		currency  string
+	}
+	var order []priceKey
+	groups := make(map[priceKey][]*ast.Price)
+	for _, directive := range tree.Directives {
+		price, ok := directive.(*ast.Price)
+		if !ok || price.Amount == nil {
+			continue
+		}
+		key := priceKey{price.Date().String(), price.Commodity, price.Amount.Currency}
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], price)
+	}
+
+	var errs []error
+	for _, key := range order {
+		prices := groups[key]
+		numbers := make(map[string]bool)
+		for _, price := range prices {
+			number, ok := parseNumber(price.Amount)
+			if !ok {
+				continue
+			}
+			numbers[number.String()] = true
+		}
+		if len(numbers) > 1 {
+			errs = append(errs, NewError(uniquePricesName, prices[0], "Disagreeing price entries"))
+		}
+	}
+	return errs
+}
@@ -36,12 +36,16 @@ type Select struct {
 	From     *From
 	Where    Expr
 	GroupBy  []Expr // column names, aliases, or 1-based integer indices
+	Having   Expr   // v3 syntax only
 	OrderBy  []Expr
 	// OrderDesc applies to the whole ORDER BY list; the official grammar
 	// accepts a single trailing ASC or DESC, not one per term.
 	OrderDesc bool
-	PivotBy   []Expr
-	Limit     *int64
+	// OrderTermDesc holds one direction per ORDER BY term when parsed with
+	// v3 syntax, and takes precedence over OrderDesc.
+	OrderTermDesc []bool
+	PivotBy       []Expr
+	Limit         *int64
 }
 
 func (*Select) stmt() {}
@@ -53,10 +57,13 @@ type Target struct {
 }
 
 // From is the FROM clause: an optional entry-level filter expression plus
-// optional summarization transforms.
+// optional summarization transforms, or a subquery or table whose rows the
+// statement selects from.
 type From struct {
 	position
 	Expr    Expr
+	Select  *Select // FROM (SELECT ...), v3 syntax only
+	Table   string  // FROM #name without the '#', v3 syntax only
 	OpenOn  *ast.Date
 	Close   bool // bare CLOSE, or CLOSE ON when CloseOn is set
 	CloseOn *ast.Date
@@ -132,6 +139,14 @@ type Binary struct {
 
 func (*Binary) expr() {}
 
+// Subquery is a parenthesized SELECT in an expression (v3 syntax only).
+type Subquery struct {
+	position
+	Select *Select
+}
+
+func (*Subquery) expr() {}
+
 // Str is a string literal.
 type Str struct {
 	position
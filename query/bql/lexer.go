@@ -38,6 +38,8 @@ func (l *Lexer) Next() Token {
 		return l.scanNumberOrDate(start, line, col)
 	case c == '"' || c == '\'':
 		return l.scanString(c, start, line, col)
+	case c == '#' && l.pos+1 < len(l.source) && isIdentStart(l.source[l.pos+1]):
+		return l.scanTable(start, line, col)
 	}
 
 	l.advance()
@@ -101,6 +103,15 @@ func (l *Lexer) scanIdent(start, line, col int) Token {
 	return Token{Type: typ, Start: start, End: l.pos, Line: line, Column: col}
 }
 
+// scanTable scans a TABLE token: '#' followed by an identifier.
+func (l *Lexer) scanTable(start, line, col int) Token {
+	l.advance() // #
+	for l.pos < len(l.source) && isIdentPart(l.source[l.pos]) {
+		l.advance()
+	}
+	return Token{Type: TABLE, Start: start, End: l.pos, Line: line, Column: col}
+}
+
 // scanNumberOrDate scans an INTEGER, DECIMAL, or DATE token. Date literals
 // are detected by shape (YYYY-MM-DD); value validation happens in the parser
 // so invalid dates report a positioned parse error, not a lexer error.
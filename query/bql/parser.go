@@ -12,14 +12,30 @@ import (
 // queryFilename is the filename used in positions for parsed query strings.
 const queryFilename = "<query>"
 
+// Option configures the parser.
+type Option func(*parser)
+
+// WithV3Syntax accepts the beanquery v3 extensions to the grammar: a HAVING
+// clause after GROUP BY, an ASC or DESC direction per ORDER BY term,
+// parenthesized subqueries, and FROM #table. Without it the parser follows
+// bean-query 2.x, which rejects them and reads HAVING as a plain name.
+func WithV3Syntax() Option {
+	return func(p *parser) {
+		p.v3 = true
+	}
+}
+
 // Parse parses a BQL statement from the given query string.
-func Parse(query string) (Statement, error) {
-	return ParseBytes([]byte(query))
+func Parse(query string, opts ...Option) (Statement, error) {
+	return ParseBytes([]byte(query), opts...)
 }
 
 // ParseBytes parses a BQL statement from the given query source.
-func ParseBytes(source []byte) (Statement, error) {
+func ParseBytes(source []byte, opts ...Option) (Statement, error) {
 	p := newParser(source)
+	for _, opt := range opts {
+		opt(p)
+	}
 	stmt, err := p.parseStatement()
 	if err != nil {
 		return nil, err
@@ -33,11 +49,26 @@ func ParseBytes(source []byte) (Statement, error) {
 	return stmt, nil
 }
 
+// ParseExpr parses a single BQL expression, such as the body of a
+// user-defined function.
+func ParseExpr(source string) (Expr, error) {
+	p := newParser([]byte(source))
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.cur.Type != EOF {
+		return nil, p.errorf(p.cur, "unexpected %s", p.describe(p.cur))
+	}
+	return expr, nil
+}
+
 // Parser is a recursive-descent parser for BQL statements.
 type parser struct {
 	source []byte
 	lexer  *Lexer
 	cur    Token
+	v3     bool
 }
 
 func newParser(source []byte) *parser {
@@ -48,6 +79,11 @@ func newParser(source []byte) *parser {
 
 func (p *parser) next() {
 	p.cur = p.lexer.Next()
+	// HAVING is only reserved in v3 syntax; bean-query 2.x reads it as a
+	// name, so it can still be a column alias there.
+	if p.cur.Type == HAVING && !p.v3 {
+		p.cur.Type = IDENT
+	}
 }
 
 // expect consumes the current token if it has the given type, or fails with a
@@ -85,7 +121,7 @@ func (p *parser) describe(tok Token) string {
 	switch tok.Type {
 	case EOF:
 		return "end of query"
-	case IDENT, STRING, INTEGER, DECIMAL, DATE, ILLEGAL:
+	case IDENT, STRING, INTEGER, DECIMAL, DATE, TABLE, ILLEGAL:
 		return fmt.Sprintf("%s %q", strings.ToLower(tok.Type.String()), tok.String(p.source))
 	default:
 		return fmt.Sprintf("%q", tok.String(p.source))
@@ -155,6 +191,18 @@ func (p *parser) parseSelect() (*Select, error) {
 			return nil, err
 		}
 		sel.GroupBy = exprs
+
+		if !p.v3 && p.cur.Type == IDENT && strings.EqualFold(p.cur.String(p.source), "HAVING") {
+			return nil, p.errorf(p.cur, "HAVING requires beanquery v3 syntax")
+		}
+		if p.cur.Type == HAVING {
+			p.next()
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			sel.Having = expr
+		}
 	}
 
 	if p.cur.Type == ORDER {
@@ -162,15 +210,21 @@ func (p *parser) parseSelect() (*Select, error) {
 		if _, err := p.expect(BY, "ORDER BY"); err != nil {
 			return nil, err
 		}
-		exprs, err := p.parseExprList()
-		if err != nil {
-			return nil, err
-		}
-		sel.OrderBy = exprs
-		if p.accept(DESC) {
-			sel.OrderDesc = true
+		if p.v3 {
+			if err := p.parseOrderTerms(sel); err != nil {
+				return nil, err
+			}
 		} else {
-			p.accept(ASC)
+			exprs, err := p.parseExprList()
+			if err != nil {
+				return nil, err
+			}
+			sel.OrderBy = exprs
+			if p.accept(DESC) {
+				sel.OrderDesc = true
+			} else {
+				p.accept(ASC)
+			}
 		}
 	}
 
@@ -202,6 +256,26 @@ func (p *parser) parseSelect() (*Select, error) {
 	return sel, nil
 }
 
+// parseOrderTerms parses the v3 ORDER BY list, where each term takes its own
+// optional direction.
+func (p *parser) parseOrderTerms(sel *Select) error {
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		desc := p.accept(DESC)
+		if !desc {
+			p.accept(ASC)
+		}
+		sel.OrderBy = append(sel.OrderBy, expr)
+		sel.OrderTermDesc = append(sel.OrderTermDesc, desc)
+		if !p.accept(COMMA) {
+			return nil
+		}
+	}
+}
+
 func (p *parser) parseTarget() (Target, error) {
 	expr, err := p.parseExpr()
 	if err != nil {
@@ -227,11 +301,24 @@ func (p *parser) parseFrom() (*From, error) {
 
 	from := &From{position: position{p.pos(tok)}}
 
+	if p.cur.Type == TABLE {
+		if !p.v3 {
+			return nil, p.errorf(p.cur, "tables require beanquery v3 syntax")
+		}
+		from.Table = strings.TrimPrefix(p.cur.String(p.source), "#")
+		p.next()
+		return from, p.checkNoTransforms("table")
+	}
+
 	if p.startsExpr() {
 		expr, err := p.parseExpr()
 		if err != nil {
 			return nil, err
 		}
+		if sub, ok := expr.(*Subquery); ok {
+			from.Select = sub.Select
+			return from, p.checkNoTransforms("subquery")
+		}
 		from.Expr = expr
 	}
 
@@ -271,6 +358,16 @@ func (p *parser) parseFrom() (*From, error) {
 	return from, nil
 }
 
+// checkNoTransforms rejects OPEN, CLOSE and CLEAR after a FROM source that
+// is not the ledger's entries.
+func (p *parser) checkNoTransforms(source string) error {
+	switch p.cur.Type {
+	case OPEN, CLOSE, CLEAR:
+		return p.errorf(p.cur, "%s does not apply to a FROM %s", p.cur.Type, source)
+	}
+	return nil
+}
+
 func (p *parser) parseBalances() (*Balances, error) {
 	tok := p.cur
 	p.next() // BALANCES
@@ -485,6 +582,9 @@ func (p *parser) parsePrimary() (Expr, error) {
 	switch tok.Type {
 	case LPAREN:
 		p.next()
+		if p.cur.Type == SELECT {
+			return p.parseSubquery(tok)
+		}
 		expr, err := p.parseExpr()
 		if err != nil {
 			return nil, err
@@ -554,6 +654,22 @@ func (p *parser) parsePrimary() (Expr, error) {
 	return nil, p.errorf(tok, "expected expression, found %s", p.describe(tok))
 }
 
+// parseSubquery parses a parenthesized SELECT, the opening parenthesis tok
+// already consumed.
+func (p *parser) parseSubquery(tok Token) (*Subquery, error) {
+	if !p.v3 {
+		return nil, p.errorf(p.cur, "subqueries require beanquery v3 syntax")
+	}
+	sel, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(RPAREN, "subquery"); err != nil {
+		return nil, err
+	}
+	return &Subquery{position: position{p.pos(tok)}, Select: sel}, nil
+}
+
 // parseDate parses a DATE token into an ast.Date, validating its value.
 func (p *parser) parseDate() (*ast.Date, error) {
 	tok, err := p.expect(DATE, "date")
@@ -77,6 +77,18 @@ func TestParseFunctionCallNoArgs(t *testing.T) {
 	assert.Zero(t, len(call.Args))
 }
 
+func TestParseExpr(t *testing.T) {
+	expr, err := ParseExpr("convert(sum(position), 'EUR', date)")
+	assert.NoError(t, err)
+
+	call := expr.(*Call)
+	assert.Equal(t, "convert", call.Func)
+	assert.Equal(t, 3, len(call.Args))
+
+	_, err = ParseExpr("sum(position) FROM")
+	assert.EqualError(t, err, `<query>:1:15: unexpected "FROM"`)
+}
+
 func TestParseWherePrecedence(t *testing.T) {
 	// NOT binds tighter than AND, AND tighter than OR.
 	stmt, err := Parse("SELECT * WHERE a = 1 OR b = 2 AND NOT c = 3")
@@ -219,6 +231,97 @@ func TestParseOrderByList(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestParseOrderByPerTermDirections(t *testing.T) {
+	stmt, err := Parse("SELECT * ORDER BY date DESC, account ASC, narration", WithV3Syntax())
+	assert.NoError(t, err)
+
+	sel := stmt.(*Select)
+	assert.Equal(t, 3, len(sel.OrderBy))
+	assert.Equal(t, []bool{true, false, false}, sel.OrderTermDesc)
+	assert.False(t, sel.OrderDesc)
+
+	// With v3 syntax a trailing direction only applies to the last term.
+	stmt, err = Parse("SELECT * ORDER BY date, account DESC", WithV3Syntax())
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, true}, stmt.(*Select).OrderTermDesc)
+}
+
+func TestParseHaving(t *testing.T) {
+	stmt, err := Parse("SELECT account, sum(number) GROUP BY account HAVING sum(number) > 0 ORDER BY account", WithV3Syntax())
+	assert.NoError(t, err)
+
+	sel := stmt.(*Select)
+	having := sel.Having.(*Binary)
+	assert.Equal(t, "sum", having.L.(*Call).Func)
+	assert.Equal(t, 1, len(sel.OrderBy))
+
+	_, err = Parse("SELECT account, sum(number) GROUP BY account HAVING sum(number) > 0")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "HAVING requires beanquery v3 syntax")
+
+	// HAVING belongs to GROUP BY.
+	_, err = Parse("SELECT sum(number) HAVING sum(number) > 0", WithV3Syntax())
+	assert.Error(t, err)
+
+	// Without v3 syntax HAVING is not reserved and can name a column.
+	stmt, err = Parse("SELECT account AS having ORDER BY having")
+	assert.NoError(t, err)
+	assert.Equal(t, "having", stmt.(*Select).Targets[0].As)
+
+	_, err = Parse("SELECT account AS having", WithV3Syntax())
+	assert.Error(t, err)
+}
+
+func TestParseSubqueries(t *testing.T) {
+	stmt, err := Parse("SELECT account, total FROM (SELECT account, sum(number) AS total GROUP BY account) WHERE total > 0", WithV3Syntax())
+	assert.NoError(t, err)
+
+	sel := stmt.(*Select)
+	assert.Zero(t, sel.From.Expr)
+	assert.Equal(t, 2, len(sel.From.Select.Targets))
+	assert.Equal(t, 1, len(sel.From.Select.GroupBy))
+	assert.NotZero(t, sel.Where)
+
+	stmt, err = Parse("SELECT date WHERE account IN (SELECT DISTINCT account WHERE number > 100)", WithV3Syntax())
+	assert.NoError(t, err)
+	in := stmt.(*Select).Where.(*Binary)
+	assert.Equal(t, IN, in.Op)
+	assert.True(t, in.R.(*Subquery).Select.Distinct)
+
+	_, err = Parse("SELECT date WHERE account IN (SELECT account)")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "subqueries require beanquery v3 syntax")
+
+	_, err = Parse("SELECT account FROM (SELECT account) CLEAR", WithV3Syntax())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "CLEAR does not apply to a FROM subquery")
+
+	_, err = Parse("SELECT account FROM (SELECT account", WithV3Syntax())
+	assert.Error(t, err)
+}
+
+func TestParseFromTable(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM #prices WHERE currency = 'HOOL'", WithV3Syntax())
+	assert.NoError(t, err)
+
+	sel := stmt.(*Select)
+	assert.Equal(t, "prices", sel.From.Table)
+	assert.Zero(t, sel.From.Expr)
+	assert.NotZero(t, sel.Where)
+
+	_, err = Parse("SELECT * FROM #prices")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tables require beanquery v3 syntax")
+
+	_, err = Parse("SELECT * FROM #entries CLOSE", WithV3Syntax())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "CLOSE does not apply to a FROM table")
+
+	// A lone '#' is not a table name.
+	_, err = Parse("SELECT * FROM # prices", WithV3Syntax())
+	assert.Error(t, err)
+}
+
 func TestParsePivotBy(t *testing.T) {
 	stmt, err := Parse("SELECT account, year(date), sum(position) GROUP BY 1, 2 PIVOT BY account, year")
 	assert.NoError(t, err)
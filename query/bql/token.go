@@ -1,5 +1,10 @@
 package bql
 
+import (
+	"maps"
+	"slices"
+)
+
 // TokenType represents the type of token scanned from a BQL query string.
 type TokenType uint8
 
@@ -19,6 +24,7 @@ const (
 	FROM
 	WHERE
 	GROUP
+	HAVING
 	ORDER
 	PIVOT
 	BY
@@ -49,6 +55,7 @@ const (
 	INTEGER // 123
 	DECIMAL // 123.45
 	DATE    // YYYY-MM-DD
+	TABLE   // #name
 
 	// Symbols
 	LPAREN    // (
@@ -81,6 +88,7 @@ var tokenNames = map[TokenType]string{
 	FROM:     "FROM",
 	WHERE:    "WHERE",
 	GROUP:    "GROUP",
+	HAVING:   "HAVING",
 	ORDER:    "ORDER",
 	PIVOT:    "PIVOT",
 	BY:       "BY",
@@ -108,6 +116,7 @@ var tokenNames = map[TokenType]string{
 	INTEGER: "INTEGER",
 	DECIMAL: "DECIMAL",
 	DATE:    "DATE",
+	TABLE:   "TABLE",
 
 	LPAREN:    "(",
 	RPAREN:    ")",
@@ -144,6 +153,7 @@ var keywords = map[string]TokenType{
 	"FROM":     FROM,
 	"WHERE":    WHERE,
 	"GROUP":    GROUP,
+	"HAVING":   HAVING,
 	"ORDER":    ORDER,
 	"PIVOT":    PIVOT,
 	"BY":       BY,
@@ -165,6 +175,12 @@ var keywords = map[string]TokenType{
 	"NULL":     NULL,
 }
 
+// Keywords returns the sorted BQL keywords, for completion in interactive
+// shells.
+func Keywords() []string {
+	return slices.Sorted(maps.Keys(keywords))
+}
+
 // Token represents a lexical token with zero-copy semantics. Like the core
 // beancount parser, tokens store byte offsets into the source buffer instead
 // of materialized strings.
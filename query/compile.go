@@ -3,6 +3,7 @@ package query
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/robinvdvleuten/beancount/ast"
@@ -40,12 +41,15 @@ type cexpr interface {
 // and PivotBy reference targets by index; hidden targets were appended
 // during resolution and are not rendered.
 type Compiled struct {
-	Targets   []CompiledTarget
-	Where     cexpr
-	From      *CompiledFrom
-	GroupBy   []int
-	OrderBy   []int
-	OrderDesc bool
+	Targets []CompiledTarget
+	Where   cexpr
+	From    *CompiledFrom
+	GroupBy []int
+	// Having filters the groups once their aggregates are final.
+	Having  cexpr
+	OrderBy []int
+	// OrderDesc holds the direction of each OrderBy term.
+	OrderDesc []bool
 	PivotBy   []int
 	Limit     *int64
 	Distinct  bool
@@ -54,6 +58,24 @@ type Compiled struct {
 	// UsesBalance is set when the running balance column is referenced,
 	// so the executor can skip per-row inventory snapshots otherwise.
 	UsesBalance bool
+
+	subqueries []*cInSubquery
+	pushdown   *pushdown    // WHERE terms answered from account histories
+	stmt       *bql.Select  // the desugared statement, for Explain
+	env        *environment // the environment targets compiled against
+}
+
+// Columns describes the visible result columns of the query. A pivoted
+// query's value columns depend on the data, so only its row column is
+// known before execution.
+func (c *Compiled) Columns() []ResultColumn {
+	var columns []ResultColumn
+	for _, target := range c.Targets {
+		if !target.Hidden {
+			columns = append(columns, ResultColumn{Name: target.Name, Type: target.Type})
+		}
+	}
+	return columns
 }
 
 // CompiledTarget is one output column (or hidden sort/group key).
@@ -63,17 +85,28 @@ type CompiledTarget struct {
 	Hidden bool
 	IsAgg  bool // the expression contains an aggregate function
 	expr   cexpr
-	key    string // canonical expression key for structural matching
+	key    string   // canonical expression key for structural matching
+	source bql.Expr // the expression as written, for Explain
 }
 
 // CompiledFrom is the compiled FROM clause: an entry-level filter plus
-// summarization transforms applied by the executor.
+// summarization transforms applied by the executor, or a subquery whose
+// result rows replace the postings.
 type CompiledFrom struct {
-	Expr    cexpr
-	OpenOn  *ast.Date
-	Close   bool
-	CloseOn *ast.Date
-	Clear   bool
+	Expr     cexpr
+	Subquery *Compiled
+	Table    string
+	table    *table
+	OpenOn   *ast.Date
+	Close    bool
+	CloseOn  *ast.Date
+	Clear    bool
+}
+
+// transforms reports whether the FROM clause rewrites the entry stream with
+// OPEN, CLOSE, or CLEAR.
+func (f *CompiledFrom) transforms() bool {
+	return f != nil && (f.OpenOn != nil || f.CloseOn != nil || f.Clear)
 }
 
 // Compile resolves and type-checks a parsed BQL statement against the query
@@ -92,15 +125,38 @@ type compiler struct {
 	ctx         *Context
 	env         *environment
 	aggs        []*cAgg
+	subqueries  []*cInSubquery
 	usesBalance bool
+	scope       map[string]macroArg // Parameters of the macro being expanded
+	expanding   []string            // Names of the macros being expanded
 }
 
 func (c *compiler) compileSelect(sel *bql.Select) (*Compiled, error) {
-	compiled := &Compiled{Distinct: sel.Distinct, Limit: sel.Limit}
+	compiled := &Compiled{Distinct: sel.Distinct, Limit: sel.Limit, stmt: sel}
 
 	// FROM compiles against the entry environment, everything else against
-	// the posting environment.
-	if sel.From != nil {
+	// the posting environment, or the columns of a FROM subquery or table.
+	env, wildcard := targetsEnv, wildcardColumns
+	if sel.From != nil && sel.From.Table != "" {
+		t, ok := tables[sel.From.Table]
+		if !ok {
+			return nil, compileErrorf(sel.From, "Invalid table name '#%s'.", sel.From.Table)
+		}
+		compiled.From = &CompiledFrom{Table: sel.From.Table, table: t}
+		env, wildcard = t.env, t.wildcard
+	} else if sel.From != nil && sel.From.Select != nil {
+		sub, err := c.compileSubquery(sel.From.Select)
+		if err != nil {
+			return nil, err
+		}
+		compiled.From = &CompiledFrom{Subquery: sub}
+		columns := sub.Columns()
+		env = subqueryEnv(columns)
+		wildcard = make([]string, len(columns))
+		for i, column := range columns {
+			wildcard[i] = column.Name
+		}
+	} else if sel.From != nil {
 		from := &CompiledFrom{
 			OpenOn:  sel.From.OpenOn,
 			Close:   sel.From.Close,
@@ -118,13 +174,14 @@ func (c *compiler) compileSelect(sel *bql.Select) (*Compiled, error) {
 		compiled.From = from
 	}
 
-	c.env = targetsEnv
+	c.env = env
+	compiled.env = env
 
 	// Targets: expand the wildcard or compile the explicit list.
 	targets := sel.Targets
 	if sel.Wildcard {
-		targets = make([]bql.Target, len(wildcardColumns))
-		for i, name := range wildcardColumns {
+		targets = make([]bql.Target, len(wildcard))
+		for i, name := range wildcard {
 			targets[i] = bql.Target{Expr: &bql.Ident{Name: name}}
 		}
 	}
@@ -139,11 +196,12 @@ func (c *compiler) compileSelect(sel *bql.Select) (*Compiled, error) {
 			name = deriveName(target.Expr)
 		}
 		compiled.Targets = append(compiled.Targets, CompiledTarget{
-			Name:  name,
-			Type:  expr.typ(),
-			IsAgg: len(c.aggs) > before,
-			expr:  expr,
-			key:   exprKey(target.Expr),
+			Name:   name,
+			Type:   expr.typ(),
+			IsAgg:  len(c.aggs) > before,
+			expr:   expr,
+			key:    exprKey(target.Expr),
+			source: target.Expr,
 		})
 	}
 
@@ -157,11 +215,21 @@ func (c *compiler) compileSelect(sel *bql.Select) (*Compiled, error) {
 			return nil, compileErrorf(sel.Where, "Aggregates are disallowed in WHERE clause.")
 		}
 		compiled.Where = expr
+		if c.env == targetsEnv && !compiled.From.transforms() {
+			compiled.pushdown = planPushdown(expr)
+		}
 	}
 
 	if err := c.resolveGroupBy(sel, compiled); err != nil {
 		return nil, err
 	}
+	if sel.Having != nil {
+		expr, err := c.compileExpr(sel.Having, true)
+		if err != nil {
+			return nil, err
+		}
+		compiled.Having = expr
+	}
 	if err := c.resolveOrderBy(sel, compiled); err != nil {
 		return nil, err
 	}
@@ -178,6 +246,7 @@ func (c *compiler) compileSelect(sel *bql.Select) (*Compiled, error) {
 	}
 	compiled.Aggs = c.aggs
 	compiled.UsesBalance = c.usesBalance
+	compiled.subqueries = c.subqueries
 
 	if err := checkGroupCoverage(sel, compiled); err != nil {
 		return nil, err
@@ -185,6 +254,19 @@ func (c *compiler) compileSelect(sel *bql.Select) (*Compiled, error) {
 	return compiled, nil
 }
 
+// compileSubquery compiles a nested SELECT on its own: it cannot refer to
+// the columns of the statement it appears in.
+func (c *compiler) compileSubquery(sel *bql.Select) (*Compiled, error) {
+	sub, err := (&compiler{ctx: c.ctx}).compileSelect(sel)
+	if err != nil {
+		return nil, err
+	}
+	if len(sub.PivotBy) > 0 {
+		return nil, compileErrorf(sel.PivotBy[0], "PIVOT BY is not supported in a subquery.")
+	}
+	return sub, nil
+}
+
 // resolveGroupBy maps GROUP BY items to target indices, appending hidden
 // targets for expressions that are not in the select list. Without an
 // explicit GROUP BY, an aggregate query implicitly groups by all
@@ -223,28 +305,52 @@ func (c *compiler) resolveGroupBy(sel *bql.Select, compiled *Compiled) error {
 
 // resolveOrderBy maps ORDER BY expressions to target indices, appending
 // hidden targets as needed. A single trailing direction applies to the
-// whole list.
+// whole list unless the statement has per-term directions.
 func (c *compiler) resolveOrderBy(sel *bql.Select, compiled *Compiled) error {
-	compiled.OrderDesc = sel.OrderDesc
-	for _, item := range sel.OrderBy {
+	for i, item := range sel.OrderBy {
 		idx, err := c.resolveTargetRef(item, compiled, true)
 		if err != nil {
 			return err
 		}
+		desc := sel.OrderDesc
+		if sel.OrderTermDesc != nil {
+			desc = sel.OrderTermDesc[i]
+		}
 		compiled.OrderBy = append(compiled.OrderBy, idx)
+		compiled.OrderDesc = append(compiled.OrderDesc, desc)
 	}
 	return nil
 }
 
+// resolvePivotBy maps the two PIVOT BY items to target indices: the first
+// labels the output rows, the distinct values of the second become columns.
 func (c *compiler) resolvePivotBy(sel *bql.Select, compiled *Compiled) error {
+	if len(sel.PivotBy) == 0 {
+		return nil
+	}
+	if len(sel.PivotBy) != 2 {
+		return compileErrorf(sel.PivotBy[0], "PIVOT BY requires exactly two columns.")
+	}
 	for _, item := range sel.PivotBy {
 		idx, err := c.resolveTargetRef(item, compiled, false)
 		if err != nil {
 			return err
 		}
+		if compiled.Targets[idx].IsAgg {
+			return compileErrorf(item, "PIVOT BY expressions may not be aggregates.")
+		}
 		compiled.PivotBy = append(compiled.PivotBy, idx)
 	}
-	return nil
+	if compiled.PivotBy[0] == compiled.PivotBy[1] {
+		return compileErrorf(sel.PivotBy[1], "PIVOT BY columns must be distinct.")
+	}
+	// The cells of the pivot table come from the other selected columns.
+	for i, target := range compiled.Targets {
+		if !target.Hidden && !slices.Contains(compiled.PivotBy, i) {
+			return nil
+		}
+	}
+	return compileErrorf(sel.PivotBy[0], "PIVOT BY requires a selected column besides the two pivot columns.")
 }
 
 // resolveTargetRef resolves a clause item to a target index. Integer
@@ -295,6 +401,7 @@ func (c *compiler) resolveTargetRef(item bql.Expr, compiled *Compiled, allowAgg
 		IsAgg:  len(c.aggs) > before,
 		expr:   expr,
 		key:    key,
+		source: item,
 	})
 	return len(compiled.Targets) - 1, nil
 }
@@ -340,11 +447,23 @@ func (c *compiler) compileExpr(e bql.Expr, allowAgg bool) (cexpr, error) {
 		return &cLiteral{v: nil, t: TAny}, nil
 
 	case *bql.Ident:
+		if arg, ok := c.scope[node.Name]; ok {
+			if arg.hasAgg && !allowAgg {
+				return nil, compileErrorf(node, "Aggregates are disallowed in this context.")
+			}
+			return arg.expr, nil
+		}
 		def, ok := c.env.columns[node.Name]
 		if !ok {
+			if macro, ok := c.ctx.macro(node.Name); ok {
+				if !macro.Column {
+					return nil, compileErrorf(node, "Function '%s' must be called with arguments.", macro.Name)
+				}
+				return c.expandMacro(node, macro, nil, allowAgg)
+			}
 			return nil, compileErrorf(node, "Invalid column name '%s' in %s.", node.Name, c.env.context)
 		}
-		if node.Name == "balance" {
+		if c.env == targetsEnv && node.Name == "balance" {
 			c.usesBalance = true
 		}
 		return &cColumn{def: def}, nil
@@ -357,6 +476,9 @@ func (c *compiler) compileExpr(e bql.Expr, allowAgg bool) (cexpr, error) {
 
 	case *bql.Binary:
 		return c.compileBinary(node, allowAgg)
+
+	case *bql.Subquery:
+		return nil, compileErrorf(node, "Subqueries are only supported in FROM and on the right of IN.")
 	}
 	return nil, compileErrorf(e, "unsupported expression")
 }
@@ -386,6 +508,12 @@ func (c *compiler) compileCall(node *bql.Call, allowAgg bool) (cexpr, error) {
 
 	def, ok := functions[name]
 	if !ok {
+		if macro, ok := c.ctx.macro(name); ok {
+			if macro.Column {
+				return nil, compileErrorf(node, "Column '%s' must be used without parentheses.", macro.Name)
+			}
+			return c.expandMacro(node, macro, node.Args, allowAgg)
+		}
 		return nil, compileErrorf(node, "Invalid function '%s(%s)' in %s.", name, argTypeList(nil, node.Args), c.env.context)
 	}
 	args := make([]cexpr, len(node.Args))
@@ -443,6 +571,9 @@ func (c *compiler) compileBinary(node *bql.Binary, allowAgg bool) (cexpr, error)
 	if err != nil {
 		return nil, err
 	}
+	if sub, ok := node.R.(*bql.Subquery); ok && node.Op == bql.IN {
+		return c.compileInSubquery(l, sub)
+	}
 	r, err := c.compileExpr(node.R, allowAgg)
 	if err != nil {
 		return nil, err
@@ -462,6 +593,21 @@ func (c *compiler) compileBinary(node *bql.Binary, allowAgg bool) (cexpr, error)
 	return &cBinary{op: node.Op, l: l, r: r, t: t}, nil
 }
 
+// compileInSubquery compiles x IN (SELECT ...). The subquery must select a
+// single column.
+func (c *compiler) compileInSubquery(x cexpr, node *bql.Subquery) (cexpr, error) {
+	sub, err := c.compileSubquery(node.Select)
+	if err != nil {
+		return nil, err
+	}
+	if len(sub.Columns()) != 1 {
+		return nil, compileErrorf(node, "Subquery must select exactly one column.")
+	}
+	in := &cInSubquery{x: x, query: sub}
+	c.subqueries = append(c.subqueries, in)
+	return in, nil
+}
+
 // deriveName builds the default column name for an unaliased target,
 // matching the official naming: columns keep their name, function calls
 // join the function and argument names with underscores, and constants get
@@ -500,6 +646,8 @@ func deriveName(e bql.Expr) string {
 		return prefix + "_" + deriveName(node.X)
 	case *bql.Binary:
 		return binaryOpNames[node.Op] + "_" + deriveName(node.L) + "_" + deriveName(node.R)
+	case *bql.Subquery:
+		return "subquery"
 	}
 	return "expr"
 }
@@ -592,6 +740,43 @@ func (c *cAgg) eval(row *Row) any {
 	return nil
 }
 
+// cInSubquery tests membership in the result of an IN subquery, which
+// Execute runs first and keeps in the context of the execution.
+type cInSubquery struct {
+	x     cexpr
+	query *Compiled
+}
+
+func (c *cInSubquery) typ() DType { return TBool }
+
+func (c *cInSubquery) eval(row *Row) any {
+	v := c.x.eval(row)
+	if v == nil || row.Ctx == nil {
+		return false
+	}
+	return row.Ctx.subqueries[c].contains(v)
+}
+
+// valueSet holds the values of a subquery column. Values match by type:
+// numbers compare numerically whether integer or decimal, and never equal a
+// string.
+type valueSet map[valueKey]bool
+
+type valueKey struct {
+	kind string
+	text string
+}
+
+func newValueKey(v any) valueKey {
+	if d, ok := asDecimal(v); ok {
+		return valueKey{"number", d.String()}
+	}
+	return valueKey{fmt.Sprintf("%T", v), valueString(v)}
+}
+
+func (s valueSet) add(v any)           { s[newValueKey(v)] = true }
+func (s valueSet) contains(v any) bool { return s[newValueKey(v)] }
+
 type cNot struct {
 	x cexpr
 }
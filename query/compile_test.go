@@ -62,18 +62,18 @@ func newContextFromSource(t *testing.T, source string) (*Context, *ast.AST) {
 	return &Context{Ledger: l, Config: cfg}, tree
 }
 
-func mustCompile(t *testing.T, ctx *Context, query string) *Compiled {
+func mustCompile(t *testing.T, ctx *Context, query string, opts ...bql.Option) *Compiled {
 	t.Helper()
-	stmt, err := bql.Parse(query)
+	stmt, err := bql.Parse(query, opts...)
 	assert.NoError(t, err)
 	compiled, err := Compile(ctx, stmt)
 	assert.NoError(t, err)
 	return compiled
 }
 
-func compileError(t *testing.T, ctx *Context, query string) error {
+func compileError(t *testing.T, ctx *Context, query string, opts ...bql.Option) error {
 	t.Helper()
-	stmt, err := bql.Parse(query)
+	stmt, err := bql.Parse(query, opts...)
 	assert.NoError(t, err)
 	_, err = Compile(ctx, stmt)
 	assert.Error(t, err)
@@ -216,7 +216,7 @@ func TestCompileOrderByMatchesTarget(t *testing.T) {
 
 	assert.Equal(t, 2, len(compiled.Targets))
 	assert.Equal(t, []int{1}, compiled.OrderBy)
-	assert.True(t, compiled.OrderDesc)
+	assert.Equal(t, []bool{true}, compiled.OrderDesc)
 }
 
 func TestCompileAggregateInWhere(t *testing.T) {
@@ -257,3 +257,42 @@ func TestCompileDistinctAndLimit(t *testing.T) {
 	assert.True(t, compiled.Distinct)
 	assert.Equal(t, int64(5), *compiled.Limit)
 }
+
+func TestCompileFromSubquery(t *testing.T) {
+	// The outer query sees the subquery's result columns with their types.
+	ctx, _ := newTestContext(t)
+	compiled := mustCompile(t, ctx, "SELECT account, total, total * 2 AS double FROM (SELECT account, sum(number) AS total GROUP BY account) WHERE total > 0", bql.WithV3Syntax())
+
+	assert.Equal(t, []ResultColumn{
+		{Name: "account", Type: TString},
+		{Name: "total", Type: TDecimal},
+		{Name: "double", Type: TDecimal},
+	}, compiled.Columns())
+	assert.NotZero(t, compiled.From.Subquery)
+
+	// The wildcard expands to the subquery's columns.
+	compiled = mustCompile(t, ctx, "SELECT * FROM (SELECT account, sum(number) AS total GROUP BY account)", bql.WithV3Syntax())
+	assert.Equal(t, []ResultColumn{
+		{Name: "account", Type: TString},
+		{Name: "total", Type: TDecimal},
+	}, compiled.Columns())
+
+	err := compileError(t, ctx, "SELECT date FROM (SELECT account)", bql.WithV3Syntax())
+	assert.Equal(t, "Invalid column name 'date' in subquery context.", err.Error())
+}
+
+func TestCompileSubqueryErrors(t *testing.T) {
+	ctx, _ := newTestContext(t)
+
+	err := compileError(t, ctx, "SELECT account WHERE account IN (SELECT account, date)", bql.WithV3Syntax())
+	assert.Equal(t, "Subquery must select exactly one column.", err.Error())
+
+	err = compileError(t, ctx, "SELECT account WHERE account = (SELECT account)", bql.WithV3Syntax())
+	assert.Contains(t, err.Error(), "Subqueries are only supported")
+
+	err = compileError(t, ctx, "SELECT account WHERE account IN (SELECT account WHERE bogus)", bql.WithV3Syntax())
+	assert.Contains(t, err.Error(), "Invalid column name 'bogus'")
+
+	err = compileError(t, ctx, "SELECT * FROM (SELECT account, year, sum(number) GROUP BY 1, 2 PIVOT BY account, year)", bql.WithV3Syntax())
+	assert.Equal(t, "PIVOT BY is not supported in a subquery.", err.Error())
+}
@@ -82,12 +82,17 @@ func summarize(summary string, expr bql.Expr) bql.Expr {
 // CompiledPrint is a compiled PRINT statement: just its entry filter.
 type CompiledPrint struct {
 	From *CompiledFrom
+
+	subqueries []*cInSubquery
 }
 
 // CompilePrint compiles a PRINT statement's FROM clause.
 func CompilePrint(ctx *Context, p *bql.Print) (*CompiledPrint, error) {
 	compiled := &CompiledPrint{}
 	if p.From != nil {
+		if p.From.Select != nil || p.From.Table != "" {
+			return nil, compileErrorf(p.From, "PRINT cannot select FROM a subquery or table.")
+		}
 		from := &CompiledFrom{
 			OpenOn:  p.From.OpenOn,
 			Close:   p.From.Close,
@@ -101,6 +106,7 @@ func CompilePrint(ctx *Context, p *bql.Print) (*CompiledPrint, error) {
 				return nil, err
 			}
 			from.Expr = expr
+			compiled.subqueries = c.subqueries
 		}
 		compiled.From = from
 	}
@@ -111,6 +117,10 @@ func CompilePrint(ctx *Context, p *bql.Print) (*CompiledPrint, error) {
 // text. Transactions are preceded by a blank line, matching the official
 // printer's spacing.
 func ExecutePrint(ctx context.Context, qctx *Context, tree *ast.AST, compiled *CompiledPrint, w io.Writer) error {
+	qctx, err := runSubqueries(ctx, qctx, tree, compiled.subqueries)
+	if err != nil {
+		return err
+	}
 	entries := []ast.Directive(tree.Directives)
 	if compiled.From != nil {
 		entries = applyFromTransforms(qctx, entries, compiled.From)
@@ -4,8 +4,12 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
 
 	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/budget"
 	"github.com/robinvdvleuten/beancount/config"
 	"github.com/robinvdvleuten/beancount/ledger"
 	"github.com/shopspring/decimal"
@@ -15,13 +19,51 @@ import (
 type Context struct {
 	Ledger *ledger.Ledger
 	Config *config.Config
+	// Macros are the user-defined functions and columns queries may use,
+	// keyed by lowercase name; see MacrosFromAST.
+	Macros map[string]*Macro
+	// Budgets are the budgets the #budgets table compares, in date order;
+	// see budget.FromAST.
+	Budgets []*budget.Budget
+
+	indexMu sync.Mutex
+	index   *entryIndex // built on first pushdown, see Context.entryIndex
+
+	// State of one execution, on contexts made by withSubqueries
+	shared     *Context                  // context this one was derived from
+	subqueries map[*cInSubquery]valueSet // values of the IN subqueries run
+}
+
+// withSubqueries returns a context for one execution that answers IN
+// subqueries from values, sharing the rest of ctx, so a compiled query can
+// run any number of times, concurrently too.
+func (ctx *Context) withSubqueries(values map[*cInSubquery]valueSet) *Context {
+	shared := ctx
+	if ctx.shared != nil {
+		shared = ctx.shared
+	}
+	merged := maps.Clone(ctx.subqueries)
+	if merged == nil {
+		merged = make(map[*cInSubquery]valueSet, len(values))
+	}
+	maps.Copy(merged, values)
+	return &Context{
+		Ledger:     ctx.Ledger,
+		Config:     ctx.Config,
+		Macros:     ctx.Macros,
+		Budgets:    ctx.Budgets,
+		shared:     shared,
+		subqueries: merged,
+	}
 }
 
 // Row is the evaluation context for one data row. In the FROM (entry)
 // environment only Entry is set; in the posting environment Txn and Posting
 // identify the flattened posting row. Balance is the running per-account
 // inventory maintained by the executor. AggValues holds finalized aggregate
-// results while group targets are evaluated.
+// results while group targets are evaluated. Rows selected FROM a subquery
+// only have Values, one per column of its result; rows of the #accounts,
+// #commodities, and #budgets tables only have Account, Commodity, or Budget.
 type Row struct {
 	Ctx       *Context
 	Entry     ast.Directive
@@ -29,6 +71,10 @@ type Row struct {
 	Posting   *ast.Posting
 	Balance   *Inventory
 	AggValues []any
+	Values    []any
+	Account   *ledger.Account
+	Commodity *ledger.CommodityNode
+	Budget    *budget.Line
 	// CostDate is the effective cost-basis date for this posting: lot
 	// reductions inherit the matched lot's date (official booking behavior),
 	// everything else gets the transaction date.
@@ -129,6 +175,30 @@ var (
 	filterEnv  = &environment{columns: entryColumns, context: "filter context"}
 )
 
+// ColumnNames returns the sorted names of the posting and entry columns,
+// for completion in interactive shells.
+func ColumnNames() []string {
+	names := slices.Collect(maps.Keys(postingColumns))
+	for name := range entryColumns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// subqueryEnv is the environment of a statement selecting FROM a subquery,
+// with one column per column of the subquery's result. When names repeat,
+// the first column wins.
+func subqueryEnv(columns []ResultColumn) *environment {
+	defs := make(map[string]*columnDef, len(columns))
+	for i, column := range columns {
+		if _, ok := defs[column.Name]; !ok {
+			defs[column.Name] = &columnDef{column.Type, func(row *Row) any { return row.Values[i] }}
+		}
+	}
+	return &environment{columns: defs, context: "subquery context"}
+}
+
 // txnColumn wraps a transaction accessor into an entry-environment column
 // that yields NULL for non-transaction directives.
 func txnColumn(eval func(txn *ast.Transaction) any) func(row *Row) any {
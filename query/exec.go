@@ -2,7 +2,7 @@ package query
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"slices"
 	"strings"
 
@@ -30,29 +30,41 @@ func Execute(ctx context.Context, qctx *Context, tree *ast.AST, compiled *Compil
 	timer := telemetry.FromContext(ctx).Start("query.execute")
 	defer timer.End()
 
-	if len(compiled.PivotBy) > 0 {
-		// Official bean-query 2.x parses but rejects PIVOT BY; match its
-		// error message exactly, punctuation included.
-		return nil, errors.New("The PIVOT BY clause is not supported yet.") //nolint:staticcheck // official message parity
-	}
-
-	rows, err := generateRows(ctx, qctx, tree, compiled)
+	qctx, err := runSubqueries(ctx, qctx, tree, compiled.subqueries)
 	if err != nil {
 		return nil, err
 	}
 
 	var output [][]any
 	if compiled.HasAgg || len(compiled.GroupBy) > 0 {
+		rows, err := generateRows(ctx, qctx, tree, compiled)
+		if err != nil {
+			return nil, err
+		}
 		output = executeGrouped(rows, compiled)
 	} else {
-		output = make([][]any, 0, len(rows))
-		for _, row := range rows {
-			output = append(output, evalTargets(row, compiled))
+		// Ungrouped rows are projected as they stream in, so the scan can
+		// stop at LIMIT when nothing reorders the output.
+		limit := newLimitCounter(compiled)
+		err := scanRows(ctx, qctx, tree, compiled, func(row *Row) bool {
+			values := evalTargets(row, compiled)
+			output = append(output, values)
+			return limit.more(values)
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	output = orderRows(output, compiled)
-	output = projectVisible(output, compiled)
+
+	var columns []ResultColumn
+	if len(compiled.PivotBy) > 0 {
+		columns, output = pivotRows(output, compiled)
+	} else {
+		output = projectVisible(output, compiled)
+		columns = compiled.Columns()
+	}
 	if compiled.Distinct {
 		output = distinctRows(output)
 	}
@@ -60,27 +72,64 @@ func Execute(ctx context.Context, qctx *Context, tree *ast.AST, compiled *Compil
 		output = output[:*compiled.Limit]
 	}
 
-	result := &Result{Rows: output}
-	for _, target := range compiled.Targets {
-		if !target.Hidden {
-			result.Columns = append(result.Columns, ResultColumn{Name: target.Name, Type: target.Type})
+	return &Result{Columns: columns, Rows: output}, nil
+}
+
+// runSubqueries executes IN subqueries and returns a context holding their
+// values for one execution. They do not depend on the outer row, so each
+// runs once per execution.
+func runSubqueries(ctx context.Context, qctx *Context, tree *ast.AST, subqueries []*cInSubquery) (*Context, error) {
+	if len(subqueries) == 0 {
+		return qctx, nil
+	}
+	values := make(map[*cInSubquery]valueSet, len(subqueries))
+	for _, sub := range subqueries {
+		result, err := Execute(ctx, qctx, tree, sub.query)
+		if err != nil {
+			return nil, err
+		}
+		set := make(valueSet, len(result.Rows))
+		for _, row := range result.Rows {
+			if row[0] != nil {
+				set.add(row[0])
+			}
 		}
+		values[sub] = set
 	}
-	return result, nil
+	return qctx.withSubqueries(values), nil
 }
 
-// generateRows applies the FROM filter to the directive stream and flattens
-// the surviving transactions into posting rows. Per-account inventories are
-// tracked for booking-style lot-date inheritance; the balance column is a
-// single running inventory over the rows that survive WHERE, matching the
-// official executor.
+// generateRows collects the rows of scanRows.
 func generateRows(ctx context.Context, qctx *Context, tree *ast.AST, compiled *Compiled) ([]*Row, error) {
-	entries := []ast.Directive(tree.Directives)
-	if compiled.From != nil {
-		entries = applyFromTransforms(qctx, entries, compiled.From)
+	var rows []*Row
+	err := scanRows(ctx, qctx, tree, compiled, func(row *Row) bool {
+		rows = append(rows, row)
+		return true
+	})
+	return rows, err
+}
+
+// scanRows applies the FROM filter to the directive stream and flattens the
+// surviving transactions into posting rows, passing each row that survives
+// WHERE to emit until it returns false. Per-account inventories are tracked
+// for booking-style lot-date inheritance; the balance column is a single
+// running inventory over the rows that survive WHERE, matching the official
+// executor.
+func scanRows(ctx context.Context, qctx *Context, tree *ast.AST, compiled *Compiled, emit func(*Row) bool) error {
+	if compiled.From != nil && compiled.From.Subquery != nil {
+		rows, err := subqueryRows(ctx, qctx, tree, compiled)
+		if err != nil {
+			return err
+		}
+		emitRows(rows, emit)
+		return nil
+	}
+	if compiled.From != nil && compiled.From.table != nil {
+		emitRows(filterRows(compiled.From.table.rows(qctx, tree), compiled), emit)
+		return nil
 	}
 
-	var rows []*Row
+	entries := candidateEntries(qctx, tree, compiled)
 	accounts := make(map[string]*Inventory)
 	running := NewInventory()
 
@@ -88,7 +137,7 @@ func generateRows(ctx context.Context, qctx *Context, tree *ast.AST, compiled *C
 		if i%1024 == 0 {
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
+				return ctx.Err()
 			default:
 			}
 		}
@@ -137,10 +186,61 @@ func generateRows(ctx context.Context, qctx *Context, tree *ast.AST, compiled *C
 				}
 				row.Balance = running.Copy()
 			}
-			rows = append(rows, row)
+			if !emit(row) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// candidateEntries returns the directives to scan: the FROM-transformed
+// stream, or only the transactions the WHERE pushdown selects from the
+// account histories. Transformed streams hold synthetic entries the
+// histories do not know, so they are always scanned in full.
+func candidateEntries(qctx *Context, tree *ast.AST, compiled *Compiled) []ast.Directive {
+	if compiled.From.transforms() {
+		return applyFromTransforms(qctx, tree.Directives, compiled.From)
+	}
+	if compiled.pushdown != nil && qctx.Ledger != nil {
+		if entries, ok := compiled.pushdown.entries(qctx, tree); ok {
+			return entries
+		}
+	}
+	return tree.Directives
+}
+
+// emitRows passes rows to emit until it returns false.
+func emitRows(rows []*Row, emit func(*Row) bool) {
+	for _, row := range rows {
+		if !emit(row) {
+			return
 		}
 	}
-	return rows, nil
+}
+
+// subqueryRows executes the FROM subquery and turns its result rows into the
+// rows of the outer query, filtered by WHERE.
+func subqueryRows(ctx context.Context, qctx *Context, tree *ast.AST, compiled *Compiled) ([]*Row, error) {
+	result, err := Execute(ctx, qctx, tree, compiled.From.Subquery)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*Row, 0, len(result.Rows))
+	for _, values := range result.Rows {
+		rows = append(rows, &Row{Ctx: qctx, Values: values})
+	}
+	return filterRows(rows, compiled), nil
+}
+
+// filterRows keeps the rows passing the WHERE clause.
+func filterRows(rows []*Row, compiled *Compiled) []*Row {
+	if compiled.Where == nil {
+		return rows
+	}
+	return slices.DeleteFunc(rows, func(row *Row) bool {
+		return !truthy(compiled.Where.eval(row))
+	})
 }
 
 // evalTargets evaluates every target (visible and hidden) for a row.
@@ -159,7 +259,8 @@ type group struct {
 }
 
 // executeGrouped hash-aggregates rows by the GROUP-BY targets and evaluates
-// the full target list once per group, in first-seen order.
+// the full target list once per group, in first-seen order. Groups failing
+// the HAVING clause are dropped.
 func executeGrouped(rows []*Row, compiled *Compiled) [][]any {
 	groups := make(map[string]*group)
 	var order []string
@@ -193,22 +294,24 @@ func executeGrouped(rows []*Row, compiled *Compiled) [][]any {
 		for i, acc := range g.accs {
 			g.rep.AggValues[i] = acc.finalize()
 		}
+		if compiled.Having != nil && !truthy(compiled.Having.eval(g.rep)) {
+			continue
+		}
 		output = append(output, evalTargets(g.rep, compiled))
 	}
 	return output
 }
 
-// orderRows sorts rows by the ORDER-BY target values. The sort is stable so
-// ties keep their natural (ledger) order, and a single direction applies to
-// the whole key list, matching the official grammar.
+// orderRows sorts rows by the ORDER-BY target values, each in its own
+// direction. The sort is stable so ties keep their natural (ledger) order.
 func orderRows(output [][]any, compiled *Compiled) [][]any {
 	if len(compiled.OrderBy) == 0 {
 		return output
 	}
 	slices.SortStableFunc(output, func(a, b []any) int {
-		for _, idx := range compiled.OrderBy {
+		for i, idx := range compiled.OrderBy {
 			if cmp := compareValues(a[idx], b[idx]); cmp != 0 {
-				if compiled.OrderDesc {
+				if compiled.OrderDesc[i] {
 					return -cmp
 				}
 				return cmp
@@ -219,6 +322,75 @@ func orderRows(output [][]any, compiled *Compiled) [][]any {
 	return output
 }
 
+// pivotRows turns the distinct values of the second PIVOT BY target into
+// columns. Each output row holds a distinct value of the first pivot target,
+// in order of appearance, followed by one cell per value of the second and
+// remaining visible target, with the value columns sorted. Cells without a
+// row are NULL; when several rows fall on one cell, the first is kept.
+func pivotRows(output [][]any, compiled *Compiled) ([]ResultColumn, [][]any) {
+	rowIdx, colIdx := compiled.PivotBy[0], compiled.PivotBy[1]
+
+	var values []int
+	for i, target := range compiled.Targets {
+		if !target.Hidden && i != rowIdx && i != colIdx {
+			values = append(values, i)
+		}
+	}
+
+	var keys []any
+	seenKeys := make(map[string]bool)
+	for _, row := range output {
+		if k := valueString(row[colIdx]); !seenKeys[k] {
+			seenKeys[k] = true
+			keys = append(keys, row[colIdx])
+		}
+	}
+	slices.SortStableFunc(keys, compareValues)
+	keyIndex := make(map[string]int, len(keys))
+	for i, key := range keys {
+		keyIndex[valueString(key)] = i
+	}
+
+	rowTarget := compiled.Targets[rowIdx]
+	columns := []ResultColumn{{Name: rowTarget.Name, Type: rowTarget.Type}}
+	for _, key := range keys {
+		name := valueString(key)
+		if key == nil {
+			name = "None"
+		}
+		for _, idx := range values {
+			column := ResultColumn{Name: name, Type: compiled.Targets[idx].Type}
+			if len(values) > 1 {
+				column.Name = fmt.Sprintf("%s (%s)", compiled.Targets[idx].Name, name)
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	var pivoted [][]any
+	rowsByKey := make(map[string][]any)
+	filled := make(map[string]bool)
+	for _, row := range output {
+		rk := valueString(row[rowIdx])
+		out, ok := rowsByKey[rk]
+		if !ok {
+			out = make([]any, len(columns))
+			out[0] = row[rowIdx]
+			rowsByKey[rk] = out
+			pivoted = append(pivoted, out)
+		}
+
+		ck := keyIndex[valueString(row[colIdx])]
+		if cell := rk + "\x00" + valueString(row[colIdx]); !filled[cell] {
+			filled[cell] = true
+			for j, idx := range values {
+				out[1+ck*len(values)+j] = row[idx]
+			}
+		}
+	}
+	return columns, pivoted
+}
+
 // projectVisible strips hidden (group/order key) columns from the output.
 func projectVisible(output [][]any, compiled *Compiled) [][]any {
 	visible := make([]int, 0, len(compiled.Targets))
@@ -241,6 +413,50 @@ func projectVisible(output [][]any, compiled *Compiled) [][]any {
 	return projected
 }
 
+// limitCounter tells a streaming scan when it has produced enough rows to
+// fill LIMIT. Only unordered, unpivoted output can stop early; with
+// DISTINCT, only new distinct rows count.
+type limitCounter struct {
+	compiled *Compiled
+	limit    int64
+	count    int64
+	seen     map[string]bool
+}
+
+func newLimitCounter(compiled *Compiled) *limitCounter {
+	if compiled.Limit == nil || len(compiled.OrderBy) > 0 || len(compiled.PivotBy) > 0 {
+		return nil
+	}
+	l := &limitCounter{compiled: compiled, limit: *compiled.Limit}
+	if compiled.Distinct {
+		l.seen = make(map[string]bool)
+	}
+	return l
+}
+
+// more counts a row of target values and reports whether the scan should
+// continue.
+func (l *limitCounter) more(values []any) bool {
+	if l == nil {
+		return true
+	}
+	if l.seen != nil {
+		var key strings.Builder
+		for i, target := range l.compiled.Targets {
+			if !target.Hidden {
+				key.WriteString(valueString(values[i]))
+				key.WriteByte('\x00')
+			}
+		}
+		if l.seen[key.String()] {
+			return true
+		}
+		l.seen[key.String()] = true
+	}
+	l.count++
+	return l.count < l.limit
+}
+
 // distinctRows removes duplicate rows, keeping first occurrences.
 func distinctRows(output [][]any) [][]any {
 	seen := make(map[string]bool, len(output))
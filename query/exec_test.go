@@ -2,6 +2,8 @@ package query
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -17,9 +19,16 @@ func runQuery(t *testing.T, query string) *Result {
 	return runQueryOn(t, ctx, tree, query)
 }
 
-func runQueryOn(t *testing.T, ctx *Context, tree *ast.AST, query string) *Result {
+// runV3Query is runQuery with the beanquery v3 syntax enabled.
+func runV3Query(t *testing.T, query string) *Result {
 	t.Helper()
-	stmt, err := bql.Parse(query)
+	ctx, tree := newTestContext(t)
+	return runQueryOn(t, ctx, tree, query, bql.WithV3Syntax())
+}
+
+func runQueryOn(t *testing.T, ctx *Context, tree *ast.AST, query string, opts ...bql.Option) *Result {
+	t.Helper()
+	stmt, err := bql.Parse(query, opts...)
 	assert.NoError(t, err)
 	compiled, err := Compile(ctx, stmt)
 	assert.NoError(t, err)
@@ -115,6 +124,97 @@ func TestExecuteOrderByHiddenColumnIsStripped(t *testing.T) {
 	assert.Equal(t, "Assets:Invest", result.Rows[0][0].(string))
 }
 
+func TestExecuteOrderByPerTermDirections(t *testing.T) {
+	result := runV3Query(t, "SELECT account, date ORDER BY account ASC, date DESC")
+
+	assert.Equal(t, 8, len(result.Rows))
+	assert.Equal(t, "Assets:Checking", result.Rows[0][0].(string))
+	assert.Equal(t, "2014-04-01", valueString(result.Rows[0][1]))
+	assert.Equal(t, "2014-01-02", valueString(result.Rows[3][1]))
+	assert.Equal(t, "Assets:Invest", result.Rows[4][0].(string))
+}
+
+func TestExecuteHaving(t *testing.T) {
+	result := runV3Query(t, "SELECT account, count(date) AS n GROUP BY account HAVING count(date) > 1")
+
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, "Assets:Checking", result.Rows[0][0].(string))
+	assert.Equal(t, int64(4), result.Rows[0][1].(int64))
+}
+
+func TestExecuteHavingAggregateNotSelected(t *testing.T) {
+	result := runV3Query(t, "SELECT account GROUP BY account HAVING sum(number) < 0 ORDER BY account")
+
+	assert.Equal(t, 1, len(result.Columns))
+	assert.Equal(t, 3, len(result.Rows))
+	assert.Equal(t, "Assets:Checking", result.Rows[0][0].(string))
+	assert.Equal(t, "Equity:Opening-Balances", result.Rows[1][0].(string))
+	assert.Equal(t, "Income:Salary", result.Rows[2][0].(string))
+}
+
+func TestExecuteFromSubquery(t *testing.T) {
+	result := runV3Query(t, "SELECT account, total FROM (SELECT account, sum(number) AS total GROUP BY account) WHERE total < 0 ORDER BY total")
+
+	assert.Equal(t, 3, len(result.Rows))
+	assert.Equal(t, "Income:Salary", result.Rows[0][0].(string))
+	assert.Equal(t, "-2500", valueString(result.Rows[0][1]))
+	assert.Equal(t, "Assets:Checking", result.Rows[1][0].(string))
+	assert.Equal(t, "Equity:Opening-Balances", result.Rows[2][0].(string))
+}
+
+func TestExecuteFromSubqueryAggregates(t *testing.T) {
+	result := runV3Query(t, "SELECT count(account), max(n) FROM (SELECT account, count(date) AS n GROUP BY account)")
+
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, int64(5), result.Rows[0][0].(int64))
+	assert.Equal(t, int64(4), result.Rows[0][1].(int64))
+}
+
+func TestExecuteInSubquery(t *testing.T) {
+	result := runV3Query(t, "SELECT date, narration WHERE account IN (SELECT account WHERE account ~ '^Expenses') ORDER BY date")
+
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, "Coffee", result.Rows[0][1].(string))
+
+	result = runV3Query(t, "SELECT DISTINCT account WHERE NOT account IN (SELECT account WHERE number > 0) ORDER BY account")
+	assert.Equal(t, 2, len(result.Rows))
+	assert.Equal(t, "Equity:Opening-Balances", result.Rows[0][0].(string))
+	assert.Equal(t, "Income:Salary", result.Rows[1][0].(string))
+}
+
+func TestExecuteInSubqueryReusesCompiled(t *testing.T) {
+	ctx, tree := newTestContext(t)
+	stmt, err := bql.Parse("SELECT narration WHERE account IN (SELECT account WHERE account ~ '^Expenses')", bql.WithV3Syntax())
+	assert.NoError(t, err)
+	compiled, err := Compile(ctx, stmt)
+	assert.NoError(t, err)
+
+	// Subquery values belong to one execution, so a compiled query can be
+	// run again and from several goroutines at once.
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := Execute(context.Background(), ctx, tree, compiled)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(result.Rows))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestExecuteInSubqueryMatchesByType(t *testing.T) {
+	// Integers and decimals compare as numbers.
+	result := runV3Query(t, "SELECT DISTINCT account WHERE number IN (SELECT 10)")
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, "Assets:Invest", result.Rows[0][0].(string))
+
+	// A string never matches a number with the same text.
+	result = runV3Query(t, "SELECT date WHERE str(year) IN (SELECT year)")
+	assert.Equal(t, 0, len(result.Rows))
+}
+
 func TestExecuteDistinct(t *testing.T) {
 	result := runQuery(t, "SELECT DISTINCT currency")
 	assert.Equal(t, 2, len(result.Rows))
@@ -169,6 +269,42 @@ func TestExecuteMetadata(t *testing.T) {
 	assert.Equal(t, "posting-level", result.Rows[0][0].(string))
 }
 
+func TestExecuteDictMetadata(t *testing.T) {
+	ctx, tree := newContextFromSource(t, `
+2014-01-01 commodity HOOL
+  name: "Hooli's Inc."
+  asset-class: "stock"
+  since: 2004-08-19
+  precision: 2
+
+2014-01-01 open Assets:Checking USD
+  institution: "Bank"
+2014-01-01 open Equity:Opening-Balances
+
+2014-01-02 * "Opening"
+  Assets:Checking  1000.00 USD
+  Equity:Opening-Balances
+`)
+
+	result := runQueryOn(t, ctx, tree, "SELECT account, getitem(open_meta(account), 'institution') AS institution, "+
+		"getitem(commodity_meta('HOOL'), 'asset-class') AS class, currency_meta(currency) AS usd")
+	assert.Equal(t, TDict, result.Columns[3].Type)
+	assert.Equal(t, []any{"Assets:Checking", "Bank", "stock", nil}, result.Rows[0])
+	assert.Equal(t, []any{"Equity:Opening-Balances", nil, "stock", nil}, result.Rows[1])
+
+	result = runQueryOn(t, ctx, tree, "SELECT commodity_meta('HOOL') AS meta, open_meta('Equity:Opening-Balances') AS empty LIMIT 1")
+	assert.Equal(t, `{'name': "Hooli's Inc.", 'asset-class': 'stock', 'since': datetime.date(2004, 8, 19), 'precision': Decimal('2')}`,
+		valueString(result.Rows[0][0]))
+	assert.Equal(t, "{}", valueString(result.Rows[0][1]))
+
+	var b strings.Builder
+	assert.NoError(t, RenderText(runQueryOn(t, ctx, tree, "SELECT open_meta(account) AS meta, account WHERE account ~ 'Checking'"), &b))
+	assert.Equal(t, ""+
+		"         meta               account    \n"+
+		"----------------------- ---------------\n"+
+		"{'institution': 'Bank'} Assets:Checking\n", b.String())
+}
+
 func TestExecuteOpenOnSummarizes(t *testing.T) {
 	// OPEN ON replaces earlier transactions with S-flagged opening entries
 	// at the day before the open date.
@@ -191,3 +327,71 @@ func TestExecuteCancellation(t *testing.T) {
 	_, err = Execute(cancelled, qctx, tree, compiled)
 	assert.Error(t, err)
 }
+
+func TestExecutePivotBy(t *testing.T) {
+	result := runQuery(t, "SELECT account, month, sum(number) AS total WHERE account ~ '^Assets' GROUP BY account, month PIVOT BY account, month")
+
+	names := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		names[i] = col.Name
+	}
+	assert.Equal(t, []string{"account", "1", "2", "3", "4"}, names)
+	assert.Equal(t, TDecimal, result.Columns[1].Type)
+
+	assert.Equal(t, 2, len(result.Rows))
+	assert.Equal(t, any("Assets:Checking"), result.Rows[0][0])
+	assert.Equal(t, "1000", valueString(result.Rows[0][1]))
+	assert.Equal(t, "-5000", valueString(result.Rows[0][4]))
+	assert.Equal(t, any("Assets:Invest"), result.Rows[1][0])
+	assert.Zero(t, result.Rows[1][1])
+	assert.Equal(t, "10", valueString(result.Rows[1][4]))
+}
+
+func TestExecutePivotByNamesColumnsPerValue(t *testing.T) {
+	result := runQuery(t, "SELECT year, account, sum(number) AS total, count(date) AS n WHERE account ~ '^Assets' GROUP BY 1, 2 PIVOT BY year, account")
+
+	names := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		names[i] = col.Name
+	}
+	assert.Equal(t, []string{"year", "total (Assets:Checking)", "n (Assets:Checking)", "total (Assets:Invest)", "n (Assets:Invest)"}, names)
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, any(int64(4)), result.Rows[0][2])
+}
+
+func TestExecutePivotByRenders(t *testing.T) {
+	ctx, tree := newTestContext(t)
+	result := runQueryOn(t, ctx, tree, "SELECT account, year, sum(position) WHERE account ~ '^Expenses|^Income' GROUP BY 1, 2 PIVOT BY 1, 2")
+
+	var text, csv strings.Builder
+	assert.NoError(t, RenderText(result, &text))
+	assert.NoError(t, RenderCSV(result, &csv, false))
+	assert.Equal(t, ""+
+		"   account        2014    \n"+
+		"------------- ------------\n"+
+		"Income:Salary -2500.00 USD\n"+
+		"Expenses:Food     4.50 USD\n", text.String())
+	assert.Equal(t, ""+
+		"account,2014\r\n"+
+		"Income:Salary,-2500.00 USD\r\n"+
+		"Expenses:Food,    4.50 USD\r\n", csv.String())
+}
+
+func TestCompilePivotByErrors(t *testing.T) {
+	ctx, _ := newTestContext(t)
+
+	err := compileError(t, ctx, "SELECT account, year, sum(number) GROUP BY 1, 2 PIVOT BY account")
+	assert.Equal(t, "PIVOT BY requires exactly two columns.", err.Error())
+
+	err = compileError(t, ctx, "SELECT account, sum(number) GROUP BY 1 PIVOT BY account, 2")
+	assert.Equal(t, "PIVOT BY expressions may not be aggregates.", err.Error())
+
+	err = compileError(t, ctx, "SELECT account, year, sum(number) GROUP BY 1, 2 PIVOT BY 1, account")
+	assert.Equal(t, "PIVOT BY columns must be distinct.", err.Error())
+
+	err = compileError(t, ctx, "SELECT account, year PIVOT BY account, year")
+	assert.Equal(t, "PIVOT BY requires a selected column besides the two pivot columns.", err.Error())
+
+	err = compileError(t, ctx, "SELECT account PIVOT BY account, year")
+	assert.Equal(t, "PIVOT BY requires a selected column besides the two pivot columns.", err.Error())
+}
@@ -0,0 +1,189 @@
+package query
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+// Explain writes the plan of a compiled query: its targets with their types,
+// the environments expressions resolve in, the FROM filter and summarization,
+// and the grouping, ordering, and pivot keys. Subqueries are explained
+// nested under the clause they appear in.
+func Explain(compiled *Compiled, out io.Writer) error {
+	var b strings.Builder
+	explain(&b, compiled, "")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+func explain(b *strings.Builder, c *Compiled, indent string) {
+	line := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(b, indent+format+"\n", args...)
+	}
+
+	line("SELECT")
+	line("  environment: %s", c.env.context)
+
+	line("  targets:")
+	nameWidth, typeWidth, exprWidth := 0, 0, 0
+	for _, target := range c.Targets {
+		nameWidth = max(nameWidth, len(target.Name))
+		typeWidth = max(typeWidth, len(target.Type.String()))
+		exprWidth = max(exprWidth, len(formatExpr(target.source)))
+	}
+	for _, target := range c.Targets {
+		var flags []string
+		if target.IsAgg {
+			flags = append(flags, "aggregate")
+		}
+		if target.Hidden {
+			flags = append(flags, "hidden")
+		}
+		text := fmt.Sprintf("%-*s  %-*s  %-*s", nameWidth, target.Name, typeWidth, target.Type, exprWidth, formatExpr(target.source))
+		if len(flags) > 0 {
+			text += "  (" + strings.Join(flags, ", ") + ")"
+		}
+		line("    %s", strings.TrimRight(text, " "))
+	}
+
+	if from := c.From; from != nil {
+		switch {
+		case from.table != nil:
+			line("  from: #%s", from.Table)
+		case from.Subquery != nil:
+			line("  from:")
+			explain(b, from.Subquery, indent+"    ")
+		default:
+			if c.stmt.From.Expr != nil {
+				line("  from: %s (filter context)", formatExpr(c.stmt.From.Expr))
+			}
+			if from.OpenOn != nil {
+				line("  open on: %s", from.OpenOn)
+			}
+			if from.CloseOn != nil {
+				line("  close on: %s", from.CloseOn)
+			} else if from.Close {
+				line("  close")
+			}
+			if from.Clear {
+				line("  clear")
+			}
+		}
+	}
+
+	if c.stmt.Where != nil {
+		line("  where: %s", formatExpr(c.stmt.Where))
+	}
+	if p := c.pushdown; p != nil {
+		var terms []string
+		if p.account != nil {
+			terms = append(terms, "account")
+		}
+		if p.start != nil {
+			terms = append(terms, "date >= "+p.start.String())
+		}
+		if p.end != nil {
+			terms = append(terms, "date < "+p.end.String())
+		}
+		line("  pushdown: %s", strings.Join(terms, ", "))
+	}
+	if len(c.GroupBy) > 0 {
+		keys := targetNames(c, c.GroupBy)
+		if len(c.stmt.GroupBy) == 0 {
+			line("  group by: %s (implicit)", keys)
+		} else {
+			line("  group by: %s", keys)
+		}
+	}
+	if c.HasAgg {
+		line("  aggregates: %d", len(c.Aggs))
+	}
+	if c.stmt.Having != nil {
+		line("  having: %s", formatExpr(c.stmt.Having))
+	}
+	if len(c.OrderBy) > 0 {
+		terms := make([]string, len(c.OrderBy))
+		for i, idx := range c.OrderBy {
+			terms[i] = c.Targets[idx].Name
+			if c.OrderDesc[i] {
+				terms[i] += " DESC"
+			}
+		}
+		line("  order by: %s", strings.Join(terms, ", "))
+	}
+	if len(c.PivotBy) > 0 {
+		line("  pivot by: %s", targetNames(c, c.PivotBy))
+	}
+	if c.Distinct {
+		line("  distinct")
+	}
+	if c.Limit != nil {
+		line("  limit: %d", *c.Limit)
+	}
+
+	for _, sub := range c.subqueries {
+		line("  in subquery:")
+		explain(b, sub.query, indent+"    ")
+	}
+}
+
+// targetNames joins the names of the targets at indices.
+func targetNames(c *Compiled, indices []int) string {
+	names := make([]string, len(indices))
+	for i, idx := range indices {
+		names[i] = c.Targets[idx].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// formatExpr renders an expression back to BQL. Nested operations are
+// parenthesized so the grouping is explicit.
+func formatExpr(e bql.Expr) string {
+	switch node := e.(type) {
+	case *bql.Ident:
+		return node.Name
+	case *bql.Call:
+		args := make([]string, len(node.Args))
+		for i, arg := range node.Args {
+			args[i] = formatExpr(arg)
+		}
+		return node.Func + "(" + strings.Join(args, ", ") + ")"
+	case *bql.Str:
+		return "'" + node.Value + "'"
+	case *bql.Int:
+		return strconv.FormatInt(node.Value, 10)
+	case *bql.Dec:
+		return node.Value.String()
+	case *bql.DateLit:
+		return node.Value.String()
+	case *bql.Bool:
+		if node.Value {
+			return "TRUE"
+		}
+		return "FALSE"
+	case *bql.Null:
+		return "NULL"
+	case *bql.Unary:
+		if node.Op == bql.NOT {
+			return "NOT " + formatOperand(node.X)
+		}
+		return node.Op.String() + formatOperand(node.X)
+	case *bql.Binary:
+		return formatOperand(node.L) + " " + node.Op.String() + " " + formatOperand(node.R)
+	case *bql.Subquery:
+		return "(SELECT ...)"
+	}
+	return "?"
+}
+
+func formatOperand(e bql.Expr) string {
+	switch e.(type) {
+	case *bql.Unary, *bql.Binary:
+		return "(" + formatExpr(e) + ")"
+	}
+	return formatExpr(e)
+}
@@ -0,0 +1,78 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+func explainQuery(t *testing.T, query string, opts ...bql.Option) string {
+	t.Helper()
+	ctx, _ := newTestContext(t)
+	var out strings.Builder
+	assert.NoError(t, Explain(mustCompile(t, ctx, query, opts...), &out))
+	return out.String()
+}
+
+func TestExplain(t *testing.T) {
+	output := explainQuery(t, "SELECT account, sum(position) AS total FROM year = 2014 OPEN ON 2014-01-01 CLEAR "+
+		"WHERE account ~ 'Expenses' AND number > 10 ORDER BY total, date DESC LIMIT 5")
+
+	assert.Equal(t, `SELECT
+  environment: targets/column context
+  targets:
+    account  str        account
+    total    Inventory  sum(position)  (aggregate)
+    date     date       date           (hidden)
+  from: year = 2014 (filter context)
+  open on: 2014-01-01
+  clear
+  where: (account ~ 'Expenses') AND (number > 10)
+  group by: account (implicit)
+  aggregates: 1
+  order by: total DESC, date DESC
+  limit: 5
+`, output)
+}
+
+func TestExplainSubqueries(t *testing.T) {
+	output := explainQuery(t, "SELECT account, n FROM (SELECT account, count(date) AS n GROUP BY account) "+
+		"WHERE account IN (SELECT account WHERE number > 100)", bql.WithV3Syntax())
+
+	assert.Equal(t, `SELECT
+  environment: subquery context
+  targets:
+    account  str  account
+    n        int  n
+  from:
+    SELECT
+      environment: targets/column context
+      targets:
+        account  str  account
+        n        int  count(date)  (aggregate)
+      group by: account
+      aggregates: 1
+  where: account IN (SELECT ...)
+  in subquery:
+    SELECT
+      environment: targets/column context
+      targets:
+        account  str  account
+      where: number > 100
+`, output)
+}
+
+func TestExplainPushdown(t *testing.T) {
+	output := explainQuery(t, "SELECT date, position WHERE account ~ 'Assets' AND date >= 2014-02-01 AND date <= 2014-03-31")
+
+	assert.Equal(t, `SELECT
+  environment: targets/column context
+  targets:
+    date      date      date
+    position  Position  position
+  where: ((account ~ 'Assets') AND (date >= 2014-02-01)) AND (date <= 2014-03-31)
+  pushdown: account, date >= 2014-02-01, date < 2014-04-01
+`, output)
+}
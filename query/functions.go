@@ -2,7 +2,9 @@ package query
 
 import (
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -46,6 +48,17 @@ func (d *funcDef) matchOverload(argTypes []DType) *funcOverload {
 	return nil
 }
 
+// FunctionNames returns the sorted names of the simple and aggregate
+// functions, for completion in interactive shells.
+func FunctionNames() []string {
+	names := slices.Collect(maps.Keys(functions))
+	for name := range aggregates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
 // functions is the registry of simple functions, shared by the targets and
 // filter environments, matching the official bean-query environment.
 var functions = map[string]*funcDef{
@@ -482,10 +495,7 @@ var functions = map[string]*funcDef{
 	}},
 	"entry_meta": {overloads: []funcOverload{
 		{[]DType{TString}, TAny, func(row *Row, args []any) any {
-			if row.Txn == nil {
-				return nil
-			}
-			return metaLookup(row.Txn.Metadata, args[0].(string))
+			return metaLookup(entryMetadata(row), args[0].(string))
 		}},
 	}},
 	"any_meta": {overloads: []funcOverload{
@@ -502,6 +512,30 @@ var functions = map[string]*funcDef{
 			return nil
 		}},
 	}},
+	"open_meta": {overloads: []funcOverload{
+		{[]DType{TString}, TDict, func(row *Row, args []any) any {
+			if account, ok := row.Ctx.Ledger.GetAccount(args[0].(string)); ok {
+				return metaDict(account.Metadata)
+			}
+			return nil
+		}},
+	}},
+	"commodity_meta": {overloads: []funcOverload{
+		{[]DType{TString}, TDict, commodityMeta},
+	}},
+	"currency_meta": {overloads: []funcOverload{
+		{[]DType{TString}, TDict, commodityMeta},
+	}},
+	"getitem": {overloads: []funcOverload{
+		{[]DType{TDict, TString}, TAny, func(_ *Row, args []any) any {
+			dict, ok := args[0].(*Dict)
+			if !ok {
+				return nil
+			}
+			value, _ := dict.Get(args[1].(string))
+			return value
+		}},
+	}},
 }
 
 // positionCost returns a position's total cost as an amount, or its units
@@ -654,6 +688,20 @@ func accountInvertsSign(ctx *Context, account string) bool {
 	return false
 }
 
+// entryMetadata returns the metadata of the directive, account, or commodity
+// a row describes.
+func entryMetadata(row *Row) []*ast.Metadata {
+	switch {
+	case row.Account != nil:
+		return row.Account.Metadata
+	case row.Commodity != nil:
+		return row.Commodity.Metadata
+	case row.Entry != nil:
+		return row.Entry.GetMetadata()
+	}
+	return nil
+}
+
 // metaLookup finds a metadata key and converts its value to a query value.
 func metaLookup(metadata []*ast.Metadata, key string) any {
 	for _, md := range metadata {
@@ -664,6 +712,28 @@ func metaLookup(metadata []*ast.Metadata, key string) any {
 	return nil
 }
 
+// metaDict converts metadata to a dict of query values.
+func metaDict(metadata []*ast.Metadata) *Dict {
+	dict := NewDict()
+	for _, md := range metadata {
+		dict.Set(md.Key, metaValue(md.Value))
+	}
+	return dict
+}
+
+// commodityMeta returns the metadata of a commodity directive, or NULL when
+// the currency was not declared.
+func commodityMeta(row *Row, args []any) any {
+	node := row.Ctx.Ledger.Graph().GetNode(args[0].(string))
+	if node == nil {
+		return nil
+	}
+	if commodity, ok := node.Meta.(*ledger.CommodityNode); ok {
+		return metaDict(commodity.Metadata)
+	}
+	return nil
+}
+
 func metaValue(v *ast.MetadataValue) any {
 	if v == nil {
 		return nil
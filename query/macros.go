@@ -0,0 +1,200 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+// macroDirective is the custom directive type that declares a macro.
+const macroDirective = "bql-function"
+
+// Macro is a user-defined function or column declared in the ledger with
+//
+//	2014-01-01 custom "bql-function" "eur(pos Inventory)" "convert(pos, 'EUR', date)"
+//	2014-01-01 custom "bql-function" "eur_total" "eur(sum(position))"
+//
+// A signature without parentheses declares a column macro, used as a bare
+// name; otherwise the macro is called like a function. Parameters may be
+// followed by a type name, checked against the argument types the way
+// built-in overloads are. Macros expand at compile time, so their bodies
+// can use aggregates and other macros.
+type Macro struct {
+	Name   string
+	Column bool
+	Params []string
+	Body   bql.Expr
+	Pos    ast.Position
+
+	overload funcOverload // Parameter types, TAny when undeclared
+}
+
+var macroNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// MacrosFromAST collects the macros declared by the custom "bql-function"
+// directives of tree, keyed by lowercase name, and an error for each
+// invalid declaration, which is left out.
+func MacrosFromAST(tree *ast.AST) (map[string]*Macro, []error) {
+	macros := make(map[string]*Macro)
+	var errs []error
+	for _, directive := range tree.Directives {
+		custom, ok := directive.(*ast.Custom)
+		if !ok || custom.Type.Value != macroDirective {
+			continue
+		}
+		macro, err := parseMacro(custom)
+		if err == nil {
+			if prev, ok := macros[macro.Name]; ok {
+				err = fmt.Errorf("function '%s' is already declared at %s", macro.Name, prev.Pos)
+			}
+		}
+		if err != nil {
+			errs = append(errs, &CompileError{Pos: custom.Position(), Message: fmt.Sprintf("Invalid %s directive: %s.", macroDirective, err)})
+			continue
+		}
+		macros[macro.Name] = macro
+	}
+	return macros, errs
+}
+
+// parseMacro builds a macro from its directive's signature and body.
+func parseMacro(custom *ast.Custom) (*Macro, error) {
+	if len(custom.Values) != 2 || custom.Values[0].String == nil || custom.Values[1].String == nil {
+		return nil, fmt.Errorf("expected a signature and a body string")
+	}
+	macro := &Macro{Pos: custom.Position()}
+
+	signature := strings.TrimSpace(*custom.Values[0].String)
+	name, params, hasParams := strings.Cut(signature, "(")
+	macro.Name = strings.ToLower(strings.TrimSpace(name))
+	if !macroNameRe.MatchString(macro.Name) {
+		return nil, fmt.Errorf("invalid function name %q", strings.TrimSpace(name))
+	}
+	if _, ok := functions[macro.Name]; ok {
+		return nil, fmt.Errorf("'%s' is a built-in function", macro.Name)
+	}
+	if _, ok := aggregates[macro.Name]; ok {
+		return nil, fmt.Errorf("'%s' is a built-in function", macro.Name)
+	}
+	if slices.Contains(ColumnNames(), macro.Name) {
+		return nil, fmt.Errorf("'%s' is a built-in column", macro.Name)
+	}
+
+	macro.Column = !hasParams
+	if hasParams {
+		params, ok := strings.CutSuffix(strings.TrimSpace(params), ")")
+		if !ok {
+			return nil, fmt.Errorf("missing ')' in signature %q", signature)
+		}
+		if err := macro.parseParams(params); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := bql.ParseExpr(*custom.Values[1].String)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	macro.Body = body
+	return macro, nil
+}
+
+// parseParams parses the comma-separated parameters of a signature, each a
+// name optionally followed by a type name.
+func (m *Macro) parseParams(params string) error {
+	if strings.TrimSpace(params) == "" {
+		return nil
+	}
+	for param := range strings.SplitSeq(params, ",") {
+		fields := strings.Fields(param)
+		if len(fields) == 0 || len(fields) > 2 || !macroNameRe.MatchString(fields[0]) {
+			return fmt.Errorf("invalid parameter %q", strings.TrimSpace(param))
+		}
+		if slices.Contains(m.Params, fields[0]) {
+			return fmt.Errorf("duplicate parameter '%s'", fields[0])
+		}
+		typ := TAny
+		if len(fields) == 2 {
+			var ok bool
+			if typ, ok = parseDType(fields[1]); !ok {
+				return fmt.Errorf("unknown type '%s' for parameter '%s'", fields[1], fields[0])
+			}
+		}
+		m.Params = append(m.Params, fields[0])
+		m.overload.params = append(m.overload.params, typ)
+	}
+	return nil
+}
+
+// parseDType looks up a type by its name, ignoring case.
+func parseDType(name string) (DType, bool) {
+	for t, typeName := range dtypeNames {
+		if strings.EqualFold(typeName, name) {
+			return t, true
+		}
+	}
+	return TAny, false
+}
+
+// macroArg is a compiled macro argument bound to a parameter name.
+type macroArg struct {
+	expr   cexpr
+	hasAgg bool
+}
+
+// expandMacro compiles a macro use in place of the call or column node.
+// Arguments compile in the caller's scope, the body in a scope holding only
+// the parameters.
+func (c *compiler) expandMacro(node bql.Expr, macro *Macro, argNodes []bql.Expr, allowAgg bool) (cexpr, error) {
+	if slices.Contains(c.expanding, macro.Name) {
+		return nil, compileErrorf(node, "Function '%s' is recursive.", macro.Name)
+	}
+	if len(argNodes) != len(macro.Params) {
+		return nil, compileErrorf(node, "Function '%s' takes %d argument(s), got %d.", macro.Name, len(macro.Params), len(argNodes))
+	}
+
+	scope := make(map[string]macroArg, len(argNodes))
+	argTypes := make([]DType, len(argNodes))
+	for i, argNode := range argNodes {
+		before := len(c.aggs)
+		arg, err := c.compileExpr(argNode, allowAgg)
+		if err != nil {
+			return nil, err
+		}
+		scope[macro.Params[i]] = macroArg{expr: arg, hasAgg: len(c.aggs) > before}
+		argTypes[i] = arg.typ()
+	}
+	def := funcDef{overloads: []funcOverload{macro.overload}}
+	if def.matchOverload(argTypes) == nil {
+		return nil, compileErrorf(node, "Invalid function '%s(%s)' in %s.", macro.Name, argTypeList(argTypes, nil), c.env.context)
+	}
+
+	outer := c.scope
+	c.scope = scope
+	c.expanding = append(c.expanding, macro.Name)
+	expr, err := c.compileExpr(macro.Body, allowAgg)
+	c.expanding = c.expanding[:len(c.expanding)-1]
+	c.scope = outer
+	if err != nil {
+		var compileErr *CompileError
+		if errors.As(err, &compileErr) {
+			return nil, compileErrorf(node, "In function '%s' declared at %s: %s", macro.Name, macro.Pos, compileErr.Message)
+		}
+		return nil, err
+	}
+	return expr, nil
+}
+
+// macro looks up a user-defined function or column by name.
+func (ctx *Context) macro(name string) (*Macro, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	macro, ok := ctx.Macros[strings.ToLower(name)]
+	return macro, ok
+}
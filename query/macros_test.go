@@ -0,0 +1,98 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/parser"
+)
+
+const macroLedger = testLedger + `
+2014-01-01 custom "bql-function" "usd(pos Inventory)" "convert(pos, 'USD')"
+2014-01-01 custom "bql-function" "usd_total" "usd(sum(position))"
+2014-01-01 custom "bql-function" "scaled(x Decimal, factor)" "x * factor"
+2014-01-01 custom "bql-function" "ping()" "pong()"
+2014-01-01 custom "bql-function" "pong()" "ping()"
+2014-01-01 custom "bql-function" "held(pos)" "units(pos)"
+`
+
+func newMacroContext(t *testing.T) (*Context, *ast.AST) {
+	t.Helper()
+	ctx, tree := newContextFromSource(t, macroLedger)
+	macros, errs := MacrosFromAST(tree)
+	assert.Zero(t, errs)
+	ctx.Macros = macros
+	return ctx, tree
+}
+
+func TestMacroExpansion(t *testing.T) {
+	ctx, tree := newMacroContext(t)
+
+	compiled := mustCompile(t, ctx, "SELECT account, USD_TOTAL WHERE account ~ 'Assets' GROUP BY account")
+	assert.Equal(t, "usd_total", compiled.Targets[1].Name)
+	assert.Equal(t, TInventory, compiled.Targets[1].Type)
+	assert.True(t, compiled.Targets[1].IsAgg)
+
+	result := runQueryOn(t, ctx, tree, "SELECT account, usd_total WHERE account ~ 'Assets' GROUP BY account ORDER BY account")
+	assert.Equal(t, [][]string{
+		{"Assets:Checking", "-1504.5 USD"},
+		{"Assets:Invest", "5200 USD"},
+	}, resultStrings(result))
+
+	result = runQueryOn(t, ctx, tree, "SELECT scaled(number, 2) AS doubled WHERE account = 'Expenses:Food'")
+	assert.Equal(t, TDecimal, result.Columns[0].Type)
+	assert.Equal(t, [][]string{{"9"}}, resultStrings(result))
+}
+
+func TestMacroCompileErrors(t *testing.T) {
+	ctx, _ := newMacroContext(t)
+
+	for _, tt := range []struct {
+		query string
+		err   string
+	}{
+		{"SELECT usd(position, 'EUR')", "Function 'usd' takes 1 argument(s), got 2."},
+		{"SELECT usd(account)", "Invalid function 'usd(str)' in targets/column context."},
+		{"SELECT usd", "Function 'usd' must be called with arguments."},
+		{"SELECT usd_total()", "Column 'usd_total' must be used without parentheses."},
+		{"SELECT ping()", "In function 'ping' declared at test.beancount:30:12: In function 'pong' declared at test.beancount:31:12: Function 'ping' is recursive."},
+		{"SELECT held(account)", "In function 'held' declared at test.beancount:32:12: Invalid function 'units(str)' in targets/column context."},
+		{"SELECT date WHERE usd_total > 0", "In function 'usd_total' declared at test.beancount:28:12: Aggregates are disallowed in this context."},
+	} {
+		err := compileError(t, ctx, tt.query)
+		assert.Equal(t, tt.err, err.Error(), tt.query)
+	}
+}
+
+func TestMacrosFromASTErrors(t *testing.T) {
+	source := `
+2014-01-01 custom "bql-function" "sum(x)" "x"
+2014-01-01 custom "bql-function" "account" "payee"
+2014-01-01 custom "bql-function" "twice(x, x)" "x + x"
+2014-01-01 custom "bql-function" "typed(x Money)" "x"
+2014-01-01 custom "bql-function" "broken(x" "x"
+2014-01-01 custom "bql-function" "body" "sum(position"
+2014-01-01 custom "bql-function" "single"
+2014-01-01 custom "bql-function" "dup" "1"
+2014-01-01 custom "bql-function" "DUP" "2"
+2014-01-01 custom "other" "ignored"
+`
+	tree, err := parser.ParseBytesWithFilename(context.Background(), "macros.beancount", []byte(source))
+	assert.NoError(t, err)
+
+	macros, errs := MacrosFromAST(tree)
+	assert.Equal(t, []string{"dup"}, slices.Collect(maps.Keys(macros)))
+	assert.EqualError(t, errors.Join(errs...), `Invalid bql-function directive: 'sum' is a built-in function.
+Invalid bql-function directive: 'account' is a built-in column.
+Invalid bql-function directive: duplicate parameter 'x'.
+Invalid bql-function directive: unknown type 'Money' for parameter 'x'.
+Invalid bql-function directive: missing ')' in signature "broken(x".
+Invalid bql-function directive: body: <query>:1:13: expected ) in function call, found end of query.
+Invalid bql-function directive: expected a signature and a body string.
+Invalid bql-function directive: function 'dup' is already declared at macros.beancount:9:12.`)
+}
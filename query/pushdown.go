@@ -0,0 +1,237 @@
+package query
+
+import (
+	"slices"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+// pushdown is the part of a WHERE clause the executor can answer from the
+// ledger's per-account posting histories instead of scanning every entry:
+// a predicate over the account name alone, and a date range [start, end).
+// It only narrows the candidate transactions; WHERE is still evaluated on
+// every row they produce.
+type pushdown struct {
+	account    cexpr
+	start, end *ast.Date
+}
+
+// planPushdown extracts the pushdown of a posting-environment WHERE clause
+// from its top-level AND terms, or returns nil when no term qualifies.
+func planPushdown(where cexpr) *pushdown {
+	if where == nil {
+		return nil
+	}
+	var p pushdown
+	for _, term := range conjuncts(where) {
+		if accountOnly(term) && referencesAccount(term) {
+			if p.account == nil {
+				p.account = term
+			} else {
+				p.account = &cBinary{op: bql.AND, l: p.account, r: term, t: TBool}
+			}
+			continue
+		}
+		p.addDateBound(term)
+	}
+	if p.account == nil && p.start == nil && p.end == nil {
+		return nil
+	}
+	return &p
+}
+
+func conjuncts(e cexpr) []cexpr {
+	if b, ok := e.(*cBinary); ok && b.op == bql.AND {
+		return append(conjuncts(b.l), conjuncts(b.r)...)
+	}
+	return []cexpr{e}
+}
+
+// accountOnly reports whether e depends on nothing but the account column,
+// so it can be evaluated once per ledger account.
+func accountOnly(e cexpr) bool {
+	switch node := e.(type) {
+	case *cLiteral:
+		return true
+	case *cColumn:
+		return node.def == postingColumns["account"]
+	case *cNot:
+		return accountOnly(node.x)
+	case *cInSubquery:
+		return accountOnly(node.x)
+	case *cBinary:
+		switch node.op {
+		case bql.EQ, bql.NE, bql.TILDE, bql.IN, bql.AND, bql.OR:
+			return accountOnly(node.l) && accountOnly(node.r)
+		}
+	}
+	return false
+}
+
+func referencesAccount(e cexpr) bool {
+	switch node := e.(type) {
+	case *cColumn:
+		return true
+	case *cNot:
+		return referencesAccount(node.x)
+	case *cInSubquery:
+		return referencesAccount(node.x)
+	case *cBinary:
+		return referencesAccount(node.l) || referencesAccount(node.r)
+	}
+	return false
+}
+
+// addDateBound narrows the date range by a comparison between the date
+// column and a date literal. Other terms are left to WHERE.
+func (p *pushdown) addDateBound(term cexpr) {
+	b, ok := term.(*cBinary)
+	if !ok {
+		return
+	}
+	op := b.op
+	column, literal := b.l, b.r
+	if _, ok := column.(*cLiteral); ok {
+		column, literal = literal, column
+		switch op {
+		case bql.LT:
+			op = bql.GT
+		case bql.LTE:
+			op = bql.GTE
+		case bql.GT:
+			op = bql.LT
+		case bql.GTE:
+			op = bql.LTE
+		}
+	}
+	if c, ok := column.(*cColumn); !ok || c.def != postingColumns["date"] {
+		return
+	}
+	l, ok := literal.(*cLiteral)
+	if !ok {
+		return
+	}
+	date, ok := l.v.(*ast.Date)
+	if !ok || date == nil {
+		return
+	}
+
+	next := ast.NewDateFromTime(date.AddDate(0, 0, 1))
+	switch op {
+	case bql.EQ:
+		p.raiseStart(date)
+		p.lowerEnd(next)
+	case bql.GT:
+		p.raiseStart(next)
+	case bql.GTE:
+		p.raiseStart(date)
+	case bql.LT:
+		p.lowerEnd(date)
+	case bql.LTE:
+		p.lowerEnd(next)
+	}
+}
+
+func (p *pushdown) raiseStart(date *ast.Date) {
+	if p.start == nil || p.start.Before(date.Time) {
+		p.start = date
+	}
+}
+
+func (p *pushdown) lowerEnd(date *ast.Date) {
+	if p.end == nil || date.Before(p.end.Time) {
+		p.end = date
+	}
+}
+
+// entries returns the directives that can produce rows passing the
+// pushdown, in ledger order, or false when the ledger's histories do not
+// describe tree. Postings at cost before the range are kept: earlier lots
+// decide the dates reductions inherit.
+func (p *pushdown) entries(qctx *Context, tree *ast.AST) ([]ast.Directive, bool) {
+	index := qctx.entryIndex(tree)
+	if index == nil {
+		return nil, false
+	}
+
+	positions := slices.Clone(index.unindexed)
+	for name, account := range qctx.Ledger.Accounts() {
+		if p.account != nil {
+			row := &Row{Ctx: qctx, Posting: &ast.Posting{Account: ast.Account(name)}}
+			if !truthy(p.account.eval(row)) {
+				continue
+			}
+		}
+		start, end := 0, len(account.Postings)
+		if p.start != nil {
+			start = account.SearchPostings(p.start)
+		}
+		if p.end != nil {
+			end = account.SearchPostings(p.end)
+		}
+		for i, posting := range account.Postings[:end] {
+			if i >= start || posting.Posting.Cost != nil {
+				positions = append(positions, index.positions[posting.Transaction])
+			}
+		}
+	}
+	slices.Sort(positions)
+	positions = slices.Compact(positions)
+
+	entries := make([]ast.Directive, len(positions))
+	for i, pos := range positions {
+		entries[i] = tree.Directives[pos]
+	}
+	return entries, true
+}
+
+// entryIndex maps the transactions in a tree to their positions, for
+// turning account histories back into directive order.
+type entryIndex struct {
+	tree      *ast.AST
+	size      int
+	positions map[*ast.Transaction]int
+	// unindexed are the transactions no account history records, such as
+	// those that failed validation; they are always scanned.
+	unindexed []int
+}
+
+// entryIndex returns the index of tree, building it on first use. It
+// returns nil when the ledger's histories reference transactions outside
+// tree, as after processing a different tree.
+func (qctx *Context) entryIndex(tree *ast.AST) *entryIndex {
+	if qctx.shared != nil {
+		return qctx.shared.entryIndex(tree)
+	}
+	qctx.indexMu.Lock()
+	defer qctx.indexMu.Unlock()
+	if index := qctx.index; index != nil && index.tree == tree && index.size == len(tree.Directives) {
+		return index
+	}
+
+	index := &entryIndex{tree: tree, size: len(tree.Directives), positions: make(map[*ast.Transaction]int)}
+	for i, entry := range tree.Directives {
+		if txn, ok := entry.(*ast.Transaction); ok {
+			index.positions[txn] = i
+		}
+	}
+	recorded := make(map[*ast.Transaction]bool, len(index.positions))
+	for _, account := range qctx.Ledger.Accounts() {
+		for _, posting := range account.Postings {
+			if _, ok := index.positions[posting.Transaction]; !ok {
+				return nil
+			}
+			recorded[posting.Transaction] = true
+		}
+	}
+	for txn, i := range index.positions {
+		if !recorded[txn] {
+			index.unindexed = append(index.unindexed, i)
+		}
+	}
+	slices.Sort(index.unindexed)
+
+	qctx.index = index
+	return index
+}
@@ -0,0 +1,172 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ledger"
+	"github.com/robinvdvleuten/beancount/parser"
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+const pushdownLedger = `
+2014-01-01 open Assets:Checking USD
+2014-01-01 open Assets:Invest HOOL
+2014-01-01 open Equity:Opening-Balances USD
+2014-01-01 open Expenses:Food USD
+2014-01-01 open Income:Gains USD
+
+2014-01-01 pad Assets:Checking Equity:Opening-Balances
+
+2014-02-01 * "Cafe" "Lunch"
+  Expenses:Food  12.00 USD
+  Assets:Checking
+
+2014-02-15 balance Assets:Checking 5000.00 USD
+
+2014-03-01 * "Broker" "Buy HOOL"
+  Assets:Invest  10 HOOL {500.00 USD}
+  Assets:Checking
+
+2014-06-01 * "Broker" "Buy HOOL"
+  Assets:Invest  5 HOOL {520.00 USD}
+  Assets:Checking
+
+2015-01-05 * "Cafe" "Lunch"
+  Expenses:Food  14.00 USD
+  Assets:Checking
+
+2015-02-01 * "Broker" "Sell HOOL"
+  Assets:Invest  -4 HOOL {500.00 USD}
+  Assets:Checking  2200.00 USD
+  Income:Gains
+`
+
+func TestPlanPushdown(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	tests := []struct {
+		where   string
+		account bool
+		start   string
+		end     string
+	}{
+		{"account ~ 'Assets'", true, "", ""},
+		{"date >= 2014-01-01 AND date < 2015-01-01", false, "2014-01-01", "2015-01-01"},
+		{"2014-03-01 < date AND date <= 2014-06-30", false, "2014-03-02", "2014-07-01"},
+		{"date = 2014-02-03 AND NOT account = 'Assets:Checking'", true, "2014-02-03", "2014-02-04"},
+		{"date > 2014-01-01 AND date > 2014-03-01 AND date < 2015-01-01 AND date < 2014-12-01", false, "2014-03-02", "2014-12-01"},
+		{"account ~ 'Assets' AND number > 10", true, "", ""},
+	}
+	for _, test := range tests {
+		p := mustCompile(t, ctx, "SELECT date WHERE "+test.where).pushdown
+		assert.NotZero(t, p, test.where)
+		assert.Equal(t, test.account, p.account != nil, test.where)
+		var start, end string
+		if p.start != nil {
+			start = p.start.String()
+		}
+		if p.end != nil {
+			end = p.end.String()
+		}
+		assert.Equal(t, test.start, start, test.where)
+		assert.Equal(t, test.end, end, test.where)
+	}
+
+	for _, where := range []string{
+		"account ~ 'Assets' OR number > 10",
+		"number > 10",
+		"date > date",
+		"year = 2014",
+	} {
+		assert.Zero(t, mustCompile(t, ctx, "SELECT date WHERE "+where).pushdown, where)
+	}
+	assert.Zero(t, mustCompile(t, ctx, "SELECT date FROM OPEN ON 2014-01-01 WHERE account ~ 'Assets'").pushdown)
+}
+
+func TestExecutePushdownMatchesFullScan(t *testing.T) {
+	ctx, tree := newContextFromSource(t, pushdownLedger)
+	for _, query := range []string{
+		"SELECT date, account, position, balance WHERE account = 'Assets:Invest' AND date >= 2015-01-01",
+		"SELECT date, account, cost_date, position WHERE account ~ 'Invest' AND date > 2014-12-31",
+		"SELECT date, narration, position WHERE account ~ 'Checking' AND date <= 2014-02-01",
+		"SELECT date, account, position WHERE date = 2015-01-05",
+		"SELECT account, sum(position) WHERE account = 'Expenses:Food' OR account = 'Income:Gains' GROUP BY account",
+		"SELECT date, account FROM year = 2015 WHERE NOT account ~ 'Assets' AND date < 2015-03-01",
+		"SELECT date, account WHERE account IN (SELECT account WHERE number < 0) AND date >= 2014-06-01",
+	} {
+		compiled := mustCompile(t, ctx, query, bql.WithV3Syntax())
+		assert.NotZero(t, compiled.pushdown, query)
+		pushed, err := Execute(context.Background(), ctx, tree, compiled)
+		assert.NoError(t, err)
+
+		compiled.pushdown = nil
+		scanned, err := Execute(context.Background(), ctx, tree, compiled)
+		assert.NoError(t, err)
+
+		assert.Equal(t, resultStrings(scanned), resultStrings(pushed), query)
+	}
+}
+
+func TestExecutePushdownScansInvalidTransactions(t *testing.T) {
+	// A transaction that fails validation stays in the directive tree, which
+	// the full scan reads, but never reaches the account histories the
+	// pushdown reads; the pushdown has to scan it anyway. The ledger is
+	// invalid on purpose.
+	source := pushdownLedger + `
+2015-03-01 * "Cafe" "Unbalanced"
+  Expenses:Food  3.00 USD
+  Assets:Checking  -2.00 USD
+`
+	tree, err := parser.ParseBytesWithFilename(context.Background(), "test.beancount", []byte(source))
+	assert.NoError(t, err)
+	l := ledger.New()
+	assert.Error(t, l.Process(context.Background(), tree))
+	ctx := &Context{Ledger: l}
+
+	compiled := mustCompile(t, ctx, "SELECT date, account, position WHERE date = 2015-03-01")
+	assert.NotZero(t, compiled.pushdown)
+	pushed, err := Execute(context.Background(), ctx, tree, compiled)
+	assert.NoError(t, err)
+	compiled.pushdown = nil
+	scanned, err := Execute(context.Background(), ctx, tree, compiled)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(scanned.Rows))
+	assert.Equal(t, resultStrings(scanned), resultStrings(pushed))
+}
+
+func TestExecutePushdownInheritsLotDates(t *testing.T) {
+	ctx, tree := newContextFromSource(t, pushdownLedger)
+	result := runQueryOn(t, ctx, tree, "SELECT cost_date, position WHERE account = 'Assets:Invest' AND date >= 2015-01-01")
+	assert.Equal(t, [][]string{{"2014-03-01", "-4 HOOL {500 USD, 2014-03-01}"}}, resultStrings(result))
+}
+
+func TestExecuteLimitDistinctStreams(t *testing.T) {
+	result := runQuery(t, "SELECT DISTINCT account LIMIT 3")
+	assert.Equal(t, [][]string{{"Assets:Checking"}, {"Equity:Opening-Balances"}, {"Income:Salary"}}, resultStrings(result))
+
+	result = runQuery(t, "SELECT date, account LIMIT 0")
+	assert.Equal(t, 0, len(result.Rows))
+}
+
+func TestLimitCounter(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	counter := newLimitCounter(mustCompile(t, ctx, "SELECT DISTINCT account LIMIT 2"))
+	assert.True(t, counter.more([]any{"Assets:Checking"}))
+	assert.True(t, counter.more([]any{"Assets:Checking"}))
+	assert.False(t, counter.more([]any{"Income:Salary"}))
+
+	assert.Zero(t, newLimitCounter(mustCompile(t, ctx, "SELECT account ORDER BY account LIMIT 2")))
+	assert.Zero(t, newLimitCounter(mustCompile(t, ctx, "SELECT account")))
+}
+
+func resultStrings(result *Result) [][]string {
+	rows := make([][]string, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = make([]string, len(row))
+		for j, v := range row {
+			rows[i][j] = valueString(v)
+		}
+	}
+	return rows
+}
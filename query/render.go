@@ -322,7 +322,8 @@ func padLeft(s string, width int) string {
 	return strings.Repeat(" ", width-len(s)) + s
 }
 
-// stringRenderer renders strings, sets, and polymorphic values left-aligned.
+// stringRenderer renders strings, sets, dicts, and polymorphic values
+// left-aligned.
 type stringRenderer struct {
 	w        int
 	unpadded bool
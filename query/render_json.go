@@ -0,0 +1,164 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+// RenderJSON writes a result as one JSON document: the columns with their
+// names and types, and the rows as objects keyed by column name, in column
+// order. Repeated column names get a numeric suffix, as in account_2. Values keep their types: decimals are strings with their own
+// precision, dates are ISO strings, sets are sorted lists, amounts and
+// positions are objects, and inventories are lists of positions.
+func RenderJSON(result *Result, w io.Writer) error {
+	names := uniqueColumnNames(result.Columns)
+	columns := make([]jsonColumn, len(result.Columns))
+	for i, col := range result.Columns {
+		columns[i] = jsonColumn{Name: names[i], Type: col.Type.String()}
+	}
+	rows := make([]jsonObject, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = jsonRow(names, row)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Columns []jsonColumn `json:"columns"`
+		Rows    []jsonObject `json:"rows"`
+	}{columns, rows})
+}
+
+// RenderJSONL writes a result as JSON Lines: one object per row, keyed by
+// column name, with keys and values like RenderJSON.
+func RenderJSONL(result *Result, w io.Writer) error {
+	names := uniqueColumnNames(result.Columns)
+	encoder := json.NewEncoder(w)
+	for _, row := range result.Rows {
+		if err := encoder.Encode(jsonRow(names, row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// jsonField is one key of a jsonObject.
+type jsonField struct {
+	key   string
+	value any
+}
+
+// jsonObject is a JSON object that keeps its keys in order, unlike a map.
+type jsonObject []jsonField
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func jsonRow(names []string, row []any) jsonObject {
+	object := make(jsonObject, len(names))
+	for i, name := range names {
+		object[i] = jsonField{name, jsonValue(row[i])}
+	}
+	return object
+}
+
+// uniqueColumnNames returns the column names with a numeric suffix on
+// repeats, as in account_2, for formats that look columns up by name.
+func uniqueColumnNames(columns []ResultColumn) []string {
+	names := make([]string, len(columns))
+	used := make(map[string]bool)
+	for i, col := range columns {
+		name := col.Name
+		for n := 2; used[name]; n++ {
+			name = col.Name + "_" + strconv.Itoa(n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// jsonValue converts a query value to the value encoded for it.
+func jsonValue(v any) any {
+	switch val := v.(type) {
+	case nil, bool, int64, string:
+		return val
+	case decimal.Decimal:
+		return DecimalString(val)
+	case Set:
+		return val.Sorted()
+	case *Amount:
+		return jsonAmount(val)
+	case *Position:
+		return jsonPosition(val)
+	case *Inventory:
+		positions := val.Positions()
+		list := make([]jsonObject, len(positions))
+		for i, p := range positions {
+			list[i] = jsonPosition(p)
+		}
+		return list
+	case *Dict:
+		object := make(jsonObject, 0, val.Len())
+		for _, key := range val.Keys() {
+			value, _ := val.Get(key)
+			object = append(object, jsonField{key, jsonValue(value)})
+		}
+		return object
+	}
+	return valueString(v)
+}
+
+func jsonAmount(a *Amount) jsonObject {
+	return jsonObject{{"number", DecimalString(a.Number)}, {"currency", a.Currency}}
+}
+
+func jsonPosition(p *Position) jsonObject {
+	object := jsonObject{{"units", jsonAmount(&p.Units)}}
+	if p.Cost != nil {
+		cost := jsonObject{{"number", DecimalString(p.Cost.Number)}, {"currency", p.Cost.Currency}}
+		if p.Cost.Date != nil {
+			cost = append(cost, jsonField{"date", p.Cost.Date.String()})
+		}
+		if p.Cost.Label != "" {
+			cost = append(cost, jsonField{"label", p.Cost.Label})
+		}
+		object = append(object, jsonField{"cost", cost})
+	}
+	return object
+}
+
+// DecimalString renders a decimal with its own precision, keeping trailing
+// zeros, as the JSON renderer writes numbers.
+func DecimalString(d decimal.Decimal) string {
+	return d.StringFixed(max(-d.Exponent(), 0))
+}
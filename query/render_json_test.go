@@ -0,0 +1,114 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRenderJSON(t *testing.T) {
+	ctx, tree := newContextFromSource(t, renderLedger)
+	result := runQueryOn(t, ctx, tree, "SELECT date, account, position, tags, number, lineno "+
+		"WHERE account ~ 'Invest|Food'")
+
+	var b strings.Builder
+	assert.NoError(t, RenderJSON(result, &b))
+	assert.Equal(t, `{
+  "columns": [
+    {
+      "name": "date",
+      "type": "date"
+    },
+    {
+      "name": "account",
+      "type": "str"
+    },
+    {
+      "name": "position",
+      "type": "Position"
+    },
+    {
+      "name": "tags",
+      "type": "set"
+    },
+    {
+      "name": "number",
+      "type": "Decimal"
+    },
+    {
+      "name": "lineno",
+      "type": "int"
+    }
+  ],
+  "rows": [
+    {
+      "date": "2014-03-05",
+      "account": "Expenses:Food",
+      "position": {
+        "units": {
+          "number": "4.50",
+          "currency": "USD"
+        }
+      },
+      "tags": [
+        "food"
+      ],
+      "number": "4.50",
+      "lineno": 18
+    },
+    {
+      "date": "2014-04-01",
+      "account": "Assets:Invest",
+      "position": {
+        "units": {
+          "number": "10",
+          "currency": "HOOL"
+        },
+        "cost": {
+          "number": "500.00",
+          "currency": "USD",
+          "date": "2014-04-01"
+        }
+      },
+      "tags": [],
+      "number": "10",
+      "lineno": 22
+    }
+  ]
+}
+`, b.String())
+}
+
+func TestRenderJSONL(t *testing.T) {
+	ctx, tree := newContextFromSource(t, renderLedger)
+	result := runQueryOn(t, ctx, tree, "SELECT account, sum(position) AS total "+
+		"WHERE account ~ 'Assets' GROUP BY account ORDER BY account")
+
+	var b strings.Builder
+	assert.NoError(t, RenderJSONL(result, &b))
+	assert.Equal(t, ""+
+		`{"account":"Assets:Checking","total":[{"units":{"number":"-1504.50","currency":"USD"}}]}`+"\n"+
+		`{"account":"Assets:Invest","total":[{"units":{"number":"10","currency":"HOOL"},"cost":{"number":"500.00","currency":"USD","date":"2014-04-01"}}]}`+"\n",
+		b.String())
+
+	b.Reset()
+	assert.NoError(t, RenderJSONL(&Result{Columns: result.Columns}, &b))
+	assert.Equal(t, "", b.String())
+}
+
+func TestRenderJSONRepeatedColumns(t *testing.T) {
+	result := &Result{
+		Columns: []ResultColumn{{Name: "account", Type: TString}, {Name: "account", Type: TString}},
+		Rows:    [][]any{{"Assets:Checking", "Assets"}},
+	}
+
+	var b strings.Builder
+	assert.NoError(t, RenderJSONL(result, &b))
+	assert.Equal(t, `{"account":"Assets:Checking","account_2":"Assets"}`+"\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, RenderJSON(result, &b))
+	assert.Contains(t, b.String(), `"name": "account_2"`)
+	assert.Contains(t, b.String(), `"account_2": "Assets"`)
+}
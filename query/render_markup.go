@@ -0,0 +1,144 @@
+package query
+
+import (
+	"html"
+	"io"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// RenderMarkdown writes a result as a Markdown (GitHub-flavored) table with
+// numeric columns right-aligned. Empty results keep their header row.
+func RenderMarkdown(result *Result, w io.Writer) error {
+	cells := make([][]string, len(result.Rows))
+	widths := make([]int, len(result.Columns))
+	for i, col := range result.Columns {
+		widths[i] = max(len(markdownEscape(col.Name)), 3)
+	}
+	for r, row := range result.Rows {
+		cells[r] = make([]string, len(row))
+		for i, value := range row {
+			cells[r][i] = markdownEscape(cellString(value))
+			widths[i] = max(widths[i], len(cells[r][i]))
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(fields []string) {
+		b.WriteByte('|')
+		for i, field := range fields {
+			b.WriteByte(' ')
+			if isNumeric(result.Columns[i].Type) {
+				b.WriteString(padLeft(field, widths[i]))
+			} else {
+				b.WriteString(padRight(field, widths[i]))
+			}
+			b.WriteString(" |")
+		}
+		b.WriteByte('\n')
+	}
+
+	header := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		header[i] = markdownEscape(col.Name)
+	}
+	writeRow(header)
+	b.WriteByte('|')
+	for i, col := range result.Columns {
+		if isNumeric(col.Type) {
+			b.WriteString(" " + strings.Repeat("-", widths[i]-1) + ": |")
+		} else {
+			b.WriteString(" " + strings.Repeat("-", widths[i]) + " |")
+		}
+	}
+	b.WriteByte('\n')
+	for _, row := range cells {
+		writeRow(row)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// RenderHTML writes a result as an HTML table. Cells of numeric columns
+// carry class="number" so a stylesheet can right-align them.
+func RenderHTML(result *Result, w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for _, col := range result.Columns {
+		b.WriteString("<th>" + html.EscapeString(col.Name) + "</th>")
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range result.Rows {
+		b.WriteString("<tr>")
+		for i, value := range row {
+			if isNumeric(result.Columns[i].Type) {
+				b.WriteString(`<td class="number">`)
+			} else {
+				b.WriteString("<td>")
+			}
+			b.WriteString(html.EscapeString(cellString(value)) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cellString renders a value for a markup table cell: like the text table,
+// but without alignment padding.
+func cellString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if val {
+			return "TRUE"
+		}
+		return "FALSE"
+	case decimal.Decimal:
+		return DecimalString(val)
+	case Set:
+		return strings.Join(val.Sorted(), ",")
+	case *Amount:
+		return DecimalString(val.Number) + " " + val.Currency
+	case *Position:
+		return cellPosition(val)
+	case *Inventory:
+		positions := val.Positions()
+		parts := make([]string, len(positions))
+		for i, p := range positions {
+			parts[i] = cellPosition(p)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return valueString(v)
+}
+
+func cellPosition(p *Position) string {
+	s := DecimalString(p.Units.Number) + " " + p.Units.Currency
+	if p.Cost != nil {
+		s += " " + costString(p.Cost)
+	}
+	return s
+}
+
+// isNumeric reports whether a column holds numbers, which tables
+// right-align.
+func isNumeric(t DType) bool {
+	switch t {
+	case TInt, TDecimal, TAmount, TPosition, TInventory:
+		return true
+	}
+	return false
+}
+
+// markdownEscape keeps pipes and line breaks in a value from breaking the
+// table.
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,53 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	ctx, tree := newContextFromSource(t, renderLedger)
+	result := runQueryOn(t, ctx, tree, "SELECT account, sum(position) AS total, count(date) AS n "+
+		"GROUP BY account ORDER BY account LIMIT 3")
+
+	var b strings.Builder
+	assert.NoError(t, RenderMarkdown(result, &b))
+	assert.Equal(t, ""+
+		"| account                 |                total |   n |\n"+
+		"| ----------------------- | -------------------: | --: |\n"+
+		"| Assets:Checking         |         -1504.50 USD |   4 |\n"+
+		"| Assets:Invest           | 10 HOOL {500.00 USD} |   1 |\n"+
+		"| Equity:Opening-Balances |         -1000.00 USD |   1 |\n",
+		b.String())
+
+	b.Reset()
+	assert.NoError(t, RenderMarkdown(&Result{Columns: []ResultColumn{{Name: "a|b", Type: TString}}}, &b))
+	assert.Equal(t, "| a\\|b |\n| ---- |\n", b.String())
+}
+
+func TestRenderHTML(t *testing.T) {
+	ctx, tree := newContextFromSource(t, renderLedger)
+	result := runQueryOn(t, ctx, tree, "SELECT payee, number, tags WHERE account = 'Income:Salary'")
+
+	var b strings.Builder
+	assert.NoError(t, RenderHTML(result, &b))
+	assert.Equal(t, `<table>
+<thead>
+<tr><th>payee</th><th>number</th><th>tags</th></tr>
+</thead>
+<tbody>
+<tr><td>Acme</td><td class="number">-2500.00</td><td>job</td></tr>
+</tbody>
+</table>
+`, b.String())
+
+	b.Reset()
+	assert.NoError(t, RenderHTML(&Result{
+		Columns: []ResultColumn{{Name: "<name>", Type: TString}},
+		Rows:    [][]any{{"Tom & Jerry"}},
+	}, &b))
+	assert.Contains(t, b.String(), "<th>&lt;name&gt;</th>")
+	assert.Contains(t, b.String(), "<td>Tom &amp; Jerry</td>")
+}
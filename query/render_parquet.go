@@ -0,0 +1,447 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+// RenderParquet writes a result as an uncompressed Parquet file with one row
+// group, for loading into data tools such as DuckDB or pandas. Column types
+// follow the query types: str as UTF-8 strings, int as INT64, bool as
+// BOOLEAN, Decimal as DECIMAL(38, s) with s the largest scale in the
+// column, date as DATE, and set as a list of strings. Amounts, positions,
+// inventories, and dicts are written as their text; with numberify,
+// amount-bearing columns split into per-currency decimal columns first, as
+// in RenderCSV. Every column is nullable.
+func RenderParquet(result *Result, w io.Writer, numberify bool) error {
+	if numberify {
+		result = numberifyResult(result)
+	}
+
+	// Parquet readers resolve columns by name, so repeated names get a
+	// numeric suffix.
+	names := uniqueColumnNames(result.Columns)
+	columns := make([]*parquetColumn, len(result.Columns))
+	for i, col := range result.Columns {
+		column, err := newParquetColumn(names[i], col.Type, result.Rows, i)
+		if err != nil {
+			return err
+		}
+		columns[i] = column
+	}
+
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	var chunks []*thriftStruct
+	var totalSize int64
+	for _, column := range columns {
+		offset := int64(file.Len())
+		page := column.page()
+		file.Write(page)
+		totalSize += int64(len(page))
+
+		meta := &thriftStruct{}
+		meta.i32(1, column.physical)
+		meta.i32List(2, []int32{parquetPlain, parquetRLE})
+		meta.stringList(3, column.path())
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(len(column.defLevels)))
+		meta.i64(6, int64(len(page)))
+		meta.i64(7, int64(len(page)))
+		meta.i64(9, offset)
+
+		chunk := &thriftStruct{}
+		chunk.i64(2, offset)
+		chunk.structField(3, meta)
+		chunks = append(chunks, chunk)
+	}
+
+	footer := &thriftStruct{}
+	footer.i32(1, 1)
+	footer.structList(2, parquetSchema(columns))
+	footer.i64(3, int64(len(result.Rows)))
+	var rowGroups []*thriftStruct
+	if len(result.Rows) > 0 {
+		group := &thriftStruct{}
+		group.structList(1, chunks)
+		group.i64(2, totalSize)
+		group.i64(3, int64(len(result.Rows)))
+		rowGroups = append(rowGroups, group)
+	}
+	footer.structList(4, rowGroups)
+	footer.binary(6, []byte("github.com/robinvdvleuten/beancount"))
+
+	encoded := footer.encode()
+	file.Write(encoded)
+	_ = binary.Write(&file, binary.LittleEndian, uint32(len(encoded)))
+	file.WriteString("PAR1")
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Parquet physical types, converted types, and encodings.
+const (
+	parquetBoolean           int32 = 0
+	parquetInt32             int32 = 1
+	parquetInt64             int32 = 2
+	parquetByteArray         int32 = 6
+	parquetFixedLenByteArray int32 = 7
+
+	parquetUTF8    int32 = 0
+	parquetList    int32 = 3
+	parquetDecimal int32 = 5
+	parquetDate    int32 = 6
+
+	parquetPlain int32 = 0
+	parquetRLE   int32 = 3
+)
+
+// logicalTypes maps the converted types used here to their field in the
+// LogicalType union.
+var logicalTypes = map[int32]int16{
+	parquetUTF8:    1, // STRING
+	parquetList:    3, // LIST
+	parquetDecimal: 5, // DECIMAL
+	parquetDate:    6, // DATE
+}
+
+// parquetColumn is one result column encoded for a data page: its schema,
+// the definition and repetition levels of its values, and the PLAIN-encoded
+// values that are not NULL.
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32 // -1 when none
+	scale     int32
+	list      bool // a LIST of strings, with repetition levels
+	defLevels []int
+	repLevels []int
+	values    bytes.Buffer
+	booleans  []bool
+}
+
+func newParquetColumn(name string, typ DType, rows [][]any, col int) (*parquetColumn, error) {
+	c := &parquetColumn{name: name, converted: -1}
+	switch typ {
+	case TBool:
+		c.physical = parquetBoolean
+	case TInt:
+		c.physical = parquetInt64
+	case TDate:
+		c.physical, c.converted = parquetInt32, parquetDate
+	case TDecimal:
+		c.physical, c.converted = parquetFixedLenByteArray, parquetDecimal
+		for _, row := range rows {
+			if d, ok := row[col].(decimal.Decimal); ok {
+				c.scale = max(c.scale, -d.Exponent())
+			}
+		}
+	case TSet:
+		c.physical, c.converted, c.list = parquetByteArray, parquetUTF8, true
+	default:
+		c.physical, c.converted = parquetByteArray, parquetUTF8
+	}
+
+	for _, row := range rows {
+		if err := c.add(row[col]); err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+	return c, nil
+}
+
+// add appends one row's value. Optional columns have definition level 1
+// for values and 0 for NULL; lists add a level for each element, 1 for an
+// empty list.
+func (c *parquetColumn) add(v any) error {
+	if c.list {
+		set, _ := v.(Set)
+		switch {
+		case set == nil:
+			c.defLevels = append(c.defLevels, 0)
+			c.repLevels = append(c.repLevels, 0)
+		case len(set) == 0:
+			c.defLevels = append(c.defLevels, 1)
+			c.repLevels = append(c.repLevels, 0)
+		default:
+			for i, elem := range set.Sorted() {
+				c.defLevels = append(c.defLevels, 2)
+				c.repLevels = append(c.repLevels, min(i, 1))
+				writeByteArray(&c.values, elem)
+			}
+		}
+		return nil
+	}
+
+	if v == nil {
+		c.defLevels = append(c.defLevels, 0)
+		return nil
+	}
+	c.defLevels = append(c.defLevels, 1)
+	switch c.physical {
+	case parquetBoolean:
+		c.booleans = append(c.booleans, v.(bool))
+	case parquetInt64:
+		_ = binary.Write(&c.values, binary.LittleEndian, v.(int64))
+	case parquetInt32:
+		_ = binary.Write(&c.values, binary.LittleEndian, daysSinceEpoch(v.(*ast.Date)))
+	case parquetFixedLenByteArray:
+		unscaled := v.(decimal.Decimal).Shift(c.scale).BigInt()
+		if unscaled.BitLen() > 127 {
+			return fmt.Errorf("%s does not fit a 38-digit decimal", v.(decimal.Decimal))
+		}
+		if unscaled.Sign() < 0 {
+			unscaled.Add(unscaled, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+		c.values.Write(unscaled.FillBytes(make([]byte, 16)))
+	default:
+		writeByteArray(&c.values, cellString(v))
+	}
+	return nil
+}
+
+// path is the column's path in the schema, down to its leaf.
+func (c *parquetColumn) path() []string {
+	if c.list {
+		return []string{c.name, "list", "element"}
+	}
+	return []string{c.name}
+}
+
+// page encodes the column as one v1 data page with its header.
+func (c *parquetColumn) page() []byte {
+	var body bytes.Buffer
+	if c.list {
+		writeLevels(&body, c.repLevels, 1)
+		writeLevels(&body, c.defLevels, 2)
+	} else {
+		writeLevels(&body, c.defLevels, 1)
+	}
+	if c.physical == parquetBoolean {
+		packed := make([]byte, (len(c.booleans)+7)/8)
+		for i, b := range c.booleans {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		body.Write(packed)
+	} else {
+		body.Write(c.values.Bytes())
+	}
+
+	dataHeader := &thriftStruct{}
+	dataHeader.i32(1, int32(len(c.defLevels)))
+	dataHeader.i32(2, parquetPlain)
+	dataHeader.i32(3, parquetRLE)
+	dataHeader.i32(4, parquetRLE)
+
+	header := &thriftStruct{}
+	header.i32(1, 0) // DATA_PAGE
+	header.i32(2, int32(body.Len()))
+	header.i32(3, int32(body.Len()))
+	header.structField(5, dataHeader)
+
+	return append(header.encode(), body.Bytes()...)
+}
+
+// parquetSchema flattens the schema tree: a root with one optional field
+// per column, lists as the standard three-level LIST group.
+func parquetSchema(columns []*parquetColumn) []*thriftStruct {
+	root := &thriftStruct{}
+	root.binary(4, []byte("schema"))
+	root.i32(5, int32(len(columns)))
+	schema := []*thriftStruct{root}
+
+	for _, c := range columns {
+		if c.list {
+			group := &thriftStruct{}
+			group.i32(3, 1) // OPTIONAL
+			group.binary(4, []byte(c.name))
+			group.i32(5, 1)
+			group.i32(6, parquetList)
+			group.structField(10, logicalType(parquetList, nil))
+
+			repeated := &thriftStruct{}
+			repeated.i32(3, 2) // REPEATED
+			repeated.binary(4, []byte("list"))
+			repeated.i32(5, 1)
+
+			element := &thriftStruct{}
+			element.i32(1, parquetByteArray)
+			element.i32(3, 0) // REQUIRED
+			element.binary(4, []byte("element"))
+			element.i32(6, parquetUTF8)
+			element.structField(10, logicalType(parquetUTF8, nil))
+
+			schema = append(schema, group, repeated, element)
+			continue
+		}
+
+		field := &thriftStruct{}
+		field.i32(1, c.physical)
+		if c.physical == parquetFixedLenByteArray {
+			field.i32(2, 16)
+		}
+		field.i32(3, 1) // OPTIONAL
+		field.binary(4, []byte(c.name))
+		if c.converted >= 0 {
+			field.i32(6, c.converted)
+		}
+		if c.converted == parquetDecimal {
+			field.i32(7, c.scale)
+			field.i32(8, 38)
+			decimalType := &thriftStruct{}
+			decimalType.i32(1, c.scale)
+			decimalType.i32(2, 38)
+			field.structField(10, logicalType(parquetDecimal, decimalType))
+		} else if c.converted >= 0 {
+			field.structField(10, logicalType(c.converted, nil))
+		}
+		schema = append(schema, field)
+	}
+	return schema
+}
+
+// logicalType builds the LogicalType union for a converted type, with the
+// parameters of the type if it has any.
+func logicalType(converted int32, params *thriftStruct) *thriftStruct {
+	if params == nil {
+		params = &thriftStruct{}
+	}
+	union := &thriftStruct{}
+	union.structField(logicalTypes[converted], params)
+	return union
+}
+
+func writeByteArray(b *bytes.Buffer, s string) {
+	_ = binary.Write(b, binary.LittleEndian, uint32(len(s)))
+	b.WriteString(s)
+}
+
+// writeLevels writes levels with the RLE/bit-packing hybrid encoding as RLE
+// runs, prefixed by their byte length.
+func writeLevels(b *bytes.Buffer, levels []int, maxLevel int) {
+	width := (bits.Len(uint(maxLevel)) + 7) / 8
+	var runs bytes.Buffer
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		writeUvarint(&runs, uint64(j-i)<<1)
+		for k := range width {
+			runs.WriteByte(byte(levels[i] >> (8 * k)))
+		}
+		i = j
+	}
+	_ = binary.Write(b, binary.LittleEndian, uint32(runs.Len()))
+	b.Write(runs.Bytes())
+}
+
+func daysSinceEpoch(date *ast.Date) int32 {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return int32(day.Unix() / 86400)
+}
+
+func writeUvarint(b *bytes.Buffer, v uint64) {
+	b.Write(binary.AppendUvarint(nil, v))
+}
+
+// thriftStruct encodes a Thrift struct with the compact protocol, which
+// Parquet uses for its metadata. Fields must be added in increasing id
+// order.
+type thriftStruct struct {
+	buf    bytes.Buffer
+	lastID int16
+}
+
+// Thrift compact protocol type ids.
+const (
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+func (s *thriftStruct) field(id int16, typ byte) {
+	if delta := id - s.lastID; delta > 0 && delta <= 15 {
+		s.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		s.buf.WriteByte(typ)
+		writeUvarint(&s.buf, zigzag(int64(id)))
+	}
+	s.lastID = id
+}
+
+func (s *thriftStruct) i32(id int16, v int32) {
+	s.field(id, thriftTypeI32)
+	writeUvarint(&s.buf, zigzag(int64(v)))
+}
+
+func (s *thriftStruct) i64(id int16, v int64) {
+	s.field(id, thriftTypeI64)
+	writeUvarint(&s.buf, zigzag(v))
+}
+
+func (s *thriftStruct) binary(id int16, v []byte) {
+	s.field(id, thriftTypeBinary)
+	writeUvarint(&s.buf, uint64(len(v)))
+	s.buf.Write(v)
+}
+
+func (s *thriftStruct) structField(id int16, v *thriftStruct) {
+	s.field(id, thriftTypeStruct)
+	s.buf.Write(v.encode())
+}
+
+func (s *thriftStruct) listHeader(id int16, size int, elem byte) {
+	s.field(id, thriftTypeList)
+	if size < 15 {
+		s.buf.WriteByte(byte(size)<<4 | elem)
+	} else {
+		s.buf.WriteByte(0xf0 | elem)
+		writeUvarint(&s.buf, uint64(size))
+	}
+}
+
+func (s *thriftStruct) i32List(id int16, values []int32) {
+	s.listHeader(id, len(values), thriftTypeI32)
+	for _, v := range values {
+		writeUvarint(&s.buf, zigzag(int64(v)))
+	}
+}
+
+func (s *thriftStruct) stringList(id int16, values []string) {
+	s.listHeader(id, len(values), thriftTypeBinary)
+	for _, v := range values {
+		writeUvarint(&s.buf, uint64(len(v)))
+		s.buf.WriteString(v)
+	}
+}
+
+func (s *thriftStruct) structList(id int16, values []*thriftStruct) {
+	s.listHeader(id, len(values), thriftTypeStruct)
+	for _, v := range values {
+		s.buf.Write(v.encode())
+	}
+}
+
+// encode returns the struct's fields followed by the stop byte.
+func (s *thriftStruct) encode() []byte {
+	return append(bytes.Clone(s.buf.Bytes()), 0)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
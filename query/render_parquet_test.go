@@ -0,0 +1,246 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/shopspring/decimal"
+)
+
+// thriftReader decodes compact-protocol structs into maps from field id to
+// value, enough to inspect the Parquet metadata written by RenderParquet.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case thriftTypeI32, thriftTypeI64:
+		return r.varint()
+	case thriftTypeBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case thriftTypeList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]any, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftTypeStruct:
+		return r.structure()
+	}
+	panic("unexpected thrift type")
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// parquetLevels decodes RLE runs of levels one byte wide.
+func parquetLevels(data []byte, count int) ([]int, int) {
+	length := int(binary.LittleEndian.Uint32(data))
+	runs := data[4 : 4+length]
+	var levels []int
+	for pos := 0; len(levels) < count; {
+		header, n := binary.Uvarint(runs[pos:])
+		pos += n
+		for range header >> 1 {
+			levels = append(levels, int(runs[pos]))
+		}
+		pos++
+	}
+	return levels, 4 + length
+}
+
+// parquetTypesResult has a column of every type RenderParquet maps, with
+// nulls, an empty set, a negative decimal, and a date before 1970.
+func parquetTypesResult() *Result {
+	date := func(year int, month time.Month, day int) *ast.Date {
+		return ast.NewDateFromTime(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	}
+	return &Result{
+		Columns: []ResultColumn{
+			{Name: "date", Type: TDate},
+			{Name: "account", Type: TString},
+			{Name: "number", Type: TDecimal},
+			{Name: "tags", Type: TSet},
+			{Name: "lineno", Type: TInt},
+			{Name: "cleared", Type: TBool},
+			{Name: "account", Type: TAmount},
+		},
+		Rows: [][]any{
+			{date(2014, 1, 2), "Assets:Checking", decimal.RequireFromString("1000.5"), NewSet("b", "a"), int64(7), true,
+				&Amount{Number: decimal.RequireFromString("1.00"), Currency: "USD"}},
+			{nil, nil, decimal.RequireFromString("-4.25"), NewSet(), nil, false, nil},
+			{date(1969, 12, 31), "Expenses:Food", nil, nil, int64(9), nil, nil},
+		},
+	}
+}
+
+func TestRenderParquet(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, RenderParquet(parquetTypesResult(), &b, false))
+	file := b.Bytes()
+	assert.Equal(t, "PAR1", string(file[:4]))
+	assert.Equal(t, "PAR1", string(file[len(file)-4:]))
+
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := (&thriftReader{data: file[len(file)-8-footerLen:]}).structure()
+	assert.Equal(t, int64(3), footer[3].(int64))
+
+	var names []string
+	for _, element := range footer[2].([]any)[1:] {
+		names = append(names, element.(map[int16]any)[4].(string))
+	}
+	assert.Equal(t, []string{"date", "account", "number", "tags", "list", "element", "lineno", "cleared", "account_2"}, names)
+	number := footer[2].([]any)[3].(map[int16]any)
+	assert.Equal(t, int64(parquetFixedLenByteArray), number[1].(int64))
+	assert.Equal(t, int64(parquetDecimal), number[6].(int64))
+	assert.Equal(t, int64(2), number[7].(int64)) // scale
+	assert.Equal(t, int64(38), number[8].(int64))
+
+	chunks := footer[4].([]any)[0].(map[int16]any)[1].([]any)
+	assert.Equal(t, 7, len(chunks))
+	page := func(i int) (levels, repLevels []int, values []byte) {
+		meta := chunks[i].(map[int16]any)[3].(map[int16]any)
+		reader := &thriftReader{data: file, pos: int(meta[9].(int64))}
+		header := reader.structure()
+		body := file[reader.pos : reader.pos+int(header[3].(int64))]
+		count := int(header[5].(map[int16]any)[1].(int64))
+		if len(meta[3].([]any)) > 1 {
+			var n int
+			repLevels, n = parquetLevels(body, count)
+			body = body[n:]
+		}
+		levels, n := parquetLevels(body, count)
+		return levels, repLevels, body[n:]
+	}
+
+	levels, _, values := page(0)
+	assert.Equal(t, []int{1, 0, 1}, levels)
+	assert.Equal(t, []int32{16072, -1}, []int32{
+		int32(binary.LittleEndian.Uint32(values)), int32(binary.LittleEndian.Uint32(values[4:])),
+	})
+
+	levels, _, values = page(1)
+	assert.Equal(t, []int{1, 0, 1}, levels)
+	assert.Equal(t, "\x0f\x00\x00\x00Assets:Checking\x0d\x00\x00\x00Expenses:Food", string(values))
+
+	levels, _, values = page(2)
+	assert.Equal(t, []int{1, 1, 0}, levels)
+	assert.Equal(t, 32, len(values))
+	assert.Equal(t, "100050", new(big.Int).SetBytes(values[:16]).String())
+	negative := new(big.Int).Sub(new(big.Int).SetBytes(values[16:]), new(big.Int).Lsh(big.NewInt(1), 128))
+	assert.Equal(t, "-425", negative.String())
+
+	levels, repLevels, values := page(3)
+	assert.Equal(t, []int{2, 2, 1, 0}, levels)
+	assert.Equal(t, []int{0, 1, 0, 0}, repLevels)
+	assert.Equal(t, "\x01\x00\x00\x00a\x01\x00\x00\x00b", string(values))
+
+	levels, _, values = page(5)
+	assert.Equal(t, []int{1, 1, 0}, levels)
+	assert.Equal(t, []byte{0x01}, values)
+
+	_, _, values = page(6)
+	assert.Equal(t, "\x08\x00\x00\x001.00 USD", string(values))
+}
+
+func TestRenderParquetNumberify(t *testing.T) {
+	ctx, tree := newContextFromSource(t, renderLedger)
+	result := runQueryOn(t, ctx, tree, "SELECT account, sum(position) AS total GROUP BY account ORDER BY account")
+
+	var b bytes.Buffer
+	assert.NoError(t, RenderParquet(result, &b, true))
+	file := b.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := (&thriftReader{data: file[len(file)-8-footerLen:]}).structure()
+
+	var names []string
+	for _, element := range footer[2].([]any)[1:] {
+		names = append(names, element.(map[int16]any)[4].(string))
+	}
+	assert.Equal(t, "account, total (USD), total (HOOL)", strings.Join(names, ", "))
+	assert.Equal(t, int64(5), footer[3].(int64))
+}
+
+func TestRenderParquetEmpty(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, RenderParquet(&Result{Columns: []ResultColumn{{Name: "account", Type: TString}}}, &b, false))
+	file := b.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := (&thriftReader{data: file[len(file)-8-footerLen:]}).structure()
+	assert.Equal(t, int64(0), footer[3].(int64))
+	assert.Equal(t, 0, len(footer[4].([]any)))
+}
+
+// TestRenderParquetGolden compares RenderParquet output with the files in
+// testdata/parquet, which were read back with an independent Parquet reader;
+// see the README there. A change to the output needs the files regenerated
+// and read back again.
+func TestRenderParquetGolden(t *testing.T) {
+	ctx, tree := newContextFromSource(t, renderLedger)
+	tests := []struct {
+		name      string
+		result    *Result
+		numberify bool
+	}{
+		{"types", parquetTypesResult(), false},
+		{"numberify", runQueryOn(t, ctx, tree, "SELECT account, sum(position) AS total GROUP BY account ORDER BY account"), true},
+		{"empty", &Result{Columns: []ResultColumn{{Name: "account", Type: TString}}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("..", "testdata", "parquet", test.name+".parquet"))
+			assert.NoError(t, err)
+			var b bytes.Buffer
+			assert.NoError(t, RenderParquet(test.result, &b, test.numberify))
+			assert.True(t, bytes.Equal(want, b.Bytes()), "output differs from %s.parquet", test.name)
+		})
+	}
+}
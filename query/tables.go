@@ -0,0 +1,207 @@
+package query
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/budget"
+	"github.com/robinvdvleuten/beancount/ledger"
+)
+
+// table is a named row source for FROM #name: its column environment, the
+// columns SELECT * expands to, and how its rows are generated.
+type table struct {
+	env      *environment
+	wildcard []string
+	rows     func(qctx *Context, tree *ast.AST) []*Row
+}
+
+// tables are the beanquery v3 tables, backed by the processed directives
+// and the ledger's accounts, price graph, and commodity nodes.
+var tables = map[string]*table{
+	"entries": {
+		env:      &environment{columns: entryColumns, context: "#entries context"},
+		wildcard: []string{"date", "type", "flag", "payee", "narration"},
+		rows:     entryRows,
+	},
+	"accounts": {
+		env:      &environment{columns: accountColumns, context: "#accounts context"},
+		wildcard: []string{"account", "open_date", "close_date", "currencies"},
+		rows:     accountRows,
+	},
+	"prices": {
+		env:      &environment{columns: priceColumns, context: "#prices context"},
+		wildcard: []string{"date", "currency", "amount"},
+		rows:     priceRows,
+	},
+	"commodities": {
+		env:      &environment{columns: commodityColumns, context: "#commodities context"},
+		wildcard: []string{"currency", "date"},
+		rows:     commodityRows,
+	},
+	"balances": {
+		env:      &environment{columns: balanceColumns, context: "#balances context"},
+		wildcard: []string{"date", "account", "amount"},
+		rows:     balanceRows,
+	},
+	"budgets": {
+		env:      &environment{columns: budgetColumns, context: "#budgets context"},
+		wildcard: []string{"date", "account", "budget", "actual"},
+		rows:     budgetRows,
+	},
+}
+
+// accountColumns describe the accounts opened in the ledger.
+var accountColumns = map[string]*columnDef{
+	"account":    {TString, func(row *Row) any { return string(row.Account.Name) }},
+	"open_date":  {TDate, func(row *Row) any { return optionalDate(row.Account.OpenDate) }},
+	"close_date": {TDate, func(row *Row) any { return optionalDate(row.Account.CloseDate) }},
+	"type":       {TString, func(row *Row) any { return row.Account.Type }},
+	"currencies": {TSet, func(row *Row) any {
+		return NewSet(row.Account.ConstraintCurrencies...)
+	}},
+	"booking": {TString, func(row *Row) any {
+		if row.Account.BookingMethod == "" {
+			return nil
+		}
+		return string(row.Account.BookingMethod)
+	}},
+	"meta": {TDict, func(row *Row) any { return metaDict(row.Account.Metadata) }},
+}
+
+// priceColumns describe price directives: the price of one unit of currency.
+var priceColumns = map[string]*columnDef{
+	"date":     {TDate, func(row *Row) any { return row.Entry.Date() }},
+	"currency": {TString, func(row *Row) any { return row.Entry.(*ast.Price).Commodity }},
+	"amount":   {TAmount, func(row *Row) any { return directiveAmount(row.Entry.(*ast.Price).Amount) }},
+}
+
+// commodityColumns describe the commodities declared with commodity
+// directives.
+var commodityColumns = map[string]*columnDef{
+	"currency": {TString, func(row *Row) any { return row.Commodity.ID }},
+	"date":     {TDate, func(row *Row) any { return optionalDate(row.Commodity.Date) }},
+	"meta":     {TDict, func(row *Row) any { return metaDict(row.Commodity.Metadata) }},
+}
+
+// balanceColumns describe balance assertions.
+var balanceColumns = map[string]*columnDef{
+	"date":      {TDate, func(row *Row) any { return row.Entry.Date() }},
+	"account":   {TString, func(row *Row) any { return string(row.Entry.(*ast.Balance).Account) }},
+	"amount":    {TAmount, func(row *Row) any { return directiveAmount(row.Entry.(*ast.Balance).Amount) }},
+	"tolerance": {TAmount, func(row *Row) any { return directiveAmount(row.Entry.(*ast.Balance).Tolerance) }},
+	"filename":  {TString, func(row *Row) any { return row.Entry.Position().Filename }},
+	"lineno":    {TInt, func(row *Row) any { return int64(row.Entry.Position().Line) }},
+}
+
+// budgetColumns compare the budgets of Context.Budgets with the actual
+// amounts, per month and budgeted account and currency.
+var budgetColumns = map[string]*columnDef{
+	"date":     {TDate, func(row *Row) any { return row.Budget.Start }},
+	"end_date": {TDate, func(row *Row) any { return row.Budget.End }},
+	"account":  {TString, func(row *Row) any { return string(row.Budget.Account) }},
+	"budget": {TAmount, func(row *Row) any {
+		return &Amount{Number: row.Budget.Budget, Currency: row.Budget.Currency}
+	}},
+	"actual": {TAmount, func(row *Row) any {
+		return &Amount{Number: row.Budget.Actual, Currency: row.Budget.Currency}
+	}},
+	"remaining": {TAmount, func(row *Row) any {
+		return &Amount{Number: row.Budget.Remaining(), Currency: row.Budget.Currency}
+	}},
+}
+
+func entryRows(qctx *Context, tree *ast.AST) []*Row {
+	rows := make([]*Row, 0, len(tree.Directives))
+	for _, entry := range tree.Directives {
+		rows = append(rows, &Row{Ctx: qctx, Entry: entry})
+	}
+	return rows
+}
+
+func accountRows(qctx *Context, _ *ast.AST) []*Row {
+	accounts := qctx.Ledger.Accounts()
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	rows := make([]*Row, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, &Row{Ctx: qctx, Account: accounts[name]})
+	}
+	return rows
+}
+
+func priceRows(qctx *Context, _ *ast.AST) []*Row {
+	var rows []*Row
+	for _, edge := range qctx.Ledger.Graph().PriceEdges() {
+		if price, ok := edge.Meta.(*ast.Price); ok {
+			rows = append(rows, &Row{Ctx: qctx, Entry: price})
+		}
+	}
+	return rows
+}
+
+func commodityRows(qctx *Context, _ *ast.AST) []*Row {
+	nodes := qctx.Ledger.Graph().GetNodesByKind(ledger.NodeCommodity)
+	slices.SortFunc(nodes, func(a, b *ledger.Node) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	rows := make([]*Row, 0, len(nodes))
+	for _, node := range nodes {
+		if commodity, ok := node.Meta.(*ledger.CommodityNode); ok {
+			rows = append(rows, &Row{Ctx: qctx, Commodity: commodity})
+		}
+	}
+	return rows
+}
+
+func balanceRows(qctx *Context, tree *ast.AST) []*Row {
+	var rows []*Row
+	for _, entry := range tree.Directives {
+		if balance, ok := entry.(*ast.Balance); ok {
+			rows = append(rows, &Row{Ctx: qctx, Entry: balance})
+		}
+	}
+	return rows
+}
+
+// budgetRows compares the budgets month by month from the first budget
+// through the last entry.
+func budgetRows(qctx *Context, tree *ast.AST) []*Row {
+	if len(qctx.Budgets) == 0 || len(tree.Directives) == 0 {
+		return nil
+	}
+	start := qctx.Budgets[0].Date
+	end := tree.Directives[len(tree.Directives)-1].Date()
+	var rows []*Row
+	for _, line := range budget.Compare(qctx.Ledger, qctx.Budgets, start, end, budget.Monthly) {
+		rows = append(rows, &Row{Ctx: qctx, Budget: line})
+	}
+	return rows
+}
+
+// optionalDate returns date, or NULL instead of a nil *ast.Date.
+func optionalDate(date *ast.Date) any {
+	if date == nil {
+		return nil
+	}
+	return date
+}
+
+// directiveAmount converts a directive's amount, or returns NULL when it is
+// absent or invalid.
+func directiveAmount(amount *ast.Amount) any {
+	if amount == nil {
+		return nil
+	}
+	number, err := ledger.ParseAmount(amount)
+	if err != nil {
+		return nil
+	}
+	return &Amount{Number: number, Currency: amount.Currency}
+}
@@ -0,0 +1,148 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/robinvdvleuten/beancount/budget"
+	"github.com/robinvdvleuten/beancount/query/bql"
+)
+
+const tablesLedger = `
+2014-01-01 commodity HOOL
+  name: "Hooli Inc."
+
+2014-01-01 open Assets:Checking USD
+  institution: "Bank"
+2014-01-01 open Assets:Invest HOOL "FIFO"
+2014-01-01 open Equity:Opening-Balances
+
+2014-01-02 * "Opening"
+  Assets:Checking  1000.00 USD
+  Equity:Opening-Balances
+
+2014-02-01 price HOOL 510.00 USD
+2014-03-01 price HOOL 520.00 USD
+2014-03-01 price EUR 1.10 USD
+
+2014-04-01 balance Assets:Checking  1000.00 USD
+
+2014-06-30 close Assets:Invest
+`
+
+func runTableQuery(t *testing.T, query string) *Result {
+	t.Helper()
+	ctx, tree := newContextFromSource(t, tablesLedger)
+	return runQueryOn(t, ctx, tree, query, bql.WithV3Syntax())
+}
+
+func TestTableEntries(t *testing.T) {
+	result := runTableQuery(t, "SELECT type, count(date) AS n FROM #entries GROUP BY type ORDER BY type")
+
+	assert.Equal(t, 6, len(result.Rows))
+	assert.Equal(t, []any{"balance", int64(1)}, result.Rows[0])
+	assert.Equal(t, []any{"open", int64(3)}, result.Rows[3])
+	assert.Equal(t, []any{"price", int64(3)}, result.Rows[4])
+}
+
+func TestTableAccounts(t *testing.T) {
+	result := runTableQuery(t, "SELECT * FROM #accounts")
+
+	assert.Equal(t, []string{"account", "open_date", "close_date", "currencies"}, columnNames(result))
+	assert.Equal(t, 3, len(result.Rows))
+	assert.Equal(t, "Assets:Checking", result.Rows[0][0].(string))
+	assert.Equal(t, "2014-01-01", valueString(result.Rows[0][1]))
+	assert.Zero(t, result.Rows[0][2])
+	assert.Equal(t, any(NewSet("USD")), result.Rows[0][3])
+	assert.Equal(t, "2014-06-30", valueString(result.Rows[1][2]))
+
+	result = runTableQuery(t, "SELECT account, booking, entry_meta('institution') AS institution FROM #accounts WHERE open_date < 2014-06-01 AND close_date = NULL")
+	assert.Equal(t, 2, len(result.Rows))
+	assert.Equal(t, []any{"Assets:Checking", "STRICT", "Bank"}, result.Rows[0])
+
+	result = runTableQuery(t, "SELECT account, meta FROM #accounts")
+	institution, _ := result.Rows[0][1].(*Dict).Get("institution")
+	assert.Equal(t, "Bank", institution)
+	assert.Equal(t, 0, result.Rows[1][1].(*Dict).Len())
+}
+
+func TestTablePrices(t *testing.T) {
+	result := runTableQuery(t, "SELECT * FROM #prices WHERE currency = 'HOOL'")
+
+	assert.Equal(t, []string{"date", "currency", "amount"}, columnNames(result))
+	assert.Equal(t, 2, len(result.Rows))
+	assert.Equal(t, "2014-02-01", valueString(result.Rows[0][0]))
+	assert.Equal(t, "510 USD", valueString(result.Rows[0][2]))
+	assert.Equal(t, "520 USD", valueString(result.Rows[1][2]))
+}
+
+func TestTableCommodities(t *testing.T) {
+	result := runTableQuery(t, "SELECT currency, date, entry_meta('name') AS name FROM #commodities")
+
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, "HOOL", result.Rows[0][0].(string))
+	assert.Equal(t, "2014-01-01", valueString(result.Rows[0][1]))
+	assert.Equal(t, "Hooli Inc.", result.Rows[0][2].(string))
+
+	result = runTableQuery(t, "SELECT getitem(meta, 'name') FROM #commodities")
+	assert.Equal(t, []any{"Hooli Inc."}, result.Rows[0])
+}
+
+func TestTableBalances(t *testing.T) {
+	result := runTableQuery(t, "SELECT * FROM #balances")
+
+	assert.Equal(t, []string{"date", "account", "amount"}, columnNames(result))
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, "Assets:Checking", result.Rows[0][1].(string))
+	assert.Equal(t, "1000 USD", valueString(result.Rows[0][2]))
+}
+
+func TestTableBudgets(t *testing.T) {
+	source := `
+2014-01-01 open Assets:Checking USD
+2014-01-01 open Expenses:Food
+2014-01-01 open Expenses:Food:Restaurants
+
+2014-01-01 custom "budget" Expenses:Food "monthly" 300.00 USD
+
+2014-01-10 * "Market"
+  Expenses:Food  120.00 USD
+  Assets:Checking
+
+2014-02-03 * "Bistro"
+  Expenses:Food:Restaurants  45.00 USD
+  Assets:Checking
+`
+	ctx, tree := newContextFromSource(t, source)
+	budgets, errs := budget.FromAST(tree)
+	assert.Zero(t, errs)
+	ctx.Budgets = budgets
+
+	result := runQueryOn(t, ctx, tree, "SELECT * FROM #budgets", bql.WithV3Syntax())
+	assert.Equal(t, []string{"date", "account", "budget", "actual"}, columnNames(result))
+	assert.Equal(t, [][]string{
+		{"2014-01-01", "Expenses:Food", "300 USD", "120 USD"},
+		{"2014-02-01", "Expenses:Food", "32.14 USD", "45 USD"},
+	}, resultStrings(result))
+
+	result = runQueryOn(t, ctx, tree, "SELECT account, sum(remaining) AS left FROM #budgets GROUP BY account", bql.WithV3Syntax())
+	assert.Equal(t, [][]string{{"Expenses:Food", "167.14 USD"}}, resultStrings(result))
+}
+
+func TestTableErrors(t *testing.T) {
+	ctx, _ := newContextFromSource(t, tablesLedger)
+
+	err := compileError(t, ctx, "SELECT * FROM #bogus", bql.WithV3Syntax())
+	assert.Equal(t, "Invalid table name '#bogus'.", err.Error())
+
+	err = compileError(t, ctx, "SELECT account FROM #prices", bql.WithV3Syntax())
+	assert.Equal(t, "Invalid column name 'account' in #prices context.", err.Error())
+}
+
+func columnNames(result *Result) []string {
+	names := make([]string, len(result.Columns))
+	for i, column := range result.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
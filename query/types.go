@@ -30,6 +30,7 @@ const (
 	TAmount
 	TPosition
 	TInventory
+	TDict
 )
 
 var dtypeNames = map[DType]string{
@@ -43,6 +44,7 @@ var dtypeNames = map[DType]string{
 	TAmount:    "Amount",
 	TPosition:  "Position",
 	TInventory: "Inventory",
+	TDict:      "dict",
 }
 
 func (t DType) String() string {
@@ -242,6 +244,42 @@ func (s Set) Sorted() []string {
 	return elems
 }
 
+// Dict maps metadata keys to values, keeping the keys in declaration order
+// like the Python dicts of the official implementation.
+type Dict struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewDict creates an empty dict.
+func NewDict() *Dict {
+	return &Dict{values: make(map[string]any)}
+}
+
+// Set stores a value under key, keeping the position of an existing key.
+func (d *Dict) Set(key string, value any) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+// Get returns the value stored under key.
+func (d *Dict) Get(key string) (any, bool) {
+	value, ok := d.values[key]
+	return value, ok
+}
+
+// Keys returns the keys in insertion order.
+func (d *Dict) Keys() []string {
+	return d.keys
+}
+
+// Len returns the number of keys.
+func (d *Dict) Len() int {
+	return len(d.keys)
+}
+
 // truthy converts a value to a boolean following Python truthiness, which is
 // what the official implementation applies in logical contexts: NULL, zero,
 // empty strings and empty collections are false.
@@ -267,6 +305,8 @@ func truthy(v any) bool {
 		return val != nil
 	case *Inventory:
 		return val != nil && len(val.positions) > 0
+	case *Dict:
+		return val != nil && val.Len() > 0
 	default:
 		return v != nil
 	}
@@ -375,11 +415,36 @@ func valueString(v any) string {
 			parts[i] = positionString(p)
 		}
 		return strings.Join(parts, ", ")
+	case *Dict:
+		items := make([]string, len(val.keys))
+		for i, key := range val.keys {
+			items[i] = pythonRepr(key) + ": " + pythonRepr(val.values[key])
+		}
+		return "{" + strings.Join(items, ", ") + "}"
 	default:
 		return fmt.Sprintf("%v", v)
 	}
 }
 
+// pythonRepr renders a value the way Python's repr does inside a container,
+// so dicts print like the official tool's.
+func pythonRepr(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "None"
+	case string:
+		if strings.Contains(val, "'") && !strings.Contains(val, `"`) {
+			return `"` + val + `"`
+		}
+		return "'" + strings.ReplaceAll(val, "'", `\'`) + "'"
+	case decimal.Decimal:
+		return fmt.Sprintf("Decimal('%s')", val.String())
+	case *ast.Date:
+		return fmt.Sprintf("datetime.date(%d, %d, %d)", val.Year(), val.Month(), val.Day())
+	}
+	return valueString(v)
+}
+
 func positionString(p *Position) string {
 	units := fmt.Sprintf("%s %s", p.Units.Number.String(), p.Units.Currency)
 	if p.Cost == nil {
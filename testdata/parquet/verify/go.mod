@@ -0,0 +1,9 @@
+module github.com/robinvdvleuten/beancount/testdata/parquet/verify
+
+go 1.25
+
+require (
+	github.com/shopspring/decimal v1.4.0
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20240122235623-d6294584ab18
+)
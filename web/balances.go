@@ -14,15 +14,18 @@ type BalancesResponse struct {
 	Currencies []string               `json:"currencies"`
 	StartDate  *string                `json:"startDate,omitempty"`
 	EndDate    *string                `json:"endDate,omitempty"`
+	Currency   string                 `json:"currency,omitempty"`
+	Valuation  string                 `json:"valuation,omitempty"`
 }
 
 // BalanceNodeResponse represents a node in the balance tree for JSON serialization.
 type BalanceNodeResponse struct {
-	Name     string                 `json:"name"`
-	Account  string                 `json:"account,omitempty"`
-	Depth    int                    `json:"depth"`
-	Balance  map[string]string      `json:"balance"`
-	Children []*BalanceNodeResponse `json:"children,omitempty"`
+	Name        string                 `json:"name"`
+	Account     string                 `json:"account,omitempty"`
+	Depth       int                    `json:"depth"`
+	Balance     map[string]string      `json:"balance"`
+	Unconverted map[string]string      `json:"unconverted,omitempty"` // Amounts without a price to currency
+	Children    []*BalanceNodeResponse `json:"children,omitempty"`
 }
 
 // handleGetBalances handles GET requests to /api/balances.
@@ -32,6 +35,10 @@ type BalanceNodeResponse struct {
 //     Must match configured account names. If omitted, returns all types (trial balance).
 //   - startDate: Start date in YYYY-MM-DD format.
 //   - endDate: End date in YYYY-MM-DD format.
+//   - currency: Currency to convert every balance to. Amounts without a price
+//     to it are returned per node under unconverted.
+//   - valuation: How to convert: cost, market (at the prices on endDate, or
+//     today; the default) or historical (at the rate on each transaction date).
 //
 // Date semantics:
 //   - Both omitted: Current inventory state (all postings).
@@ -42,6 +49,7 @@ type BalanceNodeResponse struct {
 //   - GET /api/balances - Trial balance (all types, current state)
 //   - GET /api/balances?types=Assets,Liabilities,Equity&startDate=2024-01-31&endDate=2024-01-31 - Balance sheet
 //   - GET /api/balances?types=Income,Expenses&startDate=2024-01-01&endDate=2024-01-31 - Income statement
+//   - GET /api/balances?types=Assets&startDate=2024-01-31&endDate=2024-01-31&currency=EUR&valuation=market - Assets in EUR
 func (s *Server) handleGetBalances(w http.ResponseWriter, r *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -85,8 +93,30 @@ func (s *Server) handleGetBalances(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Parse conversion
+	currency := r.URL.Query().Get("currency")
+	valuation := ledger.ValuationMarket
+	if valuationParam := r.URL.Query().Get("valuation"); valuationParam != "" {
+		if currency == "" {
+			http.Error(w, "valuation requires a currency", http.StatusBadRequest)
+			return
+		}
+		v, err := ledger.ParseValuation(valuationParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		valuation = v
+	}
+
 	// Get balance tree from ledger
-	tree, err := s.ledger.GetBalanceTree(accountTypes, startDate, endDate)
+	var tree *ledger.BalanceTree
+	var err error
+	if currency != "" {
+		tree, err = s.ledger.GetConvertedBalanceTree(accountTypes, startDate, endDate, currency, valuation)
+	} else {
+		tree, err = s.ledger.GetBalanceTree(accountTypes, startDate, endDate)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -109,6 +139,8 @@ func convertBalanceTree(tree *ledger.BalanceTree) *BalancesResponse {
 		Currencies: tree.Currencies,
 		StartDate:  tree.StartDate,
 		EndDate:    tree.EndDate,
+		Currency:   tree.Currency,
+		Valuation:  string(tree.Valuation),
 	}
 }
 
@@ -122,12 +154,18 @@ func convertBalanceNode(node *ledger.BalanceNode) *BalanceNodeResponse {
 		}
 	}
 
+	var unconverted map[string]string
+	if node.Unconverted != nil && !node.Unconverted.IsZero() {
+		unconverted = convertBalance(node.Unconverted)
+	}
+
 	return &BalanceNodeResponse{
-		Name:     node.Name,
-		Account:  node.Account,
-		Depth:    node.Depth,
-		Balance:  convertBalance(node.Balance),
-		Children: children,
+		Name:        node.Name,
+		Account:     node.Account,
+		Depth:       node.Depth,
+		Balance:     convertBalance(node.Balance),
+		Unconverted: unconverted,
+		Children:    children,
 	}
 }
 
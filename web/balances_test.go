@@ -338,3 +338,79 @@ func TestAPIBalances(t *testing.T) {
 		assert.Equal(t, "0.123456789012345678", response.Roots[0].Balance["BTC"])
 	})
 }
+
+func TestAPIBalancesConverted(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test-*.beancount")
+	assert.NoError(t, err)
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+
+	_, err = tmpFile.WriteString(`
+2024-01-01 open Assets:Checking EUR
+2024-01-01 open Assets:Dollars USD
+2024-01-01 open Assets:Pounds GBP
+2024-01-01 open Equity:Opening
+
+2024-01-01 price USD 0.90 EUR
+2024-02-01 price USD 0.80 EUR
+
+2024-01-15 * "Opening balance"
+  Assets:Checking  1000.00 EUR
+  Assets:Dollars    100.00 USD
+  Assets:Pounds      50.00 GBP
+  Equity:Opening  -1000.00 EUR
+  Equity:Opening   -100.00 USD
+  Equity:Opening    -50.00 GBP
+`)
+	assert.NoError(t, err)
+	_ = tmpFile.Close()
+
+	server := New(8080, tmpFile.Name())
+	_, err = server.reloadLedger(context.Background())
+	assert.NoError(t, err)
+	mux, err := server.setupRouter()
+	assert.NoError(t, err)
+
+	get := func(t *testing.T, query string) (*httptest.ResponseRecorder, *BalancesResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/balances?"+query, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			return rec, nil
+		}
+		var response BalancesResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		return rec, &response
+	}
+
+	t.Run("Market", func(t *testing.T) {
+		_, response := get(t, "types=Assets&startDate=2024-02-29&endDate=2024-02-29&currency=EUR")
+		assert.Equal(t, "EUR", response.Currency)
+		assert.Equal(t, "market", response.Valuation)
+		assert.Equal(t, []string{"EUR"}, response.Currencies)
+
+		assets := response.Roots[0]
+		assert.Equal(t, map[string]string{"EUR": "1080"}, assets.Balance)
+		assert.Equal(t, map[string]string{"GBP": "50"}, assets.Unconverted)
+		// Accounts without residues leave them out.
+		assert.Equal(t, "Assets:Checking", assets.Children[0].Account)
+		assert.Zero(t, assets.Children[0].Unconverted)
+	})
+
+	t.Run("Historical", func(t *testing.T) {
+		_, response := get(t, "types=Assets&startDate=2024-02-29&endDate=2024-02-29&currency=EUR&valuation=historical")
+		assert.Equal(t, map[string]string{"EUR": "1090"}, response.Roots[0].Balance)
+	})
+
+	t.Run("InvalidValuation", func(t *testing.T) {
+		rec, _ := get(t, "currency=EUR&valuation=spot")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `unknown valuation "spot"`)
+	})
+
+	t.Run("ValuationWithoutCurrency", func(t *testing.T) {
+		rec, _ := get(t, "valuation=cost")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "valuation requires a currency")
+	})
+}
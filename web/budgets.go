@@ -0,0 +1,105 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/budget"
+)
+
+// BudgetsResponse is the JSON response structure for the budgets endpoint.
+type BudgetsResponse struct {
+	StartDate *string           `json:"startDate,omitempty"`
+	EndDate   string            `json:"endDate"`
+	Interval  string            `json:"interval"`
+	Budgets   []*BudgetResponse `json:"budgets"`
+}
+
+// BudgetResponse compares the budget of an account in one currency with
+// the actual amount over one interval.
+type BudgetResponse struct {
+	Account   string `json:"account"`
+	Currency  string `json:"currency"`
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+	Budget    string `json:"budget"`
+	Actual    string `json:"actual"`
+	Remaining string `json:"remaining"`
+	Percent   string `json:"percent"`
+}
+
+// handleGetBudgets handles GET requests to /api/budgets.
+//
+// Query parameters:
+//   - startDate: First day in YYYY-MM-DD format. Defaults to the date of the first budget.
+//   - endDate: Last day in YYYY-MM-DD format. Defaults to today.
+//   - interval: daily, weekly, monthly, quarterly or yearly. Defaults to monthly.
+//
+// Examples:
+//   - GET /api/budgets - Budget vs actual per month, up to today
+//   - GET /api/budgets?startDate=2024-01-01&endDate=2024-12-31&interval=quarterly - Per quarter of 2024
+func (s *Server) handleGetBudgets(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var startDate *ast.Date
+	if len(s.budgets) > 0 {
+		startDate = s.budgets[0].Date
+	}
+	if startParam := r.URL.Query().Get("startDate"); startParam != "" {
+		d, err := ast.NewDate(startParam)
+		if err != nil {
+			http.Error(w, "invalid startDate format (expected YYYY-MM-DD): "+startParam, http.StatusBadRequest)
+			return
+		}
+		startDate = d
+	}
+	endDate := ast.NewDateFromTime(time.Now().UTC().Truncate(24 * time.Hour))
+	if endParam := r.URL.Query().Get("endDate"); endParam != "" {
+		d, err := ast.NewDate(endParam)
+		if err != nil {
+			http.Error(w, "invalid endDate format (expected YYYY-MM-DD): "+endParam, http.StatusBadRequest)
+			return
+		}
+		endDate = d
+	}
+
+	interval := budget.Monthly
+	if intervalParam := r.URL.Query().Get("interval"); intervalParam != "" {
+		period, err := budget.ParsePeriod(intervalParam)
+		if err != nil {
+			http.Error(w, "invalid interval: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		interval = period
+	}
+
+	response := &BudgetsResponse{
+		EndDate:  endDate.String(),
+		Interval: string(interval),
+		Budgets:  []*BudgetResponse{},
+	}
+	if startDate != nil {
+		start := startDate.String()
+		response.StartDate = &start
+		for _, line := range budget.Compare(s.ledger, s.budgets, startDate, endDate, interval) {
+			response.Budgets = append(response.Budgets, convertBudgetLine(line))
+		}
+	}
+	writeJSONResponse(w, response)
+}
+
+// convertBudgetLine converts a budget.Line to a BudgetResponse.
+func convertBudgetLine(line *budget.Line) *BudgetResponse {
+	return &BudgetResponse{
+		Account:   string(line.Account),
+		Currency:  line.Currency,
+		StartDate: line.Start.String(),
+		EndDate:   line.End.String(),
+		Budget:    line.Budget.String(),
+		Actual:    line.Actual.String(),
+		Remaining: line.Remaining().String(),
+		Percent:   line.Percent().StringFixed(2),
+	}
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAPIBudgets(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test-*.beancount")
+	assert.NoError(t, err)
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+
+	testContent := `
+2024-01-01 open Assets:Checking EUR
+2024-01-01 open Expenses:Food
+
+2024-01-01 custom "budget" Expenses:Food "monthly" 400.00 EUR
+
+2024-01-05 * "Market"
+  Expenses:Food  120.00 EUR
+  Assets:Checking
+
+2024-02-10 * "Market"
+  Expenses:Food  430.00 EUR
+  Assets:Checking
+`
+	_, err = tmpFile.WriteString(testContent)
+	assert.NoError(t, err)
+	_ = tmpFile.Close()
+
+	server := New(8080, tmpFile.Name())
+	_, err = server.reloadLedger(context.Background())
+	assert.NoError(t, err)
+	mux, err := server.setupRouter()
+	assert.NoError(t, err)
+
+	t.Run("Monthly", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/budgets?endDate=2024-02-29", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response BudgetsResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		assert.Equal(t, "2024-01-01", *response.StartDate)
+		assert.Equal(t, "monthly", response.Interval)
+		assert.Equal(t, 2, len(response.Budgets))
+
+		february := response.Budgets[1]
+		assert.Equal(t, "Expenses:Food", february.Account)
+		assert.Equal(t, "EUR", february.Currency)
+		assert.Equal(t, "2024-02-01", february.StartDate)
+		assert.Equal(t, "2024-02-29", february.EndDate)
+		assert.Equal(t, "400", february.Budget)
+		assert.Equal(t, "430", february.Actual)
+		assert.Equal(t, "-30", february.Remaining)
+		assert.Equal(t, "107.50", february.Percent)
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		for _, url := range []string{"/api/budgets?startDate=2024-13-01", "/api/budgets?interval=fortnightly"} {
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/robinvdvleuten/beancount/ast"
+	"github.com/robinvdvleuten/beancount/ledger"
+)
+
+// HoldingsResponse is the JSON response structure for the holdings endpoint.
+type HoldingsResponse struct {
+	Date     string             `json:"date"`
+	Holdings []*HoldingResponse `json:"holdings"`
+}
+
+// HoldingResponse represents a single holding for JSON serialization.
+// Amounts are in the holding's cost currency.
+type HoldingResponse struct {
+	Account           string  `json:"account,omitempty"`
+	Commodity         string  `json:"commodity"`
+	Units             string  `json:"units"`
+	CostCurrency      string  `json:"costCurrency"`
+	BookValue         string  `json:"bookValue"`
+	Price             *string `json:"price,omitempty"`
+	MarketValue       string  `json:"marketValue"`
+	UnrealizedGain    string  `json:"unrealizedGain"`
+	UnrealizedPercent string  `json:"unrealizedPercent"`
+}
+
+// handleGetHoldings handles GET requests to /api/holdings.
+//
+// Query parameters:
+//   - date: Valuation date in YYYY-MM-DD format. Defaults to today.
+//   - aggregate: When true, sums holdings of a commodity across accounts.
+//
+// Examples:
+//   - GET /api/holdings - Holdings per account, valued today
+//   - GET /api/holdings?date=2024-12-31&aggregate=true - Holdings per commodity at year end
+func (s *Server) handleGetHoldings(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	date := ast.NewDateFromTime(time.Now().UTC().Truncate(24 * time.Hour))
+	if dateParam := r.URL.Query().Get("date"); dateParam != "" {
+		d, err := ast.NewDate(dateParam)
+		if err != nil {
+			http.Error(w, "invalid date format (expected YYYY-MM-DD): "+dateParam, http.StatusBadRequest)
+			return
+		}
+		date = d
+	}
+
+	var aggregate bool
+	if aggregateParam := r.URL.Query().Get("aggregate"); aggregateParam != "" {
+		b, err := strconv.ParseBool(aggregateParam)
+		if err != nil {
+			http.Error(w, "invalid aggregate value (expected true or false): "+aggregateParam, http.StatusBadRequest)
+			return
+		}
+		aggregate = b
+	}
+
+	holdings, err := s.ledger.Holdings(date, aggregate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := &HoldingsResponse{
+		Date:     date.String(),
+		Holdings: make([]*HoldingResponse, len(holdings)),
+	}
+	for i, holding := range holdings {
+		response.Holdings[i] = convertHolding(holding)
+	}
+	writeJSONResponse(w, response)
+}
+
+// convertHolding converts a ledger.Holding to a HoldingResponse.
+func convertHolding(holding *ledger.Holding) *HoldingResponse {
+	var price *string
+	if holding.Priced {
+		p := holding.Price.String()
+		price = &p
+	}
+
+	return &HoldingResponse{
+		Account:           holding.Account,
+		Commodity:         holding.Commodity,
+		Units:             holding.Units.String(),
+		CostCurrency:      holding.CostCurrency,
+		BookValue:         holding.BookValue.String(),
+		Price:             price,
+		MarketValue:       holding.MarketValue.String(),
+		UnrealizedGain:    holding.UnrealizedGain.String(),
+		UnrealizedPercent: holding.UnrealizedPercent().StringFixed(2),
+	}
+}
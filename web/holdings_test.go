@@ -0,0 +1,105 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAPIHoldings(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test-*.beancount")
+	assert.NoError(t, err)
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+
+	testContent := `
+2024-01-01 open Assets:Checking USD
+2024-01-01 open Assets:Broker
+2024-01-01 open Assets:Pension
+2024-01-01 open Equity:Opening USD
+
+2024-01-15 * "Opening balance"
+  Assets:Checking  5000.00 USD
+  Equity:Opening
+
+2024-02-01 * "Buy stock"
+  Assets:Broker  10 HOOL {100.00 USD}
+  Assets:Checking
+
+2024-03-01 * "Buy stock"
+  Assets:Pension  5 HOOL {120.00 USD}
+  Assets:Checking
+
+2024-06-30 price HOOL 150.00 USD
+`
+	_, err = tmpFile.WriteString(testContent)
+	assert.NoError(t, err)
+	_ = tmpFile.Close()
+
+	server := New(8080, tmpFile.Name())
+	_, err = server.reloadLedger(context.Background())
+	assert.NoError(t, err)
+	mux, err := server.setupRouter()
+	assert.NoError(t, err)
+
+	get := func(t *testing.T, url string) *HoldingsResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var response HoldingsResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		return &response
+	}
+
+	t.Run("PerAccount", func(t *testing.T) {
+		response := get(t, "/api/holdings?date=2024-07-01")
+		assert.Equal(t, "2024-07-01", response.Date)
+		assert.Equal(t, 3, len(response.Holdings))
+
+		broker := response.Holdings[0]
+		assert.Equal(t, "Assets:Broker", broker.Account)
+		assert.Equal(t, "HOOL", broker.Commodity)
+		assert.Equal(t, "10", broker.Units)
+		assert.Equal(t, "1000", broker.BookValue)
+		assert.Equal(t, "1500", broker.MarketValue)
+		assert.Equal(t, "500", broker.UnrealizedGain)
+		assert.Equal(t, "50.00", broker.UnrealizedPercent)
+		assert.Equal(t, "150", *broker.Price)
+	})
+
+	t.Run("Aggregate", func(t *testing.T) {
+		response := get(t, "/api/holdings?date=2024-07-01&aggregate=true")
+		assert.Equal(t, 2, len(response.Holdings))
+
+		hool := response.Holdings[0]
+		assert.Equal(t, "", hool.Account)
+		assert.Equal(t, "15", hool.Units)
+		assert.Equal(t, "1600", hool.BookValue)
+		assert.Equal(t, "2250", hool.MarketValue)
+	})
+
+	t.Run("Unpriced", func(t *testing.T) {
+		response := get(t, "/api/holdings?date=2024-03-31")
+		broker := response.Holdings[0]
+		assert.Equal(t, (*string)(nil), broker.Price)
+		assert.Equal(t, "1000", broker.MarketValue)
+		assert.Equal(t, "0", broker.UnrealizedGain)
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		for _, url := range []string{"/api/holdings?date=2024-13-01", "/api/holdings?aggregate=maybe"} {
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+		}
+	})
+}
@@ -1,8 +1,6 @@
 package web
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+
+	"github.com/robinvdvleuten/beancount/loader"
 )
 
 // writeJSONResponse writes a JSON response to the http.ResponseWriter.
@@ -60,8 +60,7 @@ func jsonSafeSourceError(err error) error {
 
 // computeFingerprint returns a short hash of content for change detection.
 func computeFingerprint(content []byte) string {
-	hash := sha256.Sum256(content)
-	return hex.EncodeToString(hash[:])[:8]
+	return loader.Fingerprint(content)[:8]
 }
 
 // isAllowedFile checks if the given path is in the allowlist (root or includes).
@@ -120,7 +119,8 @@ func (s *Server) buildResponse(source []byte) *SourceResponse {
 	if s.reloadErr != nil {
 		errors = []error{s.reloadErr}
 	} else if s.ledger != nil {
-		errors = s.ledger.Errors()
+		errors = append(errors, s.loadErrors...)
+		errors = append(errors, s.ledger.Errors()...)
 	}
 	return &SourceResponse{
 		Source:      string(source),
@@ -22,6 +22,8 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 
+	"github.com/robinvdvleuten/beancount/budget"
+	"github.com/robinvdvleuten/beancount/diagnostic"
 	"github.com/robinvdvleuten/beancount/ledger"
 	"github.com/robinvdvleuten/beancount/loader"
 	"github.com/robinvdvleuten/beancount/telemetry"
@@ -37,9 +39,15 @@ type Server struct {
 
 	mu           sync.RWMutex
 	ledger       *ledger.Ledger
-	rootFile     string   // Absolute path of the root ledger file
-	includeFiles []string // Absolute paths of included files
-	reloadErr    error    // Last load or parse error, if the current files are invalid
+	budgets      []*budget.Budget // Budgets declared in the ledger, in date order
+	rootFile     string           // Absolute path of the root ledger file
+	includeFiles []string         // Absolute paths of included files
+	reloadErr    error            // Last load or parse error, if the current files are invalid
+	loadErrors   []error          // Syntax errors recovered from during the last load
+
+	// cache keeps parsed files between reloads, so a reload only parses
+	// the files that changed and revalidates from the earliest change.
+	cache *loader.Cache
 
 	// inputFile is the file path passed to New(), used only for initial loading.
 	// After loading, rootFile contains the resolved absolute path.
@@ -62,6 +70,7 @@ func NewWithVersion(port int, ledgerFile, version, commitSHA string) *Server {
 		CommitSHA: commitSHA,
 		ledger:    ledger.New(),
 		inputFile: ledgerFile,
+		cache:     loader.NewCache(),
 	}
 }
 
@@ -142,7 +151,7 @@ func (s *Server) initializeSourceState(ctx context.Context) error {
 	}
 
 	includes := []string{}
-	if result, err := loader.New().Load(ctx, s.inputFile); err == nil {
+	if result, err := loader.New(loader.WithErrorRecovery()).Load(ctx, s.inputFile); err == nil {
 		rootFile = result.Root
 		baseDir := filepath.Dir(result.Root)
 		includes = make([]string, 0, len(result.AST.Includes))
@@ -178,6 +187,8 @@ func (s *Server) setupRouter() (*http.ServeMux, error) {
 	mux.HandleFunc("PUT /api/source", s.requireWritable(s.handlePutSource))
 	mux.HandleFunc("GET /api/accounts", s.handleGetAccounts)
 	mux.HandleFunc("GET /api/balances", s.handleGetBalances)
+	mux.HandleFunc("GET /api/holdings", s.handleGetHoldings)
+	mux.HandleFunc("GET /api/budgets", s.handleGetBudgets)
 	mux.HandleFunc("GET /api/events", s.handleSSE)
 
 	// Asset routes (prod: serves embedded files with template vars replaced, dev: no-op)
@@ -197,11 +208,13 @@ func (s *Server) requireWritable(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// reloadLedger loads or reloads the ledger from disk.
+// reloadLedger loads or reloads the ledger from disk. Files that did not
+// change are not parsed again, and validation resumes from the state the
+// previous ledger had before the earliest changed entry.
 // Caller must NOT hold the mutex - this method acquires it internally.
 // Returns the old include files for comparison by the caller.
 func (s *Server) reloadLedger(ctx context.Context) (oldIncludes []string, err error) {
-	ldr := loader.New(loader.WithFollowIncludes(), loader.WithDocumentsDiscovery())
+	ldr := loader.New(loader.WithFollowIncludes(), loader.WithDocumentsDiscovery(), loader.WithErrorRecovery(), loader.WithCache(s.cache))
 
 	result, err := ldr.Load(ctx, s.inputFile)
 	if err != nil {
@@ -214,15 +227,33 @@ func (s *Server) reloadLedger(ctx context.Context) (oldIncludes []string, err er
 		return nil, err // I/O or parse error
 	}
 
+	// Entries that failed to parse were skipped; the rest is still validated
+	// so the editor can show every problem at once.
+	var loadErrors []error
+	for _, diag := range diagnostic.Errors(result.Diagnostics) {
+		loadErrors = append(loadErrors, jsonSafeSourceError(diag))
+	}
+
+	s.mu.RLock()
+	prev := s.ledger
+	s.mu.RUnlock()
+
 	l := ledger.New()
-	_ = l.Process(ctx, result.AST) // Validation errors in l.Errors()
+	_ = l.ProcessIncremental(ctx, result.AST, prev) // Validation errors in l.Errors()
+
+	budgets, budgetErrors := budget.FromAST(result.AST)
+	for _, err := range budgetErrors {
+		loadErrors = append(loadErrors, jsonSafeSourceError(err))
+	}
 
 	s.mu.Lock()
 	oldIncludes = s.includeFiles
 	s.ledger = l
+	s.budgets = budgets
 	s.rootFile = result.Root
 	s.includeFiles = result.Includes
 	s.reloadErr = nil
+	s.loadErrors = loadErrors
 	s.mu.Unlock()
 
 	return oldIncludes, nil
@@ -298,8 +298,9 @@ func TestAPISourceInitialParseErrorStillServesEditor(t *testing.T) {
 	server := New(8080, tmpFile.Name())
 	err = server.initializeSourceState(context.Background())
 	assert.NoError(t, err)
+	// Syntax errors are recovered from: the reload succeeds and reports them
 	_, err = server.reloadLedger(context.Background())
-	assert.Error(t, err)
+	assert.NoError(t, err)
 	mux, err := server.setupRouter()
 	assert.NoError(t, err)
 
@@ -320,6 +321,66 @@ func TestAPISourceInitialParseErrorStillServesEditor(t *testing.T) {
 	assert.Equal(t, http.StatusOK, accountsRec.Code)
 }
 
+func TestAPISourceReportsRecoveredParseErrorsWithValidationErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	rootFile := filepath.Join(tmpDir, "root.beancount")
+	err := os.WriteFile(rootFile, []byte(`2024-01-01 open Assets:Checking USD
+2024-01-02 opne Assets:Savings
+2024-01-03 balance Assets:Checking 10 USD
+`), 0600)
+	assert.NoError(t, err)
+
+	server := New(8080, rootFile)
+	_, err = server.reloadLedger(context.Background())
+	assert.NoError(t, err)
+	mux, err := server.setupRouter()
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/source", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	response := decodeSourceResponse(t, rec)
+	errors := response["errors"].([]interface{})
+	assert.Equal(t, 2, len(errors))
+	assert.Equal(t, "ParseError", errors[0].(map[string]interface{})["type"].(string))
+	assert.NotEqual(t, "ParseError", errors[1].(map[string]interface{})["type"].(string))
+}
+
+func TestReloadLedgerRevalidatesEditedMonth(t *testing.T) {
+	tmpDir := t.TempDir()
+	rootFile := filepath.Join(tmpDir, "root.beancount")
+	source := `2024-01-01 open Assets:Checking USD
+2024-01-01 open Equity:Opening USD
+
+2024-01-02 * "Opening"
+  Assets:Checking  100.00 USD
+  Equity:Opening
+
+2024-02-01 balance Assets:Checking 100.00 USD
+`
+	err := os.WriteFile(rootFile, []byte(source), 0600)
+	assert.NoError(t, err)
+
+	server := New(8080, rootFile)
+	_, err = server.reloadLedger(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(server.ledger.Errors()))
+
+	err = os.WriteFile(rootFile, []byte(source+"2024-02-02 balance Assets:Checking 90.00 USD\n"), 0600)
+	assert.NoError(t, err)
+	_, err = server.reloadLedger(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(server.ledger.Errors()))
+
+	err = os.WriteFile(rootFile, []byte(source), 0600)
+	assert.NoError(t, err)
+	_, err = server.reloadLedger(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(server.ledger.Errors()))
+}
+
 func decodeSourceResponse(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
 	t.Helper()
 